@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"synapse/internal/format"
+	"synapse/internal/rag"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagBenchQueries string
+	flagBenchK       int
+	flagBenchRuns    int
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench --queries <file>",
+	Short: "Measure query latency, stage by stage",
+	Long: `Run each query in --queries through hybrid retrieval --runs times and report the
+p50, p95, and mean latency of each stage: embedding the query, keyword (FTS)
+search, vector search, and fusing the results, with the total. Use it to see where
+retrieval time goes when tuning --k, models, or hardware.
+
+The queries file has one query per line (blank lines and # comments are skipped),
+or is a JSON array of golden queries as used by 'synapse eval'. Each query is run
+once first without being timed, so loading the embedding model doesn't count.
+The query cache isn't used.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := format.Parse(flagOutput)
+		if err != nil {
+			return err
+		}
+		if flagBenchRuns < 1 {
+			return fmt.Errorf("--runs must be at least 1")
+		}
+		queries, err := rag.LoadQueries(flagBenchQueries)
+		if err != nil {
+			return err
+		}
+
+		st, _, err := openReadOnlyIndex()
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+		retriever := rag.NewRetriever(st, newEmbedder(), rag.Options{K: flagBenchK, Weighting: queryWeighting(), Recency: flagRecencyWeight})
+
+		report, err := rag.Bench(retriever, queries, flagBenchRuns)
+		if err != nil {
+			return err
+		}
+		if err := format.Render(os.Stdout, out, report, benchTable(report)); err != nil {
+			return err
+		}
+		if out != format.JSON {
+			fmt.Printf("\n%d queries × %d runs, k=%d\n", report.Queries, report.Runs, report.K)
+		}
+		return nil
+	},
+}
+
+func benchTable(report *rag.BenchReport) format.Tabular {
+	tab := format.Tabular{Columns: []string{"Stage", "p50", "p95", "Mean"}}
+	for _, s := range report.Stages {
+		tab.Rows = append(tab.Rows, []string{s.Stage, formatMillis(s.P50), formatMillis(s.P95), formatMillis(s.Mean)})
+	}
+	return tab
+}
+
+// formatMillis formats a latency in milliseconds.
+func formatMillis(ms float64) string {
+	return fmt.Sprintf("%.2fms", ms)
+}
+
+func init() {
+	benchCmd.Flags().StringVar(&flagBenchQueries, "queries", "", "file of queries to run, one per line, or a golden queries JSON file")
+	benchCmd.Flags().IntVar(&flagBenchK, "k", 10, "number of results retrieved per query")
+	benchCmd.Flags().IntVar(&flagBenchRuns, "runs", 5, "times each query is run")
+	benchCmd.MarkFlagRequired("queries")
+	addOutputFlag(benchCmd)
+	rootCmd.AddCommand(benchCmd)
+}
@@ -2,12 +2,13 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"synapse/internal/embedder"
+	"synapse/internal/index"
 	"synapse/internal/llm"
 	"synapse/internal/rag"
 	"synapse/internal/store"
@@ -15,42 +16,67 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var flagK int
+var (
+	flagK             int
+	flagContextBudget int
+	flagNoStream      bool
+	flagShowThinking  bool
+	flagCitations     bool
+	flagEdgeOrder     bool
+	flagFileSummaries bool
+	flagSummaryBudget int
+	flagVerify        bool
+)
 
 var chatCmd = &cobra.Command{
 	Use:   "chat",
 	Short: "Ask questions about your indexed codebase",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Resolve DB path.
-		dbPath := flagDB
-		if dbPath == "" {
-			wd, err := os.Getwd()
-			if err != nil {
-				return err
-			}
-			dbPath = filepath.Join(wd, ".synapse", "index.db")
+		st, dbPath, err := openReadOnlyIndex()
+		if err != nil {
+			return err
 		}
-		if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-			return fmt.Errorf("index not found at %s\nRun 'synapse index <path>' first to build the index", dbPath)
+		defer st.Close()
+
+		// Queries must be embedded with the model the index was built
+		// with, so that's the only stand-in for a missing embedding model.
+		if installed := installedModels(); installed != nil {
+			indexModel, _ := st.GetMeta("embedding_model")
+			flagModel = autodetectModel(installed, "embedding", flagModel, []string{indexModel})
+			flagChatModel = autodetectModel(installed, "chat", flagChatModel, modelNames(installed, false))
 		}
 
-		st, err := store.Open(dbPath)
-		if err != nil {
-			return fmt.Errorf("open index: %w", err)
+		emb := newEmbedder()
+		chat := newChat(flagChatModel)
+		if err := chat.Ping(cmd.Context()); err != nil {
+			return err
 		}
-		defer st.Close()
 
-		emb := embedder.NewOllamaEmbedder(flagOllama, flagModel)
-		chat := llm.NewOllamaChat(flagOllama, flagChatModel)
+		retriever := rag.NewRetriever(st, emb, rag.Options{K: flagK, Weighting: queryWeighting(), Recency: flagRecencyWeight})
+		if flagCacheSize > 0 {
+			retriever.Cache = rag.NewCache(flagCacheSize, rag.DefaultCacheTTL)
+		}
+		retriever.Log = queryLog(dbPath)
+		var retrieve rag.RetrieveFunc = retriever.Retrieve
+		if client := connectDaemon(dbPath); client != nil {
+			retrieve = func(query string) ([]store.SearchResult, error) {
+				return client.Retrieve(query, retriever.Options)
+			}
+		}
+		if flagExpand {
+			retrieve = expandRetrieve(retrieve, chat, flagK)
+		}
 
 		// Load project overview if available.
-		var overview string
-		overviewPath := filepath.Join(filepath.Dir(dbPath), "overview.md")
-		if data, err := os.ReadFile(overviewPath); err == nil {
-			overview = string(data)
-		}
+		overview, _ := index.LoadOverview(st, dbPath)
+		root := projectRoot(st, dbPath)
 
 		var history []llm.Message
+		var noRAG bool
+		// The last question asked and whether it was answered from the
+		// overview, for /retry.
+		var lastQuestion string
+		var lastOverview bool
 		scanner := bufio.NewScanner(os.Stdin)
 
 		fmt.Println("synapse chat (type /help for commands, /exit to quit)")
@@ -66,7 +92,12 @@ var chatCmd = &cobra.Command{
 				continue
 			}
 
-			switch question {
+			// Questions about the whole project are answered from the overview.
+			useOverview := !noRAG && rag.IsMetaQuestion(question)
+
+			command, arg, _ := strings.Cut(question, " ")
+			arg = strings.TrimSpace(arg)
+			switch command {
 			case "/exit", "/quit":
 				fmt.Println("Goodbye.")
 				return nil
@@ -76,30 +107,214 @@ var chatCmd = &cobra.Command{
 				continue
 			case "/help":
 				fmt.Println("Commands:")
-				fmt.Println("  /clear  - clear conversation history")
-				fmt.Println("  /exit   - quit chat")
-				fmt.Println("  /help   - show this help")
+				fmt.Println("  /overview [q]    - answer from the project overview and file summaries")
+				fmt.Println("  /no-rag          - toggle retrieval off for a plain conversation with the model")
+				fmt.Println("  /summaries       - toggle including cited files' summaries in the context")
+				fmt.Println("  /show-thinking   - toggle showing reasoning models' <think> blocks")
+				fmt.Println("  /retry [model]   - ask the last question again, optionally switching to another chat model")
+				fmt.Println("  /edit <path> ... - propose a change to a file as a unified diff: /edit <path> <instruction>")
+				fmt.Println("  /focus <glob>    - only retrieve from matching paths")
+				fmt.Println("  /exclude <glob>  - never retrieve from matching paths")
+				fmt.Println("  /clear-filters   - remove all focus/exclude filters")
+				fmt.Println("  /clear           - clear conversation history")
+				fmt.Println("  /exit            - quit chat")
+				fmt.Println("  /help            - show this help")
+				continue
+			case "/focus", "/exclude":
+				if arg == "" {
+					fmt.Printf("Usage: %s <path-glob>\n", command)
+					continue
+				}
+				f := &retriever.Options.Filter
+				if command == "/focus" {
+					f.Include = append(f.Include, arg)
+				} else {
+					f.Exclude = append(f.Exclude, arg)
+				}
+				fmt.Printf("Active filters: %s\n", f.String())
+				continue
+			case "/clear-filters":
+				retriever.Options.Filter = store.Filter{}
+				fmt.Println("Filters cleared.")
+				continue
+			case "/no-rag":
+				noRAG = !noRAG
+				if noRAG {
+					fmt.Println("Retrieval off: questions go to the model without codebase context.")
+				} else {
+					fmt.Println("Retrieval on.")
+				}
+				continue
+			case "/summaries":
+				flagFileSummaries = !flagFileSummaries
+				if flagFileSummaries {
+					fmt.Println("File summaries on: cited files' summaries are added to the context.")
+				} else {
+					fmt.Println("File summaries off.")
+				}
+				continue
+			case "/show-thinking":
+				flagShowThinking = !flagShowThinking
+				if flagShowThinking {
+					fmt.Println("Showing the model's reasoning.")
+				} else {
+					fmt.Println("Hiding the model's reasoning.")
+				}
+				continue
+			case "/overview":
+				question = arg
+				if question == "" {
+					question = "Summarize what this project does."
+				}
+				useOverview = true
+			case "/retry":
+				if lastQuestion == "" {
+					fmt.Println("Nothing to retry yet.")
+					continue
+				}
+				if arg != "" {
+					chat = newChat(arg)
+					fmt.Printf("Using %s from now on.\n", arg)
+				}
+				question, useOverview = lastQuestion, lastOverview
+				history = rag.DropLastTurn(history, question)
+				fmt.Printf("Retrying: %s\n", question)
+			case "/edit":
+				path, instruction, _ := strings.Cut(arg, " ")
+				instruction = strings.TrimSpace(instruction)
+				if path == "" || instruction == "" {
+					fmt.Println("Usage: /edit <path> <instruction>")
+					continue
+				}
+				fmt.Println("[Drafting a diff...]")
+				s, err := suggestEdit(st, root, retrieve, chat, indexedPath(root, path), instruction, flagContextBudget)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					continue
+				}
+				fmt.Println()
+				fmt.Println(strings.TrimSpace(s.Answer))
+				fmt.Println()
+				switch {
+				case s.Diff == "":
+					fmt.Println("[No diff in the answer]")
+				case errors.Is(s.Check, rag.ErrNoPatch):
+					fmt.Printf("[%v]\n", s.Check)
+				case s.Check != nil:
+					fmt.Printf("Warning: %v\n", s.Check)
+				default:
+					fmt.Printf("[The diff applies cleanly to %s; nothing was changed]\n", indexedPath(root, path))
+				}
+				fmt.Println()
 				continue
 			}
+			lastQuestion, lastOverview = question, useOverview
 
-			fmt.Println("[Searching...]")
+			var msgs []llm.Message
+			var cited []store.SearchResult
+			var build func([]store.SearchResult) []llm.Message
+			switch {
+			case useOverview:
+				files, err := st.ListFiles()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "list files: %v\n", err)
+					continue
+				}
+				msgs = rag.BuildOverviewMessages(files, history, question, overview, flagContextBudget)
+			case noRAG:
+				msgs = rag.BuildPlainMessages(history, question)
+			default:
+				fmt.Println("[Searching...]")
 
-			chunks, err := rag.HybridRetrieve(question, st, emb, flagK)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "retrieval error: %v\n", err)
-				continue
+				debugWeights(question)
+				chunks, err := retrieve(question)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "retrieval error: %v\n", err)
+					continue
+				}
+
+				chunks, trimmed := rag.TrimToBudget(chunks, flagContextBudget)
+				if flagDebug && trimmed > 0 {
+					fmt.Fprintf(os.Stderr, "[debug] trimmed %d chunks to fit context\n", trimmed)
+				}
+
+				ordered := chunks
+				if flagEdgeOrder {
+					ordered = rag.OrderForAttention(chunks)
+				}
+				if flagDebug {
+					fmt.Fprintf(os.Stderr, "[debug] retrieved: %s\n", retrievedRelevance(chunks))
+					var inContext []store.SearchResult
+					for _, g := range rag.GroupByFile(ordered) {
+						inContext = append(inContext, g.Chunks...)
+					}
+					fmt.Fprintf(os.Stderr, "[debug] context order: %s\n", contextOrder(chunks, inContext))
+				}
+				var summaries map[string]string
+				if flagFileSummaries {
+					summaries, err = rag.CitedSummaries(st, chunks, flagSummaryBudget)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "file summaries: %v\n", err)
+						continue
+					}
+				}
+				// Generation may retry with the best chunks only, for a model
+				// whose context they don't all fit in.
+				build = func(subset []store.SearchResult) []llm.Message {
+					if flagEdgeOrder {
+						subset = rag.OrderForAttention(subset)
+					}
+					return rag.BuildMessages(subset, summaries, history, question, overview)
+				}
+				cited = chunks
 			}
 
-			msgs := rag.BuildMessages(chunks, history, question, overview)
-			answer, err := chat.Generate(msgs)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "llm error: %v\n", err)
-				continue
+			var answer string
+			var used []store.SearchResult
+			var err error
+			if flagNoStream {
+				answer, used, err = generateFitting(msgs, cited, build, chat.Generate)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "llm error: %v\n", err)
+					continue
+				}
+				fmt.Println()
+				fmt.Println(displayAnswer(answer))
+				fmt.Println()
+			} else {
+				fmt.Println()
+				answer, used, err = generateFitting(msgs, cited, build, func(msgs []llm.Message) (string, error) {
+					return streamAnswer(chat, msgs)
+				})
+				fmt.Println()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "llm error: %v\n", err)
+					continue
+				}
+				fmt.Println()
+			}
+			// Reasoning models expect to see only their past answers, not
+			// their reasoning, and it isn't checked by --verify.
+			answer = llm.StripThinking(answer)
+			if len(used) < len(cited) {
+				fmt.Printf("[Context reduced to %d of %d chunks to fit the model's context window]\n\n", len(used), len(cited))
+				cited = used
 			}
 
-			fmt.Println()
-			fmt.Println(answer)
-			fmt.Println()
+			if flagVerify && len(cited) > 0 {
+				refs, _ := rag.Ungrounded(answer, cited)
+				if warning := rag.GroundingWarning(refs); warning != "" {
+					fmt.Println(warning)
+					fmt.Println()
+				}
+			}
+
+			if flagCitations && len(cited) > 0 {
+				for _, loc := range resultLocations(cited, root) {
+					fmt.Println(loc)
+				}
+				fmt.Println()
+			}
 
 			// Keep last 10 turns of history.
 			history = append(history, llm.Message{Role: "user", Content: question})
@@ -116,7 +331,130 @@ var chatCmd = &cobra.Command{
 	},
 }
 
+// generateFitting answers with generate: msgs, or with build, the messages
+// for the retrieved chunks cited, retrying with fewer of them while the
+// prompt is too long for the model's context. It returns the chunks the
+// answer used.
+func generateFitting(msgs []llm.Message, cited []store.SearchResult, build func([]store.SearchResult) []llm.Message, generate func([]llm.Message) (string, error)) (string, []store.SearchResult, error) {
+	if build == nil {
+		answer, err := generate(msgs)
+		return answer, cited, err
+	}
+	answer, used, err := rag.GenerateFitting(cited, build, generate)
+	return answer, cited[:used], err
+}
+
+// displayAnswer returns answer as it's shown: without the model's
+// reasoning, unless --show-thinking is set.
+func displayAnswer(answer string) string {
+	if flagShowThinking {
+		return answer
+	}
+	return llm.StripThinking(answer)
+}
+
+// streamAnswer generates an answer to msgs, printing it as it's streamed
+// and, unless --show-thinking is set, hiding the model's reasoning. It
+// returns the whole answer, reasoning included.
+func streamAnswer(chat llm.Chat, msgs []llm.Message) (string, error) {
+	var filter llm.ThinkFilter
+	answer, err := chat.GenerateStream(msgs, func(token string) {
+		if !flagShowThinking {
+			token = filter.Write(token)
+		}
+		fmt.Print(token)
+	})
+	if !flagShowThinking {
+		fmt.Print(filter.Flush())
+	}
+	return answer, err
+}
+
+// editSuggestion is a change proposed by suggestEdit.
+type editSuggestion struct {
+	// Answer is the model's whole answer, with the diff but without its
+	// reasoning.
+	Answer string
+	// Diff is the unified diff in Answer, or "" if it has none.
+	Diff string
+	// Check is why Diff doesn't apply to the file, or rag.ErrNoPatch if
+	// that couldn't be checked; nil when it applies.
+	Check error
+}
+
+// suggestEdit asks chat for a unified diff carrying out instruction in the
+// indexed file at path, grounded in code retrieved for the instruction, and
+// checks that it applies to the file under root. The retrieved code is cut
+// to budget estimated tokens.
+func suggestEdit(st store.Store, root string, retrieve rag.RetrieveFunc, chat llm.Chat, path, instruction string, budget int) (*editSuggestion, error) {
+	f, err := indexedFile(st, path)
+	if err != nil {
+		return nil, err
+	}
+	abs := filepath.Join(root, filepath.FromSlash(f.Path))
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", f.Path, err)
+	}
+	related, err := retrieve(instruction)
+	if err != nil {
+		return nil, fmt.Errorf("retrieval error: %w", err)
+	}
+	related, _ = rag.TrimToBudget(related, budget)
+
+	answer, _, err := rag.GenerateFitting(related, func(subset []store.SearchResult) []llm.Message {
+		return rag.BuildEditMessages(f.Path, f.Language, string(data), subset, instruction)
+	}, chat.Generate)
+	if err != nil {
+		return nil, fmt.Errorf("llm error: %w", err)
+	}
+	answer = llm.StripThinking(answer)
+	s := &editSuggestion{Answer: answer, Diff: rag.ExtractDiff(answer)}
+	if s.Diff != "" {
+		s.Check = rag.CheckDiff(abs, s.Diff)
+	}
+	return s, nil
+}
+
+// contextOrder lists the retrieval rank of each chunk in context, in order,
+// e.g. "1 3 5 4 2".
+func contextOrder(ranked, context []store.SearchResult) string {
+	rank := make(map[int64]int, len(ranked))
+	for i, r := range ranked {
+		rank[r.Chunk.ID] = i + 1
+	}
+	parts := make([]string, len(context))
+	for i, c := range context {
+		parts[i] = fmt.Sprint(rank[c.Chunk.ID])
+	}
+	return strings.Join(parts, " ")
+}
+
+// retrievedRelevance lists each retrieved chunk's location and relevance in
+// rank order, e.g. "a.go:3 87%, b.go:10 keyword".
+func retrievedRelevance(ranked []store.SearchResult) string {
+	parts := make([]string, len(ranked))
+	for i, r := range ranked {
+		relevance := "keyword"
+		if r.Relevance != nil {
+			relevance = formatRelevance(*r.Relevance)
+		}
+		parts[i] = fmt.Sprintf("%s:%d %s", r.FilePath, r.Chunk.StartLine, relevance)
+	}
+	return strings.Join(parts, ", ")
+}
+
 func init() {
 	chatCmd.Flags().IntVar(&flagK, "k", 10, "number of chunks to retrieve per question")
+	chatCmd.Flags().IntVar(&flagContextBudget, "context-budget", rag.DefaultContextBudget, "approximate token budget for retrieved chunks (0 = unlimited)")
+	chatCmd.Flags().BoolVar(&flagFileSummaries, "file-summaries", false, "include the stored summaries of the files retrieved chunks come from in the context (see 'synapse summarize')")
+	chatCmd.Flags().IntVar(&flagSummaryBudget, "summary-budget", rag.DefaultSummaryBudget, "approximate token budget for file summaries with --file-summaries, on top of --context-budget (0 = unlimited)")
+	chatCmd.Flags().BoolVar(&flagEdgeOrder, "edge-order", false, "place the most relevant files at the start and end of the context and the weakest in the middle, where models attend least")
+	chatCmd.Flags().BoolVar(&flagCitations, "citations", false, "list the retrieved chunks as path:line: references after each answer")
+	chatCmd.Flags().BoolVar(&flagVerify, "verify", false, "warn when an answer mentions files or symbols that aren't in the retrieved code")
+	chatCmd.Flags().BoolVar(&flagExpand, "expand", false, "expand each question into alternative phrasings with the chat model before retrieval (adds an LLM call per question)")
+	chatCmd.Flags().BoolVar(&flagNoAutodetect, "no-autodetect", false, "fail when --model or --chat-model isn't installed in Ollama instead of using an installed one")
+	chatCmd.Flags().BoolVar(&flagNoStream, "no-stream", false, "print each answer once it's complete instead of streaming tokens")
+	chatCmd.Flags().BoolVar(&flagShowThinking, "show-thinking", false, "show the <think> blocks reasoning models like qwen3 start their answers with (toggle with /show-thinking)")
 	rootCmd.AddCommand(chatCmd)
 }
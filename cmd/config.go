@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"synapse/internal/config"
+	"synapse/internal/format"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "View and change project settings in .synapse/config.toml",
+	Long: `Settings in .synapse/config.toml (next to the index) are defaults for the flag of
+the same name, e.g. chat_model for --chat-model. Flags given on the command line
+still take precedence.`,
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List every setting, its value, and what it does",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := format.Parse(flagOutput)
+		if err != nil {
+			return err
+		}
+		values, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		settings := make([]configSetting, len(config.Keys))
+		tab := format.Tabular{Columns: []string{"Key", "Value", "Description"}}
+		for i, key := range config.Keys {
+			value, set := values[key.Name]
+			settings[i] = configSetting{Key: key.Name, Value: value, Set: set, Description: key.Description}
+			shown := value
+			if !set {
+				shown = "(default)"
+			}
+			tab.Rows = append(tab.Rows, []string{key.Name, shown, key.Description})
+		}
+		return format.Render(os.Stdout, out, settings, tab)
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print a setting's value",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := lookupConfigKey(args[0])
+		if err != nil {
+			return err
+		}
+		values, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		value, ok := values[key.Name]
+		if !ok {
+			return fmt.Errorf("%s is not set; the --%s default applies", key.Name, key.Flag())
+		}
+		fmt.Println(value)
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := lookupConfigKey(args[0])
+		if err != nil {
+			return err
+		}
+		value, err := key.Validate(args[1])
+		if err != nil {
+			return err
+		}
+		values, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		values[key.Name] = value
+		return saveConfig(values)
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a setting, restoring the flag's default",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := lookupConfigKey(args[0])
+		if err != nil {
+			return err
+		}
+		values, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		delete(values, key.Name)
+		return saveConfig(values)
+	},
+}
+
+// configSetting is the JSON shape of one entry of 'synapse config list'.
+type configSetting struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Set         bool   `json:"set"`
+	Description string `json:"description"`
+}
+
+// lookupConfigKey returns the known setting name, accepting the flag
+// spelling too (chat-model for chat_model).
+func lookupConfigKey(name string) (config.Key, error) {
+	key, ok := config.Lookup(strings.ReplaceAll(name, "-", "_"))
+	if !ok {
+		names := make([]string, len(config.Keys))
+		for i, k := range config.Keys {
+			names[i] = k.Name
+		}
+		return config.Key{}, fmt.Errorf("unknown setting %q (known: %s)", name, strings.Join(names, ", "))
+	}
+	return key, nil
+}
+
+// loadConfig reads the index's config.toml. Invalid lines are reported as
+// a warning rather than an error, so they can be fixed with 'config set'
+// or dropped with 'config unset'; saving leaves them out.
+func loadConfig() (map[string]string, error) {
+	dbPath, err := resolveDBPath()
+	if err != nil {
+		return nil, err
+	}
+	values, err := config.Load(config.Path(dbPath))
+	if err != nil && values != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		return values, nil
+	}
+	return values, err
+}
+
+func saveConfig(values map[string]string) error {
+	dbPath, err := resolveDBPath()
+	if err != nil {
+		return err
+	}
+	return config.Save(config.Path(dbPath), values)
+}
+
+func init() {
+	addOutputFlag(configListCmd)
+	configCmd.AddCommand(configListCmd, configGetCmd, configSetCmd, configUnsetCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"synapse/internal/daemon"
+
+	"github.com/spf13/cobra"
+)
+
+var flagDaemonIdle time.Duration
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keep the index open and serve search/chat queries over a Unix socket",
+	Long: `Run a background query server for the index. While it's running, 'synapse search'
+and 'synapse chat' forward retrieval to it instead of opening the index themselves,
+and share its query cache. Without a daemon they work directly as usual.
+
+Run it in the background (e.g. 'synapse daemon &'); stop it with 'synapse daemon stop'.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		st, dbPath, err := openIndex()
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+
+		emb := newEmbedder()
+		srv := daemon.NewServer(st, emb, dbPath, flagDaemonIdle)
+		srv.Log = queryLog(dbPath)
+
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sigs
+			srv.Stop()
+		}()
+
+		fmt.Fprintf(os.Stderr, "synapse daemon serving %s on %s\n", dbPath, daemon.SocketPath(dbPath))
+		return srv.Serve()
+	},
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the daemon serving the index",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dbPath, err := resolveDBPath()
+		if err != nil {
+			return err
+		}
+		client, err := daemon.Connect(daemon.SocketPath(dbPath))
+		if err != nil {
+			return fmt.Errorf("no daemon running for %s", dbPath)
+		}
+		if err := client.Stop(); err != nil {
+			return err
+		}
+		fmt.Println("Daemon stopped.")
+		return nil
+	},
+}
+
+// connectDaemon returns a client for a daemon serving dbPath with the current
+// embedding model, or nil when commands should open the index directly.
+func connectDaemon(dbPath string) *daemon.Client {
+	client, err := daemon.Connect(daemon.SocketPath(dbPath))
+	if err != nil {
+		return nil
+	}
+	if client.Model != flagModel {
+		if flagDebug {
+			fmt.Fprintf(os.Stderr, "[debug] daemon uses model %q, not %q; querying directly\n", client.Model, flagModel)
+		}
+		return nil
+	}
+	if flagDebug {
+		fmt.Fprintln(os.Stderr, "[debug] forwarding retrieval to daemon")
+	}
+	return client
+}
+
+func init() {
+	daemonCmd.Flags().DurationVar(&flagDaemonIdle, "idle", daemon.DefaultIdleTimeout, "shut down after this long without requests (0 = never)")
+	daemonCmd.AddCommand(daemonStopCmd)
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"synapse/internal/format"
+
+	"github.com/spf13/cobra"
+)
+
+var defCmd = &cobra.Command{
+	Use:   "def <symbol>",
+	Short: "Show where a symbol is defined",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := format.Parse(flagOutput)
+		if err != nil {
+			return err
+		}
+
+		st, dbPath, err := openIndex()
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+
+		symbol := args[0]
+		results, err := st.FindByName(symbol)
+		if err != nil {
+			return fmt.Errorf("find %s: %w", symbol, err)
+		}
+
+		if len(results) == 0 && out != format.JSON && out != format.Patch {
+			fmt.Printf("No definitions found for %q\n", symbol)
+			return nil
+		}
+		return format.Render(os.Stdout, out,
+			defOutput{Symbol: symbol, Definitions: toResultJSON(results)},
+			resultTable(results, projectRoot(st, dbPath)))
+	},
+}
+
+// defOutput is the JSON shape of 'synapse def'.
+type defOutput struct {
+	Symbol      string       `json:"symbol"`
+	Definitions []resultJSON `json:"definitions"`
+}
+
+func init() {
+	addOutputFlag(defCmd)
+	rootCmd.AddCommand(defCmd)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var flagDoctorCheckOnly bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the index for inconsistencies and repair them",
+	Long: `Check that the full-text (keyword) index matches the stored chunks. A crash
+mid-write can leave it stale, so keyword search returns missing chunks or misses
+content. When a problem is found the full-text index is rebuilt from the chunks;
+embeddings and summaries are not touched.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		st, dbPath, err := openIndex()
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+
+		fmt.Printf("Checking %s...\n", dbPath)
+		verr := st.VerifyFTS()
+		if verr == nil {
+			fmt.Println("  Keyword index: OK")
+			return nil
+		}
+		fmt.Printf("  Keyword index: %v\n", verr)
+		if flagDoctorCheckOnly {
+			return fmt.Errorf("index has problems; run 'synapse doctor' without --check-only to repair")
+		}
+
+		if err := st.RepairFTS(); err != nil {
+			return err
+		}
+		if err := st.VerifyFTS(); err != nil {
+			return fmt.Errorf("keyword index still inconsistent after rebuild: %w", err)
+		}
+		fmt.Println("  Keyword index: rebuilt")
+		return nil
+	},
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&flagDoctorCheckOnly, "check-only", false, "report problems without repairing them")
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"synapse/internal/format"
+	"synapse/internal/rag"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagEvalK         int
+	flagEvalMinRecall float64
+	flagEvalMinMRR    float64
+)
+
+var evalCmd = &cobra.Command{
+	Use:   "eval <queries.json>",
+	Short: "Measure retrieval quality against golden queries",
+	Long: `Run each golden query through hybrid retrieval and report recall@k (the share of
+queries with an expected file in the top k results) and MRR (the mean reciprocal rank
+of the first expected file).
+
+The queries file is a JSON array of {"query": "...", "expected": ["path", ...]}
+objects, with paths as indexed (relative to the project root). testdata/retrieval has
+a fixture project and golden queries for it.
+
+With --min-recall or --min-mrr the command fails when a score falls below the
+threshold, so it can gate retrieval changes in CI.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := format.Parse(flagOutput)
+		if err != nil {
+			return err
+		}
+		cases, err := rag.LoadEvalCases(args[0])
+		if err != nil {
+			return err
+		}
+
+		st, _, err := openReadOnlyIndex()
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+		emb := newEmbedder()
+		retriever := rag.NewRetriever(st, emb, rag.Options{K: flagEvalK, Weighting: queryWeighting(), Recency: flagRecencyWeight})
+
+		report, err := rag.Evaluate(cases, flagEvalK, retriever.Retrieve)
+		if err != nil {
+			return err
+		}
+		if err := format.Render(os.Stdout, out, report, evalTable(report)); err != nil {
+			return err
+		}
+		if out != format.JSON {
+			fmt.Printf("\nrecall@%d %.3f  MRR %.3f  (%d queries)\n", report.K, report.Recall, report.MRR, len(report.Cases))
+		}
+
+		if report.Recall < flagEvalMinRecall {
+			return fmt.Errorf("recall@%d %.3f is below --min-recall %.3f", report.K, report.Recall, flagEvalMinRecall)
+		}
+		if report.MRR < flagEvalMinMRR {
+			return fmt.Errorf("MRR %.3f is below --min-mrr %.3f", report.MRR, flagEvalMinMRR)
+		}
+		return nil
+	},
+}
+
+func evalTable(report *rag.EvalReport) format.Tabular {
+	tab := format.Tabular{Columns: []string{"Rank", "Top result", "Query"}}
+	for _, c := range report.Cases {
+		rank := "-"
+		if c.Rank > 0 {
+			rank = fmt.Sprint(c.Rank)
+		}
+		tab.Rows = append(tab.Rows, []string{rank, c.Got, c.Query})
+	}
+	return tab
+}
+
+func init() {
+	evalCmd.Flags().IntVar(&flagEvalK, "k", 10, "number of results scored per query")
+	evalCmd.Flags().Float64Var(&flagEvalMinRecall, "min-recall", 0, "fail if recall@k is below this (0-1)")
+	evalCmd.Flags().Float64Var(&flagEvalMinMRR, "min-mrr", 0, "fail if MRR is below this (0-1)")
+	addOutputFlag(evalCmd)
+	rootCmd.AddCommand(evalCmd)
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"synapse/internal/index"
+	"synapse/internal/rag"
+	"synapse/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var flagExplainBudget int
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <path>",
+	Short: "Walk through what one indexed file does, for onboarding",
+	Long: `Ask the chat model for a walkthrough of one indexed file: its purpose, its key
+types and functions, and how they interact. The prompt holds the file's indexed
+code, its summary, and the project overview; nothing else in the repository is
+retrieved. Use --chat-model to pick the model.
+
+<path> is relative to the project root or the current directory.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		st, dbPath, err := openReadOnlyIndex()
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+
+		path := indexedPath(projectRoot(st, dbPath), args[0])
+		f, err := indexedFile(st, path)
+		if err != nil {
+			return err
+		}
+		content, err := st.GetAllFileContent(f.Path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", f.Path, err)
+		}
+		overview, err := index.LoadOverview(st, dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: project overview: %v; explaining without it\n", err)
+		}
+
+		chat := newChat(flagChatModel)
+		if err := chat.Ping(cmd.Context()); err != nil {
+			return err
+		}
+
+		msgs, truncated := rag.BuildExplainMessages(f.Path, f.Language, f.Summary, content, overview, flagExplainBudget)
+		if truncated {
+			fmt.Fprintf(os.Stderr, "%s is over --context-budget; explaining its first %d tokens or so\n", f.Path, flagExplainBudget)
+		}
+		if flagNoStream {
+			answer, err := chat.GenerateContext(cmd.Context(), msgs)
+			if err != nil {
+				return fmt.Errorf("llm error: %w", err)
+			}
+			fmt.Println(displayAnswer(answer))
+			return nil
+		}
+		_, err = streamAnswer(chat, msgs)
+		fmt.Println()
+		if err != nil {
+			return fmt.Errorf("llm error: %w", err)
+		}
+		return nil
+	},
+}
+
+// indexedPath returns arg as a path in the index under root: arg relative
+// to the current directory if it names a file under root there, and as
+// given otherwise.
+func indexedPath(root, arg string) string {
+	if abs, err := filepath.Abs(arg); err == nil {
+		if _, err := os.Stat(abs); err == nil {
+			if rel, err := filepath.Rel(root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+	return store.NormalizePath(arg)
+}
+
+// indexedFile returns the indexed file at path.
+func indexedFile(st store.Store, path string) (*store.FileSummary, error) {
+	files, err := st.ListFiles()
+	if err != nil {
+		return nil, fmt.Errorf("list files: %w", err)
+	}
+	for _, f := range files {
+		if f.Path == path {
+			return &f, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not in the index; index it first, or give its path relative to the project root", path)
+}
+
+func init() {
+	explainCmd.Flags().IntVar(&flagExplainBudget, "context-budget", rag.DefaultContextBudget, "approximate token budget for the file's code; longer files are cut (0 = unlimited)")
+	explainCmd.Flags().BoolVar(&flagNoStream, "no-stream", false, "print the explanation once it's complete instead of streaming tokens")
+	explainCmd.Flags().BoolVar(&flagShowThinking, "show-thinking", false, "show the <think> blocks reasoning models like qwen3 start their answers with")
+	rootCmd.AddCommand(explainCmd)
+}
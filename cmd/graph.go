@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"synapse/internal/format"
+	"synapse/internal/rag"
+	"synapse/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var flagGraphK int
+
+var graphCmd = &cobra.Command{
+	Use:   "graph <symbol>",
+	Short: "Show where a symbol is defined and which code refers to it",
+	Long: `Show the definitions of a symbol and the chunks that mention it, grouped by file:
+an approximate view of its callers and users.
+
+References are found with keyword search over the indexed chunks, then checked
+for the symbol as a whole word. This is a heuristic, not static analysis: a
+same-named symbol in another package, or a mention in a comment, counts too.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := format.Parse(flagOutput)
+		if err != nil {
+			return err
+		}
+
+		st, dbPath, err := openIndex()
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+
+		symbol := args[0]
+		rel, err := rag.SymbolRelationships(st, symbol, flagGraphK)
+		if err != nil {
+			return err
+		}
+		if len(rel.Definitions) == 0 && len(rel.Referrers) == 0 && out != format.JSON && out != format.Patch {
+			fmt.Printf("No definitions or references found for %q\n", symbol)
+			return nil
+		}
+		return format.Render(os.Stdout, out, toGraphJSON(rel), graphTable(rel, projectRoot(st, dbPath)))
+	},
+}
+
+// graphOutput is the JSON shape of 'synapse graph'.
+type graphOutput struct {
+	Symbol      string          `json:"symbol"`
+	Definitions []resultJSON    `json:"definitions"`
+	References  []referenceJSON `json:"references"`
+}
+
+// referenceJSON is a file referring to the symbol and the chunks in it
+// that do.
+type referenceJSON struct {
+	Path   string         `json:"path"`
+	Chunks []referrerJSON `json:"chunks"`
+}
+
+type referrerJSON struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+}
+
+func toGraphJSON(rel *rag.Relationships) graphOutput {
+	out := graphOutput{
+		Symbol:      rel.Symbol,
+		Definitions: toResultJSON(rel.Definitions),
+		References:  make([]referenceJSON, len(rel.Referrers)),
+	}
+	for i, g := range rel.Referrers {
+		ref := referenceJSON{Path: g.Path}
+		for _, c := range g.Chunks {
+			ref.Chunks = append(ref.Chunks, referrerJSON{
+				Kind:      c.Chunk.Kind,
+				Name:      c.Chunk.Name,
+				StartLine: c.Chunk.StartLine,
+				EndLine:   c.Chunk.EndLine,
+			})
+		}
+		out.References[i] = ref
+	}
+	return out
+}
+
+// graphTable lists the definitions and then the referring chunks, file by
+// file, with a Relation column telling them apart.
+func graphTable(rel *rag.Relationships, root string) format.Tabular {
+	results := append([]store.SearchResult(nil), rel.Definitions...)
+	for _, g := range rel.Referrers {
+		results = append(results, g.Chunks...)
+	}
+	tab := format.Tabular{
+		Columns:   []string{"Relation", "Path", "Lines", "Kind", "Name"},
+		Locations: resultLocations(results, root),
+	}
+	for i, r := range results {
+		relation := "references"
+		if i < len(rel.Definitions) {
+			relation = "defines"
+		}
+		tab.Rows = append(tab.Rows, []string{
+			relation,
+			r.FilePath,
+			fmt.Sprintf("%d-%d", r.Chunk.StartLine, r.Chunk.EndLine),
+			r.Chunk.Kind,
+			r.Chunk.Name,
+		})
+	}
+	return tab
+}
+
+func init() {
+	graphCmd.Flags().IntVar(&flagGraphK, "k", rag.DefaultReferenceLimit, "maximum number of referring chunks")
+	addOutputFlag(graphCmd)
+	rootCmd.AddCommand(graphCmd)
+}
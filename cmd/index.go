@@ -1,36 +1,109 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
 	"time"
 
+	"synapse/internal/chunker"
+	"synapse/internal/format"
 	"synapse/internal/index"
+	"synapse/internal/store"
+	"synapse/internal/tui"
+	"synapse/internal/walker"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	flagWorkers      int
+	flagWorkers       int
 	flagOverviewModel string
+	flagYes           bool
+	flagBackup        bool
+	flagSnapshots     int
+	flagNormalize     bool
+	flagEmbedDim      int
+	flagNoOverview    bool
+	flagImports       bool
+	flagIndexDocs     bool
+	flagChunkHeader   string
+	flagGenerated     bool
+	flagHidden        bool
+	flagIndexTimeout  time.Duration
+	flagTokenizer     string
+	flagCPUProfile    string
+	flagMemProfile    string
+	flagMaxSplits     int
+	flagMaxChunks     int
+	flagMinChunkLines int
+	flagSplitBlocks   bool
+	flagMinChunkBytes int
+	flagChunkKinds    []string
+	flagOverviewSyms  int
+	flagPromptTmpl    string
+	flagResume        bool
+	flagEmbedMaxBytes int
+	flagStatsOnly     bool
+	flagExcludeSyms   []string
+	flagEmitChunks    string
+	flagEmbedFrom     string
+	flagIndexVerbose  bool
 )
 
 var indexCmd = &cobra.Command{
-	Use:   "index <path>",
+	Use:   "index [path]",
 	Short: "Index a codebase for search",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		root, err := filepath.Abs(args[0])
+		root, dbPath, err := indexPaths(args)
 		if err != nil {
 			return err
 		}
+		if root == "" {
+			return fmt.Errorf("no directory to index; run 'synapse index <path>'")
+		}
+		out, err := format.Parse(flagOutput)
+		if err != nil {
+			return err
+		}
+		switch {
+		case out == format.Markdown || out == format.Patch:
+			return fmt.Errorf("index prints its summary as table or json, not %s", out)
+		case out == format.JSON && (flagStatsOnly || flagEmitChunks != ""):
+			return fmt.Errorf("--output json isn't supported with --stats-only or --emit-chunks")
+		}
+		if flagStatsOnly {
+			return printCoverage(root)
+		}
+
+		if flagEmitChunks == "" {
+			if err := autodetectIndexModels(dbPath); err != nil {
+				return err
+			}
+		}
+		// With JSON output, progress messages go to stderr so that stdout
+		// holds only the summary.
+		progress := io.Writer(os.Stdout)
+		if out == format.JSON {
+			progress = os.Stderr
+		}
+		cfg := indexConfig(dbPath, progress)
+
+		ctx, stop := indexContext(cmd.Context())
+		defer stop()
 
-		// Default DB path is <project>/.synapse/index.db.
-		dbPath := flagDB
-		if dbPath == "" {
-			dbPath = filepath.Join(root, ".synapse", "index.db")
+		if flagEmitChunks != "" {
+			return emitChunks(ctx, cfg, root)
 		}
 
 		// Ensure the database directory exists.
@@ -38,42 +111,456 @@ var indexCmd = &cobra.Command{
 			return fmt.Errorf("create db directory: %w", err)
 		}
 
-		overviewModel := flagOverviewModel
-		if overviewModel == "" {
-			overviewModel = flagChatModel
+		idx, err := index.New(cfg)
+		if err != nil {
+			return err
 		}
+		defer idx.Close()
 
-		idx, err := index.New(index.Config{
-			DBPath:        dbPath,
-			OllamaURL:     flagOllama,
-			Model:         flagModel,
-			Workers:       flagWorkers,
-			OverviewModel: overviewModel,
-		})
+		stopProfiling, err := startProfiling(flagCPUProfile, flagMemProfile)
 		if err != nil {
 			return err
 		}
-		defer idx.Close()
 
-		fmt.Printf("Indexing %s...\n", root)
+		var stats *index.Stats
 		start := time.Now()
-
-		stats, err := idx.Index(root)
+		if flagEmbedFrom != "" {
+			in, closeIn, oerr := openChunkFile(flagEmbedFrom)
+			if oerr != nil {
+				return oerr
+			}
+			defer closeIn()
+			fmt.Fprintf(progress, "Indexing chunks from %s...\n", flagEmbedFrom)
+			stats, err = idx.IndexChunks(ctx, in, root)
+		} else {
+			fmt.Fprintf(progress, "Indexing %s...\n", root)
+			stats, err = idx.IndexContext(ctx, root)
+		}
 		elapsed := time.Since(start)
+		if perr := stopProfiling(); perr != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", perr)
+		}
 
 		if stats != nil {
-			fmt.Printf("\nDone in %s\n", elapsed.Round(time.Millisecond))
-			fmt.Printf("  Files:   %d total, %d indexed, %d skipped\n",
-				stats.FilesTotal, stats.FilesIndexed, stats.FilesSkipped)
-			fmt.Printf("  Chunks:  %d\n", stats.ChunksTotal)
+			if out == format.JSON {
+				if jerr := format.Render(os.Stdout, out, toIndexJSON(stats, elapsed), format.Tabular{}); jerr != nil {
+					return jerr
+				}
+			} else {
+				printIndexStats(os.Stdout, stats, elapsed, "indexed")
+			}
 		}
+		return indexError(err)
+	},
+}
+
+// autodetectIndexModels replaces --model, and --chat-model when it writes
+// the summaries, with installed models if they aren't installed (see
+// autodetectModel). For an existing index the only stand-in for --model is
+// the model the index at dbPath was built with, since any other would wipe
+// it; with that one missing too, it fails.
+func autodetectIndexModels(dbPath string) error {
+	installed := installedModels()
+	if installed == nil {
+		return nil
+	}
+	var indexModel string
+	if _, err := os.Stat(dbPath); err == nil {
+		if st, err := store.OpenWith(dbPath, store.OpenOptions{ReadOnly: true, FTSOnly: true}); err == nil {
+			indexModel, _ = st.GetMeta("embedding_model")
+			st.Close()
+		}
+	}
+	if indexModel == "" {
+		flagModel = autodetectModel(installed, "embedding", flagModel, modelNames(installed, true))
+	} else if !tui.HasModel(installed, flagModel) {
+		if !tui.HasModel(installed, indexModel) {
+			return fmt.Errorf("model %s used by this index is not installed; pull it or pass --model", indexModel)
+		}
+		flagModel = autodetectModel(installed, "embedding", flagModel, []string{indexModel})
+	}
+	if !flagNoOverview && flagOverviewModel == "" {
+		flagChatModel = autodetectModel(installed, "chat", flagChatModel, modelNames(installed, false))
+	}
+	return nil
+}
+
+// indexConfig returns the indexer configuration given by the flags, writing
+// progress messages and the re-index prompt to progress.
+func indexConfig(dbPath string, progress io.Writer) index.Config {
+	overviewModel := flagOverviewModel
+	if overviewModel == "" {
+		overviewModel = flagChatModel
+	}
+	return index.Config{
+		DBPath:           dbPath,
+		OllamaURL:        flagOllama,
+		Model:            flagModel,
+		Workers:          flagWorkers,
+		OverviewModel:    overviewModel,
+		ConfirmReindex:   func(oldModel, newModel string) (bool, error) { return confirmReindex(progress, oldModel, newModel) },
+		Log:              progress,
+		BackupOnReindex:  flagBackup,
+		Snapshots:        flagSnapshots,
+		EmbeddingDim:     flagEmbedDim,
+		SkipOverview:     flagNoOverview,
+		IncludeImports:   flagImports,
+		IndexDocs:        flagIndexDocs,
+		ChunkHeader:      flagChunkHeader,
+		IndexGenerated:   flagGenerated,
+		IncludeHidden:    flagHidden,
+		Tokenizer:        flagTokenizer,
+		MaxSplits:        flagMaxSplits,
+		MaxChunksPerFile: flagMaxChunks,
+		MinChunkLines:    flagMinChunkLines,
+		MinChunkBytes:    flagMinChunkBytes,
+		SplitBlocks:      flagSplitBlocks,
+		ChunkKinds:       flagChunkKinds,
+		OverviewSymbols:  flagOverviewSyms,
+		OverviewPrompt:   flagPromptTmpl,
+		Resume:           flagResume,
+		EmbedMaxBytes:    flagEmbedMaxBytes,
+		ExcludeSymbols:   flagExcludeSyms,
+		KeepAlive:        flagKeepAlive,
+		RawEmbeddings:    !flagNormalize,
+		OnProgress:       embedProgress(),
+	}
+}
+
+// embedProgress returns a progress callback that shows how far the embedding
+// of a large file has got on a status line on stderr, so it doesn't look
+// stuck. Without a terminal to redraw the line on, it returns nil.
+func embedProgress() index.ProgressFunc {
+	if fi, err := os.Stderr.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return func(p index.Progress) {
+		if p.Phase != index.PhaseEmbedding {
+			return
+		}
+		if p.BatchesDone == p.Batches {
+			fmt.Fprint(os.Stderr, "\r\033[K")
+			return
+		}
+		fmt.Fprintf(os.Stderr, "\r\033[KEmbedding chunk batch %d of %d for %s", p.BatchesDone+1, p.Batches, p.File)
+	}
+}
+
+// indexContext returns a context that Ctrl-C and --timeout cancel, so
+// indexing stops cleanly, keeping finished files.
+func indexContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt)
+	if flagIndexTimeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, flagIndexTimeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// indexError explains a cancelled or timed-out run.
+func indexError(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("indexing timed out after %s; files indexed so far were saved, re-run with --resume to continue", flagIndexTimeout)
+	case errors.Is(err, context.Canceled):
+		return fmt.Errorf("indexing interrupted; files indexed so far were saved, re-run with --resume to continue")
+	}
+	return err
+}
+
+// printIndexStats writes the summary of a run to w; done describes what happened
+// to the files that weren't skipped.
+func printIndexStats(w io.Writer, stats *index.Stats, elapsed time.Duration, done string) {
+	fmt.Fprintf(w, "\nDone in %s\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "  Files:   %d total, %d %s, %d skipped\n",
+		stats.FilesTotal, stats.FilesIndexed, done, stats.FilesSkipped)
+	if stats.FilesSkippedGenerated > 0 {
+		fmt.Fprintf(w, "  Generated: %d skipped (use --index-generated to include)\n", stats.FilesSkippedGenerated)
+	}
+	if stats.FilesHidden > 0 {
+		fmt.Fprintf(w, "  Hidden:  %d of the files found are dotfiles or in hidden directories (--include-hidden)\n", stats.FilesHidden)
+	}
+	if stats.FilesSkippedBinary > 0 {
+		fmt.Fprintf(w, "  Binary:  %d skipped (binary or non-UTF-8)\n", stats.FilesSkippedBinary)
+	}
+	if stats.FilesPanicked > 0 {
+		fmt.Fprintf(w, "  Failed:  %d files crashed the parser and were skipped (see errors above)\n", stats.FilesPanicked)
+	}
+	fmt.Fprintf(w, "  Chunks:  %d\n", stats.ChunksTotal)
+	if stats.FilesCapped > 0 {
+		fmt.Fprintf(w, "  Capped:  %d files over --max-chunks-per-file kept only their largest definitions\n", stats.FilesCapped)
+	}
+	if stats.ChunksTruncated > 0 {
+		fmt.Fprintf(w, "  Truncated: %d oversized chunks capped (raise --max-splits to index more)\n", stats.ChunksTruncated)
+	}
+	if stats.ChunksExcluded > 0 {
+		fmt.Fprintf(w, "  Excluded: %d symbols matching exclude-symbols patterns\n", stats.ChunksExcluded)
+	}
+	if stats.ChunksSplitForEmbedding > 0 {
+		fmt.Fprintf(w, "  Long:    %d chunks over --embed-max-bytes embedded in pieces\n", stats.ChunksSplitForEmbedding)
+	}
+	if stats.EmbeddingsDegenerate > 0 {
+		fmt.Fprintf(w, "  Degenerate: %d chunks got an all-zero or invalid embedding and are only found by keyword search\n", stats.EmbeddingsDegenerate)
+	}
+	if stats.EmbeddingsReused > 0 {
+		fmt.Fprintf(w, "  Reused:  %d unchanged chunk embeddings\n", stats.EmbeddingsReused)
+	}
+	if len(stats.FileErrors) > 0 {
+		if !flagIndexVerbose {
+			fmt.Fprintf(w, "  Errors:  %d files failed (--verbose lists them)\n", len(stats.FileErrors))
+			return
+		}
+		fmt.Fprintf(w, "  Errors:  %d files failed\n", len(stats.FileErrors))
+		for _, fe := range stats.FileErrors {
+			fmt.Fprintf(w, "    %s (%s): %s\n", fe.Path, fe.Stage, fe.Message)
+		}
+	}
+}
 
+// indexOutput is the JSON shape of the 'synapse index' summary.
+type indexOutput struct {
+	ElapsedMS            int64           `json:"elapsed_ms"`
+	FilesTotal           int             `json:"files_total"`
+	FilesIndexed         int             `json:"files_indexed"`
+	FilesSkipped         int             `json:"files_skipped"`
+	SkippedBinary        int             `json:"skipped_binary"`
+	SkippedGenerated     int             `json:"skipped_generated"`
+	FilesHidden          int             `json:"files_hidden"`
+	FilesPanicked        int             `json:"files_panicked"`
+	FilesCapped          int             `json:"files_capped"`
+	Chunks               int             `json:"chunks"`
+	ChunksTruncated      int             `json:"chunks_truncated"`
+	ChunksExcluded       int             `json:"chunks_excluded"`
+	ChunksSplit          int             `json:"chunks_split"`
+	EmbeddingsReused     int             `json:"embeddings_reused"`
+	EmbeddingsDegenerate int             `json:"embeddings_degenerate"`
+	FileErrors           []fileErrorJSON `json:"file_errors"`
+}
+
+type fileErrorJSON struct {
+	Path    string `json:"path"`
+	Stage   string `json:"stage"`
+	Message string `json:"message"`
+}
+
+func toIndexJSON(stats *index.Stats, elapsed time.Duration) indexOutput {
+	out := indexOutput{
+		ElapsedMS:            elapsed.Milliseconds(),
+		FilesTotal:           stats.FilesTotal,
+		FilesIndexed:         stats.FilesIndexed,
+		FilesSkipped:         stats.FilesSkipped,
+		SkippedBinary:        stats.FilesSkippedBinary,
+		SkippedGenerated:     stats.FilesSkippedGenerated,
+		FilesHidden:          stats.FilesHidden,
+		FilesPanicked:        stats.FilesPanicked,
+		FilesCapped:          stats.FilesCapped,
+		Chunks:               stats.ChunksTotal,
+		ChunksTruncated:      stats.ChunksTruncated,
+		ChunksExcluded:       stats.ChunksExcluded,
+		ChunksSplit:          stats.ChunksSplitForEmbedding,
+		EmbeddingsReused:     stats.EmbeddingsReused,
+		EmbeddingsDegenerate: stats.EmbeddingsDegenerate,
+		FileErrors:           make([]fileErrorJSON, len(stats.FileErrors)),
+	}
+	for i, fe := range stats.FileErrors {
+		out.FileErrors[i] = fileErrorJSON{Path: fe.Path, Stage: fe.Stage, Message: fe.Message}
+	}
+	return out
+}
+
+// emitChunks writes the chunks of the files under root that need indexing
+// to --emit-chunks, for a later 'synapse index --embed-from'. The summary
+// goes to stderr so the chunks can be written to stdout with "-".
+func emitChunks(ctx context.Context, cfg index.Config, root string) error {
+	out := os.Stdout
+	if flagEmitChunks != "-" {
+		f, err := os.Create(flagEmitChunks)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	fmt.Fprintf(os.Stderr, "Chunking %s...\n", root)
+	start := time.Now()
+	stats, err := index.EmitChunks(ctx, cfg, root, out)
+	if stats != nil {
+		printIndexStats(os.Stderr, stats, time.Since(start), "written")
+	}
+	if err == nil && out != os.Stdout {
+		err = out.Close()
+	}
+	return err
+}
+
+// openChunkFile opens a chunk file written by --emit-chunks; "-" is stdin.
+func openChunkFile(path string) (io.Reader, func() error, error) {
+	if path == "-" {
+		return os.Stdin, func() error { return nil }, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// startProfiling starts a CPU profile written to cpuPath, if set. The returned
+// function stops it and writes a heap profile to memPath, if set.
+func startProfiling(cpuPath, memPath string) (func() error, error) {
+	var cpuFile *os.File
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return nil, fmt.Errorf("create cpu profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("start cpu profile: %w", err)
+		}
+		cpuFile = f
+	}
+
+	return func() error {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				return fmt.Errorf("write cpu profile: %w", err)
+			}
+		}
+		if memPath == "" {
+			return nil
+		}
+		f, err := os.Create(memPath)
+		if err != nil {
+			return fmt.Errorf("create memory profile: %w", err)
+		}
+		defer f.Close()
+		runtime.GC() // up-to-date allocation statistics
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			return fmt.Errorf("write memory profile: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// confirmReindex asks on w before the index is wiped for an embedding model
+// change. Without a terminal to prompt on, --yes is required.
+func confirmReindex(w io.Writer, oldModel, newModel string) (bool, error) {
+	if flagYes {
+		return true, nil
+	}
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false, fmt.Errorf("embedding model changed from %q to %q; re-run with --yes to delete the existing index and re-embed all files", oldModel, newModel)
+	}
+
+	fmt.Fprintf(w, "Embedding model changed from %q to %q.\n", oldModel, newModel)
+	fmt.Fprint(w, "This deletes all indexed chunks and re-embeds every file. Continue? [y/N] ")
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
+
+// maxUnsupportedShown caps the extensions listed by --stats-only.
+const maxUnsupportedShown = 15
+
+// printCoverage reports which files under root would be indexed, and which
+// extensions have no grammar, without touching the index.
+func printCoverage(root string) error {
+	cov, err := index.ScanCoverage(root, index.NewRegistry(), walker.Options{IndexGenerated: flagGenerated, IncludeHidden: flagHidden})
+	if err != nil {
 		return err
-	},
+	}
+
+	langs := make([]string, 0, len(cov.Languages))
+	total := 0
+	for lang, n := range cov.Languages {
+		langs = append(langs, lang)
+		total += n
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if cov.Languages[langs[i]] != cov.Languages[langs[j]] {
+			return cov.Languages[langs[i]] > cov.Languages[langs[j]]
+		}
+		return langs[i] < langs[j]
+	})
+	fmt.Printf("Coverage of %s\n\n", root)
+	fmt.Printf("Indexable: %d files\n", total)
+	if flagHidden {
+		fmt.Printf("  (%d of them dotfiles or in hidden directories, from --include-hidden)\n", cov.Hidden)
+	}
+	for _, lang := range langs {
+		fmt.Printf("  %-12s %d\n", lang, cov.Languages[lang])
+	}
+
+	fmt.Printf("\nSkipped:\n")
+	fmt.Printf("  Generated: %d (use --index-generated to include)\n", cov.SkippedGenerated)
+	fmt.Printf("  Size:      %d (empty or over 1 MB)\n", cov.SkippedSize)
+	fmt.Printf("  Binary:    %d (binary or non-UTF-8)\n", cov.SkippedBinary)
+
+	unsupported := cov.UnsupportedByCount()
+	if len(unsupported) == 0 {
+		return nil
+	}
+	fmt.Printf("\nNot indexed (no grammar registered):\n")
+	for i, e := range unsupported {
+		if i == maxUnsupportedShown {
+			fmt.Printf("  ...and %d more extensions\n", len(unsupported)-i)
+			break
+		}
+		switch lang := index.LanguageOf(e.Ext); {
+		case e.Ext == "":
+			fmt.Printf("  %d files without an extension\n", e.Files)
+		case lang != "":
+			fmt.Printf("  you have %d .%s files that synapse can't index (no %s grammar registered)\n", e.Files, e.Ext, lang)
+		default:
+			fmt.Printf("  %d .%s files\n", e.Files, e.Ext)
+		}
+	}
+	return nil
 }
 
 func init() {
 	indexCmd.Flags().IntVar(&flagWorkers, "workers", runtime.NumCPU(), "parallel workers")
+	indexCmd.Flags().BoolVarP(&flagYes, "yes", "y", false, "re-index without confirmation when the embedding model changes")
+	indexCmd.Flags().BoolVar(&flagNoOverview, "no-overview", false, "skip file summaries and the project overview (run 'synapse summarize' later)")
+	indexCmd.Flags().BoolVar(&flagGenerated, "index-generated", false, "index generated files (*.pb.go, *_pb2.py, *.g.dart, ...) that are skipped by default")
+	indexCmd.Flags().BoolVar(&flagHidden, "include-hidden", false, "also index the hidden directories ignored by default (.vscode, .idea), even when .synapseignore lists them, and dotfiles recognized by name or #! line; .git and .synapse are never indexed")
+	indexCmd.Flags().BoolVar(&flagImports, "include-imports", false, "prepend each file's import block to its chunks (Go, Python)")
+	indexCmd.Flags().BoolVar(&flagIndexDocs, "index-docs-as-chunks", false, "also index doc comments and docstrings as chunks of their own (kind doc), so questions worded like the docs match them")
+	indexCmd.Flags().StringVar(&flagChunkHeader, "chunk-header", chunker.DefaultHeader, "how each chunk's code is framed for embedding: \"comment\" (a // File: header), \"prose\" (a sentence naming it), or a file holding a Go template")
+	indexCmd.Flags().BoolVar(&flagStatsOnly, "stats-only", false, "report which files would be indexed and which extensions have no grammar, without indexing anything")
+	indexCmd.Flags().StringVar(&flagEmitChunks, "emit-chunks", "", "chunk the files that need indexing and write them to this JSON-lines file (- for stdout) instead of embedding them; no embedding model needed")
+	indexCmd.Flags().StringVar(&flagEmbedFrom, "embed-from", "", "embed and store the chunks in a file written by --emit-chunks (- for stdin) instead of walking <path>, which is recorded as the project root")
+	indexCmd.MarkFlagsMutuallyExclusive("emit-chunks", "embed-from", "stats-only")
+	indexCmd.Flags().BoolVar(&flagResume, "resume", false, "continue an interrupted run quickly: skip reading files whose size and modification time are those recorded when they were indexed")
+	indexCmd.Flags().DurationVar(&flagIndexTimeout, "timeout", 0, "abort indexing after this long, keeping files already indexed (e.g. 30m; 0 = no limit)")
+	indexCmd.Flags().IntVar(&flagEmbedDim, "embed-dim", 0, "embedding dimension (default: detected from the model)")
+	indexCmd.Flags().IntVar(&flagEmbedMaxBytes, "embed-max-bytes", index.DefaultEmbedMaxBytes, "longest input sent to the embedding model; longer chunks are embedded in pieces and averaged instead of silently truncated by the model (-1 = no limit)")
+	indexCmd.Flags().IntVar(&flagMaxSplits, "max-splits", chunker.DefaultMaxSplits, "maximum pieces an oversized function or class is split into; the rest is skipped (-1 = no limit)")
+	indexCmd.Flags().IntVar(&flagMaxChunks, "max-chunks-per-file", chunker.DefaultMaxChunks, "maximum chunks indexed per file; files with more keep only their largest definitions (-1 = no limit)")
+	indexCmd.Flags().IntVar(&flagMinChunkLines, "min-chunk-lines", 0, "merge definitions shorter than this many lines with adjacent small ones (0 = off)")
+	indexCmd.Flags().IntVar(&flagMinChunkBytes, "min-chunk-bytes", 0, "like --min-chunk-lines, for definitions shorter than this many bytes (0 = off)")
+	indexCmd.Flags().BoolVar(&flagSplitBlocks, "split-blocks", false, "split functions and classes too big for one chunk between statements or members, repeating the signature atop each piece, instead of into overlapping 40-line windows")
+	indexCmd.Flags().StringSliceVar(&flagChunkKinds, "chunk-kinds", nil, "index only these kinds of chunks, e.g. function,method,class (default: everything)")
+	indexCmd.Flags().StringSliceVar(&flagExcludeSyms, "exclude-symbols", nil, "leave out symbols whose name matches these glob patterns, e.g. init,String,Test* (added to .synapse/exclude-symbols)")
+	indexCmd.Flags().StringVar(&flagCPUProfile, "cpuprofile", "", "write a CPU profile of the indexing run to this file")
+	indexCmd.Flags().StringVar(&flagMemProfile, "memprofile", "", "write a heap profile after the indexing run to this file")
+	indexCmd.Flags().MarkHidden("cpuprofile")
+	indexCmd.Flags().MarkHidden("memprofile")
+	indexCmd.Flags().StringVar(&flagTokenizer, "tokenizer", "", "FTS5 tokenizer for keyword search, e.g. \"porter unicode61\" or \"unicode61\" (default: the index's current one, or \"porter unicode61\" for new indexes); changing it rebuilds the keyword index")
+	indexCmd.Flags().BoolVar(&flagBackup, "backup", true, "back up the index to <db>.bak before a model-change re-index")
+	indexCmd.Flags().BoolVar(&flagNormalize, "normalize-embeddings", true, "scale embeddings and queries to unit length so distances rank like cosine similarity; normalizes an existing index's embeddings in place, and turning it off needs a new index")
+	indexCmd.Flags().IntVar(&flagSnapshots, "snapshots", 0, "before indexing, copy the existing index to .synapse/snapshots/ and keep this many of the most recent copies, for 'synapse rollback' (0 = no snapshots)")
 	indexCmd.Flags().StringVar(&flagOverviewModel, "overview-model", "", "model for overview generation (default: same as --chat-model)")
+	indexCmd.Flags().IntVar(&flagOverviewSyms, "overview-symbols", index.DefaultOverviewSymbols, "maximum symbols listed per file in the overview prompt, public ones first (-1 = no limit)")
+	indexCmd.Flags().StringVar(&flagPromptTmpl, "prompt-template", "", "file of instructions for writing the project overview, replacing .synapse/prompts/overview.md or the built-in ones")
+	indexCmd.Flags().BoolVar(&flagNoAutodetect, "no-autodetect", false, "fail when --model or --chat-model isn't installed in Ollama instead of using an installed one")
+	indexCmd.Flags().BoolVar(&flagIndexVerbose, "verbose", false, "list each file that failed to index, and why, in the summary")
+	addOutputFlag(indexCmd)
 	rootCmd.AddCommand(indexCmd)
 }
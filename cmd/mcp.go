@@ -2,12 +2,20 @@ package cmd
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"synapse/internal/embedder"
+	"synapse/internal/index"
 	"synapse/internal/rag"
 	"synapse/internal/store"
 
@@ -16,46 +24,217 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	flagMCPHTTP      string
+	flagMCPToken     string
+	flagMCPTokenFile string
+	flagMCPCallLog   string
+)
+
+// mcpTokenEnv names the environment variable the MCP bearer token can be
+// given in, which unlike --token keeps it out of the process list and shell
+// history.
+const mcpTokenEnv = "SYNAPSE_MCP_TOKEN"
+
 var mcpCmd = &cobra.Command{
 	Use:   "mcp",
 	Short: "Start an MCP server exposing codebase search tools",
-	RunE:  runMCP,
+	Long: `Start an MCP server exposing codebase search tools. By default it speaks MCP over
+stdin and stdout, for clients that spawn it. With --http it listens on an address
+instead, serving the Streamable HTTP transport at /mcp and the SSE transport at /sse
+(with messages posted to /message), for remote and browser-based clients.
+
+With --json-rpc-log, every tool call is appended to a file as a JSON line: the
+time, tool name, arguments, result or error, and how long it took. It never
+goes to stdout, which carries the protocol.`,
+	RunE: runMCP,
 }
 
 func runMCP(cmd *cobra.Command, args []string) error {
-	// Resolve DB path — same pattern as chat.go.
-	dbPath := flagDB
-	if dbPath == "" {
-		wd, err := os.Getwd()
-		if err != nil {
-			return err
-		}
-		dbPath = filepath.Join(wd, ".synapse", "index.db")
+	if flagMCPToken != "" && flagMCPHTTP == "" {
+		return fmt.Errorf("--token needs --http")
 	}
-	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-		return fmt.Errorf("index not found at %s\nRun 'synapse index <path>' first to build the index", dbPath)
+	if flagMCPTokenFile != "" && flagMCPHTTP == "" {
+		return fmt.Errorf("--token-file needs --http")
 	}
-
-	st, err := store.Open(dbPath)
+	token, err := mcpToken()
+	if err != nil {
+		return err
+	}
+	st, dbPath, err := openReadOnlyIndex()
 	if err != nil {
-		return fmt.Errorf("open index: %w", err)
+		return err
 	}
 	defer st.Close()
 
-	emb := embedder.NewOllamaEmbedder(flagOllama, flagModel)
-	overviewPath := filepath.Join(filepath.Dir(dbPath), "overview.md")
+	emb := newEmbedder()
 
-	s := mcpserver.NewMCPServer("synapse", "1.0.0", mcpserver.WithToolCapabilities(false))
+	s := mcpserver.NewMCPServer("synapse", "1.0.0",
+		mcpserver.WithToolCapabilities(false),
+		mcpserver.WithPromptCapabilities(false),
+	)
 
-	s.AddTool(searchCodebaseTool(), makeSearchHandler(st, emb))
-	s.AddTool(getFileSummaryTool(), makeFileSummaryHandler(st))
-	s.AddTool(getProjectOverviewTool(), makeOverviewHandler(overviewPath))
-	s.AddTool(listIndexedFilesTool(), makeListFilesHandler(st))
+	var cache *rag.Cache
+	if flagCacheSize > 0 {
+		cache = rag.NewCache(flagCacheSize, rag.DefaultCacheTTL)
+	}
 
+	addTool := s.AddTool
+	if flagMCPCallLog != "" {
+		f, err := os.OpenFile(flagMCPCallLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("open --json-rpc-log: %w", err)
+		}
+		defer f.Close()
+		calls := &toolCallLog{enc: json.NewEncoder(f)}
+		addTool = func(tool mcp.Tool, handler mcpserver.ToolHandlerFunc) {
+			s.AddTool(tool, calls.wrap(tool.Name, handler))
+		}
+	}
+
+	addTool(searchCodebaseTool(), makeSearchHandler(st, emb, cache, queryLog(dbPath)))
+	addTool(searchFilesTool(), makeSearchFilesHandler(st, emb, cache, queryLog(dbPath)))
+	addTool(batchSearchTool(), makeBatchSearchHandler(st, emb, cache, queryLog(dbPath)))
+	addTool(getFileSummaryTool(), makeFileSummaryHandler(st))
+	addTool(getProjectOverviewTool(), makeOverviewHandler(st, dbPath))
+	addTool(listIndexedFilesTool(), makeListFilesHandler(st))
+	addTool(getRelatedFilesTool(), makeRelatedFilesHandler(st))
+	addTool(getSymbolRelationshipsTool(), makeSymbolRelationshipsHandler(st))
+	addTool(verifyAnswerTool(), makeVerifyAnswerHandler(st, emb, cache))
+	addTool(suggestEditTool(), makeSuggestEditHandler(st, emb, cache, projectRoot(st, dbPath)))
+
+	s.AddPrompt(explainFilePrompt(), makeExplainFileHandler(st, emb))
+	s.AddPrompt(codeReviewPrompt(), makeCodeReviewHandler(st))
+
+	if flagMCPHTTP != "" {
+		return serveMCPHTTP(cmd.Context(), s, flagMCPHTTP, token)
+	}
 	return mcpserver.ServeStdio(s)
 }
 
+// mcpToken returns the bearer token given by --token, --token-file, or the
+// SYNAPSE_MCP_TOKEN environment variable, in that order, or "" for none. A
+// token file holds the token alone; surrounding whitespace is ignored.
+func mcpToken() (string, error) {
+	if flagMCPToken != "" {
+		return flagMCPToken, nil
+	}
+	if flagMCPTokenFile != "" {
+		data, err := os.ReadFile(flagMCPTokenFile)
+		if err != nil {
+			return "", fmt.Errorf("read --token-file: %w", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", fmt.Errorf("--token-file %s is empty", flagMCPTokenFile)
+		}
+		return token, nil
+	}
+	return os.Getenv(mcpTokenEnv), nil
+}
+
+// serveMCPHTTP serves s over HTTP on addr until interrupted, then shuts down
+// gracefully. With a token, requests must carry it as a bearer token.
+func serveMCPHTTP(ctx context.Context, s *mcpserver.MCPServer, addr, token string) error {
+	mux := http.NewServeMux()
+	srv := &http.Server{Addr: addr, Handler: requireBearer(token, mux)}
+	sse := mcpserver.NewSSEServer(s, mcpserver.WithHTTPServer(srv))
+	mux.Handle("/mcp", mcpserver.NewStreamableHTTPServer(s))
+	mux.Handle("/sse", sse)
+	mux.Handle("/message", sse)
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errCh := make(chan error, 1)
+	go func() { errCh <- sse.Start(addr) }()
+	if token == "" {
+		fmt.Fprintf(os.Stderr, "warning: serving MCP on %s without a token (--token, --token-file, or %s); anyone who can reach it can read the index\n", addr, mcpTokenEnv)
+	}
+	fmt.Fprintf(os.Stderr, "synapse MCP server listening on %s (/mcp, /sse)\n", addr)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), mcpShutdownTimeout)
+	defer cancel()
+	if err := sse.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shut down MCP server: %w", err)
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// mcpShutdownTimeout bounds how long in-flight MCP requests get to finish
+// when the HTTP server stops.
+const mcpShutdownTimeout = 5 * time.Second
+
+// requireBearer rejects requests without "Authorization: Bearer <token>",
+// or lets everything through when token is empty.
+func requireBearer(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// toolCallLog appends a JSON line per tool call to a file, for debugging
+// what MCP clients ask for and get.
+type toolCallLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// toolCallRecord is one line of the --json-rpc-log file.
+type toolCallRecord struct {
+	Time       time.Time           `json:"time"`
+	Tool       string              `json:"tool"`
+	Arguments  any                 `json:"arguments,omitempty"`
+	Result     *mcp.CallToolResult `json:"result,omitempty"`
+	Error      string              `json:"error,omitempty"`
+	DurationMS float64             `json:"duration_ms"`
+}
+
+// wrap returns handler, logging each call to the tool called name.
+func (l *toolCallLog) wrap(name string, handler mcpserver.ToolHandlerFunc) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		result, err := handler(ctx, req)
+		rec := toolCallRecord{
+			Time:       start.UTC(),
+			Tool:       name,
+			Arguments:  req.Params.Arguments,
+			Result:     result,
+			DurationMS: float64(time.Since(start)) / float64(time.Millisecond),
+		}
+		if err != nil {
+			rec.Error = err.Error()
+		}
+		l.mu.Lock()
+		// Logging is best-effort and never fails a call.
+		_ = l.enc.Encode(rec)
+		l.mu.Unlock()
+		return result, err
+	}
+}
+
 func init() {
+	mcpCmd.Flags().StringVar(&flagMCPHTTP, "http", "", "serve MCP over HTTP on this address (e.g. 127.0.0.1:8765) instead of stdio")
+	mcpCmd.Flags().StringVar(&flagMCPToken, "token", "", "with --http, require this bearer token in each request's Authorization header (or set "+mcpTokenEnv+")")
+	mcpCmd.Flags().StringVar(&flagMCPTokenFile, "token-file", "", "like --token, but read the token from this file, keeping it out of the process list")
+	mcpCmd.MarkFlagsMutuallyExclusive("token", "token-file")
+	mcpCmd.Flags().StringVar(&flagMCPCallLog, "json-rpc-log", "", "append every tool call, with its arguments and result, to this file as JSON lines, for debugging clients")
 	rootCmd.AddCommand(mcpCmd)
 }
 
@@ -79,6 +258,62 @@ func searchCodebaseTool() mcp.Tool {
 		mcp.WithNumber("k",
 			mcp.Description("Maximum number of chunks to return (default 10)"),
 		),
+		mcp.WithString("path_prefix",
+			mcp.Description("Optional path prefix to scope results (e.g. 'services/payments/'), relative to the project root"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Result format: 'markdown' (default) shows each chunk's code; 'json' returns {query, results: [{path, language, kind, name, start_line, end_line, distance, metric, relevance, score, content}]}; 'compact' lists one path:lines line with a one-line snippet per chunk, to save tokens"),
+			mcp.Enum("markdown", "json", "compact"),
+		),
+	)
+}
+
+func searchFilesTool() mcp.Tool {
+	return mcp.NewTool("search_files",
+		mcp.WithDescription("Find the files most relevant to a query and return their full content. Ranks files by how many of their chunks match and how well. Use for \"where's the file that does X\" questions; use search_codebase for specific snippets."),
+		mcp.WithToolAnnotation(readOnlyAnnotation),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("Natural language or keyword query to search the codebase"),
+		),
+		mcp.WithNumber("k",
+			mcp.Description("Maximum number of files to return (default 3)"),
+		),
+		mcp.WithString("path_prefix",
+			mcp.Description("Optional path prefix to scope results (e.g. 'services/payments/'), relative to the project root"),
+		),
+		mcp.WithNumber("max_bytes",
+			mcp.Description(fmt.Sprintf("Maximum bytes of file content returned in total (default %d); the last file is truncated to fit", rag.DefaultFileBudget)),
+		),
+	)
+}
+
+// maxBatchQueries caps the queries of one batch_search call, and
+// batchConcurrency how many of them are searched at once.
+const (
+	maxBatchQueries  = 50
+	batchConcurrency = 4
+)
+
+func batchSearchTool() mcp.Tool {
+	return mcp.NewTool("batch_search",
+		mcp.WithDescription(fmt.Sprintf("Run several search_codebase queries in one call, up to %d, and return each one's results. Use it to look up many symbols or topics at once.", maxBatchQueries)),
+		mcp.WithToolAnnotation(readOnlyAnnotation),
+		mcp.WithArray("queries",
+			mcp.Required(),
+			mcp.Description("Natural language or keyword queries to search the codebase for"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithNumber("k",
+			mcp.Description("Maximum number of chunks to return per query (default 10)"),
+		),
+		mcp.WithString("path_prefix",
+			mcp.Description("Optional path prefix to scope results (e.g. 'services/payments/'), relative to the project root"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Result format: 'markdown' (default) and 'compact' are as in search_codebase, one section per query; 'json' returns an object of each query's results, keyed by query, in search_codebase's json shape"),
+			mcp.Enum("markdown", "json", "compact"),
+		),
 	)
 }
 
@@ -110,9 +345,192 @@ func listIndexedFilesTool() mcp.Tool {
 	)
 }
 
+func getRelatedFilesTool() mcp.Tool {
+	return mcp.NewTool("get_related_files",
+		mcp.WithDescription("Find the files most related to a given file, by similarity of their LLM-generated summaries. Useful for orientation: what else is involved in the same feature or layer."),
+		mcp.WithToolAnnotation(readOnlyAnnotation),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("File path as indexed (relative to the project root)"),
+		),
+		mcp.WithNumber("k",
+			mcp.Description("Maximum number of files to return (default 10)"),
+		),
+	)
+}
+
+func getSymbolRelationshipsTool() mcp.Tool {
+	return mcp.NewTool("get_symbol_relationships",
+		mcp.WithDescription("Show where a symbol is defined and which functions and files refer to it: an approximate call graph. Found by keyword search for the symbol's name, not static analysis, so same-named symbols and comments count as references."),
+		mcp.WithToolAnnotation(readOnlyAnnotation),
+		mcp.WithString("symbol",
+			mcp.Required(),
+			mcp.Description("Exact symbol name, e.g. 'HybridRetrieve' (not qualified)"),
+		),
+		mcp.WithNumber("k",
+			mcp.Description(fmt.Sprintf("Maximum number of referring chunks to return (default %d)", rag.DefaultReferenceLimit)),
+		),
+	)
+}
+
+func verifyAnswerTool() mcp.Tool {
+	return mcp.NewTool("verify_answer",
+		mcp.WithDescription("Check an answer about the codebase for files and symbols that aren't in the code retrieved for its question, a sign they were made up. A heuristic: it checks mentions, not the claims made about them."),
+		mcp.WithToolAnnotation(readOnlyAnnotation),
+		mcp.WithString("question",
+			mcp.Required(),
+			mcp.Description("The question the answer responds to; code is retrieved for it as by search_codebase"),
+		),
+		mcp.WithString("answer",
+			mcp.Required(),
+			mcp.Description("The answer to check, in Markdown"),
+		),
+		mcp.WithNumber("k",
+			mcp.Description("Number of chunks to retrieve for the question (default 10)"),
+		),
+	)
+}
+
+// --- Prompt schema builders ---
+
+func suggestEditTool() mcp.Tool {
+	return mcp.NewTool("suggest_edit",
+		mcp.WithDescription("Propose a change to one indexed file as a unified diff, drafted by the local chat model from the file and code retrieved for the instruction. Nothing is written: the diff is returned, with a check of whether it applies cleanly to the file as it is on disk. Review it before applying it."),
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			ReadOnlyHint:    mcp.ToBoolPtr(true),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("Path of the file to change, relative to the project root, as returned by other tools"),
+		),
+		mcp.WithString("instruction",
+			mcp.Required(),
+			mcp.Description("The change to make, e.g. 'return an error instead of panicking when the config is missing'"),
+		),
+	)
+}
+
+func explainFilePrompt() mcp.Prompt {
+	return mcp.NewPrompt("explain_file",
+		mcp.WithPromptDescription("Explain what an indexed file does, using its summary and most relevant code."),
+		mcp.WithArgument("path",
+			mcp.RequiredArgument(),
+			mcp.ArgumentDescription("File path as indexed (relative to the project root)"),
+		),
+	)
+}
+
+func codeReviewPrompt() mcp.Prompt {
+	return mcp.NewPrompt("code_review",
+		mcp.WithPromptDescription("Review an indexed file for bugs, risky patterns, and readability."),
+		mcp.WithArgument("path",
+			mcp.RequiredArgument(),
+			mcp.ArgumentDescription("File path as indexed (relative to the project root)"),
+		),
+		mcp.WithArgument("focus",
+			mcp.ArgumentDescription("Optional aspect to concentrate on, e.g. 'error handling' or 'concurrency'"),
+		),
+	)
+}
+
+// explainChunks is how many of a file's chunks the explain_file prompt
+// includes.
+const explainChunks = 5
+
+const codeReviewInstructions = `Review the file below as an experienced maintainer of this codebase would.
+
+Look for bugs and edge cases, error handling gaps, concurrency or resource leaks, security issues, and code that is hard to follow. For each finding give the line or symbol, why it matters, and a concrete fix. Skip style nits a formatter would catch. If the file looks fine, say so briefly.`
+
+// --- Prompt handlers ---
+
+func makeExplainFileHandler(st store.Store, emb embedder.Embedder) mcpserver.PromptHandlerFunc {
+	return func(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		path := store.NormalizePath(req.Params.Arguments["path"])
+		if path == "" {
+			return nil, fmt.Errorf("path is required")
+		}
+		f, err := findIndexedFile(st, path)
+		if err != nil {
+			return nil, err
+		}
+
+		// The chunks closest to the summary are the file's core; without a
+		// summary, rank them against the path.
+		query := f.Summary
+		if query == "" {
+			query = f.Path
+		}
+		retriever := rag.NewRetriever(st, emb, rag.Options{
+			K:      explainChunks * 2,
+			Filter: store.Filter{PathPrefix: f.Path},
+		})
+		results, err := retriever.Retrieve(query)
+		if err != nil {
+			return nil, fmt.Errorf("retrieve chunks: %w", err)
+		}
+		var chunks []store.SearchResult
+		for _, r := range results {
+			if r.FilePath == f.Path && len(chunks) < explainChunks {
+				chunks = append(chunks, r)
+			}
+		}
+
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "Explain what `%s` does: its purpose, its main types and functions, and how it fits into the rest of the project. Reference line numbers where helpful.\n\n", f.Path)
+		if f.Summary != "" {
+			fmt.Fprintf(&sb, "## Summary\n\n%s\n\n", f.Summary)
+		}
+		sb.WriteString("## Key code\n\n")
+		for _, c := range chunks {
+			fmt.Fprintf(&sb, "%s `%s` (lines %d–%d):\n\n```%s\n%s\n```\n\n",
+				c.Chunk.Kind, c.Chunk.Name, c.Chunk.StartLine, c.Chunk.EndLine, strings.ToLower(c.Language), c.Chunk.Content)
+		}
+
+		return mcp.NewGetPromptResult(
+			fmt.Sprintf("Explain %s", f.Path),
+			[]mcp.PromptMessage{mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(sb.String()))},
+		), nil
+	}
+}
+
+func makeCodeReviewHandler(st store.Store) mcpserver.PromptHandlerFunc {
+	return func(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		path := store.NormalizePath(req.Params.Arguments["path"])
+		if path == "" {
+			return nil, fmt.Errorf("path is required")
+		}
+		f, err := findIndexedFile(st, path)
+		if err != nil {
+			return nil, err
+		}
+		content, err := st.GetAllFileContent(f.Path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", f.Path, err)
+		}
+
+		var sb strings.Builder
+		sb.WriteString(codeReviewInstructions)
+		if focus := strings.TrimSpace(req.Params.Arguments["focus"]); focus != "" {
+			fmt.Fprintf(&sb, "\n\nConcentrate on: %s.", focus)
+		}
+		if f.Summary != "" {
+			fmt.Fprintf(&sb, "\n\n## What the file does\n\n%s", f.Summary)
+		}
+		fmt.Fprintf(&sb, "\n\n## %s\n\n```%s\n%s\n```\n", f.Path, strings.ToLower(f.Language), content)
+
+		return mcp.NewGetPromptResult(
+			fmt.Sprintf("Review %s", f.Path),
+			[]mcp.PromptMessage{mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(sb.String()))},
+		), nil
+	}
+}
+
 // --- Handler factories ---
 
-func makeSearchHandler(st store.Store, emb *embedder.OllamaEmbedder) mcpserver.ToolHandlerFunc {
+func makeSearchHandler(st store.Store, emb embedder.Embedder, cache *rag.Cache, log *rag.QueryLog) mcpserver.ToolHandlerFunc {
 	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		query := req.GetString("query", "")
 		if query == "" {
@@ -123,12 +541,115 @@ func makeSearchHandler(st store.Store, emb *embedder.OllamaEmbedder) mcpserver.T
 			k = 10
 		}
 
-		chunks, err := rag.HybridRetrieve(query, st, emb, k)
+		retriever := rag.NewRetriever(st, emb, rag.Options{
+			K:         k,
+			Filter:    store.Filter{PathPrefix: req.GetString("path_prefix", "")},
+			Weighting: queryWeighting(),
+			Recency:   flagRecencyWeight,
+		})
+		retriever.Cache = cache
+		retriever.Log = log
+		chunks, err := retriever.Retrieve(query)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("search failed: %v", err)), nil
+		}
+
+		switch format := req.GetString("format", "markdown"); format {
+		case "markdown":
+			return mcp.NewToolResultText(formatSearchResults(query, chunks)), nil
+		case "json":
+			data, err := json.MarshalIndent(searchOutput{Query: query, Results: toResultJSON(chunks)}, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			return mcp.NewToolResultText(string(data)), nil
+		case "compact":
+			return mcp.NewToolResultText(formatCompactResults(query, chunks)), nil
+		default:
+			return mcp.NewToolResultError(fmt.Sprintf("unknown format %q (want markdown, json, or compact)", format)), nil
+		}
+	}
+}
+
+func makeBatchSearchHandler(st store.Store, emb embedder.Embedder, cache *rag.Cache, log *rag.QueryLog) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var queries []string
+		for _, q := range req.GetStringSlice("queries", nil) {
+			if q = strings.TrimSpace(q); q != "" {
+				queries = append(queries, q)
+			}
+		}
+		if len(queries) == 0 {
+			return mcp.NewToolResultError("queries is required"), nil
+		}
+		if len(queries) > maxBatchQueries {
+			return mcp.NewToolResultError(fmt.Sprintf("at most %d queries can be searched at once, got %d", maxBatchQueries, len(queries))), nil
+		}
+		k := req.GetInt("k", 10)
+		if k <= 0 {
+			k = 10
+		}
+
+		retriever := rag.NewRetriever(st, emb, rag.Options{
+			K:         k,
+			Filter:    store.Filter{PathPrefix: req.GetString("path_prefix", "")},
+			Weighting: queryWeighting(),
+			Recency:   flagRecencyWeight,
+		})
+		retriever.Cache = cache
+		retriever.Log = log
+		batch, err := rag.RetrieveBatch(queries, retriever.Retrieve, batchConcurrency)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("search failed: %v", err)), nil
+		}
+
+		formatResults := formatSearchResults
+		switch format := req.GetString("format", "markdown"); format {
+		case "markdown":
+		case "json":
+			data, err := json.MarshalIndent(batchOutput(batch), "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			return mcp.NewToolResultText(string(data)), nil
+		case "compact":
+			formatResults = formatCompactResults
+		default:
+			return mcp.NewToolResultError(fmt.Sprintf("unknown format %q (want markdown, json, or compact)", format)), nil
+		}
+		sections := make([]string, len(batch))
+		for i, b := range batch {
+			sections[i] = strings.TrimSpace(formatResults(b.Query, b.Results))
+		}
+		return mcp.NewToolResultText(strings.Join(sections, "\n\n")), nil
+	}
+}
+
+func makeSearchFilesHandler(st store.Store, emb embedder.Embedder, cache *rag.Cache, log *rag.QueryLog) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		query := req.GetString("query", "")
+		if query == "" {
+			return mcp.NewToolResultError("query is required"), nil
+		}
+		k := req.GetInt("k", 3)
+		if k <= 0 {
+			k = 3
+		}
+
+		retriever := rag.NewRetriever(st, emb, rag.Options{
+			K:         k * rag.FileChunkPool,
+			Filter:    store.Filter{PathPrefix: req.GetString("path_prefix", "")},
+			Weighting: queryWeighting(),
+			Recency:   flagRecencyWeight,
+		})
+		retriever.Cache = cache
+		retriever.Log = log
+		files, err := rag.RetrieveFiles(query, retriever.Retrieve, st, k, req.GetInt("max_bytes", 0))
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("search failed: %v", err)), nil
 		}
 
-		return mcp.NewToolResultText(formatSearchResults(query, chunks)), nil
+		return mcp.NewToolResultText(formatFileResults(query, files)), nil
 	}
 }
 
@@ -139,39 +660,29 @@ func makeFileSummaryHandler(st store.Store) mcpserver.ToolHandlerFunc {
 			return mcp.NewToolResultError("path is required"), nil
 		}
 
-		files, err := st.ListFiles()
+		f, err := findIndexedFile(st, path)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("list files failed: %v", err)), nil
+			return mcp.NewToolResultError(err.Error()), nil
 		}
-
-		for _, f := range files {
-			if f.Path == path {
-				summary := f.Summary
-				if summary == "" {
-					summary = "(No summary generated yet)"
-				}
-				return mcp.NewToolResultText(fmt.Sprintf("## %s\n\n**Language:** %s  \n**Chunks:** %d\n\n%s",
-					f.Path, f.Language, f.Chunks, summary)), nil
-			}
+		summary := f.Summary
+		if summary == "" {
+			summary = "(No summary generated yet)"
 		}
-
-		return mcp.NewToolResultError(fmt.Sprintf("file %q not found in index — call list_indexed_files to see available paths", path)), nil
+		return mcp.NewToolResultText(fmt.Sprintf("## %s\n\n**Language:** %s  \n**Chunks:** %d\n\n%s",
+			f.Path, f.Language, f.Chunks, summary)), nil
 	}
 }
 
-func makeOverviewHandler(overviewPath string) mcpserver.ToolHandlerFunc {
+func makeOverviewHandler(st store.Store, dbPath string) mcpserver.ToolHandlerFunc {
 	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		data, err := os.ReadFile(overviewPath)
+		overview, err := index.LoadOverview(st, dbPath)
 		if err != nil {
-			if os.IsNotExist(err) {
-				return mcp.NewToolResultText("No overview available yet. Run 'synapse index <path>' to generate one."), nil
-			}
 			return mcp.NewToolResultError(fmt.Sprintf("read overview failed: %v", err)), nil
 		}
-		if len(data) == 0 {
-			return mcp.NewToolResultText("Overview file exists but is empty."), nil
+		if overview == "" {
+			return mcp.NewToolResultText("No overview available yet. Run 'synapse index <path>' to generate one."), nil
 		}
-		return mcp.NewToolResultText(string(data)), nil
+		return mcp.NewToolResultText(overview), nil
 	}
 }
 
@@ -216,6 +727,154 @@ func makeListFilesHandler(st store.Store) mcpserver.ToolHandlerFunc {
 	}
 }
 
+func makeVerifyAnswerHandler(st store.Store, emb embedder.Embedder, cache *rag.Cache) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		question := req.GetString("question", "")
+		answer := req.GetString("answer", "")
+		if question == "" || answer == "" {
+			return mcp.NewToolResultError("question and answer are required"), nil
+		}
+		k := req.GetInt("k", 10)
+		if k <= 0 {
+			k = 10
+		}
+
+		retriever := rag.NewRetriever(st, emb, rag.Options{K: k, Weighting: queryWeighting(), Recency: flagRecencyWeight})
+		retriever.Cache = cache
+		chunks, err := retriever.Retrieve(question)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("search failed: %v", err)), nil
+		}
+
+		refs, checked := rag.Ungrounded(answer, chunks)
+		if len(refs) == 0 {
+			return mcp.NewToolResultText(fmt.Sprintf("All %d files and symbols the answer mentions appear in the %d chunks retrieved for the question.", checked, len(chunks))), nil
+		}
+		return mcp.NewToolResultText(rag.GroundingWarning(refs)), nil
+	}
+}
+
+func makeSuggestEditHandler(st store.Store, emb embedder.Embedder, cache *rag.Cache, root string) mcpserver.ToolHandlerFunc {
+	chat := newChat(flagChatModel)
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path := req.GetString("path", "")
+		instruction := req.GetString("instruction", "")
+		if path == "" || instruction == "" {
+			return mcp.NewToolResultError("path and instruction are required"), nil
+		}
+
+		retriever := rag.NewRetriever(st, emb, rag.Options{K: 10, Weighting: queryWeighting(), Recency: flagRecencyWeight})
+		retriever.Cache = cache
+		s, err := suggestEdit(st, root, retriever.Retrieve, chat, store.NormalizePath(path), instruction, rag.DefaultContextBudget)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if s.Diff == "" {
+			return mcp.NewToolResultText("The model gave no diff:\n\n" + s.Answer), nil
+		}
+
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "```diff\n%s```\n\n", s.Diff)
+		switch {
+		case errors.Is(s.Check, rag.ErrNoPatch):
+			fmt.Fprintf(&sb, "Note: %v.\n", s.Check)
+		case s.Check != nil:
+			fmt.Fprintf(&sb, "Warning: %v\n", s.Check)
+		default:
+			fmt.Fprintf(&sb, "The diff applies cleanly to %s. It hasn't been applied.\n", path)
+		}
+		return mcp.NewToolResultText(sb.String()), nil
+	}
+}
+
+func makeRelatedFilesHandler(st store.Store) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path := store.NormalizePath(req.GetString("path", ""))
+		if path == "" {
+			return mcp.NewToolResultError("path is required"), nil
+		}
+		k := req.GetInt("k", 10)
+		if k <= 0 {
+			k = 10
+		}
+
+		related, err := st.RelatedFiles(path, k)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("related files failed: %v", err)), nil
+		}
+		if len(related) == 0 {
+			return mcp.NewToolResultText(fmt.Sprintf("No related files found for %q.", path)), nil
+		}
+
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "## Files related to %s (%d)\n\n", path, len(related))
+		for _, r := range related {
+			fmt.Fprintf(&sb, "- **%s** (%s, relevance %s) — %s\n", r.Path, r.Language, formatRelevance(r.Relevance), r.Summary)
+		}
+		return mcp.NewToolResultText(sb.String()), nil
+	}
+}
+
+func makeSymbolRelationshipsHandler(st store.Store) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		symbol := strings.TrimSpace(req.GetString("symbol", ""))
+		if symbol == "" {
+			return mcp.NewToolResultError("symbol is required"), nil
+		}
+		rel, err := rag.SymbolRelationships(st, symbol, req.GetInt("k", rag.DefaultReferenceLimit))
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("symbol relationships failed: %v", err)), nil
+		}
+		if len(rel.Definitions) == 0 && len(rel.Referrers) == 0 {
+			return mcp.NewToolResultText(fmt.Sprintf("No definitions or references found for %q.", symbol)), nil
+		}
+
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "## Relationships of %s\n\n", symbol)
+		if len(rel.Definitions) == 0 {
+			sb.WriteString("No definition indexed.\n")
+		} else {
+			sb.WriteString("Defined in:\n")
+			for _, d := range rel.Definitions {
+				fmt.Fprintf(&sb, "- %s:%d-%d (%s)\n", d.FilePath, d.Chunk.StartLine, d.Chunk.EndLine, d.Chunk.Kind)
+			}
+		}
+		if len(rel.Referrers) == 0 {
+			sb.WriteString("\nNo references found.\n")
+			return mcp.NewToolResultText(sb.String()), nil
+		}
+		fmt.Fprintf(&sb, "\nReferenced from %d chunks in %d files:\n", rel.Referrals(), len(rel.Referrers))
+		for _, g := range rel.Referrers {
+			refs := make([]string, len(g.Chunks))
+			for i, c := range g.Chunks {
+				name := c.Chunk.Name
+				if name == "" {
+					name = c.Chunk.Kind
+				}
+				refs[i] = fmt.Sprintf("%s (%d-%d)", name, c.Chunk.StartLine, c.Chunk.EndLine)
+			}
+			fmt.Fprintf(&sb, "- %s: %s\n", g.Path, strings.Join(refs, ", "))
+		}
+		sb.WriteString("\nReferences are keyword matches of the name, not static analysis.\n")
+		return mcp.NewToolResultText(sb.String()), nil
+	}
+}
+
+// findIndexedFile returns the indexed file at path, or an error pointing to
+// list_indexed_files.
+func findIndexedFile(st store.Store, path string) (*store.FileSummary, error) {
+	files, err := st.ListFiles()
+	if err != nil {
+		return nil, fmt.Errorf("list files failed: %v", err)
+	}
+	for _, f := range files {
+		if f.Path == path {
+			return &f, nil
+		}
+	}
+	return nil, fmt.Errorf("file %q not found in index — call list_indexed_files to see available paths", path)
+}
+
 // --- Formatting helpers ---
 
 func formatSearchResults(query string, chunks []store.SearchResult) string {
@@ -223,14 +882,72 @@ func formatSearchResults(query string, chunks []store.SearchResult) string {
 		return fmt.Sprintf("No results found for query: %q", query)
 	}
 
+	groups := rag.GroupByFile(chunks)
 	var sb strings.Builder
-	fmt.Fprintf(&sb, "## Search results for %q (%d chunks)\n\n", query, len(chunks))
+	fmt.Fprintf(&sb, "## Search results for %q (%d chunks in %d files)\n\n", query, len(chunks), len(groups))
+
+	for i, g := range groups {
+		fmt.Fprintf(&sb, "### File %d: `%s` (%s)\n\n", i+1, g.Path, g.Language)
+		for _, c := range g.Chunks {
+			fmt.Fprintf(&sb, "**Lines %d–%d:** %s `%s`", c.Chunk.StartLine, c.Chunk.EndLine, c.Chunk.Kind, c.Chunk.Name)
+			if c.Relevance != nil {
+				fmt.Fprintf(&sb, " · relevance %s", formatRelevance(*c.Relevance))
+			}
+			sb.WriteString("\n\n")
+			fmt.Fprintf(&sb, "```%s\n%s\n```\n\n", strings.ToLower(c.Language), c.Chunk.Content)
+		}
+	}
 
-	for i, c := range chunks {
-		fmt.Fprintf(&sb, "### Result %d: `%s`\n\n", i+1, c.FilePath)
-		fmt.Fprintf(&sb, "**Kind:** %s  \n**Name:** %s  \n**Lines:** %d–%d  \n**Language:** %s\n\n",
-			c.Chunk.Kind, c.Chunk.Name, c.Chunk.StartLine, c.Chunk.EndLine, c.Language)
-		fmt.Fprintf(&sb, "```%s\n%s\n```\n\n", strings.ToLower(c.Language), c.Chunk.Content)
+	return sb.String()
+}
+
+// formatCompactResults lists each chunk as "path:lines kind name" with its
+// relevance and first line of code, for agents that want few tokens.
+func formatCompactResults(query string, chunks []store.SearchResult) string {
+	if len(chunks) == 0 {
+		return fmt.Sprintf("No results found for query: %q", query)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d results for %q:\n", len(chunks), query)
+	for _, c := range chunks {
+		fmt.Fprintf(&sb, "%s:%d-%d %s", c.FilePath, c.Chunk.StartLine, c.Chunk.EndLine, strings.TrimSpace(c.Chunk.Kind+" "+c.Chunk.Name))
+		if c.Relevance != nil {
+			fmt.Fprintf(&sb, " (%s)", formatRelevance(*c.Relevance))
+		}
+		if snippet := chunkSnippet(c.Chunk); snippet != "" {
+			fmt.Fprintf(&sb, " — %s", truncate(snippet, 120))
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// chunkSnippet returns the first non-blank source line of c.
+func chunkSnippet(c store.Chunk) string {
+	for _, line := range strings.Split(rag.ChunkCode(c), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+func formatFileResults(query string, files []rag.FileResult) string {
+	if len(files) == 0 {
+		return fmt.Sprintf("No files found for query: %q", query)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## Files matching %q (%d)\n\n", query, len(files))
+
+	for i, f := range files {
+		fmt.Fprintf(&sb, "### File %d: `%s`\n\n", i+1, f.Path)
+		fmt.Fprintf(&sb, "**Language:** %s  \n**Matching chunks:** %d\n\n", f.Language, f.Chunks)
+		fmt.Fprintf(&sb, "```%s\n%s\n```\n\n", strings.ToLower(f.Language), strings.TrimRight(f.Content, "\n"))
+		if f.Truncated {
+			sb.WriteString("(truncated to fit max_bytes)\n\n")
+		}
 	}
 
 	return sb.String()
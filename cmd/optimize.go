@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var optimizeCmd = &cobra.Command{
+	Use:     "optimize",
+	Aliases: []string{"vacuum"},
+	Short:   "Compact the index database and its full-text index",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		st, dbPath, err := openIndex()
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+
+		before := dbSize(dbPath)
+		if err := st.Optimize(); err != nil {
+			return err
+		}
+		after := dbSize(dbPath)
+
+		fmt.Printf("Optimized %s: %.1f MB -> %.1f MB\n", dbPath, float64(before)/(1<<20), float64(after)/(1<<20))
+		return nil
+	},
+}
+
+// dbSize returns the size of the database including its write-ahead log.
+func dbSize(dbPath string) int64 {
+	var total int64
+	for _, p := range []string{dbPath, dbPath + "-wal"} {
+		if info, err := os.Stat(p); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+func init() {
+	rootCmd.AddCommand(optimizeCmd)
+}
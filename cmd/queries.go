@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"synapse/internal/format"
+	"synapse/internal/rag"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagQueriesZero        bool
+	flagQueriesMinDistance float64
+	flagQueriesLimit       int
+)
+
+var queriesCmd = &cobra.Command{
+	Use:   "queries",
+	Short: "Report logged queries, e.g. those that found nothing",
+	Long: `Summarize the queries recorded with --log-queries, most frequent first, to find
+gaps in what's indexed. The log is a local file next to the index and is never sent
+anywhere.
+
+--zero and --min-distance narrow the report to queries that found nothing or only
+weak matches; with both, a query is shown if it matches either.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := format.Parse(flagOutput)
+		if err != nil {
+			return err
+		}
+		dbPath, err := resolveDBPath()
+		if err != nil {
+			return err
+		}
+		records, err := rag.ReadQueryLog(rag.QueryLogPath(dbPath))
+		if err != nil {
+			return fmt.Errorf("read query log: %w", err)
+		}
+		if len(records) == 0 {
+			fmt.Fprintf(os.Stderr, "No queries logged for %s; run chat, search, or mcp with --log-queries to record them\n", dbPath)
+		}
+
+		summaries := summarizeQueries(records)
+		filtered := summaries[:0]
+		for _, q := range summaries {
+			if q.matches(flagQueriesZero, flagQueriesMinDistance) {
+				filtered = append(filtered, q)
+			}
+		}
+		if flagQueriesLimit > 0 && len(filtered) > flagQueriesLimit {
+			filtered = filtered[:flagQueriesLimit]
+		}
+		return format.Render(os.Stdout, out, filtered, queriesTable(filtered))
+	},
+}
+
+// querySummary aggregates the logged runs of one query. Results and
+// BestDistance are from its latest run.
+type querySummary struct {
+	Query        string    `json:"query"`
+	Count        int       `json:"count"`
+	Results      int       `json:"results"`
+	BestDistance *float64  `json:"best_distance,omitempty"`
+	LastSearched time.Time `json:"last_searched"`
+}
+
+// matches reports whether the query passes the --zero and --min-distance
+// filters. With neither set, every query does.
+func (q querySummary) matches(zero bool, minDistance float64) bool {
+	if !zero && minDistance <= 0 {
+		return true
+	}
+	if zero && q.Results == 0 {
+		return true
+	}
+	return minDistance > 0 && q.BestDistance != nil && *q.BestDistance >= minDistance
+}
+
+// summarizeQueries groups records by query, ignoring case and spacing, and
+// sorts them by how often they were run, most recent first on ties.
+func summarizeQueries(records []rag.QueryRecord) []querySummary {
+	byQuery := make(map[string]*querySummary)
+	for _, rec := range records {
+		key := strings.Join(strings.Fields(strings.ToLower(rec.Query)), " ")
+		q, ok := byQuery[key]
+		if !ok {
+			q = &querySummary{Query: rec.Query}
+			byQuery[key] = q
+		}
+		q.Count++
+		if !rec.Time.Before(q.LastSearched) {
+			q.Results = rec.Results
+			q.BestDistance = rec.BestDistance
+			q.LastSearched = rec.Time
+		}
+	}
+	summaries := make([]querySummary, 0, len(byQuery))
+	for _, q := range byQuery {
+		summaries = append(summaries, *q)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+		return summaries[i].LastSearched.After(summaries[j].LastSearched)
+	})
+	return summaries
+}
+
+func queriesTable(summaries []querySummary) format.Tabular {
+	tab := format.Tabular{Columns: []string{"Count", "Results", "Best distance", "Last searched", "Query"}}
+	for _, q := range summaries {
+		dist := "-"
+		if q.BestDistance != nil {
+			dist = fmt.Sprintf("%.3f", *q.BestDistance)
+		}
+		tab.Rows = append(tab.Rows, []string{
+			fmt.Sprint(q.Count),
+			fmt.Sprint(q.Results),
+			dist,
+			q.LastSearched.Local().Format("2006-01-02 15:04"),
+			q.Query,
+		})
+	}
+	return tab
+}
+
+func init() {
+	queriesCmd.Flags().BoolVar(&flagQueriesZero, "zero", false, "only show queries whose latest run returned no results")
+	queriesCmd.Flags().Float64Var(&flagQueriesMinDistance, "min-distance", 0, "only show queries whose closest vector match was at least this far (low relevance)")
+	queriesCmd.Flags().IntVar(&flagQueriesLimit, "limit", 0, "show at most this many queries (0 = all)")
+	addOutputFlag(queriesCmd)
+	rootCmd.AddCommand(queriesCmd)
+}
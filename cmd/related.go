@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"synapse/internal/format"
+	"synapse/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var flagRelatedK int
+
+var relatedCmd = &cobra.Command{
+	Use:   "related <path>",
+	Short: "List files whose summaries are most similar to the given file's",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := format.Parse(flagOutput)
+		if err != nil {
+			return err
+		}
+
+		st, dbPath, err := openIndex()
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+
+		path := store.NormalizePath(args[0])
+		related, err := st.RelatedFiles(path, flagRelatedK)
+		if err != nil {
+			return err
+		}
+
+		if len(related) == 0 && out != format.JSON && out != format.Patch {
+			fmt.Printf("No related files found for %q\n", path)
+			return nil
+		}
+		return format.Render(os.Stdout, out,
+			relatedOutput{Path: path, Related: toRelatedJSON(related)},
+			relatedTable(related, projectRoot(st, dbPath)))
+	},
+}
+
+// relatedOutput is the JSON shape of 'synapse related'.
+type relatedOutput struct {
+	Path    string        `json:"path"`
+	Related []relatedJSON `json:"related"`
+}
+
+type relatedJSON struct {
+	Path      string  `json:"path"`
+	Language  string  `json:"language"`
+	Distance  float64 `json:"distance"`
+	Relevance float64 `json:"relevance"`
+	Summary   string  `json:"summary"`
+}
+
+func toRelatedJSON(related []store.RelatedFile) []relatedJSON {
+	out := make([]relatedJSON, len(related))
+	for i, r := range related {
+		out[i] = relatedJSON{Path: r.Path, Language: r.Language, Distance: r.Distance, Relevance: r.Relevance, Summary: r.Summary}
+	}
+	return out
+}
+
+func relatedTable(related []store.RelatedFile, root string) format.Tabular {
+	tab := format.Tabular{
+		Columns:   []string{"#", "Path", "Language", "Relevance", "Summary"},
+		Locations: make([]format.Location, 0, len(related)),
+	}
+	for i, r := range related {
+		tab.Rows = append(tab.Rows, []string{
+			fmt.Sprint(i + 1),
+			r.Path,
+			r.Language,
+			formatRelevance(r.Relevance),
+			truncate(r.Summary, 80),
+		})
+		tab.Locations = append(tab.Locations, format.Location{Path: citePath(root, r.Path), Line: 1, Text: truncate(r.Summary, 120)})
+	}
+	return tab
+}
+
+// truncate shortens s to its first line and at most n bytes.
+func truncate(s string, n int) string {
+	for i, c := range s {
+		if c == '\n' {
+			s = s[:i]
+			break
+		}
+	}
+	if len(s) > n {
+		return s[:n] + "..."
+	}
+	return s
+}
+
+func init() {
+	relatedCmd.Flags().IntVar(&flagRelatedK, "k", 10, "maximum number of related files")
+	addOutputFlag(relatedCmd)
+	rootCmd.AddCommand(relatedCmd)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"synapse/internal/format"
+	"synapse/internal/index"
+
+	"github.com/spf13/cobra"
+)
+
+var flagRollbackList bool
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback [snapshot]",
+	Short: "Restore the index from a snapshot taken before an index run",
+	Long: `Replace the index with a snapshot that 'synapse index --snapshots N' saved in
+.synapse/snapshots/ before a run, undoing that run and any after it. Without an
+argument the most recent snapshot is restored; --list shows the others.
+
+Stop any 'synapse mcp' or daemon using the index first. The current index is
+replaced, not kept; snapshots are left in place.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dbPath, err := resolveDBPath()
+		if err != nil {
+			return err
+		}
+
+		if flagRollbackList {
+			snaps, err := index.ListSnapshots(dbPath)
+			if err != nil {
+				return err
+			}
+			if len(snaps) == 0 {
+				fmt.Fprintf(os.Stderr, "No snapshots in %s; index with --snapshots N to take them\n", index.SnapshotsDir(dbPath))
+				return nil
+			}
+			return format.Render(os.Stdout, format.Table, nil, snapshotsTable(snaps))
+		}
+
+		var name string
+		if len(args) > 0 {
+			name = args[0]
+		}
+		snap, err := index.RestoreSnapshot(dbPath, name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Restored %s from snapshot %s (taken %s)\n", dbPath, snap.Name, snap.Taken.Format("2006-01-02 15:04:05"))
+		return nil
+	},
+}
+
+func snapshotsTable(snaps []index.Snapshot) format.Tabular {
+	tab := format.Tabular{Columns: []string{"Snapshot", "Taken", "Size"}}
+	for _, s := range snaps {
+		tab.Rows = append(tab.Rows, []string{
+			s.Name,
+			s.Taken.Format("2006-01-02 15:04:05"),
+			fmt.Sprintf("%.1f MB", float64(s.Size)/(1<<20)),
+		})
+	}
+	return tab
+}
+
+func init() {
+	rollbackCmd.Flags().BoolVar(&flagRollbackList, "list", false, "list the snapshots, newest first, instead of restoring one")
+	rootCmd.AddCommand(rollbackCmd)
+}
@@ -1,21 +1,58 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"synapse/internal/config"
+	"synapse/internal/embedder"
+	"synapse/internal/llm"
+	"synapse/internal/rag"
+	"synapse/internal/store"
+	"synapse/internal/tui"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	flagDB        string
-	flagOllama    string
-	flagModel     string
-	flagChatModel string
+	flagDB            string
+	flagRoot          string
+	flagOllama        string
+	flagModel         string
+	flagChatModel     string
+	flagDebug         bool
+	flagCacheSize     int
+	flagFTSOnly       bool
+	flagLogQueries    bool
+	flagOutput        string
+	flagKeepAlive     time.Duration
+	flagQueryTimeout  time.Duration
+	flagNoAutodetect  bool
+	flagKeywordWords  int
+	flagSemanticWords int
+	flagQueryWeight   float64
+	flagRecencyWeight float64
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "synapse",
 	Short: "Local code intelligence powered by RAG",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// 'synapse config' must work when config.toml doesn't, to fix it.
+		for c := cmd; c != nil; c = c.Parent() {
+			if c == configCmd {
+				return nil
+			}
+		}
+		dbPath, err := configDBPath(cmd, args)
+		if err != nil {
+			return err
+		}
+		return applyConfig(cmd, dbPath)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runTUI()
 	},
@@ -27,9 +64,264 @@ func Execute() {
 	}
 }
 
+// newEmbedder returns the Ollama embedder given by the flags.
+func newEmbedder() *embedder.OllamaEmbedder {
+	emb := embedder.NewOllamaEmbedder(flagOllama, flagModel)
+	emb.KeepAlive = flagKeepAlive
+	emb.QueryTimeout = flagQueryTimeout
+	return emb
+}
+
+// newChat returns an Ollama chat client for model configured by the flags.
+func newChat(model string) *llm.OllamaChat {
+	chat := llm.NewOllamaChat(flagOllama, model)
+	chat.KeepAlive = flagKeepAlive
+	return chat
+}
+
+// queryWeighting returns the weighting of keyword and vector results given
+// by the flags.
+func queryWeighting() rag.Weighting {
+	return rag.Weighting{KeywordWords: flagKeywordWords, SemanticWords: flagSemanticWords, Weight: flagQueryWeight}
+}
+
+// debugWeights prints the weights retrieval gives keyword and vector
+// matches for query, with --debug.
+func debugWeights(query string) {
+	if flagDebug {
+		w := queryWeighting().For(query)
+		fmt.Fprintf(os.Stderr, "[debug] %s query: keyword weight %g, vector weight %g\n", w.Kind, w.Keyword, w.Vector)
+	}
+}
+
+// installedModels returns the models installed in Ollama, for
+// autodetectModel. It returns nil when --no-autodetect is set or Ollama
+// can't be reached; the command then fails connecting to it as usual.
+func installedModels() []tui.OllamaModel {
+	if flagNoAutodetect {
+		return nil
+	}
+	models, err := tui.ListModels(flagOllama)
+	if err != nil {
+		return nil
+	}
+	return models
+}
+
+// autodetectModel returns model if it's installed, and otherwise the first
+// of candidates that is, warning on stderr that it's used instead. kind
+// ("embedding" or "chat") names the model in the warning. With installed
+// empty, or no candidate installed, it returns model, which then fails as
+// it would have.
+func autodetectModel(installed []tui.OllamaModel, kind, model string, candidates []string) string {
+	if len(installed) == 0 || tui.HasModel(installed, model) {
+		return model
+	}
+	for _, c := range candidates {
+		if c != "" && tui.HasModel(installed, c) {
+			fmt.Fprintf(os.Stderr, "warning: %s model %s isn't installed in Ollama; using %s instead (--no-autodetect to fail instead)\n", kind, model, c)
+			return c
+		}
+	}
+	return model
+}
+
+// modelNames returns the names of the installed embedding models, or of the
+// chat models, going by tui.IsEmbedModel.
+func modelNames(installed []tui.OllamaModel, embed bool) []string {
+	var names []string
+	for _, m := range installed {
+		if tui.IsEmbedModel(m.Name) == embed {
+			names = append(names, m.Name)
+		}
+	}
+	return names
+}
+
+// resolveDBPath returns the --db flag value, or <cwd>/.synapse/index.db.
+func resolveDBPath() (string, error) {
+	if flagDB != "" {
+		return flagDB, nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(wd, ".synapse", "index.db"), nil
+}
+
+// indexPaths returns the directory 'index' and the TUI index, and the index
+// they store it in. The path argument or --root is indexed into its own
+// .synapse/index.db unless --db says otherwise. With neither, the index
+// resolveDBPath finds is updated from the tree recorded in it, and root is
+// "" if it has none.
+func indexPaths(args []string) (root, dbPath string, err error) {
+	switch {
+	case len(args) == 1:
+		root = args[0]
+	case flagRoot != "":
+		root = flagRoot
+	default:
+		if dbPath, err = resolveDBPath(); err != nil {
+			return "", "", err
+		}
+		return recordedRoot(dbPath), dbPath, nil
+	}
+	if root, err = filepath.Abs(root); err != nil {
+		return "", "", err
+	}
+	if dbPath = flagDB; dbPath == "" {
+		dbPath = filepath.Join(root, ".synapse", "index.db")
+	}
+	return root, dbPath, nil
+}
+
+// configDBPath returns the index whose config.toml applies to cmd: the one
+// 'index' or the TUI builds, or else the one resolveDBPath finds.
+func configDBPath(cmd *cobra.Command, args []string) (string, error) {
+	if cmd == indexCmd || !cmd.HasParent() {
+		_, dbPath, err := indexPaths(args)
+		return dbPath, err
+	}
+	return resolveDBPath()
+}
+
+// applyConfig sets the flags of cmd that weren't given on the command line
+// from the config.toml of the index at dbPath.
+func applyConfig(cmd *cobra.Command, dbPath string) error {
+	values, err := config.Load(config.Path(dbPath))
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	for name, value := range values {
+		key, _ := config.Lookup(name)
+		f := cmd.Flags().Lookup(key.Flag())
+		if f == nil || f.Changed {
+			continue
+		}
+		if err := f.Value.Set(value); err != nil {
+			return fmt.Errorf("config %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// openIndex opens an existing index, returning a hint to run 'synapse index'
+// if it hasn't been built yet.
+func openIndex() (*store.SQLiteStore, string, error) {
+	return openIndexWith(store.OpenOptions{})
+}
+
+// openReadOnlyIndex is like openIndex, but opens the index read-only for
+// commands that only query it, so they can run alongside 'synapse index'.
+func openReadOnlyIndex() (*store.SQLiteStore, string, error) {
+	return openIndexWith(store.OpenOptions{ReadOnly: true})
+}
+
+func openIndexWith(opts store.OpenOptions) (*store.SQLiteStore, string, error) {
+	dbPath, err := resolveDBPath()
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		return nil, "", fmt.Errorf("index not found at %s\nRun 'synapse index <path>' first to build the index", dbPath)
+	}
+	opts.FTSOnly = flagFTSOnly
+	st, err := store.OpenWith(dbPath, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("open index: %w", err)
+	}
+	if st.FTSRepaired() {
+		fmt.Fprintln(os.Stderr, "note: the keyword index was out of sync with the stored chunks and has been rebuilt")
+	}
+	return st, dbPath, nil
+}
+
+// projectRoot returns the directory the index's paths are relative to:
+// --root, the root recorded at index time, or for older indexes the parent
+// of the default .synapse directory.
+func projectRoot(st store.Store, dbPath string) string {
+	root, _ := st.ProjectRoot()
+	return rootOrDefault(root, dbPath)
+}
+
+// rootOrDefault returns --root if given, then root, or when it's empty the
+// parent of the default .synapse directory holding dbPath.
+func rootOrDefault(root, dbPath string) string {
+	if flagRoot != "" {
+		if abs, err := filepath.Abs(flagRoot); err == nil {
+			return abs
+		}
+		return flagRoot
+	}
+	if root != "" {
+		return root
+	}
+	abs, err := filepath.Abs(dbPath)
+	if err != nil {
+		abs = dbPath
+	}
+	return filepath.Dir(filepath.Dir(abs))
+}
+
+// recordedRoot returns the project root recorded in the index at dbPath, or
+// "" if there's no index there or it has none.
+func recordedRoot(dbPath string) string {
+	if _, err := os.Stat(dbPath); err != nil {
+		return ""
+	}
+	st, err := store.OpenWith(dbPath, store.OpenOptions{ReadOnly: true, FTSOnly: true})
+	if err != nil {
+		return ""
+	}
+	defer st.Close()
+	root, _ := st.ProjectRoot()
+	return root
+}
+
+// citePath resolves an indexed path against root and makes it relative to the
+// working directory when it's inside it, so citations open from the shell.
+func citePath(root, rel string) string {
+	abs := filepath.Join(root, filepath.FromSlash(rel))
+	wd, err := os.Getwd()
+	if err != nil {
+		return abs
+	}
+	if r, err := filepath.Rel(wd, abs); err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return r
+	}
+	return abs
+}
+
+// queryLog returns the log for queries against the index at dbPath, or nil
+// unless --log-queries is set.
+func queryLog(dbPath string) *rag.QueryLog {
+	if !flagLogQueries {
+		return nil
+	}
+	return rag.NewQueryLog(rag.QueryLogPath(dbPath))
+}
+
+// addOutputFlag registers the shared --output flag on commands that print
+// structured results.
+func addOutputFlag(c *cobra.Command) {
+	c.Flags().StringVarP(&flagOutput, "output", "o", "table", "output format: table, json, markdown, or patch")
+}
+
 func init() {
 	rootCmd.PersistentFlags().StringVar(&flagDB, "db", "", "database path (default <project>/.synapse/index.db)")
+	rootCmd.PersistentFlags().StringVar(&flagRoot, "root", "", "directory the indexed files are read from, when it isn't the one recorded at index time, e.g. after moving the tree; for 'index' and the TUI, the directory to index, whose .synapse/index.db is used unless --db is given")
 	rootCmd.PersistentFlags().StringVar(&flagOllama, "ollama", "http://localhost:11434", "ollama base URL")
 	rootCmd.PersistentFlags().StringVar(&flagModel, "model", "nomic-embed-text", "embedding model")
 	rootCmd.PersistentFlags().StringVar(&flagChatModel, "chat-model", "qwen3:8b", "generative model for chat")
+	rootCmd.PersistentFlags().DurationVar(&flagKeepAlive, "keep-alive", 0, "how long Ollama keeps models loaded between requests, e.g. 30m, so they aren't reloaded for each one (0 = Ollama's default of 5m; -1s = until Ollama stops)")
+	rootCmd.PersistentFlags().DurationVar(&flagQueryTimeout, "query-timeout", embedder.DefaultQueryTimeout, "how long embedding a search query or chat question may take before it fails, e.g. 1m for a model slow to load")
+	rootCmd.PersistentFlags().BoolVar(&flagDebug, "debug", false, "print retrieval diagnostics to stderr")
+	rootCmd.PersistentFlags().BoolVar(&flagFTSOnly, "fts-only", false, "open the index without the sqlite-vec extension: keyword search only, for platforms where it fails to load")
+	rootCmd.PersistentFlags().BoolVar(&flagLogQueries, "log-queries", false, "record queries and how many results they found in queries.jsonl next to the index (see 'synapse queries')")
+	rootCmd.PersistentFlags().IntVar(&flagKeywordWords, "keyword-query-words", rag.DefaultKeywordQueryWords, "queries of at most this many words that name an identifier (camelCase, snake_case, pkg.Func, ...) weigh keyword matches up by --query-weight")
+	rootCmd.PersistentFlags().IntVar(&flagSemanticWords, "semantic-query-words", rag.DefaultSemanticQueryWords, "queries of at least this many words without identifiers, or phrased as questions, weigh vector matches up by --query-weight")
+	rootCmd.PersistentFlags().Float64Var(&flagQueryWeight, "query-weight", rag.DefaultQueryWeight, "how much more keyword or vector matches count when the query's shape favors them (1 = weigh them alike for every query)")
+	rootCmd.PersistentFlags().Float64Var(&flagRecencyWeight, "recency-weight", 0, "from 0 to 1, how much results from recently modified files are favored: up to this fraction of a result's score is lost as its file ages, half of it per 30 days (0 = off)")
+	rootCmd.PersistentFlags().IntVar(&flagCacheSize, "query-cache", 0, "cache results for up to N recent queries (0 = disabled)")
 }
@@ -0,0 +1,433 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"synapse/internal/format"
+	"synapse/internal/llm"
+	"synapse/internal/rag"
+	"synapse/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagSearchK    int
+	flagSearchPath string
+	flagExpand     bool
+	flagWholeFiles bool
+	flagMaxBytes   int
+	flagIndexes    []string
+	flagQueriesIn  string
+	flagSearchJobs int
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query> | --queries-from <file>",
+	Short: "Search the index and print matching chunks",
+	Long: `Search the index with hybrid keyword and vector retrieval and print the matching
+chunks.
+
+With --whole-files, chunk matches are aggregated by file and the --k most relevant
+files are returned whole, up to --max-bytes of content (see the json output). This
+reads the index directly rather than through a running daemon.
+
+With --index given more than once, each of those indexes is searched instead of
+--db, for example one per service, and the results are merged by rank and tagged
+with the index they came from. The indexes must use the same embedding model.
+
+With --queries-from, each query in the file (one per line, or a JSON array) is
+searched in turn, --concurrency at a time, with one index and embedder for all of
+them. The json output is an object of results keyed by query; other formats list
+every query's results with a Query column.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if flagQueriesIn != "" && len(args) > 0 {
+			return fmt.Errorf("give queries as arguments or with --queries-from, not both")
+		}
+		if flagQueriesIn != "" {
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := format.Parse(flagOutput)
+		if err != nil {
+			return err
+		}
+		var queries []string
+		if flagQueriesIn != "" {
+			if flagWholeFiles {
+				return fmt.Errorf("--queries-from can't be combined with --whole-files")
+			}
+			if queries, err = rag.LoadQueries(flagQueriesIn); err != nil {
+				return err
+			}
+		}
+
+		query := strings.Join(args, " ")
+		opts := rag.Options{
+			K:         flagSearchK,
+			Filter:    store.Filter{PathPrefix: flagSearchPath},
+			Weighting: queryWeighting(),
+			Recency:   flagRecencyWeight,
+		}
+		if flagWholeFiles {
+			opts.K = flagSearchK * rag.FileChunkPool
+		}
+
+		dbPath, err := resolveDBPath()
+		if err != nil {
+			return err
+		}
+
+		var retrieve rag.RetrieveFunc
+		var root string
+		var roots map[string]string // by index name, for --index
+		var st store.Store
+		if len(flagIndexes) > 0 {
+			if flagWholeFiles {
+				return fmt.Errorf("--index can't be combined with --whole-files")
+			}
+			var sources []rag.Source
+			sources, roots, err = openSources(flagIndexes)
+			for _, src := range sources {
+				defer src.Store.Close()
+			}
+			if err != nil {
+				return err
+			}
+			retriever, err := rag.NewFederatedRetriever(sources, newEmbedder(), opts)
+			if err != nil {
+				return err
+			}
+			retrieve = retriever.Retrieve
+		} else if client := connectDaemon(dbPath); client != nil && !flagWholeFiles {
+			retrieve = func(q string) ([]store.SearchResult, error) {
+				return client.Retrieve(q, opts)
+			}
+			root = rootOrDefault(client.Root, dbPath)
+		} else {
+			sqlStore, _, openErr := openIndex()
+			if openErr != nil {
+				return openErr
+			}
+			defer sqlStore.Close()
+			st = sqlStore
+			emb := newEmbedder()
+			retriever := rag.NewRetriever(st, emb, opts)
+			retriever.Log = queryLog(dbPath)
+			retrieve = retriever.Retrieve
+			root = projectRoot(st, dbPath)
+		}
+		if flagExpand {
+			retrieve = expandRetrieve(retrieve, newChat(flagChatModel), opts.K)
+		}
+		if queries != nil {
+			batch, err := rag.RetrieveBatch(queries, retrieve, flagSearchJobs)
+			if err != nil {
+				return err
+			}
+			return format.Render(os.Stdout, out, batchOutput(batch), batchTable(batch, root, roots))
+		}
+		debugWeights(query)
+		if flagWholeFiles {
+			files, err := rag.RetrieveFiles(query, retrieve, st, flagSearchK, flagMaxBytes)
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 && out != format.JSON && out != format.Patch {
+				fmt.Printf("No results found for %q\n", query)
+				return nil
+			}
+			return format.Render(os.Stdout, out,
+				fileSearchOutput{Query: query, Files: toFileJSON(files)},
+				fileTable(files, root))
+		}
+
+		results, err := retrieve(query)
+		if err != nil {
+			return err
+		}
+
+		if len(results) == 0 && out != format.JSON && out != format.Patch {
+			fmt.Printf("No results found for %q\n", query)
+			return nil
+		}
+		tab := resultTable(results, root)
+		if roots != nil {
+			tab = federatedTable(results, roots)
+		}
+		return format.Render(os.Stdout, out,
+			searchOutput{Query: query, Results: toResultJSON(results)},
+			tab)
+	},
+}
+
+// openSources opens the indexes at paths for a search over all of them.
+// Each is named after its project directory, or by its path when another
+// has that name. It returns the sources opened so far even on error, for
+// the caller to close, and each one's project root by name.
+func openSources(paths []string) ([]rag.Source, map[string]string, error) {
+	var sources []rag.Source
+	roots := make(map[string]string)
+	opened := make(map[string]bool)
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			return sources, nil, fmt.Errorf("index not found at %s", p)
+		}
+		if abs, err := filepath.Abs(p); err == nil {
+			if opened[abs] {
+				return sources, nil, fmt.Errorf("index %s is given twice", p)
+			}
+			opened[abs] = true
+		}
+		st, err := store.OpenWith(p, store.OpenOptions{FTSOnly: flagFTSOnly})
+		if err != nil {
+			return sources, nil, fmt.Errorf("open index %s: %w", p, err)
+		}
+		root := projectRoot(st, p)
+		name := filepath.Base(root)
+		if _, taken := roots[name]; taken {
+			name = p
+		}
+		roots[name] = root
+		sources = append(sources, rag.Source{Name: name, Store: st})
+	}
+	return sources, roots, nil
+}
+
+// expandRetrieve wraps retrieve to first expand each query with the chat
+// model and fuse the results of every phrasing. If expansion fails, the query
+// is searched as is.
+func expandRetrieve(retrieve rag.RetrieveFunc, chat llm.Chat, k int) rag.RetrieveFunc {
+	return func(query string) ([]store.SearchResult, error) {
+		expansions, err := rag.ExpandQuery(query, chat)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v; searching without expansion\n", err)
+			return retrieve(query)
+		}
+		if flagDebug {
+			fmt.Fprintf(os.Stderr, "[debug] expanded query: %s\n", strings.Join(expansions, " | "))
+		}
+		return rag.RetrieveExpanded(query, expansions, k, retrieve)
+	}
+}
+
+// searchOutput is the JSON shape of 'synapse search'.
+type searchOutput struct {
+	Query   string       `json:"query"`
+	Results []resultJSON `json:"results"`
+}
+
+// resultJSON is the stable JSON representation of a retrieved chunk, shared
+// by every command that prints chunks.
+type resultJSON struct {
+	Path      string   `json:"path"`
+	Language  string   `json:"language"`
+	Kind      string   `json:"kind"`
+	Name      string   `json:"name"`
+	StartLine int      `json:"start_line"`
+	EndLine   int      `json:"end_line"`
+	Distance  float64  `json:"distance"`
+	Metric    string   `json:"metric,omitempty"`
+	Relevance *float64 `json:"relevance,omitempty"`
+	Score     float64  `json:"score"`
+	Content   string   `json:"content"`
+	// Index is the index the chunk came from, with --index.
+	Index string `json:"index,omitempty"`
+	// QualifiedName is Name prefixed with the type it belongs to, if any.
+	QualifiedName string `json:"qualified_name,omitempty"`
+}
+
+func toResultJSON(results []store.SearchResult) []resultJSON {
+	out := make([]resultJSON, len(results))
+	for i, r := range results {
+		out[i] = resultJSON{
+			Path:      r.FilePath,
+			Language:  r.Language,
+			Kind:      r.Chunk.Kind,
+			Name:      r.Chunk.Name,
+			StartLine: r.Chunk.StartLine,
+			EndLine:   r.Chunk.EndLine,
+			Distance:  r.Distance,
+			Metric:    r.Metric,
+			Relevance: r.Relevance,
+			Score:     r.Score,
+			Content:   r.Chunk.Content,
+			Index:     r.Source,
+
+			QualifiedName: r.Chunk.QualifiedName,
+		}
+	}
+	return out
+}
+
+// batchOutput is the JSON shape of 'synapse search --queries-from': each
+// query's results, keyed by query.
+func batchOutput(batch []rag.BatchResult) map[string][]resultJSON {
+	out := make(map[string][]resultJSON, len(batch))
+	for _, b := range batch {
+		out[b.Query] = toResultJSON(b.Results)
+	}
+	return out
+}
+
+// batchTable is resultTable, or federatedTable when roots is set, for the
+// results of several queries, with a Query column. Queries without vector
+// matches get "—" for Relevance when others have them.
+func batchTable(batch []rag.BatchResult, root string, roots map[string]string) format.Tabular {
+	tables := make([]format.Tabular, len(batch))
+	var columns []string
+	for i, b := range batch {
+		tables[i] = resultTable(b.Results, root)
+		if roots != nil {
+			tables[i] = federatedTable(b.Results, roots)
+		}
+		if len(tables[i].Columns) > len(columns) {
+			columns = tables[i].Columns
+		}
+	}
+	tab := format.Tabular{Columns: slices.Insert(slices.Clone(columns), 0, "Query")}
+	for i, t := range tables {
+		for _, row := range t.Rows {
+			for len(row) < len(columns) {
+				row = append(row, "—")
+			}
+			tab.Rows = append(tab.Rows, slices.Insert(row, 0, batch[i].Query))
+		}
+		tab.Locations = append(tab.Locations, t.Locations...)
+	}
+	return tab
+}
+
+// fileSearchOutput is the JSON shape of 'synapse search --whole-files'.
+type fileSearchOutput struct {
+	Query string     `json:"query"`
+	Files []fileJSON `json:"files"`
+}
+
+// fileJSON is the JSON representation of a whole-file search result.
+type fileJSON struct {
+	Path      string  `json:"path"`
+	Language  string  `json:"language"`
+	Score     float64 `json:"score"`
+	Chunks    int     `json:"chunks"`
+	Truncated bool    `json:"truncated"`
+	Content   string  `json:"content"`
+}
+
+func toFileJSON(files []rag.FileResult) []fileJSON {
+	out := make([]fileJSON, len(files))
+	for i, f := range files {
+		out[i] = fileJSON{
+			Path:      f.Path,
+			Language:  f.Language,
+			Score:     f.Score,
+			Chunks:    f.Chunks,
+			Truncated: f.Truncated,
+			Content:   f.Content,
+		}
+	}
+	return out
+}
+
+// fileTable lists whole-file results for table and markdown output, and a
+// location per file, resolved against root, for patch output.
+func fileTable(files []rag.FileResult, root string) format.Tabular {
+	tab := format.Tabular{
+		Columns:   []string{"#", "Path", "Score", "Chunks", "Bytes"},
+		Locations: make([]format.Location, 0, len(files)),
+	}
+	for i, f := range files {
+		size := fmt.Sprint(len(f.Content))
+		if f.Truncated {
+			size += " (truncated)"
+		}
+		tab.Rows = append(tab.Rows, []string{
+			fmt.Sprint(i + 1),
+			f.Path,
+			fmt.Sprintf("%.4f", f.Score),
+			fmt.Sprint(f.Chunks),
+			size,
+		})
+		tab.Locations = append(tab.Locations, format.Location{Path: citePath(root, f.Path), Line: 1, Text: f.Language})
+	}
+	return tab
+}
+
+// resultTable lists results for table and markdown output, and their
+// locations, resolved against root, for patch output. A Relevance column is
+// added when some result is a vector match.
+func resultTable(results []store.SearchResult, root string) format.Tabular {
+	tab := format.Tabular{
+		Columns:   []string{"#", "Path", "Lines", "Kind", "Name"},
+		Locations: resultLocations(results, root),
+	}
+	scored := slices.ContainsFunc(results, func(r store.SearchResult) bool { return r.Relevance != nil })
+	if scored {
+		tab.Columns = append(tab.Columns, "Relevance")
+	}
+	for i, r := range results {
+		row := []string{
+			fmt.Sprint(i + 1),
+			r.FilePath,
+			fmt.Sprintf("%d-%d", r.Chunk.StartLine, r.Chunk.EndLine),
+			r.Chunk.Kind,
+			r.Chunk.Symbol(),
+		}
+		if scored {
+			relevance := "—" // a keyword-only match
+			if r.Relevance != nil {
+				relevance = formatRelevance(*r.Relevance)
+			}
+			row = append(row, relevance)
+		}
+		tab.Rows = append(tab.Rows, row)
+	}
+	return tab
+}
+
+// federatedTable is resultTable for results from several indexes: it adds
+// an Index column and resolves each location against its index's root.
+func federatedTable(results []store.SearchResult, roots map[string]string) format.Tabular {
+	tab := resultTable(results, "")
+	tab.Columns = slices.Insert(tab.Columns, 1, "Index")
+	for i, r := range results {
+		tab.Rows[i] = slices.Insert(tab.Rows[i], 1, r.Source)
+		tab.Locations[i].Path = citePath(roots[r.Source], r.FilePath)
+	}
+	return tab
+}
+
+// formatRelevance formats a 0–100 relevance score as a whole percentage.
+func formatRelevance(relevance float64) string {
+	return fmt.Sprintf("%.0f%%", relevance)
+}
+
+// resultLocations returns a citation per result, with paths usable from the
+// working directory.
+func resultLocations(results []store.SearchResult, root string) []format.Location {
+	locs := make([]format.Location, 0, len(results))
+	for _, r := range results {
+		text := strings.TrimSpace(r.Chunk.Kind + " " + r.Chunk.Symbol())
+		locs = append(locs, format.Location{Path: citePath(root, r.FilePath), Line: r.Chunk.StartLine, Text: text})
+	}
+	return locs
+}
+
+func init() {
+	searchCmd.Flags().IntVar(&flagSearchK, "k", 10, "maximum number of results (files with --whole-files)")
+	searchCmd.Flags().StringVar(&flagSearchPath, "path", "", "only return results from files under this path prefix")
+	searchCmd.Flags().BoolVar(&flagExpand, "expand", false, "expand the query into alternative phrasings with the chat model and fuse their results (adds an LLM call)")
+	searchCmd.Flags().BoolVar(&flagWholeFiles, "whole-files", false, "rank files by their matching chunks and return whole files instead of chunks")
+	searchCmd.Flags().IntVar(&flagMaxBytes, "max-bytes", rag.DefaultFileBudget, "with --whole-files, maximum bytes of file content returned in total")
+	searchCmd.Flags().StringVar(&flagQueriesIn, "queries-from", "", "search each query in this file (one per line, or a JSON array; - for stdin) instead of <query>")
+	searchCmd.Flags().IntVar(&flagSearchJobs, "concurrency", 1, "with --queries-from, how many queries are searched at once")
+	searchCmd.Flags().StringArrayVar(&flagIndexes, "index", nil, "search this index instead of --db; repeat to search several at once and merge the results")
+	addOutputFlag(searchCmd)
+	rootCmd.AddCommand(searchCmd)
+}
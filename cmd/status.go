@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"synapse/internal/format"
+
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show index statistics",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := format.Parse(flagOutput)
+		if err != nil {
+			return err
+		}
+
+		st, dbPath, err := openReadOnlyIndex()
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+
+		files, err := st.ListFiles()
+		if err != nil {
+			return fmt.Errorf("list files: %w", err)
+		}
+		model, err := st.GetMeta("embedding_model")
+		if err != nil {
+			return fmt.Errorf("get meta: %w", err)
+		}
+		lastIndexed, err := st.GetMeta("last_indexed")
+		if err != nil {
+			return fmt.Errorf("get meta: %w", err)
+		}
+
+		status := statusOutput{
+			DBPath:         dbPath,
+			EmbeddingModel: model,
+			LastIndexed:    lastIndexed,
+			Files:          len(files),
+		}
+		if info, err := os.Stat(dbPath); err == nil {
+			status.SizeBytes = info.Size()
+		}
+		stale, err := st.ListStaleFiles(projectRoot(st, dbPath))
+		if err != nil {
+			return fmt.Errorf("check for changed files: %w", err)
+		}
+		status.ChangedFiles = len(stale.Changed)
+		status.MissingFiles = len(stale.Missing)
+
+		byLang := make(map[string]*languageStats)
+		for _, f := range files {
+			status.Chunks += f.Chunks
+			ls, ok := byLang[f.Language]
+			if !ok {
+				ls = &languageStats{Language: f.Language}
+				byLang[f.Language] = ls
+			}
+			ls.Files++
+			ls.Chunks += f.Chunks
+		}
+		for _, ls := range byLang {
+			status.Languages = append(status.Languages, *ls)
+		}
+		sort.Slice(status.Languages, func(i, j int) bool {
+			return status.Languages[i].Files > status.Languages[j].Files
+		})
+
+		return format.Render(os.Stdout, out, status, status.table())
+	},
+}
+
+// statusOutput is the JSON shape of 'synapse status'.
+type statusOutput struct {
+	DBPath         string          `json:"db_path"`
+	EmbeddingModel string          `json:"embedding_model"`
+	LastIndexed    string          `json:"last_indexed"`
+	Files          int             `json:"files"`
+	ChangedFiles   int             `json:"changed_files"`
+	MissingFiles   int             `json:"missing_files"`
+	Chunks         int             `json:"chunks"`
+	SizeBytes      int64           `json:"size_bytes"`
+	Languages      []languageStats `json:"languages"`
+}
+
+type languageStats struct {
+	Language string `json:"language"`
+	Files    int    `json:"files"`
+	Chunks   int    `json:"chunks"`
+}
+
+func (s statusOutput) table() format.Tabular {
+	langs := make([]string, len(s.Languages))
+	for i, l := range s.Languages {
+		langs[i] = fmt.Sprintf("%s (%d)", l.Language, l.Files)
+	}
+	stale := "none"
+	if s.ChangedFiles+s.MissingFiles > 0 {
+		stale = fmt.Sprintf("%d changed, %d missing since indexing (run 'synapse index' to update)", s.ChangedFiles, s.MissingFiles)
+	}
+	return format.Tabular{
+		Columns: []string{"Field", "Value"},
+		Rows: [][]string{
+			{"Database", s.DBPath},
+			{"Embedding model", s.EmbeddingModel},
+			{"Last indexed", s.LastIndexed},
+			{"Files", fmt.Sprint(s.Files)},
+			{"Stale files", stale},
+			{"Chunks", fmt.Sprint(s.Chunks)},
+			{"Size", fmt.Sprintf("%.1f MB", float64(s.SizeBytes)/(1<<20))},
+			{"Languages", strings.Join(langs, ", ")},
+		},
+	}
+}
+
+func init() {
+	addOutputFlag(statusCmd)
+	rootCmd.AddCommand(statusCmd)
+}
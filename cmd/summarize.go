@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"synapse/internal/index"
+
+	"github.com/spf13/cobra"
+)
+
+var flagForceSummaries bool
+
+var summarizeCmd = &cobra.Command{
+	Use:   "summarize",
+	Short: "Generate file summaries and the project overview for an existing index",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dbPath, err := resolveDBPath()
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+			return fmt.Errorf("index not found at %s\nRun 'synapse index <path>' first to build the index", dbPath)
+		}
+
+		overviewModel := flagOverviewModel
+		if overviewModel == "" {
+			overviewModel = flagChatModel
+		}
+		if err := newChat(overviewModel).Ping(cmd.Context()); err != nil {
+			return err
+		}
+
+		idx, err := index.New(index.Config{
+			DBPath:          dbPath,
+			OllamaURL:       flagOllama,
+			Model:           flagModel,
+			OverviewModel:   overviewModel,
+			OverviewSymbols: flagOverviewSyms,
+			OverviewPrompt:  flagPromptTmpl,
+			KeepAlive:       flagKeepAlive,
+		})
+		if err != nil {
+			return err
+		}
+		defer idx.Close()
+
+		return idx.Summarize(cmd.Context(), flagForceSummaries)
+	},
+}
+
+func init() {
+	summarizeCmd.Flags().BoolVar(&flagForceSummaries, "force", false, "regenerate summaries for files that already have one, and retry those the model failed to summarize")
+	summarizeCmd.Flags().StringVar(&flagOverviewModel, "overview-model", "", "model for summaries and the overview (default: same as --chat-model)")
+	summarizeCmd.Flags().IntVar(&flagOverviewSyms, "overview-symbols", index.DefaultOverviewSymbols, "maximum symbols listed per file in the overview prompt, public ones first (-1 = no limit)")
+	summarizeCmd.Flags().StringVar(&flagPromptTmpl, "prompt-template", "", "file of instructions for writing the project overview, replacing .synapse/prompts/overview.md or the built-in ones")
+	rootCmd.AddCommand(summarizeCmd)
+}
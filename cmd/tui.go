@@ -1,26 +1,26 @@
 package cmd
 
 import (
-	"os"
-	"path/filepath"
-
 	"synapse/internal/tui"
 )
 
 func runTUI() error {
-	dbPath := flagDB
-	if dbPath == "" {
-		wd, err := os.Getwd()
-		if err != nil {
-			return err
-		}
-		dbPath = filepath.Join(wd, ".synapse", "index.db")
+	// The TUI indexes --root, or the tree the index was built from, or the
+	// working directory for a new index.
+	root, dbPath, err := indexPaths(nil)
+	if err != nil {
+		return err
 	}
-
 	return tui.Run(tui.Config{
-		DBPath:    dbPath,
-		OllamaURL: flagOllama,
-		Model:     flagModel,
-		ChatModel: flagChatModel,
+		DBPath:       dbPath,
+		Root:         root,
+		OllamaURL:    flagOllama,
+		Model:        flagModel,
+		ChatModel:    flagChatModel,
+		CacheSize:    flagCacheSize,
+		KeepAlive:    flagKeepAlive,
+		QueryTimeout: flagQueryTimeout,
+		Weighting:    queryWeighting(),
+		Recency:      flagRecencyWeight,
 	})
 }
@@ -0,0 +1,178 @@
+package chunker
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// blockLines is the most lines statements are grouped into per piece by
+// splitBlocks, like the windows of splitOversized. A single statement can
+// be longer if it can't be split and fits in maxChunkBytes.
+const blockLines = 40
+
+// blockSpan is a piece of an oversized definition: whole lines start to end
+// (1-based, inclusive), shown after prefix, the signatures of the
+// definition and of any nested blocks enclosing the lines.
+type blockSpan struct {
+	prefix     string
+	start, end int
+}
+
+// splitBlocks splits an oversized capture between the statements of its
+// body, or the members of a class body, so each piece is syntactically
+// whole. Pieces after the first start with the capture's signature, and
+// with that of the block they're nested in when a statement too big on its
+// own is split between its own statements. It returns nil when the capture
+// has no body to split or some statement can't be split small enough; the
+// caller then falls back to splitOversized.
+func (c *ASTChunker) splitBlocks(cap capture, header string, lines []string) []RawChunk {
+	if cap.node == nil {
+		return nil
+	}
+	spans, ok := blockSpans(cap.node, "", cap.startLine, cap.endLine, maxChunkBytes-len(header), lines)
+	if !ok || len(spans) < 2 {
+		return nil
+	}
+	chunks := make([]RawChunk, len(spans))
+	for i, s := range spans {
+		chunks[i] = RawChunk{
+			Name:      cap.name,
+			Kind:      cap.kind,
+			StartLine: s.start,
+			EndLine:   s.end,
+			Content:   header + s.prefix + strings.Join(lines[s.start-1:s.end], "\n"),
+			Piece:     i + 1,
+		}
+	}
+	return chunks
+}
+
+// blockSpans splits lines from to to, which hold node, between the
+// statements of node's body into spans of up to blockLines lines that fit
+// in budget bytes with their prefix. prefix is shown before the first span,
+// whose lines include node's signature; it and the signature are shown
+// before the others. It reports false when node has no body to split or a
+// statement can't be split to fit.
+func blockSpans(n *sitter.Node, prefix string, from, to, budget int, lines []string) ([]blockSpan, bool) {
+	body := blockOf(n)
+	if body == nil || body.NamedChildCount() == 0 {
+		return nil, false
+	}
+	first := int(body.NamedChild(0).StartPoint().Row) + 1
+	if first <= from || first > to {
+		return nil, false // the signature shares a line with a statement
+	}
+	sig := prefix + strings.Join(lines[from-1:first-1], "\n") + "\n"
+	fits := func(prefix string, start, end int) bool {
+		return end-start < blockLines && len(prefix)+linesBytes(lines, start, end) <= budget
+	}
+
+	var spans []blockSpan
+	var cur *blockSpan
+	for i := range int(body.NamedChildCount()) {
+		stmt := body.NamedChild(i)
+		start, end := from, int(stmt.EndPoint().Row)+1
+		if len(spans) > 0 || cur != nil {
+			start = lastEnd(spans, cur) + 1
+		}
+		if i == int(body.NamedChildCount())-1 {
+			end = to // the body's closing line
+		}
+		if end < start {
+			continue // on the line of the statement before
+		}
+		if cur != nil && fits(cur.prefix, cur.start, end) {
+			cur.end = end
+			continue
+		}
+		p := sig
+		if start == from {
+			p = prefix
+		}
+		if fits(p, start, end) {
+			if cur != nil {
+				spans = append(spans, *cur)
+			}
+			cur = &blockSpan{prefix: p, start: start, end: end}
+			continue
+		}
+		// Too long on its own: split it between its own statements, or
+		// keep it whole if it can't be and isn't too big.
+		nested, ok := blockSpans(stmt, p, start, end, budget, lines)
+		switch {
+		case ok:
+			// The statements before it can take the place of the
+			// signature atop its first piece.
+			if cur != nil && fits(cur.prefix, cur.start, nested[0].end) {
+				nested[0] = blockSpan{prefix: cur.prefix, start: cur.start, end: nested[0].end}
+			} else if cur != nil {
+				spans = append(spans, *cur)
+			}
+			// And the statements after it can join its last piece.
+			spans = append(spans, nested[:len(nested)-1]...)
+			cur = &nested[len(nested)-1]
+		case len(p)+linesBytes(lines, start, end) <= budget:
+			if cur != nil {
+				spans = append(spans, *cur)
+			}
+			cur = &blockSpan{prefix: p, start: start, end: end}
+		default:
+			return nil, false
+		}
+	}
+	if cur != nil {
+		spans = append(spans, *cur)
+	}
+	return spans, true
+}
+
+// lastEnd returns the last line of cur, or of the last of spans when cur is
+// nil.
+func lastEnd(spans []blockSpan, cur *blockSpan) int {
+	if cur != nil {
+		return cur.end
+	}
+	return spans[len(spans)-1].end
+}
+
+// linesBytes returns the size of lines start to end joined by newlines.
+func linesBytes(lines []string, start, end int) int {
+	n := 0
+	for _, l := range lines[start-1 : end] {
+		n += len(l) + 1
+	}
+	return n
+}
+
+// blockOf returns the node holding n's statements or members, such as a
+// function's body or the consequence of an if statement, or nil if it has
+// none. Declarations wrapped by export statements or decorators are looked
+// through.
+func blockOf(n *sitter.Node) *sitter.Node {
+	for _, field := range []string{"body", "consequence", "block"} {
+		if b := n.ChildByFieldName(field); b != nil && b.NamedChildCount() > 0 {
+			return b
+		}
+	}
+	for _, field := range []string{"declaration", "definition"} {
+		if inner := n.ChildByFieldName(field); inner != nil {
+			return blockOf(inner)
+		}
+	}
+	// Grammars without a body field, e.g. a Go struct's field list.
+	for i := range int(n.NamedChildCount()) {
+		child := n.NamedChild(i)
+		t := child.Type()
+		if strings.Contains(t, "parameter") || strings.Contains(t, "argument") {
+			continue
+		}
+		if strings.HasSuffix(t, "block") || strings.HasSuffix(t, "body") || strings.HasSuffix(t, "_list") {
+			return child
+		}
+		if b := blockOf(child); b != nil && child.StartPoint().Row == n.StartPoint().Row {
+			return b
+		}
+	}
+	return nil
+}
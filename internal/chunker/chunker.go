@@ -3,41 +3,186 @@ package chunker
 import (
 	"context"
 	"fmt"
+	"path"
+	"slices"
 	"sort"
 	"strings"
+	"text/template"
 
 	sitter "github.com/smacker/go-tree-sitter"
 )
 
 const maxChunkBytes = 8192
 
+// DefaultMaxSplits is the default cap on the pieces an oversized chunk is
+// split into, so one enormous (often generated) function can't dominate an
+// indexing run. 64 overlapping windows cover roughly 1,900 lines.
+const DefaultMaxSplits = 64
+
+// DefaultMaxChunks is the default cap on the chunks indexed per file. Files
+// over it are almost always generated (giant switch statements, constant
+// tables) and would crowd everything else out of search results.
+const DefaultMaxChunks = 500
+
+// fallbackWindow is the number of lines per chunk when a file (or part of
+// one) can't be chunked by the grammar's query.
+const fallbackWindow = 40
+
 // RawChunk is a chunk extracted from a source file before embedding.
 type RawChunk struct {
-	Name      string
-	Kind      string
-	StartLine int
-	EndLine   int
-	Content   string
+	Name      string `json:"name,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Content   string `json:"content"`
+	// QualifiedName is Name prefixed with the type or class it belongs
+	// to, such as SQLiteStore.Search for a Go method or User.save for a
+	// Python method, or "" when it belongs to none.
+	QualifiedName string `json:"qualified_name,omitempty"`
+	// Block is the single-file component block the chunk came from
+	// ("script", "template", or "style"), or "" for other files.
+	Block string `json:"block,omitempty"`
+	// Piece is the chunk's 1-based place among the pieces an oversized
+	// definition was split into, or 0 for a whole definition.
+	Piece int `json:"piece,omitempty"`
 }
 
 // ASTChunker parses source files using tree-sitter and extracts semantic chunks.
 type ASTChunker struct {
 	registry *Registry
+	// IncludeImports prepends the file's import block to every chunk for
+	// languages that define an ImportQuery.
+	IncludeImports bool
+	// MaxSplits caps the pieces an oversized chunk is split into; the rest
+	// of it is dropped. 0 means no limit.
+	MaxSplits int
+	// OnTruncate, if set, is called when a chunk hits MaxSplits, with the
+	// number of pieces dropped. It may be called concurrently.
+	OnTruncate func(path, name string, dropped int)
+	// Kinds, if set, keeps only captures of these kinds, given as aliases
+	// from each language's KindAliases ("function", "method", ...). A
+	// language that doesn't define an alias has no chunks of that kind.
+	Kinds []string
+	// ExcludeSymbols drops captures whose name matches any of these
+	// path.Match patterns, e.g. "init" or "Test*".
+	ExcludeSymbols []string
+	// OnExclude, if set, is called for each capture dropped by
+	// ExcludeSymbols. It may be called concurrently.
+	OnExclude func(path, name string)
+	// MaxChunks caps the chunks returned per file. A file with more keeps a
+	// representative subset: named definitions before unnamed windows and
+	// split pieces, largest first, returned in source order. 0 means no
+	// limit.
+	MaxChunks int
+	// IndexDocs adds a chunk of kind DocKind for each doc comment or
+	// docstring of a definition, for languages that define a DocQuery, so
+	// questions worded like the documentation match it directly.
+	IndexDocs bool
+	// OnCap, if set, is called when a file hits MaxChunks, with the number of
+	// chunks it had. It may be called concurrently.
+	OnCap func(path string, total int)
+	// MinChunkLines and MinChunkBytes, if set, merge definitions shorter
+	// than either, such as one-line getters and stubs, with their small
+	// neighbors into one unnamed chunk, so trivial code isn't embedded on
+	// its own (see mergeSmall). One with no small neighbor is kept as it
+	// is. Doc chunks and fallback windows aren't affected.
+	MinChunkLines int
+	MinChunkBytes int
+	// SplitBlocks splits definitions over the chunk size limit between
+	// their statements, or a class's members, instead of into overlapping
+	// line windows, with the signature repeated atop each piece (see
+	// splitBlocks). Windows are still used when a statement is too big to
+	// fit even split at its nested blocks.
+	SplitBlocks bool
+	// Header, if set, renders the header put above each chunk's code, which
+	// is embedded with it (see ParseHeader and Headers). nil uses the
+	// DefaultHeader.
+	Header *template.Template
+}
+
+// PanicError reports a panic recovered while chunking the file at Path.
+type PanicError struct {
+	Path  string
+	Value any
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic while chunking: %v", e.Value)
 }
 
 // NewASTChunker creates a chunker backed by the given registry.
 func NewASTChunker(r *Registry) *ASTChunker {
-	return &ASTChunker{registry: r}
+	return &ASTChunker{registry: r, MaxSplits: DefaultMaxSplits, MaxChunks: DefaultMaxChunks}
 }
 
 // Chunk parses the source and returns semantic chunks. If no grammar is
 // registered for the file, it returns nil (caller should use fallback).
-func (c *ASTChunker) Chunk(path string, src []byte) ([]RawChunk, error) {
-	spec, lang := c.registry.Lookup(path)
+//
+// The tree-sitter bindings can panic on malformed input or grammar edge
+// cases; Chunk recovers and returns a *PanicError instead, so one bad file
+// doesn't take down an indexing run.
+func (c *ASTChunker) Chunk(path string, src []byte) (chunks []RawChunk, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			chunks, err = nil, &PanicError{Path: path, Value: v}
+		}
+	}()
+	spec, lang := c.registry.Detect(path, src)
 	if spec == nil {
 		return nil, nil
 	}
+	if spec.SFC {
+		chunks, err = c.chunkSFC(path, lang, src)
+	} else {
+		chunks, err = c.chunkSource(path, lang, spec, src, false)
+	}
+	if err == nil && c.MaxChunks > 0 && len(chunks) > c.MaxChunks {
+		if c.OnCap != nil {
+			c.OnCap(path, len(chunks))
+		}
+		chunks = representative(chunks, c.MaxChunks)
+	}
+	return chunks, err
+}
 
+// representative returns n of chunks, preferring named definitions whole
+// over unnamed line windows, doc chunks, and pieces of split definitions,
+// and larger chunks over smaller ones. They're returned in their original
+// order. Sorting once keeps it fast for the files with the most chunks,
+// which are the ones it's called for.
+func representative(chunks []RawChunk, n int) []RawChunk {
+	whole := func(c RawChunk) bool { return c.Name != "" && c.Kind != DocKind && c.Piece == 0 }
+	order := make([]int, len(chunks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := chunks[order[i]], chunks[order[j]]
+		if whole(a) != whole(b) {
+			return whole(a)
+		}
+		return len(a.Content) > len(b.Content)
+	})
+	kept := order[:n]
+	sort.Ints(kept)
+	out := make([]RawChunk, n)
+	for i, idx := range kept {
+		out[i] = chunks[idx]
+	}
+	return out
+}
+
+// chunkSource chunks src with spec's grammar, or in line windows if it has
+// none. lang labels the chunks. When fallback is set, lines no capture covers
+// are always chunked in windows, as if spec.Fallback were set and the file
+// failed to parse.
+func (c *ASTChunker) chunkSource(path, lang string, spec *LanguageSpec, src []byte, fallback bool) ([]RawChunk, error) {
+	if spec.Language == nil {
+		if !spec.Fallback || !c.keepKind(spec, "statement") {
+			return nil, nil
+		}
+		return c.fallbackChunks(path, lang, strings.Split(string(src), "\n"), nil), nil
+	}
 	parser := sitter.NewParser()
 	parser.SetLanguage(spec.Language)
 	tree, err := parser.ParseCtx(context.Background(), nil, src)
@@ -63,55 +208,312 @@ func (c *ASTChunker) Chunk(path string, src []byte) ([]RawChunk, error) {
 			break
 		}
 		var chunkNode *sitter.Node
-		var nameStr string
+		var nameParts []string
 		for _, cap := range m.Captures {
 			capName := q.CaptureNameForId(cap.Index)
 			switch capName {
 			case "chunk":
 				chunkNode = cap.Node
 			case "name":
-				nameStr = cap.Node.Content(src)
+				nameParts = append(nameParts, unquote(cap.Node.Content(src)))
 			}
 		}
 		if chunkNode == nil {
 			continue
 		}
 		captures = append(captures, capture{
-			name:      nameStr,
+			name:      strings.Join(nameParts, "."),
+			receiver:  receiverType(chunkNode, src),
 			kind:      chunkNode.Type(),
+			declKind:  declarationType(chunkNode),
 			startLine: int(chunkNode.StartPoint().Row) + 1,
 			endLine:   int(chunkNode.EndPoint().Row) + 1,
 			startByte: chunkNode.StartByte(),
 			endByte:   chunkNode.EndByte(),
+			node:      chunkNode,
 		})
 	}
+	qualify(captures)
+
+	// Filter by kind and name before deduplicating, so e.g. methods survive
+	// when their enclosing class is dropped. The fallback still treats every
+	// capture as covered.
+	kept := captures
+	if len(c.Kinds) > 0 || len(c.ExcludeSymbols) > 0 {
+		kept = nil
+		for _, cap := range captures {
+			if !c.keepKind(spec, cap.declKind) {
+				continue
+			}
+			if c.excluded(cap.name) {
+				if c.OnExclude != nil {
+					c.OnExclude(path, cap.name)
+				}
+				continue
+			}
+			kept = append(kept, cap)
+		}
+	}
+
+	// Docs can belong to nested definitions, so they're matched before
+	// deduplicating.
+	lines := strings.Split(string(src), "\n")
+	var docs []RawChunk
+	if c.IndexDocs && spec.DocQuery != "" {
+		docs, err = c.docChunks(path, lang, spec, tree, src, lines, kept)
+		if err != nil {
+			return nil, fmt.Errorf("compile doc query for %s: %w", lang, err)
+		}
+	}
 
 	// Deduplicate: when captures overlap, keep only the outer (larger) node.
-	captures = dedup(captures)
+	kept = c.mergeSmall(dedup(kept))
+
+	var imports string
+	if c.IncludeImports && spec.ImportQuery != "" {
+		imports, err = importBlock(spec, tree, src)
+		if err != nil {
+			return nil, fmt.Errorf("compile import query for %s: %w", lang, err)
+		}
+	}
 
 	// Build chunks with context enrichment.
-	lines := strings.Split(string(src), "\n")
 	var chunks []RawChunk
-	for _, cap := range captures {
-		content := enrichContent(path, lang, cap.kind, cap.name, lines, cap.startLine, cap.endLine)
+	for _, cap := range kept {
+		content := c.enrichContent(path, lang, cap.kind, cap.name, imports, lines, cap.startLine, cap.endLine)
 
 		if len(content) > maxChunkBytes {
-			splits := splitOversized(content, cap.name, cap.kind, cap.startLine)
+			var splits []RawChunk
+			if c.SplitBlocks {
+				header := c.header(HeaderData{Path: path, Language: lang, Kind: cap.kind, Name: cap.name, Imports: imports})
+				splits = c.splitBlocks(cap, header, lines)
+			}
+			if splits == nil {
+				splits = splitOversized(content, cap.name, cap.kind, cap.startLine)
+			}
+			for i := range splits {
+				splits[i].QualifiedName = cap.qualified
+			}
+			if c.MaxSplits > 0 && len(splits) > c.MaxSplits {
+				if c.OnTruncate != nil {
+					c.OnTruncate(path, cap.name, len(splits)-c.MaxSplits)
+				}
+				splits = splits[:c.MaxSplits]
+			}
 			chunks = append(chunks, splits...)
 		} else {
 			chunks = append(chunks, RawChunk{
-				Name:      cap.name,
-				Kind:      cap.kind,
-				StartLine: cap.startLine,
-				EndLine:   cap.endLine,
-				Content:   content,
+				Name:          cap.name,
+				QualifiedName: cap.qualified,
+				Kind:          cap.kind,
+				StartLine:     cap.startLine,
+				EndLine:       cap.endLine,
+				Content:       content,
 			})
 		}
 	}
 
+	if (fallback || spec.Fallback && (len(captures) == 0 || tree.RootNode().HasError())) && c.keepKind(spec, "statement") {
+		chunks = append(chunks, c.fallbackChunks(path, lang, lines, captures)...)
+	}
+	chunks = append(chunks, docs...)
+
 	return chunks, nil
 }
 
+// receiverType returns the type name of the receiver of a method node, as
+// in Go's func (s *SQLiteStore) Search, or "" for nodes without one.
+func receiverType(n *sitter.Node, src []byte) string {
+	recv := n.ChildByFieldName("receiver")
+	if recv == nil {
+		return ""
+	}
+	// The receiver's type can be a pointer or generic: the first type
+	// name in it is the base type.
+	stack := []*sitter.Node{recv}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n.Type() == "type_identifier" {
+			return n.Content(src)
+		}
+		for i := int(n.NamedChildCount()) - 1; i >= 0; i-- {
+			stack = append(stack, n.NamedChild(i))
+		}
+	}
+	return ""
+}
+
+// qualify sets the qualified name of each named capture: its name prefixed
+// with its receiver type, or else with the names of the captures enclosing
+// it, outermost first, as in Outer.Inner.method. Captures with no scope
+// keep it empty. A definition captured twice, bare and wrapped (decorated,
+// exported), is one scope.
+func qualify(caps []capture) {
+	for i := range caps {
+		c := &caps[i]
+		switch {
+		case c.name == "":
+			continue
+		case c.receiver != "":
+			c.qualified = c.receiver + "." + c.name
+			continue
+		}
+		var scopes []*capture
+		for j := range caps {
+			o := &caps[j]
+			if o.name != "" && o.startByte <= c.startByte && c.endByte <= o.endByte &&
+				(o.startByte != c.startByte || o.endByte != c.endByte) {
+				scopes = append(scopes, o)
+			}
+		}
+		sort.SliceStable(scopes, func(a, b int) bool {
+			return scopes[a].endByte-scopes[a].startByte > scopes[b].endByte-scopes[b].startByte
+		})
+		var parts []string
+		for _, s := range scopes {
+			if len(parts) == 0 || parts[len(parts)-1] != s.name {
+				parts = append(parts, s.name)
+			}
+		}
+		if n := len(parts); n > 0 && parts[n-1] == c.name {
+			parts = parts[:n-1]
+		}
+		if len(parts) > 0 {
+			c.qualified = strings.Join(parts, ".") + "." + c.name
+		}
+	}
+}
+
+// unquote strips the quotes of a string literal captured as a name, such as
+// an HCL block label.
+func unquote(s string) string {
+	if len(s) >= 2 && strings.ContainsRune("\"'`", rune(s[0])) && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// keepKind reports whether a chunk of the given node type passes the Kinds
+// filter.
+func (c *ASTChunker) keepKind(spec *LanguageSpec, nodeType string) bool {
+	if len(c.Kinds) == 0 {
+		return true
+	}
+	for _, alias := range c.Kinds {
+		if slices.Contains(spec.KindAliases[alias], nodeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded reports whether a symbol name matches ExcludeSymbols.
+func (c *ASTChunker) excluded(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, pattern := range c.ExcludeSymbols {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// declarationType returns the node type that decides a chunk's kind: that
+// of the wrapped declaration for nodes like export_statement and
+// decorated_definition, which can hold either a function or a class.
+func declarationType(n *sitter.Node) string {
+	for _, field := range []string{"declaration", "definition"} {
+		if inner := n.ChildByFieldName(field); inner != nil {
+			return inner.Type()
+		}
+	}
+	return n.Type()
+}
+
+// importBlock returns the text of the file's import statements, in source
+// order, joined by newlines.
+func importBlock(spec *LanguageSpec, tree *sitter.Tree, src []byte) (string, error) {
+	q, err := sitter.NewQuery([]byte(spec.ImportQuery), spec.Language)
+	if err != nil {
+		return "", err
+	}
+	defer q.Close()
+
+	qc := sitter.NewQueryCursor()
+	defer qc.Close()
+	qc.Exec(q, tree.RootNode())
+
+	var parts []string
+	for {
+		m, ok := qc.NextMatch()
+		if !ok {
+			break
+		}
+		for _, cap := range m.Captures {
+			if q.CaptureNameForId(cap.Index) == "import" {
+				parts = append(parts, cap.Node.Content(src))
+			}
+		}
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// fallbackChunks splits the lines not covered by any capture into windows of
+// fallbackWindow lines, so statements the grammar can't parse (e.g. an
+// unsupported SQL dialect) are still searchable.
+func (c *ASTChunker) fallbackChunks(path, lang string, lines []string, caps []capture) []RawChunk {
+	covered := make([]bool, len(lines)+1)
+	for _, cap := range caps {
+		for l := cap.startLine; l <= cap.endLine && l <= len(lines); l++ {
+			covered[l] = true
+		}
+	}
+
+	var chunks []RawChunk
+	emit := func(start, end int) {
+		// Trim blank lines at both ends of the run.
+		for start <= end && strings.TrimSpace(lines[start-1]) == "" {
+			start++
+		}
+		for end >= start && strings.TrimSpace(lines[end-1]) == "" {
+			end--
+		}
+		for s := start; s <= end; s += fallbackWindow {
+			e := s + fallbackWindow - 1
+			if e > end {
+				e = end
+			}
+			chunks = append(chunks, RawChunk{
+				Kind:      "statement",
+				StartLine: s,
+				EndLine:   e,
+				Content:   c.enrichContent(path, lang, "statement", "", "", lines, s, e),
+			})
+		}
+	}
+
+	start := 0
+	for l := 1; l <= len(lines); l++ {
+		if covered[l] {
+			if start > 0 {
+				emit(start, l-1)
+				start = 0
+			}
+			continue
+		}
+		if start == 0 {
+			start = l
+		}
+	}
+	if start > 0 {
+		emit(start, len(lines))
+	}
+	return chunks
+}
+
 // dedup removes captures that are fully contained within a larger capture.
 func dedup(caps []capture) []capture {
 	if len(caps) <= 1 {
@@ -139,13 +541,9 @@ func dedup(caps []capture) []capture {
 	return result
 }
 
-func enrichContent(path, lang, kind, name string, lines []string, startLine, endLine int) string {
+func (c *ASTChunker) enrichContent(path, lang, kind, name, imports string, lines []string, startLine, endLine int) string {
 	var b strings.Builder
-	fmt.Fprintf(&b, "// File: %s\n", path)
-	fmt.Fprintf(&b, "// Language: %s\n", lang)
-	if name != "" {
-		fmt.Fprintf(&b, "// %s: %s\n", kind, name)
-	}
+	b.WriteString(c.header(HeaderData{Path: path, Language: lang, Kind: kind, Name: name, Imports: imports}))
 	// Lines are 1-indexed.
 	start := startLine - 1
 	end := endLine
@@ -184,6 +582,7 @@ func splitOversized(content, name, kind string, baseStartLine int) []RawChunk {
 			StartLine: baseStartLine + i,
 			EndLine:   baseStartLine + end - 1,
 			Content:   chunk,
+			Piece:     len(chunks) + 1,
 		})
 		if end >= len(lines) {
 			break
@@ -195,9 +594,13 @@ func splitOversized(content, name, kind string, baseStartLine int) []RawChunk {
 
 type capture struct {
 	name      string
+	receiver  string // receiver type of a method; see receiverType
+	qualified string // see qualify
 	kind      string
+	declKind  string // node type matched against Kinds; see declarationType
 	startLine int
 	endLine   int
 	startByte uint32
 	endByte   uint32
+	node      *sitter.Node // nil for merged captures
 }
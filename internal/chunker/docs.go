@@ -0,0 +1,116 @@
+package chunker
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// DocKind is the kind of the chunks IndexDocs extracts from doc comments and
+// docstrings.
+const DocKind = "doc"
+
+// minDocBytes is the shortest doc text, without comment markers, worth a
+// chunk of its own; shorter ones say little their symbol's chunk doesn't.
+const minDocBytes = 40
+
+// doc is a run of doc comment lines, or a docstring.
+type doc struct {
+	startLine int
+	endLine   int
+	// inside is set for docstrings, which document the definition they're
+	// in rather than the one that follows.
+	inside bool
+}
+
+// docChunks returns a chunk per doc comment or docstring captured by the
+// spec's DocQuery that documents one of captures, named after the symbol
+// it documents.
+func (c *ASTChunker) docChunks(path, lang string, spec *LanguageSpec, tree *sitter.Tree, src []byte, lines []string, captures []capture) ([]RawChunk, error) {
+	q, err := sitter.NewQuery([]byte(spec.DocQuery), spec.Language)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	qc := sitter.NewQueryCursor()
+	defer qc.Close()
+	qc.Exec(q, tree.RootNode())
+
+	// Consecutive comment nodes, e.g. "//" lines, form one doc.
+	var docs []doc
+	for {
+		m, ok := qc.NextMatch()
+		if !ok {
+			break
+		}
+		for _, cap := range m.Captures {
+			name := q.CaptureNameForId(cap.Index)
+			if name != "doc" && name != "docstring" {
+				continue
+			}
+			// A comment after code on its line isn't a doc comment.
+			row := cap.Node.StartPoint().Row
+			if name == "doc" && int(row) < len(lines) && strings.TrimSpace(lines[row][:min(int(cap.Node.StartPoint().Column), len(lines[row]))]) != "" {
+				continue
+			}
+			d := doc{
+				startLine: int(cap.Node.StartPoint().Row) + 1,
+				endLine:   int(cap.Node.EndPoint().Row) + 1,
+				inside:    name == "docstring",
+			}
+			if n := len(docs); n > 0 && !d.inside && !docs[n-1].inside && docs[n-1].endLine+1 == d.startLine {
+				docs[n-1].endLine = d.endLine
+				continue
+			}
+			docs = append(docs, d)
+		}
+	}
+
+	var chunks []RawChunk
+	for _, d := range docs {
+		owner := documented(d, captures)
+		if owner == nil || len(docText(lines, d)) < minDocBytes {
+			continue
+		}
+		chunks = append(chunks, RawChunk{
+			Name:          owner.name,
+			QualifiedName: owner.qualified,
+			Kind:          DocKind,
+			StartLine:     d.startLine,
+			EndLine:       d.endLine,
+			Content:       c.enrichContent(path, lang, DocKind, owner.name, "", lines, d.startLine, d.endLine),
+		})
+	}
+	return chunks, nil
+}
+
+// documented returns the named capture d documents, or nil: the one
+// starting on the line after a comment, or the smallest one containing a
+// docstring.
+func documented(d doc, captures []capture) *capture {
+	var owner *capture
+	for i := range captures {
+		c := &captures[i]
+		switch {
+		case c.name == "":
+		case !d.inside && c.startLine == d.endLine+1:
+			return c
+		case d.inside && c.startLine < d.startLine && d.endLine <= c.endLine:
+			if owner == nil || c.endLine-c.startLine < owner.endLine-owner.startLine {
+				owner = c
+			}
+		}
+	}
+	return owner
+}
+
+// docText returns the words of d's lines without comment markers and quotes.
+func docText(lines []string, d doc) string {
+	var words []string
+	for _, line := range lines[d.startLine-1 : min(d.endLine, len(lines))] {
+		line = strings.TrimLeft(strings.TrimSpace(line), `/*#"'`)
+		words = append(words, strings.Fields(strings.TrimRight(line, `*/"'`))...)
+	}
+	return strings.Join(words, " ")
+}
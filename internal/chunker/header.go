@@ -0,0 +1,98 @@
+package chunker
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// DefaultHeader names the header of Headers chunks get unless
+// ASTChunker.Header says otherwise.
+const DefaultHeader = "comment"
+
+// Headers are the built-in chunk header templates, by name. "comment" is a
+// terse comment block, "prose" a sentence describing the chunk, which some
+// embedding models match natural-language questions against better.
+var Headers = map[string]string{
+	"comment": `// File: {{.Path}}
+// Language: {{.Language}}
+{{if .Name}}// {{.Kind}}: {{.Name}}
+{{end}}{{if .Imports}}// Imports:
+{{.Imports}}
+
+{{end}}`,
+	"prose": `{{if .Name}}This is the {{words .Kind}} {{.Name}}{{else}}This is {{words .Kind}} code{{end}} from the {{.Language}} file {{.Path}}{{if .Imports}}, which imports:
+{{.Imports}}
+
+{{else}}:
+{{end}}`,
+}
+
+// HeaderData is what a chunk header template is executed with.
+type HeaderData struct {
+	Path     string
+	Language string
+	// Kind is the chunk's tree-sitter node type, e.g.
+	// "function_declaration", or "statement" or DocKind.
+	Kind string
+	// Name is the chunk's symbol name, or "" for unnamed chunks.
+	Name string
+	// Imports is the file's import block when ASTChunker.IncludeImports is
+	// set, or "".
+	Imports string
+}
+
+var headerFuncs = template.FuncMap{
+	// words turns a node type like "function_declaration" into words.
+	"words": func(s string) string { return strings.ReplaceAll(s, "_", " ") },
+}
+
+// ParseHeader parses a chunk header template: a text/template executed with
+// a HeaderData, which may also call words to turn a node type into words.
+// The chunk's code follows its output on the next line. The template is
+// tried on sample data, so one referring to unknown fields fails here
+// rather than while chunking.
+func ParseHeader(text string) (*template.Template, error) {
+	t, err := template.New("header").Funcs(headerFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	sample := HeaderData{Path: "main.go", Language: "Go", Kind: "function_declaration", Name: "main", Imports: `import "fmt"`}
+	if err := t.Execute(new(strings.Builder), sample); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// header returns the header of a chunk described by d.
+func (c *ASTChunker) header(d HeaderData) string {
+	if c.Header == nil {
+		return commentHeader(d)
+	}
+	var b strings.Builder
+	if err := c.Header.Execute(&b, d); err != nil {
+		// ParseHeader has tried the template already, so this is rare.
+		return commentHeader(d)
+	}
+	h := b.String()
+	if h != "" && !strings.HasSuffix(h, "\n") {
+		h += "\n"
+	}
+	return h
+}
+
+// commentHeader is the "comment" header of Headers, without the template.
+func commentHeader(d HeaderData) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// File: %s\n", d.Path)
+	fmt.Fprintf(&b, "// Language: %s\n", d.Language)
+	if d.Name != "" {
+		fmt.Fprintf(&b, "// %s: %s\n", d.Kind, d.Name)
+	}
+	if d.Imports != "" {
+		b.WriteString("// Imports:\n")
+		b.WriteString(d.Imports)
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
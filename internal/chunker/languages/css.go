@@ -0,0 +1,21 @@
+package languages
+
+import (
+	"synapse/internal/chunker"
+
+	"github.com/smacker/go-tree-sitter/css"
+)
+
+// RegisterCSS registers the grammar for Vue and Svelte style blocks. It has
+// no extensions, so standalone stylesheets aren't indexed.
+func RegisterCSS(r *chunker.Registry) {
+	r.Register("css", &chunker.LanguageSpec{
+		Language: css.GetLanguage(),
+		Query: `
+			(rule_set (selectors) @name) @chunk
+			(media_statement) @chunk
+			(supports_statement) @chunk
+			(keyframes_statement (keyframes_name) @name) @chunk
+		`,
+	})
+}
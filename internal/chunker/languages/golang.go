@@ -15,7 +15,14 @@ func RegisterGo(r *chunker.Registry) {
 			(method_declaration name: (field_identifier) @name) @chunk
 			(type_declaration (type_spec name: (type_identifier) @name)) @chunk
 		`,
-		Extensions: []string{"go"},
+		ImportQuery: `(source_file (import_declaration) @import)`,
+		DocQuery:    `(source_file (comment) @doc)`,
+		Extensions:  []string{"go"},
+		KindAliases: map[string][]string{
+			"function": {"function_declaration"},
+			"method":   {"method_declaration"},
+			"type":     {"type_declaration"},
+		},
 	})
 }
 
@@ -0,0 +1,16 @@
+package languages
+
+import "synapse/internal/chunker"
+
+// RegisterGraphQL indexes GraphQL schemas and operations in line windows:
+// go-tree-sitter has no GraphQL grammar, so there are no definitions to
+// capture, but the types and fields are still searchable.
+func RegisterGraphQL(r *chunker.Registry) {
+	r.Register("graphql", &chunker.LanguageSpec{
+		Extensions: []string{"graphql", "gql"},
+		Fallback:   true,
+		KindAliases: map[string][]string{
+			"statement": {"statement"},
+		},
+	})
+}
@@ -0,0 +1,23 @@
+package languages
+
+import (
+	"synapse/internal/chunker"
+
+	"github.com/smacker/go-tree-sitter/hcl"
+)
+
+// RegisterHCL registers HCL, mainly for Terraform. Each top-level block is
+// a chunk named after its type and labels, e.g.
+// resource.aws_instance.web for resource "aws_instance" "web".
+func RegisterHCL(r *chunker.Registry) {
+	r.Register("hcl", &chunker.LanguageSpec{
+		Language: hcl.GetLanguage(),
+		Query: `
+			(config_file (body (block (identifier) @name (string_lit)* @name) @chunk))
+		`,
+		Extensions: []string{"tf", "hcl"},
+		KindAliases: map[string][]string{
+			"block": {"block"},
+		},
+	})
+}
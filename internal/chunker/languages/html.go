@@ -0,0 +1,18 @@
+package languages
+
+import (
+	"synapse/internal/chunker"
+
+	"github.com/smacker/go-tree-sitter/html"
+)
+
+// RegisterHTML registers the grammar for Vue and Svelte template blocks.
+// It has no extensions, so standalone HTML files aren't indexed.
+func RegisterHTML(r *chunker.Registry) {
+	r.Register("html", &chunker.LanguageSpec{
+		Language: html.GetLanguage(),
+		Query: `
+			(element (start_tag (tag_name) @name)) @chunk
+		`,
+	})
+}
@@ -17,6 +17,17 @@ func RegisterJavaScript(r *chunker.Registry) {
 			(export_statement (class_declaration name: (identifier) @name)) @chunk
 			(lexical_declaration (variable_declarator name: (identifier) @name value: (arrow_function))) @chunk
 		`,
-		Extensions: []string{"js", "jsx", "mjs", "cjs"},
+		DocQuery: `
+			(program (comment) @doc)
+			(class_body (comment) @doc)
+		`,
+		Extensions:   []string{"js", "jsx", "mjs", "cjs"},
+		Filenames:    []string{"Jakefile"},
+		Interpreters: []string{"node", "nodejs"},
+		KindAliases: map[string][]string{
+			"function": {"function_declaration", "lexical_declaration"},
+			"class":    {"class_declaration"},
+			"method":   {"method_definition"},
+		},
 	})
 }
@@ -15,6 +15,22 @@ func RegisterPython(r *chunker.Registry) {
 			(decorated_definition definition: (function_definition name: (identifier) @name)) @chunk
 			(decorated_definition definition: (class_definition name: (identifier) @name)) @chunk
 		`,
-		Extensions: []string{"py", "pyi"},
+		ImportQuery: `
+			(module (import_statement) @import)
+			(module (import_from_statement) @import)
+			(module (future_import_statement) @import)
+		`,
+		DocQuery: `
+			(module (comment) @doc)
+			(function_definition body: (block . (expression_statement (string) @docstring)))
+			(class_definition body: (block . (expression_statement (string) @docstring)))
+		`,
+		Extensions:   []string{"py", "pyi"},
+		Filenames:    []string{"SConstruct", "SConscript", "wscript", ".gclient"},
+		Interpreters: []string{"python"},
+		KindAliases: map[string][]string{
+			"function": {"function_definition"},
+			"class":    {"class_definition"},
+		},
 	})
 }
@@ -0,0 +1,31 @@
+package languages
+
+import (
+	"synapse/internal/chunker"
+
+	"github.com/smacker/go-tree-sitter/sql"
+)
+
+// RegisterSQL targets the common DDL subset. Dialect-specific syntax the
+// grammar can't parse is picked up by the statement-level fallback.
+func RegisterSQL(r *chunker.Registry) {
+	r.Register("sql", &chunker.LanguageSpec{
+		Language: sql.GetLanguage(),
+		Query: `
+			(create_table (object_reference) @name) @chunk
+			(create_view (object_reference) @name) @chunk
+			(create_materialized_view (object_reference) @name) @chunk
+			(create_function (object_reference) @name) @chunk
+			(create_index column: (identifier) @name) @chunk
+		`,
+		Extensions: []string{"sql"},
+		Fallback:   true,
+		KindAliases: map[string][]string{
+			"table":     {"create_table"},
+			"view":      {"create_view", "create_materialized_view"},
+			"function":  {"create_function"},
+			"index":     {"create_index"},
+			"statement": {"statement"},
+		},
+	})
+}
@@ -0,0 +1,13 @@
+package languages
+
+import "synapse/internal/chunker"
+
+// RegisterSvelte registers Svelte components. Like Vue components they're
+// split into blocks, with the markup outside <script> and <style> chunked as
+// the template.
+func RegisterSvelte(r *chunker.Registry) {
+	r.Register("svelte", &chunker.LanguageSpec{
+		SFC:        true,
+		Extensions: []string{"svelte"},
+	})
+}
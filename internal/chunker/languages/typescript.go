@@ -19,6 +19,18 @@ func RegisterTypeScript(r *chunker.Registry) {
 			(interface_declaration name: (type_identifier) @name) @chunk
 			(type_alias_declaration name: (type_identifier) @name) @chunk
 		`,
-		Extensions: []string{"ts", "tsx"},
+		DocQuery: `
+			(program (comment) @doc)
+			(class_body (comment) @doc)
+		`,
+		Extensions:   []string{"ts", "tsx"},
+		Interpreters: []string{"ts-node", "tsx"},
+		KindAliases: map[string][]string{
+			"function":  {"function_declaration", "lexical_declaration"},
+			"class":     {"class_declaration"},
+			"method":    {"method_definition"},
+			"interface": {"interface_declaration"},
+			"type":      {"type_alias_declaration"},
+		},
 	})
 }
@@ -0,0 +1,13 @@
+package languages
+
+import "synapse/internal/chunker"
+
+// RegisterVue registers Vue single-file components, whose blocks are chunked
+// with the JavaScript or TypeScript, HTML, and CSS grammars, which must be
+// registered too.
+func RegisterVue(r *chunker.Registry) {
+	r.Register("vue", &chunker.LanguageSpec{
+		SFC:        true,
+		Extensions: []string{"vue"},
+	})
+}
@@ -0,0 +1,94 @@
+package chunker_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"synapse/internal/chunker"
+	"synapse/internal/chunker/languages"
+)
+
+// TestSplitPiecesNumbered checks that the pieces of an oversized definition
+// are numbered, with or without a header, and whole definitions aren't.
+func TestSplitPiecesNumbered(t *testing.T) {
+	var body strings.Builder
+	for i := range 300 {
+		fmt.Fprintf(&body, "\tvalue%03d := compute(%d, \"padding to make the function oversized\")\n\t_ = value%03d\n", i, i, i)
+	}
+	src := "package demo\n\nfunc Big() {\n" + body.String() + "}\n\nfunc Small() {}\n"
+	empty, err := chunker.ParseHeader("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name        string
+		splitBlocks bool
+		header      bool
+	}{{"windows", false, true}, {"windows without a header", false, false}, {"blocks", true, true}} {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := chunker.NewRegistry()
+			languages.RegisterGo(reg)
+			ch := chunker.NewASTChunker(reg)
+			ch.SplitBlocks = tt.splitBlocks
+			if !tt.header {
+				ch.Header = empty
+			}
+			chunks, err := ch.Chunk("demo.go", []byte(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var pieces []int
+			for _, c := range chunks {
+				switch c.Name {
+				case "Big":
+					pieces = append(pieces, c.Piece)
+				case "Small":
+					if c.Piece != 0 {
+						t.Errorf("whole definition Small has piece %d", c.Piece)
+					}
+				}
+			}
+			if len(pieces) < 2 {
+				t.Fatalf("Big was split into %d pieces, want several", len(pieces))
+			}
+			for i, p := range pieces {
+				if p != i+1 {
+					t.Fatalf("pieces of Big numbered %v, want 1 to %d", pieces, len(pieces))
+				}
+			}
+		})
+	}
+}
+
+// TestMaxChunksPrefersWholeDefinitions checks that a file over MaxChunks
+// keeps its largest whole definitions, in order, over the pieces of a split
+// one and doc chunks.
+func TestMaxChunksPrefersWholeDefinitions(t *testing.T) {
+	var src strings.Builder
+	src.WriteString("package demo\n\nfunc Big() {\n")
+	for i := range 600 {
+		fmt.Fprintf(&src, "\tvalue%03d := compute(%d, \"padding to make the function oversized\")\n", i, i)
+	}
+	src.WriteString("}\n")
+	for i, name := range []string{"One", "Two", "Three", "Four", "Five"} {
+		fmt.Fprintf(&src, "\n// %s is documented at some length, so its doc chunk is longer than it.\nfunc %s() {\n%s}\n", name, name, strings.Repeat("\tstep()\n", i+1))
+	}
+	reg := chunker.NewRegistry()
+	languages.RegisterGo(reg)
+	ch := chunker.NewASTChunker(reg)
+	ch.IndexDocs = true
+	ch.MaxChunks = 3
+	chunks, err := ch.Chunk("demo.go", []byte(src.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range chunks {
+		got = append(got, c.Kind+" "+c.Name)
+	}
+	want := []string{"function_declaration Three", "function_declaration Four", "function_declaration Five"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+}
@@ -1,10 +1,15 @@
 package chunker
 
 import (
+	"bytes"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
+	"synapse/internal/walker"
+
 	sitter "github.com/smacker/go-tree-sitter"
 )
 
@@ -13,23 +18,57 @@ type LanguageSpec struct {
 	Language *sitter.Language
 	// Query is a tree-sitter S-expression query that captures top-level
 	// definitions. It must use @chunk for the outer node and @name for the
-	// identifier (optional).
+	// identifier (optional). Several @name captures, such as a block's type
+	// and string labels, are joined with dots, without their quotes.
 	Query      string
 	Extensions []string
+	// Filenames are exact file names that identify the language whatever
+	// their extension, such as "SConstruct" or ".gclient".
+	Filenames []string
+	// Interpreters are the program names in a "#!" line that identify the
+	// language in files without a registered extension (e.g. "python" for
+	// "#!/usr/bin/env python3"). Version suffixes are ignored.
+	Interpreters []string
+	// ImportQuery optionally captures the file's import statements with
+	// @import. When import context is enabled, their text is prepended to
+	// every chunk from the file.
+	ImportQuery string
+	// DocQuery optionally captures doc comments with @doc, which document
+	// the definition starting on the line after them (consecutive comment
+	// lines form one doc), and docstrings with @docstring, which document
+	// the definition they're in. ASTChunker.IndexDocs makes chunks of them.
+	DocQuery string
+	// Fallback enables line-window chunking of source not covered by any
+	// capture when the file fails to parse cleanly or yields no captures.
+	// A spec without a Language, for a format with no grammar, is chunked
+	// in line windows only, and needs it.
+	Fallback bool
+	// SFC marks a single-file component format (Vue, Svelte). Its files are
+	// split into script, template, and style blocks, each chunked with the
+	// registered "javascript"/"typescript", "html", or "css" spec; Language
+	// and Query are unused.
+	SFC bool
+	// KindAliases maps friendly chunk kinds ("function", "class", ...) to
+	// the node types they cover in this grammar, for ASTChunker.Kinds.
+	KindAliases map[string][]string
 }
 
 // Registry maps file extensions to language specs.
 type Registry struct {
-	mu    sync.RWMutex
-	specs map[string]*LanguageSpec // extension (without dot) → spec
-	langs map[string]*LanguageSpec // language name → spec
+	mu           sync.RWMutex
+	specs        map[string]*LanguageSpec // extension (without dot) → spec
+	filenames    map[string]*LanguageSpec // exact file name → spec
+	langs        map[string]*LanguageSpec // language name → spec
+	interpreters map[string]*LanguageSpec // shebang program → spec
 }
 
 // NewRegistry creates an empty registry.
 func NewRegistry() *Registry {
 	return &Registry{
-		specs: make(map[string]*LanguageSpec),
-		langs: make(map[string]*LanguageSpec),
+		specs:        make(map[string]*LanguageSpec),
+		filenames:    make(map[string]*LanguageSpec),
+		langs:        make(map[string]*LanguageSpec),
+		interpreters: make(map[string]*LanguageSpec),
 	}
 }
 
@@ -41,24 +80,100 @@ func (r *Registry) Register(name string, spec *LanguageSpec) {
 	for _, ext := range spec.Extensions {
 		r.specs[ext] = spec
 	}
+	for _, name := range spec.Filenames {
+		r.filenames[name] = spec
+	}
+	for _, prog := range spec.Interpreters {
+		r.interpreters[prog] = spec
+	}
 }
 
-// Lookup returns the spec for a file path based on its extension, or nil.
+// Lookup returns the spec for a file path based on its file name or
+// extension, or nil.
 func (r *Registry) Lookup(path string) (spec *LanguageSpec, lang string) {
-	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	base := filepath.Base(path)
+	ext := strings.TrimPrefix(filepath.Ext(base), ".")
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	if s, ok := r.filenames[base]; ok {
+		return s, r.nameOf(s, base)
+	}
 	s, ok := r.specs[ext]
 	if !ok {
 		return nil, ""
 	}
-	// Find the language name for this spec.
+	return s, r.nameOf(s, ext)
+}
+
+// Detect is like Lookup, but when the file name and extension aren't
+// registered it falls back to the interpreter in a "#!" line in the first
+// walker.SniffSize bytes of src. A registered extension always wins: content
+// isn't used to tell apart languages sharing one, such as C and C++ headers.
+func (r *Registry) Detect(path string, src []byte) (spec *LanguageSpec, lang string) {
+	if spec, lang := r.Lookup(path); spec != nil {
+		return spec, lang
+	}
+	prog := shebangInterpreter(src)
+	if prog == "" {
+		return nil, ""
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.interpreters[prog]
+	if !ok {
+		return nil, ""
+	}
+	return s, r.nameOf(s, prog)
+}
+
+// nameOf returns the language name spec is registered under, or fallback.
+// The caller must hold r.mu.
+func (r *Registry) nameOf(spec *LanguageSpec, fallback string) string {
 	for name, sp := range r.langs {
-		if sp == s {
-			return s, name
+		if sp == spec {
+			return name
 		}
 	}
-	return s, ext
+	return fallback
+}
+
+// shebangInterpreter returns the program named by a "#!" line at the start
+// of src, without directory or version suffix, or "" if there is none.
+// "#!/usr/bin/env -S python3.11 -u" yields "python".
+func shebangInterpreter(src []byte) string {
+	if len(src) > walker.SniffSize {
+		src = src[:walker.SniffSize]
+	}
+	line, ok := bytes.CutPrefix(src, []byte("#!"))
+	if !ok {
+		return ""
+	}
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+	prog := path.Base(fields[0])
+	if prog == "env" {
+		prog = ""
+		// Skip env's options and VAR=value assignments.
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				prog = path.Base(f)
+				break
+			}
+		}
+	}
+	return strings.TrimRight(prog, "0123456789.")
+}
+
+// Language returns the spec registered under name, or nil.
+func (r *Registry) Language(name string) *LanguageSpec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.langs[name]
 }
 
 // LanguageName returns the language name for a file path, or "".
@@ -67,6 +182,25 @@ func (r *Registry) LanguageName(path string) string {
 	return lang
 }
 
+// KindAliases returns the friendly chunk kinds defined by any registered
+// language, sorted.
+func (r *Registry) KindAliases() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	seen := make(map[string]bool)
+	for _, spec := range r.langs {
+		for alias := range spec.KindAliases {
+			seen[alias] = true
+		}
+	}
+	aliases := make([]string, 0, len(seen))
+	for alias := range seen {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
 // Extensions returns the set of all registered file extensions (without dot).
 func (r *Registry) Extensions() map[string]bool {
 	r.mu.RLock()
@@ -77,3 +211,14 @@ func (r *Registry) Extensions() map[string]bool {
 	}
 	return exts
 }
+
+// Filenames returns the set of all registered exact file names.
+func (r *Registry) Filenames() map[string]bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make(map[string]bool, len(r.filenames))
+	for name := range r.filenames {
+		names[name] = true
+	}
+	return names
+}
@@ -0,0 +1,161 @@
+package chunker
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// sfcTagRe matches the opening tag of a top-level single-file component block.
+var sfcTagRe = regexp.MustCompile(`(?i)<(script|template|style)\b([^>]*)>`)
+
+// sfcLangRe extracts a block's lang attribute, e.g. lang="ts".
+var sfcLangRe = regexp.MustCompile(`(?i)\blang\s*=\s*["']?([\w-]+)`)
+
+// sfcBlock is one block of a single-file component: the byte ranges of its
+// content, without the enclosing tags.
+type sfcBlock struct {
+	tag    string // "script", "template", or "style"
+	lang   string // the lang attribute, lowercased, or ""
+	ranges [][2]int
+}
+
+// splitSFC finds the top-level <script>, <template>, and <style> blocks of a
+// Vue or Svelte component. Svelte has no <template> block, so when markup is
+// set everything outside script and style blocks becomes one template block.
+func splitSFC(src []byte, markup bool) []sfcBlock {
+	var blocks []sfcBlock
+	var outside [][2]int
+	pos := 0
+	for pos < len(src) {
+		m := sfcTagRe.FindSubmatchIndex(src[pos:])
+		if m == nil {
+			break
+		}
+		tagStart, contentStart := pos+m[0], pos+m[1]
+		tag := strings.ToLower(string(src[pos+m[2] : pos+m[3]]))
+		attrs := src[pos+m[4] : pos+m[5]]
+		outside = append(outside, [2]int{pos, tagStart})
+
+		if bytes.HasSuffix(bytes.TrimSpace(attrs), []byte("/")) {
+			pos = contentStart // self-closing, no content
+			continue
+		}
+		contentEnd, next := closingTag(src, contentStart, tag)
+		b := sfcBlock{tag: tag, ranges: [][2]int{{contentStart, contentEnd}}}
+		if lm := sfcLangRe.FindSubmatch(attrs); lm != nil {
+			b.lang = strings.ToLower(string(lm[1]))
+		}
+		blocks = append(blocks, b)
+		pos = next
+	}
+	outside = append(outside, [2]int{pos, len(src)})
+
+	if markup {
+		blocks = append(blocks, sfcBlock{tag: "template", ranges: outside})
+	}
+	return blocks
+}
+
+// closingTag returns where the content of a tag block opened before from
+// ends, and where the source after its closing tag starts. Template blocks
+// may nest <template> elements; script and style content can't contain
+// their closing tag. An unclosed block runs to the end of src.
+func closingTag(src []byte, from int, tag string) (contentEnd, next int) {
+	// Lowercase ASCII only, so offsets in lower match src.
+	lower := make([]byte, len(src))
+	for i, ch := range src {
+		if 'A' <= ch && ch <= 'Z' {
+			ch += 'a' - 'A'
+		}
+		lower[i] = ch
+	}
+	open := []byte("<" + tag)
+	closing := []byte("</" + tag)
+	depth := 0
+	for pos := from; ; {
+		c := bytes.Index(lower[pos:], closing)
+		if c < 0 {
+			return len(src), len(src)
+		}
+		c += pos
+		if tag == "template" {
+			// Count nested openings before this closing tag.
+			for o := bytes.Index(lower[pos:c], open); o >= 0; o = bytes.Index(lower[pos:c], open) {
+				depth++
+				pos += o + len(open)
+			}
+		}
+		if depth == 0 {
+			end := bytes.IndexByte(src[c:], '>')
+			if end < 0 {
+				return c, len(src)
+			}
+			return c, c + end + 1
+		}
+		depth--
+		pos = c + len(closing)
+	}
+}
+
+// blockLanguage returns the registered language that chunks a block.
+func blockLanguage(b sfcBlock) string {
+	switch b.tag {
+	case "script":
+		if b.lang == "ts" || b.lang == "tsx" || b.lang == "typescript" {
+			return "typescript"
+		}
+		return "javascript"
+	case "template":
+		if b.lang == "" || b.lang == "html" {
+			return "html"
+		}
+	case "style":
+		if b.lang == "" || b.lang == "css" || b.lang == "postcss" {
+			return "css"
+		}
+	}
+	return "" // e.g. Pug templates or Sass, which have no grammar here
+}
+
+// maskOutside returns a copy of src with every byte outside ranges, other
+// than newlines, replaced by a space, so a block parses on its own while
+// line numbers and offsets still match the whole file.
+func maskOutside(src []byte, ranges [][2]int) []byte {
+	masked := bytes.Repeat([]byte(" "), len(src))
+	for i, ch := range src {
+		if ch == '\n' {
+			masked[i] = '\n'
+		}
+	}
+	for _, r := range ranges {
+		copy(masked[r[0]:r[1]], src[r[0]:r[1]])
+	}
+	return masked
+}
+
+// chunkSFC chunks each block of a single-file component with its own
+// grammar. Blocks in languages without a registered grammar are skipped.
+func (c *ASTChunker) chunkSFC(path, lang string, src []byte) ([]RawChunk, error) {
+	var chunks []RawChunk
+	for _, b := range splitSFC(src, lang == "svelte") {
+		blockLang := blockLanguage(b)
+		spec := c.registry.Language(blockLang)
+		if spec == nil {
+			continue
+		}
+		label := fmt.Sprintf("%s (%s block, %s)", lang, b.tag, blockLang)
+		// Lines of the block that no capture covers are chunked in windows,
+		// so e.g. the top-level statements of <script setup> aren't lost.
+		blockChunks, err := c.chunkSource(path, label, spec, maskOutside(src, b.ranges), true)
+		if err != nil {
+			return nil, err
+		}
+		for i := range blockChunks {
+			blockChunks[i].Block = b.tag
+		}
+		chunks = append(chunks, blockChunks...)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,79 @@
+package chunker
+
+// MergedKind is the kind of a chunk merged from small definitions of
+// different kinds (see ASTChunker.MinChunkLines).
+const MergedKind = "merged"
+
+// small reports whether code spanning lines lines and bytes bytes falls
+// short of MinChunkLines or MinChunkBytes.
+func (c *ASTChunker) small(lines, bytes int) bool {
+	return lines < c.MinChunkLines || bytes < c.MinChunkBytes
+}
+
+// mergeSmall merges runs of adjacent captures that are each too small (see
+// small) into captures spanning them, closing each once it's big enough or
+// would outgrow maxChunkBytes. Small captures left over at the end of a run
+// join the capture merged before them if they fit; a small capture with no
+// small neighbor is kept as it is, so its doc chunk and the fallback, which
+// count it as covered, stay consistent with it. caps must be deduplicated
+// and in source order.
+func (c *ASTChunker) mergeSmall(caps []capture) []capture {
+	if c.MinChunkLines <= 0 && c.MinChunkBytes <= 0 {
+		return caps
+	}
+	var out, group []capture
+	runStart := 0 // the index in out of the current run's first merged capture
+	flush := func() {
+		if len(group) == 0 {
+			return
+		}
+		first, last := group[0], group[len(group)-1]
+		switch {
+		case c.small(last.endLine-first.startLine+1, int(last.endByte-first.startByte)) &&
+			len(out) > runStart && int(last.endByte-out[len(out)-1].startByte) <= maxChunkBytes:
+			out[len(out)-1] = mergeCaptures(append([]capture{out[len(out)-1]}, group...))
+		case len(group) > 1:
+			out = append(out, mergeCaptures(group))
+		default:
+			out = append(out, first)
+		}
+		group = nil
+	}
+	for _, cp := range caps {
+		if !c.small(cp.endLine-cp.startLine+1, int(cp.endByte-cp.startByte)) {
+			flush()
+			out = append(out, cp)
+			runStart = len(out)
+			continue
+		}
+		if len(group) > 0 && int(cp.endByte-group[0].startByte) > maxChunkBytes {
+			flush()
+		}
+		group = append(group, cp)
+		first := group[0]
+		if len(group) > 1 && !c.small(cp.endLine-first.startLine+1, int(cp.endByte-first.startByte)) {
+			flush()
+		}
+	}
+	flush()
+	return out
+}
+
+// mergeCaptures returns an unnamed capture spanning group, of its captures'
+// kind if they share one and MergedKind otherwise.
+func mergeCaptures(group []capture) capture {
+	first, last := group[0], group[len(group)-1]
+	m := capture{
+		kind:      first.kind,
+		startLine: first.startLine,
+		endLine:   last.endLine,
+		startByte: first.startByte,
+		endByte:   last.endByte,
+	}
+	for _, g := range group[1:] {
+		if g.kind != m.kind {
+			m.kind = MergedKind
+		}
+	}
+	return m
+}
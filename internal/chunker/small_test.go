@@ -0,0 +1,44 @@
+package chunker_test
+
+import (
+	"testing"
+
+	"synapse/internal/chunker"
+	"synapse/internal/chunker/languages"
+)
+
+// TestMergeSmallKeepsLoneCapture checks that a small definition with no
+// small neighbor keeps its chunk, next to its doc chunk.
+func TestMergeSmallKeepsLoneCapture(t *testing.T) {
+	reg := chunker.NewRegistry()
+	languages.RegisterGo(reg)
+	ch := chunker.NewASTChunker(reg)
+	ch.MinChunkLines = 3
+	ch.IndexDocs = true
+	src := `package demo
+
+// Big documents a function long enough to stand on its own in the index.
+func Big() {
+	a := 1
+	b := 2
+	_ = a + b
+}
+
+// Tiny is a one-line function, documented well enough for a doc chunk.
+func Tiny() {}
+`
+	chunks, err := ch.Chunk("demo.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[string][]string)
+	for _, c := range chunks {
+		kinds[c.Name] = append(kinds[c.Name], c.Kind)
+	}
+	want := []string{"function_declaration", chunker.DocKind}
+	for _, name := range []string{"Big", "Tiny"} {
+		if len(kinds[name]) != 2 || kinds[name][0] != want[0] || kinds[name][1] != want[1] {
+			t.Errorf("chunks of %s have kinds %v, want %v", name, kinds[name], want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Package config reads and writes .synapse/config.toml, which holds project
+// defaults for command-line flags. The file is a flat list of TOML
+// "key = value" pairs; flags given on the command line take precedence.
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is the value type of a setting.
+type Type int
+
+const (
+	String Type = iota
+	Bool
+	Int
+	Float
+	// Duration is a Go duration such as 30s or 1m30s, stored as a string.
+	Duration
+)
+
+// Key is a known setting. Its value is the default of the flag of the same
+// name with dashes, e.g. chat_model sets --chat-model.
+type Key struct {
+	Name string
+	Type Type
+	// Min is the smallest allowed value of an Int or Float setting.
+	Min int
+	// Max is the largest allowed value of an Int or Float setting; 0 means
+	// no limit.
+	Max         int
+	Description string
+}
+
+// Flag returns the name of the flag the key sets.
+func (k Key) Flag() string { return strings.ReplaceAll(k.Name, "_", "-") }
+
+// Keys lists the known settings, sorted by name.
+var Keys = []Key{
+	{Name: "chat_model", Type: String, Description: "generative model for chat"},
+	{Name: "chunk_header", Type: String, Description: "how chunks are framed for embedding: comment, prose, or a template file"},
+	{Name: "context_budget", Type: Int, Min: 0, Description: "approximate token budget for retrieved chunks in chat (0 = unlimited)"},
+	{Name: "embed_max_bytes", Type: Int, Min: -1, Description: "longest input sent to the embedding model when indexing (-1 = no limit)"},
+	{Name: "exclude_symbols", Type: String, Description: "comma-separated symbol name patterns left out when indexing, e.g. init,Test*"},
+	{Name: "file_summaries", Type: Bool, Description: "include cited files' summaries in the chat context"},
+	{Name: "index_docs_as_chunks", Type: Bool, Description: "also index doc comments and docstrings as chunks of their own"},
+	{Name: "k", Type: Int, Min: 1, Description: "number of chunks retrieved by chat and search"},
+	{Name: "keep_alive", Type: Duration, Description: "how long Ollama keeps models loaded between requests, e.g. 30m"},
+	{Name: "keyword_query_words", Type: Int, Min: 1, Description: "most words a query naming an identifier can have for keyword matches to count more"},
+	{Name: "log_queries", Type: Bool, Description: "record queries in queries.jsonl next to the index"},
+	{Name: "max_chunks_per_file", Type: Int, Min: -1, Description: "maximum chunks indexed per file; files with more keep only their largest definitions (-1 = no limit)"},
+	{Name: "max_splits", Type: Int, Min: -1, Description: "maximum pieces an oversized function or class is split into (-1 = no limit)"},
+	{Name: "min_chunk_bytes", Type: Int, Min: 0, Description: "merge definitions shorter than this many bytes with adjacent small ones when indexing (0 = off)"},
+	{Name: "min_chunk_lines", Type: Int, Min: 0, Description: "merge definitions shorter than this many lines with adjacent small ones when indexing (0 = off)"},
+	{Name: "model", Type: String, Description: "embedding model"},
+	{Name: "normalize_embeddings", Type: Bool, Description: "scale embeddings to unit length so distances rank like cosine similarity"},
+	{Name: "ollama", Type: String, Description: "Ollama base URL"},
+	{Name: "overview_model", Type: String, Description: "model for file summaries and the overview"},
+	{Name: "overview_symbols", Type: Int, Min: -1, Description: "maximum symbols listed per file in the overview prompt (-1 = no limit)"},
+	{Name: "prompt_template", Type: String, Description: "file of instructions for writing the project overview"},
+	{Name: "query_cache", Type: Int, Min: 0, Description: "cache results for up to N recent queries (0 = disabled)"},
+	{Name: "query_timeout", Type: Duration, Description: "how long embedding a search query or chat question may take, e.g. 1m"},
+	{Name: "query_weight", Type: Float, Min: 1, Description: "how much more keyword or vector matches count for queries that favor them (1 = always alike)"},
+	{Name: "recency_weight", Type: Float, Min: 0, Max: 1, Description: "from 0 to 1, how much results from recently modified files are favored (0 = off)"},
+	{Name: "semantic_query_words", Type: Int, Min: 1, Description: "fewest words a plain-language query needs for vector matches to count more"},
+	{Name: "snapshots", Type: Int, Min: 0, Description: "copies of the index kept in snapshots/ from before each index run, for 'synapse rollback' (0 = no snapshots)"},
+	{Name: "split_blocks", Type: Bool, Description: "split oversized functions and classes between statements instead of into line windows"},
+	{Name: "summary_budget", Type: Int, Min: 0, Description: "approximate token budget for file summaries in chat (0 = unlimited)"},
+	{Name: "tokenizer", Type: String, Description: "FTS5 tokenizer for keyword search"},
+	{Name: "verify", Type: Bool, Description: "warn when a chat answer mentions files or symbols not in the retrieved code"},
+	{Name: "workers", Type: Int, Min: 1, Description: "parallel indexing workers"},
+}
+
+// Lookup returns the known setting called name.
+func Lookup(name string) (Key, bool) {
+	for _, k := range Keys {
+		if k.Name == name {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// Validate checks value against the key's type and returns it normalized,
+// e.g. "TRUE" as "true".
+func (k Key) Validate(value string) (string, error) {
+	switch k.Type {
+	case Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%s must be true or false, got %q", k.Name, value)
+		}
+		return strconv.FormatBool(b), nil
+	case Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", fmt.Errorf("%s must be an integer, got %q", k.Name, value)
+		}
+		if n < k.Min {
+			return "", fmt.Errorf("%s must be at least %d, got %d", k.Name, k.Min, n)
+		}
+		if k.Max != 0 && n > k.Max {
+			return "", fmt.Errorf("%s must be at most %d, got %d", k.Name, k.Max, n)
+		}
+		return strconv.Itoa(n), nil
+	case Float:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("%s must be a number, got %q", k.Name, value)
+		}
+		if f < float64(k.Min) {
+			return "", fmt.Errorf("%s must be at least %d, got %g", k.Name, k.Min, f)
+		}
+		if k.Max != 0 && f > float64(k.Max) {
+			return "", fmt.Errorf("%s must be at most %d, got %g", k.Name, k.Max, f)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	case Duration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return "", fmt.Errorf("%s must be a duration such as 30s or 5m, got %q", k.Name, value)
+		}
+	}
+	return value, nil
+}
+
+// Path returns the config file location for the index at dbPath.
+func Path(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "config.toml")
+}
+
+// Load reads the settings in the file at path. A missing file has none.
+// Unknown keys and invalid values are errors, so typos don't go unnoticed;
+// the settings on the other lines are still returned alongside the error,
+// so 'synapse config' can show and repair the file.
+func Load(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	var errs []error
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s:%d: expected key = value", path, n))
+			continue
+		}
+		name = strings.TrimSpace(name)
+		key, ok := Lookup(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s:%d: unknown setting %q", path, n, name))
+			continue
+		}
+		value, err := parseValue(strings.TrimSpace(raw))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %s: %w", path, n, name, err))
+			continue
+		}
+		if value, err = key.Validate(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", path, n, err))
+			continue
+		}
+		values[name] = value
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return values, errors.Join(errs...)
+}
+
+// parseValue decodes a TOML scalar: a quoted string, or a bare integer or
+// boolean, optionally followed by a comment.
+func parseValue(raw string) (string, error) {
+	if strings.HasPrefix(raw, `"`) {
+		end := closingQuote(raw)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return strconv.Unquote(raw[:end+1])
+	}
+	if i := strings.IndexByte(raw, '#'); i >= 0 {
+		raw = strings.TrimSpace(raw[:i])
+	}
+	if raw == "" {
+		return "", fmt.Errorf("missing value")
+	}
+	return raw, nil
+}
+
+// closingQuote returns the index of the quote ending the string s starts
+// with, or -1.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// Save writes values to the file at path, sorted by key, replacing it.
+func Save(path string, values map[string]string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("# synapse settings; see 'synapse config list'.\n")
+	for _, name := range names {
+		value := values[name]
+		if key, ok := Lookup(name); !ok || key.Type == String || key.Type == Duration {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&b, "%s = %s\n", name, value)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"synapse/internal/rag"
+	"synapse/internal/store"
+)
+
+// dialTimeout bounds connecting to the socket, so a dead daemon doesn't
+// delay the fallback to direct mode.
+const dialTimeout = 500 * time.Millisecond
+
+// Client talks to a running daemon.
+type Client struct {
+	sock   string
+	Model  string // embedding model the daemon queries with
+	DBPath string // index the daemon serves
+	Root   string // project root recorded in the index, if any
+}
+
+// Connect checks that a daemon is listening on sock and returns a client
+// for it.
+func Connect(sock string) (*Client, error) {
+	c := &Client{sock: sock}
+	resp, err := c.call(request{Op: "ping"})
+	if err != nil {
+		return nil, err
+	}
+	c.Model = resp.Model
+	c.DBPath = resp.DBPath
+	c.Root = resp.Root
+	return c, nil
+}
+
+// Retrieve runs hybrid retrieval in the daemon with the given options.
+func (c *Client) Retrieve(query string, opts rag.Options) ([]store.SearchResult, error) {
+	resp, err := c.call(request{Op: "retrieve", Query: query, Options: opts})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// Stop asks the daemon to shut down.
+func (c *Client) Stop() error {
+	_, err := c.call(request{Op: "stop"})
+	return err
+}
+
+func (c *Client) call(req request) (*response, error) {
+	conn, err := net.DialTimeout("unix", c.sock, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
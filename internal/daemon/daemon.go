@@ -0,0 +1,175 @@
+// Package daemon keeps an index open in a long-running process and answers
+// retrieval queries over a Unix domain socket, so repeated CLI invocations
+// skip opening the store and share one query cache.
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"synapse/internal/embedder"
+	"synapse/internal/rag"
+	"synapse/internal/store"
+)
+
+// DefaultIdleTimeout is how long the daemon waits without requests before
+// shutting itself down.
+const DefaultIdleTimeout = 30 * time.Minute
+
+// cacheSize is the number of queries the shared cache holds.
+const cacheSize = 256
+
+// SocketPath returns the socket location for the index at dbPath.
+func SocketPath(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "daemon.sock")
+}
+
+// request is one client call. Each connection carries a single request and
+// its response, JSON-encoded.
+type request struct {
+	Op      string      `json:"op"` // "ping", "retrieve", or "stop"
+	Query   string      `json:"query,omitempty"`
+	Options rag.Options `json:"options"`
+}
+
+type response struct {
+	Error   string               `json:"error,omitempty"`
+	Model   string               `json:"model,omitempty"`
+	DBPath  string               `json:"db_path,omitempty"`
+	Root    string               `json:"root,omitempty"`
+	Results []store.SearchResult `json:"results,omitempty"`
+}
+
+// Server answers retrieval requests against an open store.
+type Server struct {
+	// Log, if set, records the queries the server retrieves.
+	Log *rag.QueryLog
+
+	store  store.Store
+	emb    embedder.Embedder
+	dbPath string
+	cache  *rag.Cache
+	idle   time.Duration
+
+	mu       sync.Mutex
+	listener net.Listener
+	timer    *time.Timer
+	stopped  bool
+}
+
+// NewServer creates a server for the store at dbPath. It shuts down after
+// idle without requests; idle <= 0 disables the timeout.
+func NewServer(st store.Store, emb embedder.Embedder, dbPath string, idle time.Duration) *Server {
+	return &Server{
+		store:  st,
+		emb:    emb,
+		dbPath: dbPath,
+		cache:  rag.NewCache(cacheSize, rag.DefaultCacheTTL),
+		idle:   idle,
+	}
+}
+
+// Serve listens on the index's socket and handles requests until Stop is
+// called, a client sends "stop", or the idle timeout passes. It refuses to
+// start if another daemon is already serving the index.
+func (s *Server) Serve() error {
+	sock := SocketPath(s.dbPath)
+	if _, err := Connect(sock); err == nil {
+		return fmt.Errorf("a daemon is already running on %s", sock)
+	}
+	// A leftover socket from a crashed daemon blocks Listen.
+	if err := os.Remove(sock); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove stale socket: %w", err)
+	}
+
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", sock, err)
+	}
+	defer os.Remove(sock)
+
+	s.mu.Lock()
+	s.listener = l
+	if s.idle > 0 {
+		s.timer = time.AfterFunc(s.idle, s.Stop)
+	}
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			stopped := s.stopped
+			s.mu.Unlock()
+			if stopped || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.touch()
+		go s.handle(conn)
+	}
+}
+
+// Stop closes the listener, ending Serve.
+func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if s.listener != nil {
+		s.listener.Close()
+	}
+}
+
+// touch restarts the idle timer.
+func (s *Server) touch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		s.timer.Reset(s.idle)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(response{Error: fmt.Sprintf("decode request: %v", err)})
+		return
+	}
+
+	var resp response
+	switch req.Op {
+	case "ping":
+		resp.Model = s.emb.Model()
+		resp.DBPath = s.dbPath
+		resp.Root, _ = s.store.ProjectRoot()
+	case "retrieve":
+		r := rag.NewRetriever(s.store, s.emb, req.Options)
+		r.Cache = s.cache
+		r.Log = s.Log
+		results, err := r.Retrieve(req.Query)
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		resp.Results = results
+	case "stop":
+		defer s.Stop()
+	default:
+		resp.Error = fmt.Sprintf("unknown op %q", req.Op)
+	}
+	json.NewEncoder(conn).Encode(resp)
+}
@@ -0,0 +1,105 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Embedder turns text into embedding vectors. OllamaEmbedder is the default
+// implementation; any other backend satisfying this interface can be used for
+// indexing and retrieval.
+type Embedder interface {
+	// Embed returns one embedding per input text, in the same order.
+	Embed(texts []string) ([][]float32, error)
+	// EmbedSingle embeds a single text and returns its vector.
+	EmbedSingle(text string) ([]float32, error)
+	// Model returns the model name recorded in the index metadata.
+	Model() string
+}
+
+// Pinger is implemented by embedders backed by a remote service that can be
+// health-checked before long operations.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// ContextEmbedder is implemented by embedders whose requests can be
+// cancelled or bounded by a deadline.
+type ContextEmbedder interface {
+	EmbedContext(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbedContext embeds texts with e, honoring ctx when e supports it. For
+// other embedders it only checks ctx before the call.
+func EmbedContext(ctx context.Context, e Embedder, texts []string) ([][]float32, error) {
+	if ce, ok := e.(ContextEmbedder); ok {
+		return ce.EmbedContext(ctx, texts)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return e.Embed(texts)
+}
+
+// Dimensioner is implemented by embedders that know (or cache) the size of
+// the vectors they produce.
+type Dimensioner interface {
+	Dimension() (int, error)
+}
+
+// Dimension returns the vector size e produces, probing the model with a
+// short string if e doesn't implement Dimensioner.
+func Dimension(e Embedder) (int, error) {
+	if d, ok := e.(Dimensioner); ok {
+		return d.Dimension()
+	}
+	return probeDimension(e)
+}
+
+// probeDimension embeds a short string as a batch, since the model may have
+// to load first and EmbedSingle is allowed less time than batches.
+func probeDimension(e Embedder) (int, error) {
+	vecs, err := e.Embed([]string{"dimension probe"})
+	if err != nil {
+		return 0, fmt.Errorf("probe embedding dimension: %w", err)
+	}
+	if len(vecs) != 1 || len(vecs[0]) == 0 {
+		return 0, fmt.Errorf("probe embedding dimension: model %q returned an empty vector", e.Model())
+	}
+	return len(vecs[0]), nil
+}
+
+// ErrNonFinite is returned for embeddings containing NaN or infinite values,
+// which make every distance to them meaningless.
+var ErrNonFinite = errors.New("embedding contains NaN or infinite values")
+
+// Validate returns ErrNonFinite if v contains NaN or infinite values.
+func Validate(v []float32) error {
+	for _, x := range v {
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return ErrNonFinite
+		}
+	}
+	return nil
+}
+
+// IsZero reports whether every component of v is zero. Such a vector has no
+// direction, so its cosine distance to anything is undefined; models return
+// them for empty or degenerate inputs.
+func IsZero(v []float32) bool {
+	for _, x := range v {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	_ Embedder        = (*OllamaEmbedder)(nil)
+	_ Pinger          = (*OllamaEmbedder)(nil)
+	_ ContextEmbedder = (*OllamaEmbedder)(nil)
+	_ Dimensioner     = (*OllamaEmbedder)(nil)
+)
@@ -0,0 +1,39 @@
+package embedder
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestDegenerateVectors(t *testing.T) {
+	nan, inf := float32(math.NaN()), float32(math.Inf(1))
+	tests := []struct {
+		name    string
+		v       []float32
+		invalid bool
+		zero    bool
+	}{
+		{name: "normal", v: []float32{0.6, -0.8, 0}},
+		{name: "duplicate components", v: []float32{0.5, 0.5, 0.5, 0.5}},
+		{name: "zero", v: []float32{0, 0, 0}, zero: true},
+		{name: "negative zero", v: []float32{float32(math.Copysign(0, -1)), 0}, zero: true},
+		{name: "NaN", v: []float32{0.1, nan, 0.2}, invalid: true},
+		{name: "all NaN", v: []float32{nan, nan}, invalid: true},
+		{name: "infinite", v: []float32{0, -inf}, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.v)
+			if tt.invalid != (err != nil) {
+				t.Errorf("Validate = %v, want invalid %v", err, tt.invalid)
+			}
+			if err != nil && !errors.Is(err, ErrNonFinite) {
+				t.Errorf("Validate = %v, want ErrNonFinite", err)
+			}
+			if got := IsZero(tt.v); got != tt.zero {
+				t.Errorf("IsZero = %v, want %v", got, tt.zero)
+			}
+		})
+	}
+}
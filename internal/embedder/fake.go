@@ -0,0 +1,113 @@
+package embedder
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// FakeEmbedder produces deterministic vectors without a model, for tests and
+// examples that run offline. Each text is embedded as a normalized bag of its
+// hashed words, with identifiers split at camelCase and snake_case
+// boundaries, so texts sharing words are close and search ordering is stable.
+type FakeEmbedder struct {
+	dim int
+}
+
+// NewFakeEmbedder creates a fake embedder producing dim-sized vectors.
+func NewFakeEmbedder(dim int) *FakeEmbedder {
+	return &FakeEmbedder{dim: dim}
+}
+
+// Model returns a name that records the dimension, e.g. "fake-64", so an
+// index built with one dimension isn't reused with another.
+func (e *FakeEmbedder) Model() string { return fmt.Sprintf("fake-%d", e.dim) }
+
+// Dimension returns the configured vector size.
+func (e *FakeEmbedder) Dimension() (int, error) {
+	if e.dim <= 0 {
+		return 0, fmt.Errorf("fake embedder: invalid dimension %d", e.dim)
+	}
+	return e.dim, nil
+}
+
+// Embed returns one vector per text.
+func (e *FakeEmbedder) Embed(texts []string) ([][]float32, error) {
+	if _, err := e.Dimension(); err != nil {
+		return nil, err
+	}
+	vecs := make([][]float32, len(texts))
+	for i, t := range texts {
+		vecs[i] = e.vector(t)
+	}
+	return vecs, nil
+}
+
+// EmbedSingle embeds a single text.
+func (e *FakeEmbedder) EmbedSingle(text string) ([]float32, error) {
+	vecs, err := e.Embed([]string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vecs[0], nil
+}
+
+func (e *FakeEmbedder) vector(text string) []float32 {
+	v := make([]float32, e.dim)
+	for _, w := range fakeWords(text) {
+		h := fnv.New64a()
+		h.Write([]byte(w))
+		sum := h.Sum64()
+		// The sign bit spreads collisions so they cancel out rather than
+		// pile up in one direction.
+		if sum>>63 == 1 {
+			v[sum%uint64(e.dim)]--
+		} else {
+			v[sum%uint64(e.dim)]++
+		}
+	}
+
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		// Text without words still gets a valid unit vector.
+		v[0] = 1
+		return v
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range v {
+		v[i] *= scale
+	}
+	return v
+}
+
+// fakeWords lowercases text and splits it into words, breaking identifiers
+// such as parseHTTPRequest or max_retries into their parts.
+func fakeWords(text string) []string {
+	var words []string
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		runes := []rune(field)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			lowerToUpper := unicode.IsLower(runes[i-1]) && unicode.IsUpper(runes[i])
+			acronymEnd := i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i+1])
+			if lowerToUpper || acronymEnd {
+				words = append(words, strings.ToLower(string(runes[start:i])))
+				start = i
+			}
+		}
+		words = append(words, strings.ToLower(string(runes[start:])))
+	}
+	return words
+}
+
+var (
+	_ Embedder    = (*FakeEmbedder)(nil)
+	_ Dimensioner = (*FakeEmbedder)(nil)
+)
@@ -2,18 +2,38 @@ package embedder
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
+
+	"synapse/internal/ollama"
 )
 
+// DefaultQueryTimeout bounds EmbedSingle unless QueryTimeout is set.
+const DefaultQueryTimeout = 30 * time.Second
+
 // OllamaEmbedder calls the Ollama /api/embed endpoint.
 type OllamaEmbedder struct {
 	baseURL string
 	model   string
 	client  *http.Client
+
+	// KeepAlive is how long Ollama keeps the model loaded after a request,
+	// so consecutive batches don't wait for it to reload. 0 uses the
+	// server's default (5 minutes); a negative value keeps it loaded.
+	KeepAlive time.Duration
+	// QueryTimeout bounds EmbedSingle, which embeds search queries, so a
+	// stalled server fails an interactive query quickly instead of after
+	// the two minutes a large batch is allowed. 0 uses DefaultQueryTimeout.
+	QueryTimeout time.Duration
+
+	mu  sync.Mutex
+	dim int // cached by Dimension
 }
 
 // NewOllamaEmbedder creates an embedder targeting the given Ollama instance.
@@ -30,9 +50,39 @@ func NewOllamaEmbedder(baseURL, model string) *OllamaEmbedder {
 // Model returns the configured model name.
 func (e *OllamaEmbedder) Model() string { return e.model }
 
+// Dimension returns the model's vector size. The first call embeds a probe
+// string; later calls return the cached result.
+func (e *OllamaEmbedder) Dimension() (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.dim == 0 {
+		dim, err := probeDimension(e)
+		if err != nil {
+			return 0, err
+		}
+		e.dim = dim
+	}
+	return e.dim, nil
+}
+
+// Ping checks that Ollama is reachable by fetching its model list. It fails
+// fast so callers can report an unreachable server before doing expensive work.
+func (e *OllamaEmbedder) Ping(ctx context.Context) error {
+	return ollama.Ping(ctx, e.client, e.baseURL)
+}
+
 type embedRequest struct {
-	Model string   `json:"model"`
-	Input []string `json:"input"`
+	Model     string   `json:"model"`
+	Input     []string `json:"input"`
+	KeepAlive string   `json:"keep_alive,omitempty"`
+}
+
+// singleEmbedRequest embeds one text, passed as a string rather than a
+// batch of one.
+type singleEmbedRequest struct {
+	Model     string `json:"model"`
+	Input     string `json:"input"`
+	KeepAlive string `json:"keep_alive,omitempty"`
 }
 
 type embedResponse struct {
@@ -42,19 +92,81 @@ type embedResponse struct {
 // Embed sends a batch of texts to Ollama and returns their embeddings.
 // The returned slice has the same length and order as the input.
 func (e *OllamaEmbedder) Embed(texts []string) ([][]float32, error) {
+	return e.EmbedContext(context.Background(), texts)
+}
+
+// EmbedContext is like Embed but aborts the request when ctx is done.
+func (e *OllamaEmbedder) EmbedContext(ctx context.Context, texts []string) ([][]float32, error) {
 	if len(texts) == 0 {
 		return nil, nil
 	}
 
-	body, err := json.Marshal(embedRequest{
-		Model: e.model,
-		Input: texts,
+	result, err := e.post(ctx, embedRequest{
+		Model:     e.model,
+		Input:     texts,
+		KeepAlive: ollama.KeepAlive(e.KeepAlive),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(result.Embeddings))
+	}
+	// All-zero vectors are valid output for degenerate inputs and are left
+	// to callers; NaN or Inf means the model is broken.
+	for i, v := range result.Embeddings {
+		if err := Validate(v); err != nil {
+			return nil, fmt.Errorf("model %q returned an invalid embedding for input %d: %w", e.model, i+1, err)
+		}
+	}
+
+	return result.Embeddings, nil
+}
+
+// EmbedSingle embeds a single text, such as a search query, and returns
+// the embedding vector. It gives up after QueryTimeout.
+func (e *OllamaEmbedder) EmbedSingle(text string) ([]float32, error) {
+	timeout := e.QueryTimeout
+	if timeout <= 0 {
+		timeout = DefaultQueryTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	result, err := e.post(ctx, singleEmbedRequest{
+		Model:     e.model,
+		Input:     text,
+		KeepAlive: ollama.KeepAlive(e.KeepAlive),
 	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("ollama embed request: no response within %s (raise --query-timeout if the model is slow to load)", timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Embeddings) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(result.Embeddings))
+	}
+	if err := Validate(result.Embeddings[0]); err != nil {
+		return nil, fmt.Errorf("model %q returned an invalid embedding: %w", e.model, err)
+	}
+	return result.Embeddings[0], nil
+}
+
+// post sends payload to /api/embed and decodes the response.
+func (e *OllamaEmbedder) post(ctx context.Context, payload any) (*embedResponse, error) {
+	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("marshal embed request: %w", err)
 	}
 
-	resp, err := e.client.Post(e.baseURL+"/api/embed", "application/json", bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/api/embed", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("ollama embed request: %w", err)
 	}
@@ -69,19 +181,5 @@ func (e *OllamaEmbedder) Embed(texts []string) ([][]float32, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("decode embed response: %w", err)
 	}
-
-	if len(result.Embeddings) != len(texts) {
-		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(result.Embeddings))
-	}
-
-	return result.Embeddings, nil
-}
-
-// EmbedSingle embeds a single text and returns the embedding vector.
-func (e *OllamaEmbedder) EmbedSingle(text string) ([]float32, error) {
-	results, err := e.Embed([]string{text})
-	if err != nil {
-		return nil, err
-	}
-	return results[0], nil
+	return &result, nil
 }
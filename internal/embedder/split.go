@@ -0,0 +1,86 @@
+package embedder
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// EmbedSplit embeds texts with e like EmbedContext, but first splits any text
+// longer than maxBytes into pieces at line boundaries. Models silently
+// truncate inputs past their context length, embedding only the start; the
+// vector of a split text is instead the mean of its pieces' vectors,
+// weighted by their length, so it covers the whole text. maxBytes <= 0
+// disables splitting. It also returns how many texts were split.
+func EmbedSplit(ctx context.Context, e Embedder, texts []string, maxBytes int) ([][]float32, int, error) {
+	if maxBytes <= 0 {
+		vecs, err := EmbedContext(ctx, e, texts)
+		return vecs, 0, err
+	}
+
+	var inputs []string
+	pieces := make([][]string, len(texts))
+	split := 0
+	for i, t := range texts {
+		pieces[i] = splitText(t, maxBytes)
+		if len(pieces[i]) > 1 {
+			split++
+		}
+		inputs = append(inputs, pieces[i]...)
+	}
+	if split == 0 {
+		vecs, err := EmbedContext(ctx, e, texts)
+		return vecs, 0, err
+	}
+
+	vecs, err := EmbedContext(ctx, e, inputs)
+	if err != nil {
+		return nil, 0, err
+	}
+	out := make([][]float32, len(texts))
+	next := 0
+	for i, ps := range pieces {
+		if len(ps) == 1 {
+			out[i] = vecs[next]
+			next++
+			continue
+		}
+		mean := make([]float32, len(vecs[next]))
+		for _, p := range ps {
+			w := float32(len(p)) / float32(len(texts[i]))
+			for d, x := range vecs[next] {
+				mean[d] += w * x
+			}
+			next++
+		}
+		out[i] = mean
+	}
+	return out, split, nil
+}
+
+// splitText splits text into pieces of at most maxBytes, breaking after a
+// newline where possible and otherwise at a rune boundary.
+func splitText(text string, maxBytes int) []string {
+	if len(text) <= maxBytes {
+		return []string{text}
+	}
+	var pieces []string
+	for len(text) > maxBytes {
+		cut := strings.LastIndexByte(text[:maxBytes], '\n') + 1
+		if cut <= 0 {
+			cut = maxBytes
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				cut = maxBytes // maxBytes is smaller than one rune
+			}
+		}
+		pieces = append(pieces, text[:cut])
+		text = text[cut:]
+	}
+	if text != "" {
+		pieces = append(pieces, text)
+	}
+	return pieces
+}
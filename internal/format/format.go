@@ -0,0 +1,112 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Format is an output format for CLI commands.
+type Format string
+
+const (
+	Table    Format = "table"
+	JSON     Format = "json"
+	Markdown Format = "markdown"
+	// Patch prints one "path:line: text" location per result, the format
+	// grep -n and compilers use, so editors and terminals can jump to it.
+	Patch Format = "patch"
+)
+
+// Parse validates a user-supplied format name.
+func Parse(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case Table, JSON, Markdown, Patch:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (want table, json, markdown, or patch)", s)
+}
+
+// Tabular is data with a fixed set of columns, rendered as aligned text or a
+// markdown table.
+type Tabular struct {
+	Columns []string
+	Rows    [][]string
+	// Locations, if set, are what patch output prints. Commands whose rows
+	// don't point into source files leave it empty.
+	Locations []Location
+}
+
+// Location is a position in a source file.
+type Location struct {
+	Path string
+	Line int
+	Text string
+}
+
+// String formats the location as "path:line: text".
+func (l Location) String() string {
+	return fmt.Sprintf("%s:%d: %s", l.Path, l.Line, l.Text)
+}
+
+// Render writes the output in format f. JSON output marshals value, which
+// should be a stable, tagged struct or slice; table and markdown render tab.
+func Render(w io.Writer, f Format, value any, tab Tabular) error {
+	switch f {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(value)
+	case Markdown:
+		return renderMarkdown(w, tab)
+	case Patch:
+		return renderPatch(w, tab)
+	default:
+		return renderTable(w, tab)
+	}
+}
+
+func renderTable(w io.Writer, tab Tabular) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(tab.Columns, "\t")))
+	for _, row := range tab.Rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func renderPatch(w io.Writer, tab Tabular) error {
+	if tab.Locations == nil {
+		return fmt.Errorf("patch output is not supported for this command")
+	}
+	for _, l := range tab.Locations {
+		if _, err := fmt.Fprintln(w, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderMarkdown(w io.Writer, tab Tabular) error {
+	fmt.Fprintf(w, "| %s |\n", strings.Join(tab.Columns, " | "))
+	seps := make([]string, len(tab.Columns))
+	for i := range seps {
+		seps[i] = "---"
+	}
+	fmt.Fprintf(w, "| %s |\n", strings.Join(seps, " | "))
+	for _, row := range tab.Rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			// Pipes and newlines would break the table layout.
+			cell = strings.ReplaceAll(cell, "|", `\|`)
+			cells[i] = strings.ReplaceAll(cell, "\n", " ")
+		}
+		if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
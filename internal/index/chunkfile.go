@@ -0,0 +1,222 @@
+package index
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"synapse/internal/chunker"
+	"synapse/internal/store"
+	"synapse/internal/walker"
+)
+
+// chunkFileVersion is the version of the chunk file format written by
+// EmitChunks. Readers reject other versions.
+const chunkFileVersion = 1
+
+// chunkFileHeader is the first line of a chunk file.
+type chunkFileHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	// Options are the chunkerOptions the chunks were made with; files
+	// written before they were recorded have none.
+	Options string `json:"options,omitempty"`
+}
+
+// chunkRecord is one file's chunks in a chunk file. Every other line after
+// the header is a record.
+type chunkRecord struct {
+	Path     string             `json:"path"`
+	Hash     string             `json:"hash"`
+	Language string             `json:"language"`
+	Size     int64              `json:"size"`
+	ModTime  int64              `json:"mtime,omitempty"` // Unix seconds
+	Chunks   []chunker.RawChunk `json:"chunks"`
+}
+
+// EmitChunks walks and chunks root like IndexContext but writes the chunks
+// to w as JSON lines instead of embedding and storing them, so another
+// machine can do that with IndexChunks. It needs no embedding model. If an
+// index exists at cfg.DBPath, only files that changed since they were stored
+// there are written, unless it was built with other chunking options. The
+// returned Stats counts written files as indexed.
+func EmitChunks(ctx context.Context, cfg Config, root string, w io.Writer) (*Stats, error) {
+	reg := NewRegistry()
+	ch, err := newChunker(cfg, reg)
+	if err != nil {
+		return nil, err
+	}
+
+	var s *store.SQLiteStore
+	var known map[string]store.FileRecord
+	if _, err := os.Stat(cfg.DBPath); err == nil {
+		if s, err = store.OpenReadOnly(cfg.DBPath); err != nil {
+			return nil, fmt.Errorf("open store: %w", err)
+		}
+		defer s.Close()
+		if cfg.Resume {
+			if known, err = s.FileRecords(); err != nil {
+				return nil, fmt.Errorf("list indexed files: %w", err)
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	options := chunkerOptions(ch)
+	if err := enc.Encode(chunkFileHeader{Format: "synapse-chunks", Version: chunkFileVersion, Options: options}); err != nil {
+		return nil, err
+	}
+
+	hashes := s
+	if s != nil {
+		rechunk, err := chunkerOptionsChanged(s, options)
+		if err != nil {
+			return nil, err
+		}
+		if rechunk {
+			hashes, known = nil, nil
+		}
+	}
+
+	var c counters
+	var stats Stats
+	var writeErr error
+	chunkCh, walkErrCh := chunkFiles(ctx, root, hashes, ch, reg, cfg.Workers,
+		walker.Options{IndexGenerated: cfg.IndexGenerated, IncludeHidden: cfg.IncludeHidden}, known, &c)
+	for batch := range chunkCh {
+		if writeErr != nil {
+			continue // drain so the chunk stage can finish
+		}
+		writeErr = enc.Encode(chunkRecord{
+			Path:     batch.work.info.RelPath,
+			Hash:     batch.work.hash,
+			Language: batch.work.lang,
+			Size:     batch.work.info.Size,
+			ModTime:  batch.work.info.ModTime.Unix(),
+			Chunks:   batch.chunks,
+		})
+		stats.FilesIndexed++
+		stats.ChunksTotal += len(batch.chunks)
+		if cfg.OnProgress != nil {
+			cfg.OnProgress(Progress{Phase: "Chunking files...", FilesProcessed: stats.FilesIndexed, FilesTotal: int(c.filesTotal.Load())})
+		}
+	}
+	if err := <-walkErrCh; err != nil {
+		return nil, fmt.Errorf("walk error: %w", err)
+	}
+	if writeErr == nil {
+		writeErr = bw.Flush()
+	}
+	c.fill(&stats)
+	if writeErr != nil {
+		return &stats, fmt.Errorf("write chunks: %w", writeErr)
+	}
+	if err := ctx.Err(); err != nil {
+		return &stats, fmt.Errorf("chunking stopped: %w", err)
+	}
+	return &stats, nil
+}
+
+// IndexChunks is like IndexContext but embeds and stores the chunks in r,
+// written by EmitChunks, instead of walking a tree. root is recorded as the
+// project root; it needn't exist on this machine. Files whose hash matches
+// the stored one are skipped, unless the chunks were made with other
+// chunking options than the index was built with.
+func (idx *Indexer) IndexChunks(ctx context.Context, r io.Reader, root string) (*Stats, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	header, err := readChunkFileHeader(dec)
+	if err != nil {
+		return nil, err
+	}
+	if err := idx.prepare(ctx); err != nil {
+		return nil, err
+	}
+	embedMax := idx.config.EmbedMaxBytes
+	if embedMax == 0 {
+		embedMax = DefaultEmbedMaxBytes
+	}
+	hashes := idx.store
+	if header.Options != "" {
+		rechunk, err := chunkerOptionsChanged(idx.store, header.Options)
+		if err != nil {
+			return nil, err
+		}
+		if rechunk {
+			fmt.Fprintln(idx.log(), "Chunking options changed since the last run — replacing all files")
+			hashes = nil
+		}
+	}
+
+	var c counters
+	chunkCh, readErrCh := readChunks(ctx, dec, hashes, &c)
+	stats, err := embedAndStore(ctx, idx.store, idx.embedder, embedMax, chunkCh, &c, idx.config.OnProgress)
+	if rerr := <-readErrCh; rerr != nil && err == nil {
+		err = rerr
+	}
+	c.fill(stats)
+	return idx.finish(ctx, root, stats, err, header.Options)
+}
+
+// readChunkFileHeader decodes and checks the header of a chunk file.
+func readChunkFileHeader(dec *json.Decoder) (chunkFileHeader, error) {
+	var header chunkFileHeader
+	if err := dec.Decode(&header); err != nil {
+		return header, fmt.Errorf("read chunk file header: %w", err)
+	}
+	if header.Format != "synapse-chunks" {
+		return header, fmt.Errorf("not a chunk file written by 'synapse index --emit-chunks'")
+	}
+	if header.Version != chunkFileVersion {
+		return header, fmt.Errorf("chunk file version %d is not supported (want %d)", header.Version, chunkFileVersion)
+	}
+	return header, nil
+}
+
+// readChunks decodes the records of a chunk file, after its header, into
+// batches for embedAndStore, skipping files whose hash matches the one stored
+// in s unless s is nil. It returns the read error once the channel is closed.
+func readChunks(ctx context.Context, dec *json.Decoder, s *store.SQLiteStore, c *counters) (<-chan chunkBatch, <-chan error) {
+	chunkCh := make(chan chunkBatch, 4)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		defer close(chunkCh)
+
+		for ctx.Err() == nil {
+			var rec chunkRecord
+			if err := dec.Decode(&rec); err == io.EOF {
+				return
+			} else if err != nil {
+				errCh <- fmt.Errorf("read chunk file: %w", err)
+				return
+			}
+			// Paths are stored relative to the project root, so reject any
+			// that could escape it.
+			if !filepath.IsLocal(filepath.FromSlash(rec.Path)) {
+				errCh <- fmt.Errorf("read chunk file: invalid path %q", rec.Path)
+				return
+			}
+			c.filesTotal.Add(1)
+			if s != nil {
+				if existing, err := s.GetFileHash(rec.Path); err == nil && existing == rec.Hash {
+					continue // unchanged
+				}
+			}
+			chunkCh <- chunkBatch{
+				work: fileWork{
+					info: walker.FileInfo{RelPath: rec.Path, Size: rec.Size, ModTime: time.Unix(rec.ModTime, 0)},
+					hash: rec.Hash,
+					lang: rec.Language,
+				},
+				chunks: rec.Chunks,
+			}
+		}
+	}()
+	return chunkCh, errCh
+}
@@ -0,0 +1,114 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"synapse/internal/chunker"
+	"synapse/internal/walker"
+)
+
+// Coverage reports which files in a tree synapse would index, and why the
+// rest would be skipped.
+type Coverage struct {
+	// Languages counts indexable files by language.
+	Languages map[string]int
+	// Unsupported counts files without a registered grammar by extension,
+	// without the dot; "" is files without an extension.
+	Unsupported map[string]int
+	// SkippedGenerated, SkippedSize, and SkippedBinary count files left out
+	// as generated code, for being empty or over 1 MB, and for containing
+	// binary or non-UTF-8 data.
+	SkippedGenerated int
+	SkippedSize      int
+	SkippedBinary    int
+	// Hidden counts the indexable files that are dotfiles or in hidden
+	// directories, when walking with IncludeHidden. They're also counted in
+	// Languages.
+	Hidden int
+}
+
+// ExtensionCount is the number of files with one extension.
+type ExtensionCount struct {
+	Ext   string
+	Files int
+}
+
+// UnsupportedByCount returns the unsupported extensions, most files first.
+func (c *Coverage) UnsupportedByCount() []ExtensionCount {
+	exts := make([]ExtensionCount, 0, len(c.Unsupported))
+	for ext, n := range c.Unsupported {
+		exts = append(exts, ExtensionCount{ext, n})
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if exts[i].Files != exts[j].Files {
+			return exts[i].Files > exts[j].Files
+		}
+		return exts[i].Ext < exts[j].Ext
+	})
+	return exts
+}
+
+// ScanCoverage walks root like indexing does, honoring .synapseignore and
+// opts, but reads files only to detect their language and stores nothing.
+func ScanCoverage(root string, reg *chunker.Registry, opts walker.Options) (*Coverage, error) {
+	cov := &Coverage{
+		Languages:   make(map[string]int),
+		Unsupported: make(map[string]int),
+	}
+	// The callbacks run on the walk goroutine and only touch fields the
+	// loop below doesn't.
+	opts.OnSkipGenerated = func(string) { cov.SkippedGenerated++ }
+	opts.OnSkipSize = func(string) { cov.SkippedSize++ }
+	opts.OnSkipUnsupported = func(relPath string) {
+		base := filepath.Base(relPath)
+		ext := filepath.Ext(base)
+		if ext == base {
+			ext = "" // a dotfile such as .gitignore
+		}
+		ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+		cov.Unsupported[ext]++
+	}
+	opts.Sniff = func(head []byte) bool {
+		spec, _ := reg.Detect("", head)
+		return spec != nil
+	}
+	opts.Filenames = reg.Filenames()
+	opts.OnHidden = func(string) { cov.Hidden++ }
+
+	files, errs := walker.Walk(root, reg.Extensions(), opts)
+	for fi := range files {
+		src, err := os.ReadFile(fi.Path)
+		if err != nil {
+			continue
+		}
+		if isBinary(src) {
+			cov.SkippedBinary++
+			continue
+		}
+		_, lang := reg.Detect(fi.Path, src)
+		cov.Languages[lang]++
+	}
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	return cov, nil
+}
+
+// wellKnownLanguages names the languages of common extensions without a
+// registered grammar, for coverage reports.
+var wellKnownLanguages = map[string]string{
+	"c": "C", "h": "C", "cc": "C++", "cpp": "C++", "cxx": "C++", "hpp": "C++",
+	"cs": "C#", "dart": "Dart", "ex": "Elixir", "exs": "Elixir", "erl": "Erlang",
+	"hs": "Haskell", "java": "Java", "kt": "Kotlin", "kts": "Kotlin", "lua": "Lua",
+	"m": "Objective-C", "ml": "OCaml", "php": "PHP", "pl": "Perl", "r": "R",
+	"rb": "Ruby", "rs": "Rust", "scala": "Scala", "sh": "Shell", "bash": "Shell",
+	"swift": "Swift", "tf": "Terraform", "zig": "Zig", "proto": "Protocol Buffers",
+}
+
+// LanguageOf returns the language name of a common extension, or "".
+func LanguageOf(ext string) string {
+	return wellKnownLanguages[ext]
+}
@@ -1,20 +1,56 @@
 package index
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
 
 	"synapse/internal/chunker"
 	"synapse/internal/chunker/languages"
 	"synapse/internal/embedder"
 	"synapse/internal/llm"
 	"synapse/internal/store"
+	"synapse/internal/walker"
 )
 
-// ProgressFunc is called with the current phase, files processed so far,
-// and total files discovered. total may increase as more files are discovered.
-type ProgressFunc func(phase string, filesProcessed, filesTotal int)
+// PhaseEmbedding is the Progress phase reported while the chunks of a file
+// too large for one embedding request are embedded.
+const PhaseEmbedding = "Embedding chunks..."
+
+// Progress reports how far an indexing run has got.
+type Progress struct {
+	// Phase describes the current step, e.g. "Indexing files...".
+	Phase string
+	// FilesProcessed counts files stored so far, and FilesTotal the files
+	// discovered; FilesTotal may increase as more files are discovered.
+	// Neither is set in PhaseEmbedding updates.
+	FilesProcessed int
+	FilesTotal     int
+	// File, BatchesDone, and Batches are set in PhaseEmbedding updates: the
+	// file being embedded and how many of its sub-batches of chunks are done.
+	File        string
+	BatchesDone int
+	Batches     int
+}
+
+// ProgressFunc is called as indexing progresses, possibly from several
+// goroutines at once.
+type ProgressFunc func(Progress)
+
+// ConfirmFunc is asked before the index is wiped because the embedding model
+// changed. Returning false aborts indexing.
+type ConfirmFunc func(oldModel, newModel string) (bool, error)
 
 // Config holds the indexer configuration.
 type Config struct {
@@ -24,94 +60,656 @@ type Config struct {
 	Workers       int
 	OverviewModel string
 	OnProgress    ProgressFunc
+	// Log receives the run's progress messages, such as which file is being
+	// summarized. nil uses os.Stdout; warnings always go to os.Stderr.
+	Log io.Writer
+	// ConfirmReindex, if set, must approve wiping the index on a model change.
+	ConfirmReindex ConfirmFunc
+	// BackupOnReindex copies the database to <DBPath>.bak before wiping it.
+	BackupOnReindex bool
+	// Snapshots, if positive, copies an existing index to SnapshotsDir
+	// before each run, keeping this many of the most recent copies, so a
+	// run that made results worse can be undone with RestoreSnapshot.
+	Snapshots int
+	// EmbeddingDim overrides the embedding dimension. When 0 it's detected
+	// by embedding a probe string.
+	EmbeddingDim int
+	// SkipOverview skips file summaries and the project overview; run
+	// Summarize later to generate them.
+	SkipOverview bool
+	// IncludeImports prepends each file's import block to its chunks for
+	// languages that define an import query. Like the other options that
+	// shape chunks, changing it re-chunks every file on the next run.
+	IncludeImports bool
+	// IndexDocs also indexes each definition's doc comment or docstring as a
+	// chunk of its own, of kind chunker.DocKind and named after the
+	// definition, so natural-language questions can match documentation
+	// directly.
+	IndexDocs bool
+	// ChunkHeader frames each chunk's code for embedding: the name of one of
+	// chunker.Headers, or the path of a file holding a header template (see
+	// chunker.ParseHeader). Empty uses chunker.DefaultHeader. The header is
+	// stored with the chunk, so changing it re-chunks every file.
+	ChunkHeader string
+	// IndexGenerated indexes generated files (e.g. *.pb.go, *_pb2.py) that
+	// are skipped by default.
+	IndexGenerated bool
+	// IncludeHidden indexes hidden directories that the ignore patterns
+	// name, such as .vscode, and dotfiles identified by name or "#!" line;
+	// see walker.Options.IncludeHidden. .git and .synapse stay excluded.
+	IncludeHidden bool
+	// Tokenizer is the FTS5 tokenizer for keyword search. When empty the
+	// index keeps the tokenizer it was last configured with, or switches to
+	// store.DefaultTokenizer if it never was. Changing it rebuilds the
+	// full-text index.
+	Tokenizer string
+	// MaxSplits caps the pieces an oversized chunk is split into. 0 uses
+	// chunker.DefaultMaxSplits; a negative value removes the cap.
+	MaxSplits int
+	// MaxChunksPerFile caps the chunks indexed per file; files with more
+	// keep only their largest definitions (see chunker.ASTChunker.MaxChunks),
+	// and their summary still covers what was kept. 0 uses
+	// chunker.DefaultMaxChunks; a negative value removes the cap.
+	MaxChunksPerFile int
+	// MinChunkLines and MinChunkBytes merge definitions shorter than
+	// either with their small neighbors (see chunker.ASTChunker.MinChunkLines).
+	// 0 turns each off.
+	MinChunkLines int
+	MinChunkBytes int
+	// SplitBlocks splits oversized definitions between statements instead
+	// of into line windows (see chunker.ASTChunker.SplitBlocks).
+	SplitBlocks bool
+	// Resume skips reading and hashing files whose size and modification
+	// time are those recorded when they were last indexed (see
+	// store.FileRecord.Unchanged), so a run that was interrupted continues
+	// quickly. It trusts file modification times.
+	Resume bool
+	// OverviewSymbols caps the symbols listed per file in the overview
+	// prompt, keeping public ones first. 0 uses DefaultOverviewSymbols; a
+	// negative value removes the cap.
+	OverviewSymbols int
+	// OverviewPrompt, if set, is the path of a file holding the
+	// instructions for writing the project overview, in place of
+	// prompts/overview.md next to DBPath or the built-in ones. The file
+	// summaries and symbols are appended to them. Indexing rewrites the
+	// overview when the instructions changed since it was written.
+	OverviewPrompt string
+	// ChunkKinds, if set, indexes only chunks of these kinds ("function",
+	// "method", "class", ...), mapped to node types per language.
+	ChunkKinds []string
+	// EmbedMaxBytes caps the length of each input sent to the embedding
+	// model, which would otherwise silently truncate longer chunks. Longer
+	// chunks are embedded in pieces and their vectors averaged. 0 uses
+	// DefaultEmbedMaxBytes; a negative value removes the cap.
+	EmbedMaxBytes int
+	// KeepAlive is how long Ollama keeps the embedding and overview models
+	// loaded between requests; 0 uses the server's default.
+	KeepAlive time.Duration
+	// RawEmbeddings stores embeddings as the model returns them. By default
+	// they're scaled to unit length, and queries too before searching, so
+	// the L2 distance sqlite-vec measures ranks like cosine similarity; an
+	// existing index of raw embeddings is normalized in place. Going back to
+	// raw embeddings needs a new index.
+	RawEmbeddings bool
+	// ExcludeSymbols leaves out chunks whose symbol name matches one of
+	// these glob patterns, e.g. "init" or "Test*", in addition to those in
+	// the exclude-symbols file next to the database.
+	ExcludeSymbols []string
 }
 
 // Indexer is the public API for indexing and searching codebases.
 type Indexer struct {
 	store    *store.SQLiteStore
-	embedder *embedder.OllamaEmbedder
+	embedder embedder.Embedder
 	chunker  *chunker.ASTChunker
 	registry *chunker.Registry
 	config   Config
 }
 
-// New creates a new Indexer with the given configuration.
-func New(cfg Config) (*Indexer, error) {
-	s, err := store.Open(cfg.DBPath)
+// ExcludeSymbolsPath returns the location of the exclude-symbols file for
+// the index at dbPath.
+func ExcludeSymbolsPath(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "exclude-symbols")
+}
+
+// LoadExcludeSymbols reads symbol name patterns from the file at path, one
+// per line, skipping blank lines and # comments. A missing file has none.
+func LoadExcludeSymbols(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("open store: %w", err)
+		return nil, fmt.Errorf("read exclude symbols: %w", err)
 	}
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, nil
+}
 
+// NewRegistry returns a registry of every language synapse can index.
+func NewRegistry() *chunker.Registry {
 	reg := chunker.NewRegistry()
 	languages.RegisterGo(reg)
 	languages.RegisterJavaScript(reg)
 	languages.RegisterTypeScript(reg)
 	languages.RegisterPython(reg)
+	languages.RegisterSQL(reg)
+	languages.RegisterGraphQL(reg)
+	languages.RegisterHTML(reg)
+	languages.RegisterCSS(reg)
+	languages.RegisterVue(reg)
+	languages.RegisterSvelte(reg)
+	languages.RegisterHCL(reg)
+	return reg
+}
 
-	return &Indexer{
+// New creates a new Indexer with the given configuration.
+func New(cfg Config) (*Indexer, error) {
+	s, err := store.Open(cfg.DBPath)
+	if err != nil {
+		return nil, fmt.Errorf("open store: %w", err)
+	}
+
+	reg := NewRegistry()
+	ch, err := newChunker(cfg, reg)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	emb := embedder.NewOllamaEmbedder(cfg.OllamaURL, cfg.Model)
+	emb.KeepAlive = cfg.KeepAlive
+	idx := &Indexer{
 		store:    s,
-		embedder: embedder.NewOllamaEmbedder(cfg.OllamaURL, cfg.Model),
-		chunker:  chunker.NewASTChunker(reg),
+		embedder: emb,
+		chunker:  ch,
 		registry: reg,
 		config:   cfg,
-	}, nil
+	}
+	if err := idx.checkDimension(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return idx, nil
+}
+
+// newChunker returns a chunker for reg configured by cfg, including the
+// patterns in the exclude-symbols file next to cfg.DBPath.
+func newChunker(cfg Config, reg *chunker.Registry) (*chunker.ASTChunker, error) {
+	ch := chunker.NewASTChunker(reg)
+	ch.IncludeImports = cfg.IncludeImports
+	ch.IndexDocs = cfg.IndexDocs
+	if cfg.ChunkHeader != "" && cfg.ChunkHeader != chunker.DefaultHeader {
+		header, err := chunkHeader(cfg.ChunkHeader)
+		if err != nil {
+			return nil, err
+		}
+		ch.Header = header
+	}
+	if cfg.MaxSplits != 0 {
+		ch.MaxSplits = max(cfg.MaxSplits, 0)
+	}
+	ch.MinChunkLines = cfg.MinChunkLines
+	ch.MinChunkBytes = cfg.MinChunkBytes
+	ch.SplitBlocks = cfg.SplitBlocks
+	if cfg.MaxChunksPerFile != 0 {
+		ch.MaxChunks = max(cfg.MaxChunksPerFile, 0)
+	}
+	if len(cfg.ChunkKinds) > 0 {
+		known := reg.KindAliases()
+		for _, kind := range cfg.ChunkKinds {
+			if !slices.Contains(known, kind) {
+				return nil, fmt.Errorf("unknown chunk kind %q (known: %s)", kind, strings.Join(known, ", "))
+			}
+		}
+		ch.Kinds = cfg.ChunkKinds
+	}
+	fileSymbols, err := LoadExcludeSymbols(ExcludeSymbolsPath(cfg.DBPath))
+	if err != nil {
+		return nil, err
+	}
+	for _, pattern := range append(cfg.ExcludeSymbols, fileSymbols...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("exclude symbol pattern %q: %w", pattern, err)
+		}
+		ch.ExcludeSymbols = append(ch.ExcludeSymbols, pattern)
+	}
+	return ch, nil
 }
 
-// Index indexes the codebase at the given root path.
+// chunkHeader returns the header template name refers to: one of
+// chunker.Headers, or else the file at that path.
+func chunkHeader(name string) (*template.Template, error) {
+	text, ok := chunker.Headers[name]
+	if !ok {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			names := slices.Sorted(maps.Keys(chunker.Headers))
+			return nil, fmt.Errorf("chunk header %q is neither a built-in one (%s) nor a readable file: %w", name, strings.Join(names, ", "), err)
+		}
+		text = string(data)
+	}
+	t, err := chunker.ParseHeader(text)
+	if err != nil {
+		return nil, fmt.Errorf("chunk header %s: %w", name, err)
+	}
+	return t, nil
+}
+
+// checkDimension fails early when the model's vectors don't fit an existing
+// index built with the same model, instead of on the first insert. A model
+// change is fine: Index wipes and resizes the index for it.
+func (idx *Indexer) checkDimension() error {
+	indexDim, err := idx.store.EmbeddingDim()
+	if err != nil || indexDim == 0 {
+		return err
+	}
+	lastModel, err := idx.store.GetMeta("embedding_model")
+	if err != nil {
+		return fmt.Errorf("get meta: %w", err)
+	}
+	if lastModel != "" && lastModel != idx.config.Model {
+		return nil
+	}
+	if p, ok := idx.embedder.(embedder.Pinger); ok && idx.config.EmbeddingDim == 0 {
+		if err := p.Ping(context.Background()); err != nil {
+			return err
+		}
+	}
+	dim, err := idx.dimension()
+	if err != nil {
+		return err
+	}
+	if dim != indexDim {
+		return fmt.Errorf("model %q outputs %d-dim vectors but the index at %s is %d-dim; re-index with the original model or delete the index and run 'synapse index' again",
+			idx.config.Model, dim, idx.config.DBPath, indexDim)
+	}
+	return nil
+}
+
+// dimension returns the configured embedding dimension, or the model's.
+func (idx *Indexer) dimension() (int, error) {
+	if idx.config.EmbeddingDim > 0 {
+		return idx.config.EmbeddingDim, nil
+	}
+	return embedder.Dimension(idx.embedder)
+}
+
+// Index indexes the codebase at the given root path. When embedding or
+// storing fails it returns the Stats so far, whose FileErrors say which
+// files failed, along with the error.
 func (idx *Indexer) Index(root string) (*Stats, error) {
+	return idx.IndexContext(context.Background(), root)
+}
+
+// IndexContext is like Index but stops when ctx is done. Files stored before
+// then are kept, so a later run only processes the rest; it returns the
+// partial Stats along with an error wrapping ctx.Err().
+func (idx *Indexer) IndexContext(ctx context.Context, root string) (*Stats, error) {
+	if err := idx.prepare(ctx); err != nil {
+		return nil, err
+	}
+
+	var known map[string]store.FileRecord
+	if idx.config.Resume {
+		var err error
+		known, err = idx.store.FileRecords()
+		if err != nil {
+			return nil, fmt.Errorf("list indexed files: %w", err)
+		}
+	}
+
+	embedMax := idx.config.EmbedMaxBytes
+	if embedMax == 0 {
+		embedMax = DefaultEmbedMaxBytes
+	}
+	options := chunkerOptions(idx.chunker)
+	rechunk, err := chunkerOptionsChanged(idx.store, options)
+	if err != nil {
+		return nil, err
+	}
+	if rechunk {
+		fmt.Fprintln(idx.log(), "Chunking options changed since the last run — re-chunking all files")
+		known = nil
+	}
+	stats, err := runPipeline(ctx, root, idx.store, idx.chunker, idx.registry, idx.embedder, embedMax, idx.config.Workers,
+		walker.Options{IndexGenerated: idx.config.IndexGenerated, IncludeHidden: idx.config.IncludeHidden}, known, rechunk, idx.config.OnProgress)
+	return idx.finish(ctx, root, stats, err, options)
+}
+
+// chunkerOptionsMeta is the meta key holding the chunkerOptions the index
+// was last built with.
+const chunkerOptionsMeta = "chunker_options"
+
+// chunkerOptions describes the options of ch that shape the chunks it
+// returns, so a run can tell that they changed since the index was built
+// and re-chunk files whose content didn't.
+func chunkerOptions(ch *chunker.ASTChunker) string {
+	header := chunker.DefaultHeader
+	if ch.Header != nil && ch.Header.Tree != nil {
+		header = ch.Header.Tree.Root.String()
+	}
+	opts := struct {
+		IncludeImports bool     `json:"include_imports,omitempty"`
+		IndexDocs      bool     `json:"index_docs,omitempty"`
+		Header         string   `json:"header"`
+		MaxSplits      int      `json:"max_splits"`
+		MaxChunks      int      `json:"max_chunks"`
+		MinChunkLines  int      `json:"min_chunk_lines,omitempty"`
+		MinChunkBytes  int      `json:"min_chunk_bytes,omitempty"`
+		SplitBlocks    bool     `json:"split_blocks,omitempty"`
+		Kinds          []string `json:"kinds,omitempty"`
+		ExcludeSymbols []string `json:"exclude_symbols,omitempty"`
+	}{
+		IncludeImports: ch.IncludeImports,
+		IndexDocs:      ch.IndexDocs,
+		Header:         header,
+		MaxSplits:      ch.MaxSplits,
+		MaxChunks:      ch.MaxChunks,
+		MinChunkLines:  ch.MinChunkLines,
+		MinChunkBytes:  ch.MinChunkBytes,
+		SplitBlocks:    ch.SplitBlocks,
+		Kinds:          slices.Sorted(slices.Values(ch.Kinds)),
+		ExcludeSymbols: slices.Sorted(slices.Values(ch.ExcludeSymbols)),
+	}
+	data, _ := json.Marshal(opts)
+	return string(data)
+}
+
+// chunkerOptionsChanged reports whether the index in s was built with
+// chunking options other than options. An index that predates recording
+// them hasn't changed as far as we know.
+func chunkerOptionsChanged(s *store.SQLiteStore, options string) (bool, error) {
+	stored, err := s.GetMeta(chunkerOptionsMeta)
+	if err != nil {
+		return false, fmt.Errorf("get meta: %w", err)
+	}
+	return stored != "" && stored != options, nil
+}
+
+// prepare readies the store for a run: it checks the embedding backend,
+// takes a snapshot if configured, wipes the index if the embedding model
+// changed, and applies the embedding dimension, normalization, and tokenizer.
+func (idx *Indexer) prepare(ctx context.Context) error {
+	// Fail fast if the embedding backend is down, before walking and chunking.
+	if p, ok := idx.embedder.(embedder.Pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			return err
+		}
+	}
+
+	if idx.config.Snapshots > 0 {
+		if err := idx.takeSnapshot(idx.config.Snapshots); err != nil {
+			return fmt.Errorf("snapshot index: %w", err)
+		}
+	}
+
 	// Check if the embedding model changed since last indexing.
 	lastModel, err := idx.store.GetMeta("embedding_model")
 	if err != nil {
-		return nil, fmt.Errorf("get meta: %w", err)
+		return fmt.Errorf("get meta: %w", err)
 	}
 	if lastModel != "" && lastModel != idx.config.Model {
-		fmt.Printf("Embedding model changed from %q to %q — re-indexing all files\n", lastModel, idx.config.Model)
+		if idx.config.ConfirmReindex != nil {
+			ok, err := idx.config.ConfirmReindex(lastModel, idx.config.Model)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("re-index cancelled: index still uses %q", lastModel)
+			}
+		}
+		if idx.config.BackupOnReindex {
+			backupPath := idx.config.DBPath + ".bak"
+			fmt.Fprintf(idx.log(), "Backing up index to %s\n", backupPath)
+			if err := idx.store.Backup(backupPath); err != nil {
+				return fmt.Errorf("backup index: %w", err)
+			}
+		}
+		fmt.Fprintf(idx.log(), "Embedding model changed from %q to %q — re-indexing all files\n", lastModel, idx.config.Model)
 		if err := idx.store.DeleteAllChunks(); err != nil {
-			return nil, fmt.Errorf("delete all chunks: %w", err)
+			return fmt.Errorf("delete all chunks: %w", err)
 		}
 	}
 
-	stats, err := runPipeline(root, idx.store, idx.chunker, idx.registry, idx.embedder, idx.config.Workers, idx.config.OnProgress)
+	dim, err := idx.dimension()
 	if err != nil {
-		return nil, err
+		return err
 	}
+	if err := idx.store.SetEmbeddingDim(dim); err != nil {
+		return err
+	}
+	if err := idx.store.SetNormalize(!idx.config.RawEmbeddings); err != nil {
+		return err
+	}
+	return idx.configureTokenizer()
+}
 
-	if err := idx.store.SetMeta("embedding_model", idx.config.Model); err != nil {
-		return nil, fmt.Errorf("set meta: %w", err)
+// finish completes a run that stored stats and ended with err: it records
+// the index metadata and generates summaries and the overview. options, if
+// set, are the chunkerOptions the run's chunks were made with; they're
+// recorded only when the run completed, so one that stopped early re-chunks
+// everything again next time.
+func (idx *Indexer) finish(ctx context.Context, root string, stats *Stats, err error, options string) (*Stats, error) {
+	if err != nil {
+		if ctx.Err() == nil {
+			return stats, err
+		}
+		// Files stored before the cancellation are complete; record the
+		// index metadata so they're kept and searchable.
+		if err := idx.markIndexed(root); err != nil {
+			return stats, err
+		}
+		return stats, fmt.Errorf("indexing stopped: %w", ctx.Err())
 	}
 
-	// Generate project overview if files were indexed.
-	if stats.FilesIndexed > 0 {
-		overviewModel := idx.config.OverviewModel
-		if overviewModel == "" {
-			overviewModel = "qwen3:8b"
+	if err := idx.markIndexed(root); err != nil {
+		return nil, err
+	}
+	if options != "" {
+		if err := idx.store.SetMeta(chunkerOptionsMeta, options); err != nil {
+			return nil, fmt.Errorf("set meta: %w", err)
 		}
-		chat := llm.NewOllamaChat(idx.config.OllamaURL, overviewModel)
+	}
 
-		fmt.Println("Generating file summaries...")
-		if idx.config.OnProgress != nil {
-			idx.config.OnProgress("Generating file summaries...", 0, 0)
+	// Generate project overview if files were indexed, or finish summarizing
+	// after a run that was interrupted while at it, or rewrite it with
+	// instructions other than those it was written with.
+	if (stats.FilesIndexed > 0 || idx.summariesPending() || idx.overviewPromptChanged()) && !idx.config.SkipOverview {
+		if err := idx.Summarize(ctx, false); err != nil {
+			if ctx.Err() != nil {
+				return stats, fmt.Errorf("indexing stopped during summarization: %w", ctx.Err())
+			}
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
 		}
-		if err := summarizeFiles(idx.store, chat); err != nil {
-			fmt.Fprintf(os.Stderr, "warning: file summarization failed: %v\n", err)
+	}
+
+	return stats, nil
+}
+
+// configureTokenizer applies the configured FTS tokenizer, rebuilding the
+// full-text index from the stored chunks when it changed.
+func (idx *Indexer) configureTokenizer() error {
+	tokenizer := idx.config.Tokenizer
+	if tokenizer == "" {
+		configured, err := idx.store.GetMeta("fts_tokenizer")
+		if err != nil {
+			return fmt.Errorf("get meta: %w", err)
 		}
+		tokenizer = configured
+	}
+	if tokenizer == "" {
+		tokenizer = store.DefaultTokenizer
+	}
+	rebuilt, err := idx.store.SetTokenizer(tokenizer)
+	if err != nil {
+		return err
+	}
+	if rebuilt {
+		fmt.Fprintf(idx.log(), "Rebuilt keyword index with tokenizer %q\n", tokenizer)
+	}
+	return nil
+}
 
-		fmt.Println("Generating project overview...")
-		if idx.config.OnProgress != nil {
-			idx.config.OnProgress("Generating project overview...", 0, 0)
+// markIndexed records the embedding model, project root, and the index time.
+func (idx *Indexer) markIndexed(root string) error {
+	if abs, err := filepath.Abs(root); err == nil {
+		if err := idx.store.SetMeta("project_root", abs); err != nil {
+			return fmt.Errorf("set meta: %w", err)
 		}
-		overview, err := synthesizeOverview(idx.store, chat)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "warning: overview generation failed: %v\n", err)
-		} else {
-			overviewPath := filepath.Join(filepath.Dir(idx.config.DBPath), "overview.md")
-			if err := os.WriteFile(overviewPath, []byte(overview), 0o644); err != nil {
-				fmt.Fprintf(os.Stderr, "warning: failed to write overview: %v\n", err)
+	}
+	if err := idx.store.SetMeta("embedding_model", idx.config.Model); err != nil {
+		return fmt.Errorf("set meta: %w", err)
+	}
+	// An index summarized before the overview was stored in it has only
+	// the file; keep the overview with the index from now on.
+	if stored, err := idx.store.GetMeta("overview"); err == nil && stored == "" {
+		if data, err := os.ReadFile(idx.overviewPath()); err == nil && len(data) > 0 {
+			if err := idx.store.SetMeta("overview", string(data)); err != nil {
+				return fmt.Errorf("set meta: %w", err)
 			}
 		}
 	}
+	// Marks the index as modified so query caches are invalidated.
+	if err := idx.store.SetMeta("last_indexed", time.Now().UTC().Format(time.RFC3339Nano)); err != nil {
+		return fmt.Errorf("set meta: %w", err)
+	}
+	return nil
+}
 
-	return stats, nil
+// summariesPending reports whether some indexed file has no summary yet or
+// the overview hasn't been written. Files the model failed to summarize
+// aren't pending until they change; see summaryFailuresMeta.
+func (idx *Indexer) summariesPending() bool {
+	if overview, err := LoadOverview(idx.store, idx.config.DBPath); err != nil || overview == "" {
+		return true
+	}
+	files, err := idx.store.ListFiles()
+	if err != nil {
+		return false
+	}
+	failures, err := summaryFailures(idx.store)
+	if err != nil {
+		return false
+	}
+	for _, f := range files {
+		if _, failed := failures[f.Path]; f.Summary == "" && f.Chunks > 0 && !failed {
+			return true
+		}
+	}
+	return false
+}
+
+// overviewPromptMeta is the meta key holding the overviewPromptHash of the
+// instructions the overview was last written with.
+const overviewPromptMeta = "overview_prompt"
+
+// overviewPromptHash identifies the overview instructions, so a run can tell
+// that they changed since the overview was written.
+func overviewPromptHash(instructions string) string {
+	h := sha256.Sum256([]byte(instructions))
+	return hex.EncodeToString(h[:])
+}
+
+// overviewPromptChanged reports whether the overview instructions differ
+// from those the overview was written with. For an overview written before
+// they were recorded, it reports whether they differ from the built-in ones.
+// Instructions that can't be loaded haven't changed; Summarize reports why.
+func (idx *Indexer) overviewPromptChanged() bool {
+	instructions, err := loadOverviewPrompt(promptsDir(idx.config.DBPath), idx.config.OverviewPrompt)
+	if err != nil {
+		return false
+	}
+	stored, err := idx.store.GetMeta(overviewPromptMeta)
+	if err != nil {
+		return false
+	}
+	if stored == "" {
+		stored = overviewPromptHash(overviewPrompt)
+	}
+	return stored != overviewPromptHash(instructions)
+}
+
+// log returns where progress messages are written.
+func (idx *Indexer) log() io.Writer {
+	if idx.config.Log != nil {
+		return idx.config.Log
+	}
+	return os.Stdout
+}
+
+// overviewPath is where the copy of the project overview is written.
+func (idx *Indexer) overviewPath() string {
+	return filepath.Join(filepath.Dir(idx.config.DBPath), OverviewFile)
+}
+
+// Summarize generates per-file summaries and the project overview for the
+// existing index using the overview model. Files that already have a summary
+// are skipped unless force is set. It stops early when ctx is done.
+func (idx *Indexer) Summarize(ctx context.Context, force bool) error {
+	overviewModel := idx.config.OverviewModel
+	if overviewModel == "" {
+		overviewModel = "qwen3:8b"
+	}
+	chat := llm.NewOllamaChat(idx.config.OllamaURL, overviewModel)
+	chat.KeepAlive = idx.config.KeepAlive
+
+	prompts, err := loadSummaryPrompts(promptsDir(idx.config.DBPath))
+	if err != nil {
+		return fmt.Errorf("load summary prompts: %w", err)
+	}
+	instructions, err := loadOverviewPrompt(promptsDir(idx.config.DBPath), idx.config.OverviewPrompt)
+	if err != nil {
+		return fmt.Errorf("load overview prompt: %w", err)
+	}
+
+	fmt.Fprintln(idx.log(), "Generating file summaries...")
+	if idx.config.OnProgress != nil {
+		idx.config.OnProgress(Progress{Phase: "Generating file summaries..."})
+	}
+	if err := summarizeFiles(ctx, idx.log(), idx.store, chat, prompts, force); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "warning: file summarization failed: %v\n", err)
+	}
+	if err := embedSummaries(ctx, idx.store, idx.embedder); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+
+	fmt.Fprintln(idx.log(), "Generating project overview...")
+	if idx.config.OnProgress != nil {
+		idx.config.OnProgress(Progress{Phase: "Generating project overview..."})
+	}
+	maxSymbols := idx.config.OverviewSymbols
+	if maxSymbols == 0 {
+		maxSymbols = DefaultOverviewSymbols
+	}
+	overview, err := synthesizeOverview(ctx, idx.log(), idx.store, chat, instructions, maxSymbols)
+	if err != nil {
+		return fmt.Errorf("overview generation failed: %w", err)
+	}
+	// The index holds the overview, so it travels with the database; the
+	// file is a copy for reading it elsewhere.
+	if err := idx.store.SetMeta("overview", overview); err != nil {
+		return fmt.Errorf("failed to store overview: %w", err)
+	}
+	if err := idx.store.SetMeta(overviewPromptMeta, overviewPromptHash(instructions)); err != nil {
+		return fmt.Errorf("set meta: %w", err)
+	}
+	if err := os.WriteFile(idx.overviewPath(), []byte(overview), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not write %s: %v\n", idx.overviewPath(), err)
+	}
+	return nil
 }
 
 // Search finds the top-k chunks closest to the query.
@@ -120,7 +718,7 @@ func (idx *Indexer) Search(query string, k int) ([]store.SearchResult, error) {
 	if err != nil {
 		return nil, fmt.Errorf("embed query: %w", err)
 	}
-	return idx.store.Search(embedding, k)
+	return idx.store.Search(embedding, k, store.Filter{})
 }
 
 // Store returns the underlying store so callers (e.g. the TUI) can reuse it
@@ -1,20 +1,26 @@
 package index
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"synapse/internal/embedder"
 	"synapse/internal/llm"
+	"synapse/internal/rag"
 	"synapse/internal/store"
 )
 
-const fileSummaryPrompt = `Summarize this source file in 2-3 sentences. What does it define, and what is its role in the project? Be specific about the types, functions, or interfaces it provides. Do not speculate about things not shown in the code.
-
-File: %s
-Language: %s
-
-` + "```\n%s\n```"
-
 const overviewPrompt = `You are a senior software architect analyzing a codebase. Based ONLY on the file summaries and symbol names provided below, write a concise architectural overview in Markdown.
 
 Rules:
@@ -31,19 +37,115 @@ Cover:
 Keep it under 300 words. Do not include code snippets.
 `
 
-// summarizeFiles generates per-file summaries for any files that don't have one yet.
-func summarizeFiles(s *store.SQLiteStore, chat *llm.OllamaChat) error {
+// OverviewFile is the name of the copy of the project overview written next
+// to the index, for reading it outside synapse.
+const OverviewFile = "overview.md"
+
+// LoadOverview returns the project overview stored in st, whose database is
+// at dbPath. An index summarized before the overview was stored in it has
+// only the OverviewFile next to it, which is read instead. It returns ""
+// when there's no overview.
+func LoadOverview(st store.Store, dbPath string) (string, error) {
+	overview, err := st.GetMeta("overview")
+	if err != nil {
+		return "", fmt.Errorf("get meta: %w", err)
+	}
+	if overview != "" {
+		return overview, nil
+	}
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(dbPath), OverviewFile))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// summaryFailuresMeta is the meta key holding, as a JSON object, the files
+// the model returned no summary for or couldn't fit in its context window,
+// with the hash of the content it was given. They're retried only once that
+// content changes or summaries are forced, so a file the model never
+// answers for doesn't keep every run, such as each 'index --resume',
+// summarizing.
+const summaryFailuresMeta = "summary_failures"
+
+// summaryFailures returns the files the model returned no summary for as
+// they are now, by path, with their hashes.
+func summaryFailures(s *store.SQLiteStore) (map[string]string, error) {
+	failures := make(map[string]string)
+	stored, err := s.GetMeta(summaryFailuresMeta)
+	if err != nil || stored == "" {
+		return failures, err
+	}
+	if err := json.Unmarshal([]byte(stored), &failures); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", summaryFailuresMeta, err)
+	}
+	records, err := s.FileRecords()
+	if err != nil {
+		return nil, fmt.Errorf("file records: %w", err)
+	}
+	for path, hash := range failures {
+		if rec, ok := records[path]; !ok || rec.Hash != hash {
+			delete(failures, path)
+		}
+	}
+	return failures, nil
+}
+
+// saveSummaryFailures records failures, as returned by summaryFailures.
+func saveSummaryFailures(s *store.SQLiteStore, failures map[string]string) error {
+	data, err := json.Marshal(failures)
+	if err != nil {
+		return err
+	}
+	return s.SetMeta(summaryFailuresMeta, string(data))
+}
+
+// summarizeFiles generates per-file summaries for any files that don't have one
+// yet, or for every file when force is set, with the instructions in prompts
+// for each file's language. Each summary is saved as soon as it's generated,
+// so an interrupted run picks up where it stopped. Files the model returned
+// no summary for, or that don't fit in its context window, are recorded in
+// summaryFailuresMeta and skipped until they change, unless force is set;
+// other errors end the run, to be retried by the next one.
+func summarizeFiles(ctx context.Context, out io.Writer, s *store.SQLiteStore, chat llm.Chat, prompts map[string]string, force bool) error {
 	files, err := s.ListFiles()
 	if err != nil {
 		return fmt.Errorf("list files: %w", err)
 	}
+	failures, err := summaryFailures(s)
+	if err != nil {
+		return err
+	}
+	records, err := s.FileRecords()
+	if err != nil {
+		return fmt.Errorf("file records: %w", err)
+	}
+	skip := func(f store.FileSummary) bool {
+		if f.Chunks == 0 {
+			return true
+		}
+		_, failed := failures[f.Path]
+		return !force && (f.Summary != "" || failed)
+	}
+
+	if !force {
+		done := 0
+		for _, f := range files {
+			if skip(f) {
+				done++
+			}
+		}
+		if done > 0 && done < len(files) {
+			fmt.Fprintf(out, "  %d of %d files already summarized; continuing with the rest\n", done, len(files))
+		}
+	}
 
 	for _, f := range files {
-		if f.Summary != "" {
+		if skip(f) {
 			continue
 		}
 
-		fmt.Printf("  Summarizing %s...\n", f.Path)
+		fmt.Fprintf(out, "  Summarizing %s...\n", f.Path)
 
 		content, err := s.GetAllFileContent(f.Path)
 		if err != nil {
@@ -53,26 +155,84 @@ func summarizeFiles(s *store.SQLiteStore, chat *llm.OllamaChat) error {
 			continue
 		}
 
-		prompt := fmt.Sprintf(fileSummaryPrompt, f.Path, f.Language, content)
+		prompt := summaryPrompt(prompts, f.Path, f.Language, content)
 		msgs := []llm.Message{
 			{Role: "user", Content: prompt},
 		}
 
-		summary, err := chat.Generate(msgs)
+		summary, err := chat.GenerateContext(ctx, msgs)
+		if errors.Is(err, llm.ErrEmptyResponse) || errors.Is(err, llm.ErrContextLength) {
+			fmt.Fprintf(os.Stderr, "warning: no summary for %s: %v; retried when it changes or by 'synapse summarize --force'\n", f.Path, err)
+			failures[f.Path] = records[f.Path].Hash
+			if err := saveSummaryFailures(s, failures); err != nil {
+				return fmt.Errorf("save summary failures: %w", err)
+			}
+			continue
+		}
 		if err != nil {
 			return fmt.Errorf("summarize %s: %w", f.Path, err)
 		}
 
-		if err := s.SetFileSummary(f.Path, strings.TrimSpace(summary)); err != nil {
+		if err := s.SetFileSummary(f.Path, strings.TrimSpace(llm.StripThinking(summary))); err != nil {
 			return fmt.Errorf("save summary for %s: %w", f.Path, err)
 		}
+		if _, ok := failures[f.Path]; ok {
+			delete(failures, f.Path)
+			if err := saveSummaryFailures(s, failures); err != nil {
+				return fmt.Errorf("save summary failures: %w", err)
+			}
+		}
 	}
 
 	return nil
 }
 
-// synthesizeOverview combines all file summaries into a project-level architectural overview.
-func synthesizeOverview(s *store.SQLiteStore, chat *llm.OllamaChat) (string, error) {
+// embedSummaries embeds the summaries of files that don't have a summary
+// embedding yet, for file-level similarity (Store.RelatedFiles).
+func embedSummaries(ctx context.Context, s *store.SQLiteStore, emb embedder.Embedder) error {
+	files, err := s.FilesWithoutEmbedding()
+	if err != nil {
+		return fmt.Errorf("list files: %w", err)
+	}
+	for i := 0; i < len(files); i += embedBatchSize {
+		batch := files[i:min(i+embedBatchSize, len(files))]
+		texts := make([]string, len(batch))
+		for j, f := range batch {
+			texts[j] = fmt.Sprintf("File: %s\nLanguage: %s\n\n%s", f.Path, f.Language, f.Summary)
+		}
+		embs, err := embedder.EmbedContext(ctx, emb, texts)
+		if err != nil {
+			return fmt.Errorf("embed summaries: %w", err)
+		}
+		for j, f := range batch {
+			if err := s.SetFileEmbedding(f.Path, embs[j]); err != nil {
+				return fmt.Errorf("save summary embedding for %s: %w", f.Path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DefaultOverviewSymbols is the default cap on the symbols listed per file in
+// the overview prompt.
+const DefaultOverviewSymbols = 20
+
+// overviewBudget is the estimated token budget for a single overview
+// prompt. Ollama silently drops the start of prompts longer than the model's
+// context window (4096 tokens by default), so larger inputs are summarized
+// per directory first, leaving room for the instructions and the answer.
+const overviewBudget = 3000
+
+const dirSummaryPrompt = `Summarize the %s part of a codebase in 3-5 sentences, based ONLY on the file summaries and symbol names provided below. What is it responsible for, what are its main components, and how do they relate? Do not speculate about things not shown.
+
+`
+
+// synthesizeOverview combines all file summaries into a project-level
+// architectural overview, following instructions. When they don't fit in
+// one prompt, directories are summarized first, from the deepest that fit,
+// and the overview is built from those; see dirSummarizer. At most
+// maxSymbols symbols are listed per file; see selectSymbols.
+func synthesizeOverview(ctx context.Context, out io.Writer, s *store.SQLiteStore, chat llm.Chat, instructions string, maxSymbols int) (string, error) {
 	files, err := s.ListFiles()
 	if err != nil {
 		return "", fmt.Errorf("list files: %w", err)
@@ -92,28 +252,293 @@ func synthesizeOverview(s *store.SQLiteStore, chat *llm.OllamaChat) (string, err
 		chunksByFile[c.FilePath] = append(chunksByFile[c.FilePath], c)
 	}
 
+	sections := make([]string, len(files))
+	total := 0
+	for i, f := range files {
+		sections[i] = fileSection(f, chunksByFile[f.Path], maxSymbols)
+		total += rag.EstimateTokens(sections[i])
+	}
+
 	var b strings.Builder
-	b.WriteString(overviewPrompt)
+	b.WriteString(instructions)
 	b.WriteString("\n## Project Structure\n\n")
+	if total <= overviewBudget {
+		for _, sec := range sections {
+			b.WriteString(sec)
+		}
+	} else {
+		fmt.Fprintln(out, "  File summaries exceed one prompt; summarizing by directory...")
+		d := &dirSummarizer{ctx: ctx, out: out, s: s, chat: chat, files: files, sections: sections}
+		all := make([]int, len(files))
+		for i := range all {
+			all[i] = i
+		}
+		parts, err := d.parts(".", all)
+		if err != nil {
+			return "", err
+		}
+		input, err := d.fit(".", parts)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(input)
+	}
 
-	for _, f := range files {
-		fmt.Fprintf(&b, "### %s  (%s, %d chunks)\n", f.Path, f.Language, f.Chunks)
+	msgs := []llm.Message{
+		{Role: "user", Content: b.String()},
+	}
 
-		if f.Summary != "" {
-			fmt.Fprintf(&b, "Summary: %s\n", f.Summary)
+	overview, err := chat.GenerateContext(ctx, msgs)
+	return llm.StripThinking(overview), err
+}
+
+// fileSection formats a file's summary and symbols for an overview prompt.
+func fileSection(f store.FileSummary, chunks []store.ChunkSummary, maxSymbols int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s  (%s, %d chunks)\n", f.Path, f.Language, f.Chunks)
+	if f.Summary != "" {
+		fmt.Fprintf(&b, "Summary: %s\n", f.Summary)
+	}
+	symbols := selectSymbols(f.Language, chunks, maxSymbols)
+	for _, c := range symbols {
+		fmt.Fprintf(&b, "  - [%s] %s\n", c.Kind, c.Name)
+	}
+	if omitted := len(chunks) - len(symbols); omitted > 0 {
+		fmt.Fprintf(&b, "  - (%d more)\n", omitted)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// selectSymbols returns up to limit of a file's symbols, in source order,
+// preferring public ones over private ones when it has more. limit < 0
+// returns them all.
+func selectSymbols(lang string, chunks []store.ChunkSummary, limit int) []store.ChunkSummary {
+	if limit < 0 || len(chunks) <= limit {
+		return chunks
+	}
+	order := make([]int, len(chunks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return isPublic(lang, chunks[order[a]]) && !isPublic(lang, chunks[order[b]])
+	})
+	order = order[:limit]
+	sort.Ints(order)
+	selected := make([]store.ChunkSummary, len(order))
+	for i, j := range order {
+		selected[i] = chunks[j]
+	}
+	return selected
+}
+
+// isPublic reports whether a symbol is visible outside its file or package:
+// capitalized in Go, exported in JavaScript and TypeScript, and not
+// underscore-prefixed elsewhere.
+func isPublic(lang string, c store.ChunkSummary) bool {
+	switch lang {
+	case "go":
+		r, _ := utf8.DecodeRuneInString(c.Name)
+		return unicode.IsUpper(r)
+	case "javascript", "typescript":
+		return c.Kind == "export_statement"
+	}
+	return !strings.HasPrefix(c.Name, "_")
+}
+
+// dirSummarizer summarizes the files of a codebase too large for one
+// overview prompt, directory by directory. A summary is stored and reused for
+// as long as its input doesn't change.
+type dirSummarizer struct {
+	ctx      context.Context
+	out      io.Writer // for progress messages
+	s        *store.SQLiteStore
+	chat     llm.Chat
+	files    []store.FileSummary
+	sections []string // each file's section; see fileSection
+}
+
+// overviewPart is a section of an overview prompt: a file's, or a summary
+// of several files'.
+type overviewPart struct {
+	text  string
+	files int // how many files it describes
+}
+
+// parts describes the files under dir, given by their indexes: with their
+// own sections when those fit in one prompt together, and otherwise with
+// the sections of the files directly in dir and a summary of each
+// subdirectory.
+func (d *dirSummarizer) parts(dir string, idx []int) ([]overviewPart, error) {
+	total := 0
+	for _, i := range idx {
+		total += rag.EstimateTokens(d.sections[i])
+	}
+	var parts []overviewPart
+	if total <= overviewBudget {
+		for _, i := range idx {
+			parts = append(parts, overviewPart{text: d.sections[i], files: 1})
+		}
+		return parts, nil
+	}
+
+	var subdirs []string
+	bySubdir := make(map[string][]int)
+	for _, i := range idx {
+		sub, ok := childDir(dir, d.files[i].Path)
+		if !ok {
+			parts = append(parts, overviewPart{text: d.sections[i], files: 1})
+			continue
+		}
+		if _, ok := bySubdir[sub]; !ok {
+			subdirs = append(subdirs, sub)
 		}
+		bySubdir[sub] = append(bySubdir[sub], i)
+	}
+	for _, sub := range subdirs {
+		input, err := d.parts(sub, bySubdir[sub])
+		if err != nil {
+			return nil, err
+		}
+		text, err := d.fit(sub, input)
+		if err != nil {
+			return nil, err
+		}
+		summary, err := d.summarize(sub, dirLabel(sub), text)
+		if err != nil {
+			return nil, err
+		}
+		files := len(bySubdir[sub])
+		parts = append(parts, overviewPart{text: dirSection(dirLabel(sub), files, summary), files: files})
+	}
+	return parts, nil
+}
 
-		if fileChunks, ok := chunksByFile[f.Path]; ok {
-			for _, c := range fileChunks {
-				fmt.Fprintf(&b, "  - [%s] %s\n", c.Kind, c.Name)
+// fit joins parts into the input of one prompt about dir. While they don't
+// fit, runs of them are summarized together, each run at most a prompt
+// long; any part too long for a prompt on its own is left out.
+func (d *dirSummarizer) fit(dir string, parts []overviewPart) (string, error) {
+	for round := 1; partTokens(parts) > overviewBudget; round++ {
+		runs := packParts(parts)
+		if len(runs) == len(parts) {
+			break // no two parts fit in a prompt together
+		}
+		merged := make([]overviewPart, 0, len(runs))
+		for n, run := range runs {
+			if len(run) == 1 {
+				merged = append(merged, run[0])
+				continue
+			}
+			var input strings.Builder
+			files := 0
+			for _, p := range run {
+				input.WriteString(p.text)
+				files += p.files
 			}
+			label := fmt.Sprintf("%s (part %d of %d)", dirLabel(dir), n+1, len(runs))
+			summary, err := d.summarize(fmt.Sprintf("%s#%d.%d", dir, round, n+1), label, input.String())
+			if err != nil {
+				return "", err
+			}
+			merged = append(merged, overviewPart{text: dirSection(label, files, summary), files: files})
 		}
-		b.WriteString("\n")
+		parts = merged
 	}
 
-	msgs := []llm.Message{
-		{Role: "user", Content: b.String()},
+	var b strings.Builder
+	used, omitted := 0, 0
+	for _, p := range parts {
+		used += rag.EstimateTokens(p.text)
+		if used > overviewBudget {
+			omitted += p.files
+			continue
+		}
+		b.WriteString(p.text)
+	}
+	if omitted > 0 {
+		fmt.Fprintf(&b, "(%d more files not shown)\n", omitted)
+	}
+	return b.String(), nil
+}
+
+// summarize returns the summary of input, labeled label in the prompt,
+// stored under key.
+func (d *dirSummarizer) summarize(key, label, input string) (string, error) {
+	h := sha256.Sum256([]byte(input))
+	hash := hex.EncodeToString(h[:])
+	summary, storedHash, err := d.s.DirSummary(key)
+	if err != nil {
+		return "", fmt.Errorf("get summary for %s: %w", label, err)
+	}
+	if summary != "" && storedHash == hash {
+		return summary, nil
+	}
+	fmt.Fprintf(d.out, "  Summarizing directory %s...\n", label)
+	prompt := fmt.Sprintf(dirSummaryPrompt, label) + input
+	summary, err = d.chat.GenerateContext(d.ctx, []llm.Message{{Role: "user", Content: prompt}})
+	if err != nil {
+		return "", fmt.Errorf("summarize %s: %w", label, err)
 	}
+	summary = strings.TrimSpace(llm.StripThinking(summary))
+	if err := d.s.SetDirSummary(key, hash, summary); err != nil {
+		return "", fmt.Errorf("save summary for %s: %w", label, err)
+	}
+	return summary, nil
+}
 
-	return chat.Generate(msgs)
+// dirSection formats the summary of files, labeled label, for a prompt.
+func dirSection(label string, files int, summary string) string {
+	return fmt.Sprintf("### %s  (%d files)\n%s\n\n", label, files, summary)
+}
+
+// partTokens estimates the tokens in parts.
+func partTokens(parts []overviewPart) int {
+	total := 0
+	for _, p := range parts {
+		total += rag.EstimateTokens(p.text)
+	}
+	return total
+}
+
+// packParts splits parts into runs of consecutive parts that fit in one
+// prompt together. A part too long on its own is a run by itself.
+func packParts(parts []overviewPart) [][]overviewPart {
+	var runs [][]overviewPart
+	used := 0
+	for _, p := range parts {
+		n := rag.EstimateTokens(p.text)
+		if len(runs) == 0 || used+n > overviewBudget {
+			runs = append(runs, nil)
+			used = 0
+		}
+		runs[len(runs)-1] = append(runs[len(runs)-1], p)
+		used += n
+	}
+	return runs
+}
+
+// childDir returns the subdirectory of dir that the file at path, relative
+// to the project root, is in, or false for a file directly in dir.
+func childDir(dir, path string) (string, bool) {
+	rest := filepath.ToSlash(path)
+	if dir != "." {
+		rest = strings.TrimPrefix(rest, dir+"/")
+	}
+	first, _, ok := strings.Cut(rest, "/")
+	if !ok {
+		return "", false
+	}
+	if dir == "." {
+		return first, true
+	}
+	return dir + "/" + first, true
+}
+
+// dirLabel names a directory, relative to the project root, in prompts.
+func dirLabel(dir string) string {
+	if dir == "." {
+		return "project root"
+	}
+	return dir + "/"
 }
@@ -0,0 +1,192 @@
+//go:build sqlite_fts5
+
+package index
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"synapse/internal/llm"
+	"synapse/internal/rag"
+	"synapse/internal/store"
+)
+
+// TestSynthesizeOverviewLargeDir checks that a directory too large for one
+// prompt is summarized in parts rather than losing files, that no prompt
+// exceeds the budget, and that the summaries are reused while nothing
+// changes.
+func TestSynthesizeOverviewLargeDir(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	paths := []string{"main.go"}
+	for _, dir := range []string{"src/deep/x", "src/deep/y"} {
+		for i := range 30 {
+			paths = append(paths, fmt.Sprintf("%s/f%02d.go", dir, i))
+		}
+	}
+	summary := strings.Repeat("Handles one small, well-defined part of the work. ", 12)
+	for _, path := range paths {
+		if _, _, err := s.ReplaceFileChunks(store.FileRecord{Path: path, Hash: path, Language: "go"}, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetFileSummary(path, summary); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	chat := llm.NewFakeChat("A short summary.")
+	if _, err := synthesizeOverview(context.Background(), io.Discard, s, chat, overviewPrompt, DefaultOverviewSymbols); err != nil {
+		t.Fatal(err)
+	}
+	calls := chat.Calls()
+	var prompts strings.Builder
+	for _, call := range calls {
+		prompt := call[len(call)-1].Content
+		if n := rag.EstimateTokens(prompt); n > overviewBudget+rag.EstimateTokens(overviewPrompt) {
+			t.Errorf("prompt of %d tokens exceeds the budget:\n%.200s...", n, prompt)
+		}
+		prompts.WriteString(prompt)
+	}
+	for _, path := range paths {
+		if !strings.Contains(prompts.String(), "### "+path+" ") {
+			t.Errorf("%s is in no prompt", path)
+		}
+	}
+	if strings.Contains(prompts.String(), "more files not shown") {
+		t.Error("files were left out of a prompt")
+	}
+
+	again := llm.NewFakeChat("A short summary.")
+	if _, err := synthesizeOverview(context.Background(), io.Discard, s, again, overviewPrompt, DefaultOverviewSymbols); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(again.Calls()); n != 1 {
+		t.Errorf("second run made %d requests, want 1 (the overview, from stored summaries)", n)
+	}
+}
+
+// TestSummarizeFilesEmptyResponse checks that a file the model returns no
+// summary for isn't retried until it changes, and stops being pending.
+func TestSummarizeFilesEmptyResponse(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"<think>Hmm.</think>"},"done":true}`)
+	}))
+	defer srv.Close()
+	chat := llm.NewOllamaChat(srv.URL, "fake")
+
+	s, err := store.Open(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.SetEmbeddingDim(3); err != nil {
+		t.Fatal(err)
+	}
+	chunks := []store.Chunk{{Name: "run", Kind: "function", StartLine: 1, EndLine: 1, Content: "func run() {}"}}
+	put := func(hash string) {
+		t.Helper()
+		if _, _, err := s.ReplaceFileChunks(store.FileRecord{Path: "a.go", Hash: hash, Language: "go"}, chunks, [][]float32{{1, 0, 0}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	summarize := func() {
+		t.Helper()
+		if err := summarizeFiles(context.Background(), io.Discard, s, chat, nil, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := &Indexer{store: s, config: Config{DBPath: filepath.Join(t.TempDir(), "index.db")}}
+	if err := s.SetMeta("overview", "An overview."); err != nil {
+		t.Fatal(err)
+	}
+
+	put("1")
+	summarize()
+	summarize()
+	if requests != 1 {
+		t.Errorf("file with no summary was requested %d times, want 1", requests)
+	}
+	if idx.summariesPending() {
+		t.Error("summaries pending for a file the model gave no summary for")
+	}
+
+	put("2")
+	if !idx.summariesPending() {
+		t.Error("no summaries pending after the file changed")
+	}
+	summarize()
+	if requests != 2 {
+		t.Errorf("changed file was requested %d times in all, want 2", requests)
+	}
+}
+
+// TestSummarizeFilesContextLength checks that a file too long for the
+// model is recorded and skipped so the files after it are summarized, and
+// that other errors stop the run without recording the file.
+func TestSummarizeFilesContextLength(t *testing.T) {
+	var requests []string
+	overloaded := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case strings.Contains(string(body), "File: a.go"):
+			requests = append(requests, "a.go")
+			http.Error(w, `{"error":"prompt too long; exceeded max context length"}`, http.StatusInternalServerError)
+		case overloaded:
+			requests = append(requests, "b.go")
+			http.Error(w, `{"error":"server busy"}`, http.StatusServiceUnavailable)
+		default:
+			requests = append(requests, "b.go")
+			fmt.Fprint(w, `{"message":{"role":"assistant","content":"Runs things."},"done":true}`)
+		}
+	}))
+	defer srv.Close()
+	chat := llm.NewOllamaChat(srv.URL, "fake")
+
+	s, err := store.Open(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.SetEmbeddingDim(3); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"a.go", "b.go"} {
+		chunks := []store.Chunk{{Name: "run", Kind: "function", StartLine: 1, EndLine: 1, Content: "func run() {}"}}
+		if _, _, err := s.ReplaceFileChunks(store.FileRecord{Path: path, Hash: path, Language: "go"}, chunks, [][]float32{{1, 0, 0}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	summarize := func() error {
+		return summarizeFiles(context.Background(), io.Discard, s, chat, nil, false)
+	}
+
+	if err := summarize(); err == nil || !strings.Contains(err.Error(), "server busy") {
+		t.Fatalf("summarize with the server busy = %v, want its error", err)
+	}
+	overloaded = false
+	if err := summarize(); err != nil {
+		t.Fatal(err)
+	}
+	if err := summarize(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.go", "b.go", "b.go"}; !slices.Equal(requests, want) {
+		t.Errorf("requests for %v, want %v: a.go once, b.go until it's summarized", requests, want)
+	}
+	if summary, err := s.GetFileSummary("b.go"); err != nil || summary != "Runs things." {
+		t.Errorf("summary of b.go = %q, %v", summary, err)
+	}
+}
@@ -1,13 +1,19 @@
 package index
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"runtime"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"unicode/utf8"
 
 	"synapse/internal/chunker"
 	"synapse/internal/embedder"
@@ -17,12 +23,75 @@ import (
 
 const embedBatchSize = 32
 
+// DefaultEmbedMaxBytes is the default cap on each embedding input. It stays
+// within the 2048-token context Ollama gives embedding models by default,
+// at the ~3 bytes per token typical of source code.
+const DefaultEmbedMaxBytes = 6000
+
 // Stats reports indexing results.
 type Stats struct {
 	FilesTotal   int
 	FilesIndexed int
 	FilesSkipped int
-	ChunksTotal  int
+	// FilesSkippedBinary counts files with a registered extension that were
+	// skipped because they contain binary or non-UTF-8 data. They are also
+	// included in FilesSkipped.
+	FilesSkippedBinary int
+	// FilesSkippedGenerated counts generated files (e.g. *.pb.go) left out
+	// by the walker. Unlike the other skip counts they're not in FilesTotal.
+	FilesSkippedGenerated int
+	// FilesHidden counts hidden files, or files in hidden directories, that
+	// the walker found with IncludeHidden. They're also in FilesTotal.
+	FilesHidden int
+	// FilesPanicked counts files whose chunking panicked inside tree-sitter.
+	// They're skipped, also included in FilesSkipped, and retried next run.
+	FilesPanicked int
+	ChunksTotal   int
+	// EmbeddingsReused counts chunks of changed files whose content was
+	// unchanged, so their stored embeddings were kept instead of re-embedded.
+	EmbeddingsReused int
+	// FilesCapped counts files with more chunks than the per-file cap; only
+	// a representative subset of their chunks was indexed.
+	FilesCapped int
+	// ChunksTruncated counts oversized chunks that hit the split cap; only
+	// their first pieces were indexed.
+	ChunksTruncated int
+	// ChunksSplitForEmbedding counts chunks longer than the embedding input
+	// cap, embedded in pieces whose vectors were averaged.
+	ChunksSplitForEmbedding int
+	// ChunksExcluded counts symbols left out because their name matched an
+	// exclude-symbols pattern.
+	ChunksExcluded int
+	// EmbeddingsDegenerate counts chunks whose embedding was all zeros (or
+	// NaN or infinite). Their vectors aren't stored, so only keyword search
+	// finds them.
+	EmbeddingsDegenerate int
+	// FileErrors lists the files that failed to read, chunk, embed, or
+	// store, sorted by path. Each is also reported on stderr as it happens.
+	FileErrors []FileError
+}
+
+// Stages of the pipeline a FileError can happen in.
+const (
+	StageRead  = "read"
+	StageChunk = "chunk"
+	StageEmbed = "embed"
+	StageStore = "store"
+)
+
+// FileError is a file that failed to index.
+type FileError struct {
+	Path string
+	// Stage is where it failed: StageRead, StageChunk, StageEmbed, or
+	// StageStore.
+	Stage   string
+	Message string
+}
+
+// isBinary reports whether src looks like binary data rather than source
+// text: it contains a NUL byte or is not valid UTF-8.
+func isBinary(src []byte) bool {
+	return bytes.IndexByte(src, 0) >= 0 || !utf8.Valid(src)
 }
 
 // fileWork is a file that needs to be (re-)indexed.
@@ -39,31 +108,119 @@ type chunkBatch struct {
 	chunks []chunker.RawChunk
 }
 
-// embeddedBatch has chunks with their embeddings ready to store.
+// embeddedBatch has chunks with their embeddings ready to store. Chunks
+// whose content is already stored for the file have a nil embedding.
 type embeddedBatch struct {
 	work       fileWork
 	chunks     []chunker.RawChunk
+	hashes     []string
 	embeddings [][]float32
 }
 
+// counters are the Stats tallies updated concurrently by pipeline stages.
+type counters struct {
+	filesTotal           atomic.Int64
+	filesBinary          atomic.Int64
+	filesGenerated       atomic.Int64
+	filesHidden          atomic.Int64
+	filesPanicked        atomic.Int64
+	filesCapped          atomic.Int64
+	chunksTruncated      atomic.Int64
+	chunksExcluded       atomic.Int64
+	chunksSplit          atomic.Int64
+	embeddingsDegenerate atomic.Int64
+
+	errMu      sync.Mutex
+	fileErrors []FileError
+}
+
+// fileError records that the file at path failed in stage, and reports it
+// on stderr.
+func (c *counters) fileError(path, stage string, err error) {
+	fmt.Fprintf(os.Stderr, "%s error %s: %v\n", stage, path, err)
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	c.fileErrors = append(c.fileErrors, FileError{Path: path, Stage: stage, Message: err.Error()})
+}
+
+// fill copies the tallies into stats, whose FilesIndexed must be set.
+func (c *counters) fill(stats *Stats) {
+	stats.FilesTotal = int(c.filesTotal.Load())
+	stats.FilesSkipped = stats.FilesTotal - stats.FilesIndexed
+	stats.FilesSkippedBinary = int(c.filesBinary.Load())
+	stats.FilesSkippedGenerated = int(c.filesGenerated.Load())
+	stats.FilesHidden = int(c.filesHidden.Load())
+	stats.FilesPanicked = int(c.filesPanicked.Load())
+	stats.FilesCapped = int(c.filesCapped.Load())
+	stats.ChunksTruncated = int(c.chunksTruncated.Load())
+	stats.ChunksExcluded = int(c.chunksExcluded.Load())
+	stats.ChunksSplitForEmbedding = int(c.chunksSplit.Load())
+	stats.EmbeddingsDegenerate = int(c.embeddingsDegenerate.Load())
+	c.errMu.Lock()
+	stats.FileErrors = append([]FileError(nil), c.fileErrors...)
+	c.errMu.Unlock()
+	sort.SliceStable(stats.FileErrors, func(i, j int) bool { return stats.FileErrors[i].Path < stats.FileErrors[j].Path })
+}
+
+// runPipeline indexes the files under root that changed since they were
+// stored in s, or every file when rechunk is set: it chunks them, then embeds
+// and stores the chunks. Chunks whose content is unchanged keep their
+// embeddings either way.
 func runPipeline(
+	ctx context.Context,
 	root string,
 	s *store.SQLiteStore,
 	astChunker *chunker.ASTChunker,
 	registry *chunker.Registry,
-	emb *embedder.OllamaEmbedder,
+	emb embedder.Embedder,
+	embedMaxBytes int,
 	numWorkers int,
+	walkOpts walker.Options,
+	known map[string]store.FileRecord,
+	rechunk bool,
 	onProgress ProgressFunc,
 ) (*Stats, error) {
+	var c counters
+	hashes := s
+	if rechunk {
+		hashes = nil
+	}
+	chunkCh, walkErrCh := chunkFiles(ctx, root, hashes, astChunker, registry, numWorkers, walkOpts, known, &c)
+	stats, err := embedAndStore(ctx, s, emb, embedMaxBytes, chunkCh, &c, onProgress)
+	if werr := <-walkErrCh; werr != nil {
+		return nil, fmt.Errorf("walk error: %w", werr)
+	}
+	c.fill(stats)
+	return stats, err
+}
+
+// chunkFiles walks root and chunks the files that changed since they were
+// stored in s, or every file when s is nil. It returns the chunks of each
+// file, and the walk's error once the channel is closed.
+func chunkFiles(
+	ctx context.Context,
+	root string,
+	s *store.SQLiteStore,
+	astChunker *chunker.ASTChunker,
+	registry *chunker.Registry,
+	numWorkers int,
+	walkOpts walker.Options,
+	known map[string]store.FileRecord,
+	c *counters,
+) (<-chan chunkBatch, <-chan error) {
 	if numWorkers <= 0 {
 		numWorkers = runtime.NumCPU()
 	}
 
-	var stats Stats
-	var filesTotal atomic.Int64
-
 	// Stage 1: Walk (only files with registered grammars)
-	fileCh, walkErrCh := walker.Walk(root, registry.Extensions())
+	walkOpts.OnSkipGenerated = func(string) { c.filesGenerated.Add(1) }
+	walkOpts.OnHidden = func(string) { c.filesHidden.Add(1) }
+	walkOpts.Filenames = registry.Filenames()
+	walkOpts.Sniff = func(head []byte) bool {
+		spec, _ := registry.Detect("", head)
+		return spec != nil
+	}
+	fileCh, walkErrCh := walker.Walk(root, registry.Extensions(), walkOpts)
 
 	// Stage 2: Hash + check (N workers)
 	workCh := make(chan fileWork, numWorkers)
@@ -73,20 +230,34 @@ func runPipeline(
 		go func() {
 			defer hashWg.Done()
 			for fi := range fileCh {
-				filesTotal.Add(1)
+				c.filesTotal.Add(1)
+				if ctx.Err() != nil {
+					continue // drain so the walker can finish
+				}
+				if rec, ok := known[fi.RelPath]; ok && rec.Unchanged(fi.Size, fi.ModTime) {
+					continue // not modified since it was indexed
+				}
 				src, err := os.ReadFile(fi.Path)
 				if err != nil {
+					c.fileError(fi.RelPath, StageRead, err)
+					continue
+				}
+				if isBinary(src) {
+					fmt.Fprintf(os.Stderr, "skipping binary file %s\n", fi.RelPath)
+					c.filesBinary.Add(1)
 					continue
 				}
 				h := sha256.Sum256(src)
 				hash := hex.EncodeToString(h[:])
 
-				existing, err := s.GetFileHash(fi.RelPath)
-				if err == nil && existing == hash {
-					continue // unchanged
+				if s != nil {
+					existing, err := s.GetFileHash(fi.RelPath)
+					if err == nil && existing == hash {
+						continue // unchanged
+					}
 				}
 
-				lang := registry.LanguageName(fi.Path)
+				_, lang := registry.Detect(fi.Path, src)
 				workCh <- fileWork{
 					info: fi,
 					hash: hash,
@@ -102,6 +273,22 @@ func runPipeline(
 	}()
 
 	// Stage 3: Chunk (N workers)
+	chunkerCopy := *astChunker
+	astChunker = &chunkerCopy
+	astChunker.OnTruncate = func(path, name string, dropped int) {
+		if name == "" {
+			name = "chunk"
+		}
+		fmt.Fprintf(os.Stderr, "truncating oversized %s in %s: indexed %d pieces, dropped %d\n",
+			name, path, astChunker.MaxSplits, dropped)
+		c.chunksTruncated.Add(1)
+	}
+	astChunker.OnExclude = func(string, string) { c.chunksExcluded.Add(1) }
+	astChunker.OnCap = func(path string, total int) {
+		fmt.Fprintf(os.Stderr, "capping %s: %d chunks, indexed the %d largest definitions\n",
+			path, total, astChunker.MaxChunks)
+		c.filesCapped.Add(1)
+	}
 	chunkCh := make(chan chunkBatch, numWorkers)
 	var chunkWg sync.WaitGroup
 	for range numWorkers {
@@ -109,14 +296,21 @@ func runPipeline(
 		go func() {
 			defer chunkWg.Done()
 			for w := range workCh {
+				if ctx.Err() != nil {
+					continue
+				}
 				chunks, err := astChunker.Chunk(w.info.RelPath, w.src)
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "chunker error %s: %v\n", w.info.RelPath, err)
+					var pe *chunker.PanicError
+					if errors.As(err, &pe) {
+						c.filesPanicked.Add(1)
+					}
+					c.fileError(w.info.RelPath, StageChunk, err)
 					continue
 				}
-				if len(chunks) > 0 {
-					chunkCh <- chunkBatch{work: w, chunks: chunks}
-				}
+				// Sent even without chunks, so a file that no longer yields
+				// any loses the ones stored for it.
+				chunkCh <- chunkBatch{work: w, chunks: chunks}
 			}
 		}()
 	}
@@ -125,6 +319,24 @@ func runPipeline(
 		close(chunkCh)
 	}()
 
+	return chunkCh, walkErrCh
+}
+
+// embedAndStore embeds the chunks of each file from chunkCh and stores them
+// in s, replacing the file's previous chunks. It drains chunkCh even after
+// an error. The returned Stats has the files and chunks stored; the rest is
+// left in c.
+func embedAndStore(
+	ctx context.Context,
+	s *store.SQLiteStore,
+	emb embedder.Embedder,
+	embedMaxBytes int,
+	chunkCh <-chan chunkBatch,
+	c *counters,
+	onProgress ProgressFunc,
+) (*Stats, error) {
+	var stats Stats
+
 	// Stage 4: Embed (1 worker, batches of embedBatchSize)
 	embeddedCh := make(chan embeddedBatch, 4)
 	var embedErr error
@@ -135,31 +347,74 @@ func runPipeline(
 		defer close(embeddedCh)
 
 		for batch := range chunkCh {
-			texts := make([]string, len(batch.chunks))
+			// After a failure or cancellation keep draining so upstream
+			// stages don't block.
+			if embedErr != nil || ctx.Err() != nil {
+				continue
+			}
+			// Only embed chunks whose content isn't already stored for this
+			// file; the store keeps the embeddings of the rest.
+			existing, err := s.ChunkHashes(batch.work.info.RelPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "chunk hashes error %s: %v\n", batch.work.info.RelPath, err)
+				existing = nil
+			}
+			hashes := make([]string, len(batch.chunks))
+			var texts []string
+			var toEmbed []int
 			for i, c := range batch.chunks {
-				texts[i] = c.Content
+				hashes[i] = store.ChunkHash(c.Name, c.Content)
+				if existing[hashes[i]] > 0 {
+					existing[hashes[i]]--
+					continue
+				}
+				texts = append(texts, c.Content)
+				toEmbed = append(toEmbed, i)
 			}
 
-			// Embed in sub-batches of embedBatchSize.
+			// Embed in sub-batches of embedBatchSize. Files needing more than
+			// one report each, so they don't look stuck.
 			allEmbeddings := make([][]float32, 0, len(texts))
+			batches := (len(texts) + embedBatchSize - 1) / embedBatchSize
+			reportBatches := func(done int) {
+				if onProgress != nil && batches > 1 {
+					onProgress(Progress{Phase: PhaseEmbedding, File: batch.work.info.RelPath, BatchesDone: done, Batches: batches})
+				}
+			}
+			reportBatches(0)
 			for i := 0; i < len(texts); i += embedBatchSize {
 				end := i + embedBatchSize
 				if end > len(texts) {
 					end = len(texts)
 				}
-				embs, err := emb.Embed(texts[i:end])
+				embs, split, err := embedder.EmbedSplit(ctx, emb, texts[i:end], embedMaxBytes)
+				c.chunksSplit.Add(int64(split))
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "embed error %s: %v\n", batch.work.info.RelPath, err)
-					embedErr = err
-					return
+					if ctx.Err() == nil {
+						c.fileError(batch.work.info.RelPath, StageEmbed, err)
+						embedErr = err
+					}
+					break
 				}
 				allEmbeddings = append(allEmbeddings, embs...)
+				reportBatches(i/embedBatchSize + 1)
+			}
+			if len(allEmbeddings) != len(texts) {
+				continue
 			}
 
+			embeddings := make([][]float32, len(batch.chunks))
+			for j, i := range toEmbed {
+				embeddings[i] = allEmbeddings[j]
+				if embedder.IsZero(allEmbeddings[j]) || embedder.Validate(allEmbeddings[j]) != nil {
+					c.embeddingsDegenerate.Add(1)
+				}
+			}
 			embeddedCh <- embeddedBatch{
 				work:       batch.work,
 				chunks:     batch.chunks,
-				embeddings: allEmbeddings,
+				hashes:     hashes,
+				embeddings: embeddings,
 			}
 		}
 	}()
@@ -172,46 +427,38 @@ func runPipeline(
 		defer storeWg.Done()
 
 		for eb := range embeddedCh {
-			fileID, err := s.UpsertFile(store.FileRecord{
-				Path:      eb.work.info.RelPath,
-				Hash:      eb.work.hash,
-				Language:  eb.work.lang,
-				SizeBytes: eb.work.info.Size,
-			})
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "store upsert error %s: %v\n", eb.work.info.RelPath, err)
-				storeErr = err
-				continue
-			}
-
 			storeChunks := make([]store.Chunk, len(eb.chunks))
 			for i, c := range eb.chunks {
 				storeChunks[i] = store.Chunk{
-					Name:      c.Name,
-					Kind:      c.Kind,
-					StartLine: c.StartLine,
-					EndLine:   c.EndLine,
-					Content:   c.Content,
+					Name:          c.Name,
+					QualifiedName: c.QualifiedName,
+					Kind:          c.Kind,
+					StartLine:     c.StartLine,
+					EndLine:       c.EndLine,
+					Content:       c.Content,
+					Hash:          eb.hashes[i],
+					Metadata:      chunkMetadata(c),
 				}
 			}
 
-			chunkIDs, err := s.InsertChunks(fileID, storeChunks)
+			_, reused, err := s.ReplaceFileChunks(store.FileRecord{
+				Path:      eb.work.info.RelPath,
+				Hash:      eb.work.hash,
+				Language:  eb.work.lang,
+				SizeBytes: eb.work.info.Size,
+				ModTime:   eb.work.info.ModTime,
+			}, storeChunks, eb.embeddings)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "store chunks error %s: %v\n", eb.work.info.RelPath, err)
-				storeErr = err
-				continue
-			}
-
-			if err := s.InsertEmbeddings(chunkIDs, eb.embeddings); err != nil {
-				fmt.Fprintf(os.Stderr, "store embeddings error %s: %v\n", eb.work.info.RelPath, err)
+				c.fileError(eb.work.info.RelPath, StageStore, err)
 				storeErr = err
 				continue
 			}
 
 			stats.FilesIndexed++
+			stats.EmbeddingsReused += reused
 			stats.ChunksTotal += len(eb.chunks)
 			if onProgress != nil {
-				onProgress("Indexing files...", stats.FilesIndexed, int(filesTotal.Load()))
+				onProgress(Progress{Phase: "Indexing files...", FilesProcessed: stats.FilesIndexed, FilesTotal: int(c.filesTotal.Load())})
 			}
 		}
 	}()
@@ -220,20 +467,28 @@ func runPipeline(
 	storeWg.Wait()
 	embedWg.Wait()
 
-	// Check walk errors.
-	if err := <-walkErrCh; err != nil {
-		return nil, fmt.Errorf("walk error: %w", err)
+	if err := ctx.Err(); err != nil {
+		return &stats, err
 	}
-
-	stats.FilesTotal = int(filesTotal.Load())
-	stats.FilesSkipped = stats.FilesTotal - stats.FilesIndexed
-
 	if embedErr != nil {
 		return &stats, fmt.Errorf("embedding failed: %w", embedErr)
 	}
 	if storeErr != nil {
 		return &stats, fmt.Errorf("storage failed: %w", storeErr)
 	}
-
 	return &stats, nil
 }
+
+// chunkMetadata returns the metadata stored with c, as a JSON object: the
+// component block it came from and its place among the pieces of a split
+// definition. It returns "" when c has neither.
+func chunkMetadata(c chunker.RawChunk) string {
+	if c.Block == "" && c.Piece == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(struct {
+		Block string `json:"block,omitempty"`
+		Piece int    `json:"piece,omitempty"`
+	}{c.Block, c.Piece})
+	return string(data)
+}
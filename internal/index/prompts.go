@@ -0,0 +1,84 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// summaryInstructions asks for a file summary. It's used for languages
+// without a prompt of their own.
+const summaryInstructions = `Summarize this source file in 2-3 sentences. What does it define, and what is its role in the project? Be specific about the types, functions, or interfaces it provides. Do not speculate about things not shown in the code.`
+
+// languageSummaryInstructions are the built-in summary instructions for
+// languages the general prompt suits poorly, keyed by language name.
+var languageSummaryInstructions = map[string]string{
+	"css":  `Summarize this stylesheet in 2-3 sentences. Which pages, components, or elements does it style, and what does it establish for them (layout, theme variables, typography, responsive breakpoints)? Name the main selectors or custom properties. Do not speculate about things not shown in the code.`,
+	"html": `Summarize this HTML file in 2-3 sentences. What page or template is it, what are its main sections and forms, and which scripts and stylesheets does it load? Do not speculate about things not shown in the code.`,
+	"sql":  `Summarize this SQL file in 2-3 sentences. Which tables, views, indexes, or functions does it create or change, and what data do they hold or queries do they serve? Name the key columns and relationships. Do not speculate about things not shown in the code.`,
+}
+
+// promptsDir returns the directory of prompt overrides for the index at
+// dbPath.
+func promptsDir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "prompts")
+}
+
+// loadSummaryPrompts returns the summary instructions by language: the
+// built-in ones, replaced or extended by the summary-<lang>.md files in dir.
+// A missing dir has none.
+func loadSummaryPrompts(dir string) (map[string]string, error) {
+	prompts := make(map[string]string, len(languageSummaryInstructions))
+	for lang, text := range languageSummaryInstructions {
+		prompts[lang] = text
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "summary-*.md"))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		text := strings.TrimSpace(string(data))
+		if text == "" {
+			return nil, fmt.Errorf("%s is empty", p)
+		}
+		lang := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(p), "summary-"), ".md")
+		prompts[lang] = text
+	}
+	return prompts, nil
+}
+
+// loadOverviewPrompt returns the instructions for writing the project
+// overview: those in the file at path if it's set, else those in
+// overview.md in dir, else the built-in overviewPrompt.
+func loadOverviewPrompt(dir, path string) (string, error) {
+	if path == "" {
+		path = filepath.Join(dir, "overview.md")
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return overviewPrompt, nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return text + "\n", nil
+}
+
+// summaryPrompt returns the prompt summarizing the file at path: the
+// instructions for its language, then the file itself.
+func summaryPrompt(prompts map[string]string, path, lang, content string) string {
+	instructions, ok := prompts[lang]
+	if !ok {
+		instructions = summaryInstructions
+	}
+	return fmt.Sprintf("%s\n\nFile: %s\nLanguage: %s\n\n```\n%s\n```", instructions, path, lang, content)
+}
@@ -0,0 +1,84 @@
+//go:build sqlite_fts5
+
+package index_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"synapse/internal/index"
+	"synapse/internal/ollamatest"
+)
+
+// TestRechunkOnOptionChange checks that changing an option that shapes
+// chunks re-chunks files whose content didn't change, once.
+func TestRechunkOnOptionChange(t *testing.T) {
+	srv := ollamatest.NewServer(t, 16)
+	root := t.TempDir()
+	src := "package demo\n\ntype Point struct {\n\tX, Y int\n}\n\nfunc alphaOne() int {\n\treturn 1\n}\n"
+	if err := os.WriteFile(filepath.Join(root, "a.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "index.db")
+	run := func(kinds ...string) *index.Stats {
+		t.Helper()
+		idx, err := index.New(index.Config{DBPath: dbPath, OllamaURL: srv.URL, Model: "fake", Workers: 1, SkipOverview: true, ChunkKinds: kinds})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		stats, err := idx.Index(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+	if stats := run(); stats.ChunksTotal != 2 {
+		t.Fatalf("first run stored %d chunks, want 2", stats.ChunksTotal)
+	}
+
+	stats := run("function")
+	if stats.FilesIndexed != 1 || stats.ChunksTotal != 1 {
+		t.Errorf("run with --chunk-kinds function indexed %d files and %d chunks, want 1 and 1", stats.FilesIndexed, stats.ChunksTotal)
+	}
+	if stats.EmbeddingsReused != 1 {
+		t.Errorf("re-chunked file reused %d embeddings, want 1", stats.EmbeddingsReused)
+	}
+	if stats := run("function"); stats.FilesIndexed != 0 {
+		t.Errorf("run with the same options indexed %d files, want 0", stats.FilesIndexed)
+	}
+}
+
+// TestChunklessFileDropsChunks checks that a file changed to yield no
+// chunks loses the ones stored for it.
+func TestChunklessFileDropsChunks(t *testing.T) {
+	srv := ollamatest.NewServer(t, 16)
+	root := t.TempDir()
+	path := filepath.Join(root, "a.go")
+	if err := os.WriteFile(path, []byte("package demo\n\nfunc alphaOne() int {\n\treturn 1\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := index.New(index.Config{DBPath: filepath.Join(t.TempDir(), "index.db"), OllamaURL: srv.URL, Model: "fake", Workers: 1, SkipOverview: true, ChunkKinds: []string{"function"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	if _, err := idx.Index(root); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("package demo\n\ntype Point struct {\n\tX, Y int\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.Index(root); err != nil {
+		t.Fatal(err)
+	}
+	files, err := idx.Store().ListFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Chunks != 0 {
+		t.Errorf("files after a.go lost its only function = %+v, want a.go with no chunks", files)
+	}
+}
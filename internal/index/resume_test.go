@@ -0,0 +1,55 @@
+//go:build sqlite_fts5
+
+package index_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"synapse/internal/index"
+	"synapse/internal/ollamatest"
+)
+
+// TestResumeOlderModTime edits a file to content of the same size with an
+// older modification time, as restoring it from a backup would, and checks
+// that --resume re-indexes it rather than taking it as unchanged.
+func TestResumeOlderModTime(t *testing.T) {
+	srv := ollamatest.NewServer(t, 16)
+	root := t.TempDir()
+	path := filepath.Join(root, "a.go")
+	if err := os.WriteFile(path, []byte("package demo\n\nfunc alphaOne() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "index.db")
+	run := func(resume bool) *index.Stats {
+		t.Helper()
+		idx, err := index.New(index.Config{DBPath: dbPath, OllamaURL: srv.URL, Model: "fake", Workers: 1, SkipOverview: true, Resume: resume})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		stats, err := idx.Index(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+	run(false)
+
+	if stats := run(true); stats.FilesIndexed != 0 {
+		t.Errorf("resume with nothing changed indexed %d files, want 0", stats.FilesIndexed)
+	}
+
+	if err := os.WriteFile(path, []byte("package demo\n\nfunc omegaTwo() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-24 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if stats := run(true); stats.FilesIndexed != 1 {
+		t.Errorf("resume after restoring an older a.go indexed %d files, want 1", stats.FilesIndexed)
+	}
+}
@@ -0,0 +1,177 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"synapse/internal/store"
+)
+
+// snapshotTimeFormat names snapshot files, so they sort by when they were
+// taken, followed by microseconds (see snapshotName). Parsing with it
+// accepts names with or without them, as older snapshots have none.
+const snapshotTimeFormat = "20060102-150405"
+
+// snapshotOverviewSuffix names the copy of the OverviewFile kept with a
+// snapshot: its name followed by this.
+const snapshotOverviewSuffix = ".overview.md"
+
+// Snapshot is a copy of the index taken before an indexing run.
+type Snapshot struct {
+	// Name is the snapshot's file name without .db, e.g.
+	// "20240131-154500.123456".
+	Name  string
+	Path  string
+	Taken time.Time
+	Size  int64
+}
+
+// SnapshotsDir returns the directory holding the snapshots of the index at
+// dbPath.
+func SnapshotsDir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "snapshots")
+}
+
+// ListSnapshots returns the snapshots of the index at dbPath, newest first.
+// Files in the directory with other names are ignored.
+func ListSnapshots(dbPath string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(SnapshotsDir(dbPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snaps []Snapshot
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".db")
+		if !ok || e.IsDir() {
+			continue
+		}
+		taken, err := time.ParseInLocation(snapshotTimeFormat, name, time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, Snapshot{
+			Name:  name,
+			Path:  filepath.Join(SnapshotsDir(dbPath), e.Name()),
+			Taken: taken,
+			Size:  info.Size(),
+		})
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Taken.After(snaps[j].Taken) })
+	return snaps, nil
+}
+
+// snapshotName names a snapshot taken at t. Microseconds keep runs in
+// quick succession from overwriting each other's snapshots.
+func snapshotName(t time.Time) string {
+	return t.Format(snapshotTimeFormat + ".000000")
+}
+
+// overviewCopy returns where the copy of the overview taken with the
+// snapshot at path is kept.
+func overviewCopy(path string) string {
+	return strings.TrimSuffix(path, ".db") + snapshotOverviewSuffix
+}
+
+// takeSnapshot copies the index, and the OverviewFile next to it if any,
+// to the snapshots directory and deletes the oldest snapshots beyond keep.
+// An index that was never indexed into isn't copied.
+func (idx *Indexer) takeSnapshot(keep int) error {
+	last, err := idx.store.GetMeta("last_indexed")
+	if err != nil {
+		return fmt.Errorf("get meta: %w", err)
+	}
+	if last == "" {
+		return nil
+	}
+	dir := SnapshotsDir(idx.config.DBPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	taken := time.Now()
+	dest := filepath.Join(dir, snapshotName(taken)+".db")
+	for {
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			break
+		}
+		taken = taken.Add(time.Microsecond)
+		dest = filepath.Join(dir, snapshotName(taken)+".db")
+	}
+	fmt.Fprintf(idx.log(), "Saving a snapshot of the index to %s\n", dest)
+	if err := idx.store.Backup(dest); err != nil {
+		return err
+	}
+	if err := copyIfExists(idx.overviewPath(), overviewCopy(dest)); err != nil {
+		return fmt.Errorf("snapshot overview: %w", err)
+	}
+
+	snaps, err := ListSnapshots(idx.config.DBPath)
+	if err != nil {
+		return err
+	}
+	for _, s := range snaps[min(keep, len(snaps)):] {
+		if err := os.Remove(s.Path); err != nil {
+			return err
+		}
+		if err := os.Remove(overviewCopy(s.Path)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyIfExists copies the file at src to dst, unless there's none at src.
+func copyIfExists(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0o644)
+}
+
+// RestoreSnapshot replaces the index at dbPath with the snapshot called
+// name, or the newest one if name is "", along with the OverviewFile next to
+// it when the snapshot has a copy. It returns the snapshot restored.
+// Nothing else should have the index open.
+func RestoreSnapshot(dbPath, name string) (*Snapshot, error) {
+	snaps, err := ListSnapshots(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(snaps) == 0 {
+		return nil, fmt.Errorf("no snapshots in %s; index with --snapshots N to take them", SnapshotsDir(dbPath))
+	}
+	snap := &snaps[0]
+	if name != "" {
+		snap = nil
+		for i := range snaps {
+			if snaps[i].Name == strings.TrimSuffix(name, ".db") {
+				snap = &snaps[i]
+				break
+			}
+		}
+		if snap == nil {
+			return nil, fmt.Errorf("no snapshot %q in %s", name, SnapshotsDir(dbPath))
+		}
+	}
+	if err := store.Restore(dbPath, snap.Path); err != nil {
+		return nil, err
+	}
+	if err := copyIfExists(overviewCopy(snap.Path), filepath.Join(filepath.Dir(dbPath), OverviewFile)); err != nil {
+		return nil, fmt.Errorf("restore overview: %w", err)
+	}
+	return snap, nil
+}
@@ -0,0 +1,59 @@
+//go:build sqlite_fts5
+
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSnapshots takes snapshots in quick succession, which must not
+// overwrite each other, and checks that restoring one brings back the
+// overview as it was when the snapshot was taken.
+func TestSnapshots(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "index.db")
+	idx, err := New(Config{DBPath: dbPath, Model: "fake"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	overviewPath := idx.overviewPath()
+	if err := idx.store.SetMeta("last_indexed", "2024-01-31T15:45:00Z"); err != nil {
+		t.Fatal(err)
+	}
+	// A snapshot named before names had microseconds.
+	old := filepath.Join(SnapshotsDir(dbPath), "20240131-154500.db")
+	if err := os.MkdirAll(SnapshotsDir(dbPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(old, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, overview := range []string{"first overview", "second overview"} {
+		if err := os.WriteFile(idx.overviewPath(), []byte(overview), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := idx.takeSnapshot(5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx.Close()
+	snaps, err := ListSnapshots(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 3 || snaps[0].Name == snaps[1].Name || snaps[2].Path != old {
+		t.Fatalf("snapshots %+v, want two new ones, newest first, then the old one", snaps)
+	}
+
+	if err := os.WriteFile(overviewPath, []byte("later overview"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RestoreSnapshot(dbPath, snaps[1].Name); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(overviewPath); err != nil || string(data) != "first overview" {
+		t.Errorf("overview after restoring the first snapshot = %q, %v; want %q", data, err, "first overview")
+	}
+}
@@ -0,0 +1,129 @@
+//go:build sqlite_fts5
+
+package index_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"synapse/internal/index"
+	"synapse/internal/ollamatest"
+)
+
+// TestReindexRegeneratesSummary edits one of two files and re-indexes: the
+// edited file's summary must be regenerated from its new code, and the
+// other's kept. The fake chat model answers with its prompt, so a summary
+// shows the code it was written from.
+func TestReindexRegeneratesSummary(t *testing.T) {
+	srv := ollamatest.NewServer(t, 16)
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("edited.go", "package demo\n\nfunc oldBehavior() {}\n")
+	write("kept.go", "package demo\n\nfunc untouched() {}\n")
+
+	dbPath := filepath.Join(t.TempDir(), "index.db")
+	reindex := func() {
+		t.Helper()
+		idx, err := index.New(index.Config{DBPath: dbPath, OllamaURL: srv.URL, Model: "fake", OverviewModel: "fake", Workers: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		if _, err := idx.Index(root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	summaries := func() map[string]string {
+		t.Helper()
+		idx, err := index.New(index.Config{DBPath: dbPath, OllamaURL: srv.URL, Model: "fake"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		got := make(map[string]string)
+		for _, name := range []string{"edited.go", "kept.go"} {
+			if got[name], err = idx.Store().GetFileSummary(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return got
+	}
+
+	reindex()
+	before := summaries()
+	if !strings.Contains(before["edited.go"], "oldBehavior") {
+		t.Fatalf("first summary of edited.go = %q, want it written from oldBehavior", before["edited.go"])
+	}
+
+	write("edited.go", "package demo\n\nfunc newBehavior(n int) int { return n }\n")
+	calls := len(srv.Chat.Calls())
+	reindex()
+	after := summaries()
+
+	if strings.Contains(after["edited.go"], "oldBehavior") || !strings.Contains(after["edited.go"], "newBehavior") {
+		t.Errorf("summary of edited.go after the edit = %q, want it regenerated from newBehavior", after["edited.go"])
+	}
+	if after["kept.go"] != before["kept.go"] {
+		t.Errorf("summary of kept.go changed though the file didn't")
+	}
+	for _, call := range srv.Chat.Calls()[calls:] {
+		if prompt := call[len(call)-1].Content; strings.Contains(prompt, "untouched") && !strings.Contains(prompt, "newBehavior") {
+			t.Errorf("kept.go was summarized again")
+		}
+	}
+}
+
+// TestPromptTemplateRegeneratesOverview checks that new overview
+// instructions rewrite the overview of an index whose files didn't change,
+// once.
+func TestPromptTemplateRegeneratesOverview(t *testing.T) {
+	srv := ollamatest.NewServer(t, 16)
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.go"), []byte("package demo\n\nfunc alphaOne() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := filepath.Join(t.TempDir(), "overview.md")
+	if err := os.WriteFile(tmpl, []byte("Describe the architecture in Esperanto.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "index.db")
+	reindex := func(prompt string) string {
+		t.Helper()
+		idx, err := index.New(index.Config{DBPath: dbPath, OllamaURL: srv.URL, Model: "fake", OverviewModel: "fake", OverviewPrompt: prompt, Workers: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		if _, err := idx.Index(root); err != nil {
+			t.Fatal(err)
+		}
+		overview, err := index.LoadOverview(idx.Store(), dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return overview
+	}
+
+	if overview := reindex(""); strings.Contains(overview, "Esperanto") {
+		t.Fatalf("overview written with the built-in instructions = %q", overview)
+	}
+	calls := len(srv.Chat.Calls())
+	if overview := reindex(""); len(srv.Chat.Calls()) != calls {
+		t.Errorf("unchanged index with unchanged instructions was summarized again: %q", overview)
+	}
+	if overview := reindex(tmpl); !strings.Contains(overview, "Esperanto") {
+		t.Errorf("overview after --prompt-template = %q, want it written from the template", overview)
+	}
+	calls = len(srv.Chat.Calls())
+	reindex(tmpl)
+	if len(srv.Chat.Calls()) != calls {
+		t.Errorf("overview was rewritten again with the same template")
+	}
+}
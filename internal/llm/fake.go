@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// FakeChat answers without a model, for tests and examples that run offline.
+// It returns its canned Responses in order, repeating the last one, or with
+// none echoes the last user message. It is safe for concurrent use.
+type FakeChat struct {
+	Responses []string
+
+	mu    sync.Mutex
+	calls [][]Message
+}
+
+// NewFakeChat creates a fake chat returning responses in order; with none it
+// echoes the question.
+func NewFakeChat(responses ...string) *FakeChat {
+	return &FakeChat{Responses: responses}
+}
+
+// Calls returns the conversations the fake has been asked to answer, oldest
+// first.
+func (c *FakeChat) Calls() [][]Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]Message(nil), c.calls...)
+}
+
+// Generate returns the next canned response.
+func (c *FakeChat) Generate(messages []Message) (string, error) {
+	return c.GenerateContext(context.Background(), messages)
+}
+
+// GenerateContext is like Generate but fails if ctx is already done.
+func (c *FakeChat) GenerateContext(ctx context.Context, messages []Message) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, append([]Message(nil), messages...))
+	switch n := len(c.calls); {
+	case len(c.Responses) == 0:
+		return lastUserMessage(messages), nil
+	case n <= len(c.Responses):
+		return c.Responses[n-1], nil
+	default:
+		return c.Responses[len(c.Responses)-1], nil
+	}
+}
+
+// GenerateStream is like Generate but passes the response to onToken a word
+// at a time.
+func (c *FakeChat) GenerateStream(messages []Message, onToken func(string)) (string, error) {
+	answer, err := c.Generate(messages)
+	if err != nil || onToken == nil {
+		return answer, err
+	}
+	for _, tok := range strings.SplitAfter(answer, " ") {
+		if tok != "" {
+			onToken(tok)
+		}
+	}
+	return answer, nil
+}
+
+func lastUserMessage(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
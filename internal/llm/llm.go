@@ -0,0 +1,20 @@
+package llm
+
+import "context"
+
+// Chat generates assistant responses to a conversation. OllamaChat is the
+// default implementation; FakeChat answers offline for tests and examples.
+type Chat interface {
+	// Generate returns the assistant's response to messages.
+	Generate(messages []Message) (string, error)
+	// GenerateContext is like Generate but aborts when ctx is done.
+	GenerateContext(ctx context.Context, messages []Message) (string, error)
+	// GenerateStream is like Generate but calls onToken with each piece of
+	// the response as it's produced. It returns the full response.
+	GenerateStream(messages []Message, onToken func(string)) (string, error)
+}
+
+var (
+	_ Chat = (*OllamaChat)(nil)
+	_ Chat = (*FakeChat)(nil)
+)
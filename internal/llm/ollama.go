@@ -2,11 +2,16 @@ package llm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
+
+	"synapse/internal/ollama"
 )
 
 // Message represents a single chat message.
@@ -15,11 +20,40 @@ type Message struct {
 	Content string `json:"content"`
 }
 
+// ErrContextLength is returned, wrapped, when the prompt doesn't fit in the
+// model's context window.
+var ErrContextLength = errors.New("prompt exceeds the model's context length")
+
+// ErrEmptyResponse is returned, wrapped, when the model's response has no
+// content, such as when a reasoning model spends it all thinking.
+var ErrEmptyResponse = errors.New("the model returned an empty response")
+
+// contextLengthPhrases appear in the errors Ollama and the runners behind it
+// report for prompts longer than the context window.
+var contextLengthPhrases = []string{"context length", "context window", "context size", "prompt too long", "too many tokens"}
+
+// chatError returns an error for the message Ollama reported, wrapping
+// ErrContextLength if it's about the prompt's length.
+func chatError(prefix, msg string) error {
+	lower := strings.ToLower(msg)
+	for _, phrase := range contextLengthPhrases {
+		if strings.Contains(lower, phrase) {
+			return fmt.Errorf("%s: %w: %s", prefix, ErrContextLength, msg)
+		}
+	}
+	return fmt.Errorf("%s: %s", prefix, msg)
+}
+
 // OllamaChat calls the Ollama /api/chat endpoint for generative responses.
 type OllamaChat struct {
 	baseURL string
 	model   string
 	client  *http.Client
+
+	// KeepAlive is how long Ollama keeps the model loaded after a request,
+	// so consecutive requests don't wait for it to reload. 0 uses the
+	// server's default (5 minutes); a negative value keeps it loaded.
+	KeepAlive time.Duration
 }
 
 // NewOllamaChat creates a chat client targeting the given Ollama instance and model.
@@ -33,28 +67,101 @@ func NewOllamaChat(baseURL, model string) *OllamaChat {
 	}
 }
 
+// Ping checks that Ollama is reachable by fetching its model list. It fails
+// fast so callers can report an unreachable server before doing expensive work.
+func (c *OllamaChat) Ping(ctx context.Context) error {
+	return ollama.Ping(ctx, c.client, c.baseURL)
+}
+
 type chatRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-	Stream   bool      `json:"stream"`
+	Model     string    `json:"model"`
+	Messages  []Message `json:"messages"`
+	Stream    bool      `json:"stream"`
+	KeepAlive string    `json:"keep_alive,omitempty"`
 }
 
 type chatResponse struct {
-	Message Message `json:"message"`
+	Message    responseMessage `json:"message"`
+	Done       bool            `json:"done"`
+	DoneReason string          `json:"done_reason"`
+	Error      string          `json:"error"`
+}
+
+// responseMessage is the assistant message of a chat response. Reasoning
+// models put their reasoning in Thinking rather than Content, and models
+// that support tools can answer with ToolCalls instead of content.
+type responseMessage struct {
+	Content   string     `json:"content"`
+	Thinking  string     `json:"thinking"`
+	ToolCalls []toolCall `json:"tool_calls"`
+}
+
+type toolCall struct {
+	Function struct {
+		Name string `json:"name"`
+	} `json:"function"`
+}
+
+// emptyResponse collects what a response held other than content, to
+// explain why it has none.
+type emptyResponse struct {
+	thinking   bool
+	tools      []string
+	doneReason string
+}
+
+func (e *emptyResponse) add(part chatResponse) {
+	if strings.TrimSpace(part.Message.Thinking) != "" || strings.Contains(part.Message.Content, thinkOpen) || strings.Contains(part.Message.Content, thinkClose) {
+		e.thinking = true
+	}
+	for _, call := range part.Message.ToolCalls {
+		e.tools = append(e.tools, call.Function.Name)
+	}
+	if part.DoneReason != "" {
+		e.doneReason = part.DoneReason
+	}
+}
+
+// err returns ErrEmptyResponse, wrapped with what the model produced
+// instead and what to try.
+func (e *emptyResponse) err() error {
+	var detail string
+	switch {
+	case len(e.tools) > 0:
+		detail = fmt.Sprintf(" (it asked to call %s, but no tools are offered)", strings.Join(e.tools, ", "))
+	case e.thinking && e.doneReason == "length":
+		detail = " (it ran out of tokens while thinking)"
+	case e.thinking:
+		detail = " (it only produced its reasoning)"
+	}
+	return fmt.Errorf("%w%s — try a different model or lower temperature", ErrEmptyResponse, detail)
 }
 
 // Generate sends a conversation to Ollama and returns the assistant's response.
 func (c *OllamaChat) Generate(messages []Message) (string, error) {
+	return c.GenerateContext(context.Background(), messages)
+}
+
+// GenerateContext is like Generate but aborts the request when ctx is done.
+// A response with no content outside <think> blocks fails with
+// ErrEmptyResponse.
+func (c *OllamaChat) GenerateContext(ctx context.Context, messages []Message) (string, error) {
 	body, err := json.Marshal(chatRequest{
-		Model:    c.model,
-		Messages: messages,
-		Stream:   false,
+		Model:     c.model,
+		Messages:  messages,
+		Stream:    false,
+		KeepAlive: ollama.KeepAlive(c.KeepAlive),
 	})
 	if err != nil {
 		return "", fmt.Errorf("marshal chat request: %w", err)
 	}
 
-	resp, err := c.client.Post(c.baseURL+"/api/chat", "application/json", bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("ollama chat request: %w", err)
 	}
@@ -62,7 +169,7 @@ func (c *OllamaChat) Generate(messages []Message) (string, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("ollama chat returned %d: %s", resp.StatusCode, string(respBody))
+		return "", chatError(fmt.Sprintf("ollama chat returned %d", resp.StatusCode), string(respBody))
 	}
 
 	var result chatResponse
@@ -70,5 +177,68 @@ func (c *OllamaChat) Generate(messages []Message) (string, error) {
 		return "", fmt.Errorf("decode chat response: %w", err)
 	}
 
+	if strings.TrimSpace(StripThinking(result.Message.Content)) == "" {
+		var empty emptyResponse
+		empty.add(result)
+		return "", empty.err()
+	}
 	return result.Message.Content, nil
 }
+
+// GenerateStream is like Generate but calls onToken with each piece of the
+// response as Ollama produces it. It returns the full response, or fails
+// with ErrEmptyResponse if it has no content outside <think> blocks.
+func (c *OllamaChat) GenerateStream(messages []Message, onToken func(string)) (string, error) {
+	body, err := json.Marshal(chatRequest{
+		Model:     c.model,
+		Messages:  messages,
+		Stream:    true,
+		KeepAlive: ollama.KeepAlive(c.KeepAlive),
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshal chat request: %w", err)
+	}
+
+	resp, err := c.client.Post(c.baseURL+"/api/chat", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("ollama chat request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", chatError(fmt.Sprintf("ollama chat returned %d", resp.StatusCode), string(respBody))
+	}
+
+	// Ollama streams one JSON object per line until done is set.
+	var answer strings.Builder
+	var empty emptyResponse
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var part chatResponse
+		if err := dec.Decode(&part); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return answer.String(), fmt.Errorf("decode chat stream: %w", err)
+		}
+		if part.Error != "" {
+			return answer.String(), chatError("ollama chat", part.Error)
+		}
+		if part.Message.Content != "" {
+			answer.WriteString(part.Message.Content)
+			if onToken != nil {
+				onToken(part.Message.Content)
+			}
+		}
+		empty.add(part)
+		if part.Done {
+			break
+		}
+	}
+
+	if strings.TrimSpace(StripThinking(answer.String())) == "" {
+		return "", empty.err()
+	}
+	return answer.String(), nil
+}
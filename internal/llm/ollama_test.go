@@ -0,0 +1,79 @@
+package llm_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"synapse/internal/llm"
+)
+
+// TestEmptyResponse checks that a response with no content fails with
+// ErrEmptyResponse explaining what the model produced instead, streamed or
+// not.
+func TestEmptyResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		// parts are the messages of the response, one per streamed line;
+		// unstreamed, only the last is sent.
+		parts []string
+		want  string
+	}{
+		{
+			name:  "nothing",
+			parts: []string{`{"message":{"content":""},"done":true}`},
+			want:  "empty response — try",
+		},
+		{
+			name:  "thinking field",
+			parts: []string{`{"message":{"content":"","thinking":"Let me see."},"done":true,"done_reason":"stop"}`},
+			want:  "(it only produced its reasoning)",
+		},
+		{
+			name:  "think tags",
+			parts: []string{`{"message":{"content":"<think>Let me see.</think>\n"},"done":true,"done_reason":"stop"}`},
+			want:  "(it only produced its reasoning)",
+		},
+		{
+			name:  "out of tokens while thinking",
+			parts: []string{`{"message":{"content":"","thinking":"Let me see."},"done":false}`, `{"message":{"content":""},"done":true,"done_reason":"length"}`},
+			want:  "(it ran out of tokens while thinking)",
+		},
+		{
+			name:  "tool call",
+			parts: []string{`{"message":{"content":"","tool_calls":[{"function":{"name":"search"}}]},"done":true}`},
+			want:  "(it asked to call search, but no tools are offered)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if strings.Contains(string(body), `"stream":true`) {
+					fmt.Fprint(w, strings.Join(tt.parts, "\n"))
+					return
+				}
+				// Unstreamed, Ollama sends one message with everything.
+				fmt.Fprint(w, tt.parts[len(tt.parts)-1])
+			}))
+			defer srv.Close()
+			chat := llm.NewOllamaChat(srv.URL, "fake")
+			msgs := []llm.Message{{Role: "user", Content: "hi"}}
+
+			_, err := chat.GenerateStream(msgs, nil)
+			if !errors.Is(err, llm.ErrEmptyResponse) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("GenerateStream error = %v, want ErrEmptyResponse mentioning %q", err, tt.want)
+			}
+			if len(tt.parts) == 1 {
+				_, err := chat.Generate(msgs)
+				if !errors.Is(err, llm.ErrEmptyResponse) || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("Generate error = %v, want ErrEmptyResponse mentioning %q", err, tt.want)
+				}
+			}
+		})
+	}
+}
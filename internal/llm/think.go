@@ -0,0 +1,135 @@
+package llm
+
+import "strings"
+
+// The tags reasoning models such as qwen3 and deepseek-r1 wrap their
+// reasoning in, ahead of the answer.
+const (
+	thinkOpen  = "<think>"
+	thinkClose = "</think>"
+)
+
+// SplitThinking separates a response's reasoning, in <think> blocks, from
+// its answer. Nested blocks count as one, and an unclosed block runs to the
+// end of the response, as when the model ran out of tokens while thinking.
+// A closing tag before any opening one ends reasoning that began in the
+// prompt, as some chat templates open the block themselves; later stray
+// closing tags are dropped.
+func SplitThinking(s string) (thinking, answer string) {
+	var think, out strings.Builder
+	depth := 0
+	seen := false
+	for {
+		open, close := strings.Index(s, thinkOpen), strings.Index(s, thinkClose)
+		if open < 0 && close < 0 {
+			break
+		}
+		if close < 0 || (open >= 0 && open < close) {
+			writeThinking(&think, &out, depth, s[:open])
+			depth++
+			s = s[open+len(thinkOpen):]
+		} else {
+			switch {
+			case depth > 0:
+				think.WriteString(s[:close])
+				depth--
+			case !seen:
+				think.WriteString(out.String())
+				think.WriteString(s[:close])
+				out.Reset()
+			default:
+				out.WriteString(s[:close])
+			}
+			s = s[close+len(thinkClose):]
+		}
+		seen = true
+	}
+	writeThinking(&think, &out, depth, s)
+	return strings.TrimSpace(think.String()), strings.TrimLeft(out.String(), " \t\r\n")
+}
+
+// writeThinking writes s to think inside a block, at depth > 0, and to out
+// otherwise.
+func writeThinking(think, out *strings.Builder, depth int, s string) {
+	if depth > 0 {
+		think.WriteString(s)
+	} else {
+		out.WriteString(s)
+	}
+}
+
+// StripThinking returns a response without its <think> blocks (see
+// SplitThinking).
+func StripThinking(s string) string {
+	_, answer := SplitThinking(s)
+	return answer
+}
+
+// ThinkFilter hides <think> blocks from a response as it's streamed, token
+// by token, holding back the start of a tag split between tokens. Unlike
+// SplitThinking it can't take back text already shown, so a closing tag
+// without an opening one is dropped on its own, keeping the text on either
+// side as it was. The zero value is ready to use.
+type ThinkFilter struct {
+	depth   int
+	pending string // the start of a tag at the end of the last token
+	shown   bool   // whether the answer has started
+}
+
+// Write returns the part of token outside <think> blocks, with the
+// whitespace that leads the answer removed.
+func (f *ThinkFilter) Write(token string) string {
+	s := f.pending + token
+	f.pending = ""
+	var out strings.Builder
+	for s != "" {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			f.emit(&out, s)
+			break
+		}
+		f.emit(&out, s[:i])
+		s = s[i:]
+		switch {
+		case strings.HasPrefix(s, thinkOpen):
+			f.depth++
+			s = s[len(thinkOpen):]
+		case strings.HasPrefix(s, thinkClose):
+			if f.depth > 0 {
+				f.depth--
+			}
+			s = s[len(thinkClose):]
+		case strings.HasPrefix(thinkOpen, s) || strings.HasPrefix(thinkClose, s):
+			f.pending = s // wait for the rest of the tag
+			s = ""
+		default:
+			f.emit(&out, s[:1])
+			s = s[1:]
+		}
+	}
+	return out.String()
+}
+
+// Flush returns what Write held back at the end of the response: a partial
+// tag that never completed, shown unless inside a block.
+func (f *ThinkFilter) Flush() string {
+	var out strings.Builder
+	f.emit(&out, f.pending)
+	f.pending = ""
+	return out.String()
+}
+
+// emit writes s to out unless inside a block.
+func (f *ThinkFilter) emit(out *strings.Builder, s string) {
+	if f.depth > 0 {
+		return
+	}
+	if !f.shown {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			return
+		}
+		f.shown = true
+	}
+	out.WriteString(s)
+}
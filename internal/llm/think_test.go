@@ -0,0 +1,55 @@
+package llm_test
+
+import (
+	"strings"
+	"testing"
+
+	"synapse/internal/llm"
+)
+
+// TestThinking checks SplitThinking on whole responses and ThinkFilter on
+// the same responses streamed token by token.
+func TestThinking(t *testing.T) {
+	tests := []struct {
+		name     string
+		tokens   []string
+		thinking string
+		answer   string
+		// streamed is what ThinkFilter shows, where it differs from answer.
+		streamed string
+	}{
+		{name: "no reasoning", tokens: []string{"Hello", " world"}, answer: "Hello world"},
+		{name: "block first", tokens: []string{"<think>Let me see.</think>\n\nAnswer"}, thinking: "Let me see.", answer: "Answer"},
+		{name: "nested", tokens: []string{"<think>a<think>b</think>c</think> Answer"}, thinking: "abc", answer: "Answer"},
+		{name: "unclosed", tokens: []string{"Sure. <think>never ends"}, thinking: "never ends", answer: "Sure. "},
+		{name: "block mid-answer", tokens: []string{"Hello<think>x</think> world"}, thinking: "x", answer: "Hello world"},
+		{name: "tags split across tokens", tokens: []string{"<thi", "nk>hidden</th", "ink>", "\nAnswer"}, thinking: "hidden", answer: "Answer"},
+		{name: "tag never completed", tokens: []string{"a <thi"}, answer: "a <thi"},
+		{name: "less-than sign", tokens: []string{"a <", " b"}, answer: "a < b"},
+		{name: "opened by the template", tokens: []string{"reasoning</think>\nAnswer"}, thinking: "reasoning", answer: "Answer", streamed: "reasoning\nAnswer"},
+		{name: "opened by the template, same line", tokens: []string{"Hi</th", "ink> there"}, thinking: "Hi", answer: "there", streamed: "Hi there"},
+		{name: "stray closing tag later", tokens: []string{"<think>x</think>A</think>B"}, thinking: "x", answer: "AB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thinking, answer := llm.SplitThinking(strings.Join(tt.tokens, ""))
+			if thinking != tt.thinking || answer != tt.answer {
+				t.Errorf("SplitThinking = %q, %q, want %q, %q", thinking, answer, tt.thinking, tt.answer)
+			}
+
+			var f llm.ThinkFilter
+			var shown strings.Builder
+			for _, tok := range tt.tokens {
+				shown.WriteString(f.Write(tok))
+			}
+			shown.WriteString(f.Flush())
+			want := tt.streamed
+			if want == "" {
+				want = tt.answer
+			}
+			if shown.String() != want {
+				t.Errorf("ThinkFilter shows %q, want %q", shown.String(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Package ollama holds what the embedding and chat clients share about
+// talking to an Ollama server.
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// pingTimeout bounds the Ping health check.
+const pingTimeout = 5 * time.Second
+
+// Ping checks that the Ollama server at baseURL is reachable by fetching its
+// model list with client. It fails fast so callers can report an
+// unreachable server before doing expensive work.
+func Ping(ctx context.Context, client *http.Client, baseURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/api/tags", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot reach Ollama at %s: %w", baseURL, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cannot reach Ollama at %s: /api/tags returned %d", baseURL, resp.StatusCode)
+	}
+	return nil
+}
+
+// KeepAlive formats d as a request's keep_alive parameter; 0 omits it, for
+// the server's default.
+func KeepAlive(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
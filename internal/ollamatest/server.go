@@ -0,0 +1,97 @@
+// Package ollamatest runs a stand-in for the Ollama API, so tests can index,
+// search, and summarize through the real HTTP clients without a model.
+package ollamatest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"synapse/internal/embedder"
+	"synapse/internal/llm"
+)
+
+// Server answers /api/tags, /api/embed, and /api/chat. Embeddings come from
+// an embedder.FakeEmbedder, so they're deterministic; chat answers come
+// from Chat.
+type Server struct {
+	*httptest.Server
+	// Chat answers chat requests, streamed or not. It echoes the question
+	// unless given canned responses.
+	Chat *llm.FakeChat
+
+	embedder *embedder.FakeEmbedder
+}
+
+// NewServer starts a server embedding into dim-sized vectors. It's closed
+// when the test ends.
+func NewServer(t testing.TB, dim int) *Server {
+	s := &Server{Chat: llm.NewFakeChat(), embedder: embedder.NewFakeEmbedder(dim)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"models": []map[string]string{{"name": s.embedder.Model()}}})
+	})
+	mux.HandleFunc("POST /api/embed", s.embed)
+	mux.HandleFunc("POST /api/chat", s.chat)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// embed answers an embedding request, whose input is a string or a list.
+func (s *Server) embed(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Input json.RawMessage `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var texts []string
+	if err := json.Unmarshal(req.Input, &texts); err != nil {
+		var text string
+		if err := json.Unmarshal(req.Input, &text); err != nil {
+			http.Error(w, "input is neither a string nor a list", http.StatusBadRequest)
+			return
+		}
+		texts = []string{text}
+	}
+	vecs, err := s.embedder.Embed(texts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]any{"embeddings": vecs})
+}
+
+// chat answers a chat request in one response, or for a streamed request
+// in one response per word.
+func (s *Server) chat(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Messages []llm.Message `json:"messages"`
+		Stream   bool          `json:"stream"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	message := func(content string, done bool) map[string]any {
+		return map[string]any{"message": map[string]string{"role": "assistant", "content": content}, "done": done}
+	}
+	if !req.Stream {
+		answer, _ := s.Chat.Generate(req.Messages)
+		writeJSON(w, message(answer, true))
+		return
+	}
+	enc := json.NewEncoder(w)
+	s.Chat.GenerateStream(req.Messages, func(token string) {
+		enc.Encode(message(token, false))
+	})
+	enc.Encode(message("", true))
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,106 @@
+package rag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"synapse/internal/store"
+)
+
+// LoadQueries reads queries from the file at path, or stdin for "-": a JSON
+// array of queries or of EvalCase, or else one query per line, skipping
+// blank lines and # comments.
+func LoadQueries(path string) ([]string, error) {
+	name := path
+	var data []byte
+	var err error
+	if path == "-" {
+		name = "stdin"
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var queries []string
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("[")) {
+		if queries, err = parseQueryArray(trimmed); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", name, err)
+		}
+	} else {
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				queries = append(queries, line)
+			}
+		}
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("%s has no queries", name)
+	}
+	return queries, nil
+}
+
+// parseQueryArray parses a JSON array of query strings or of EvalCase,
+// leaving out empty queries.
+func parseQueryArray(data []byte) ([]string, error) {
+	var all []string
+	if err := json.Unmarshal(data, &all); err != nil {
+		var cases []EvalCase
+		if err := json.Unmarshal(data, &cases); err != nil {
+			return nil, fmt.Errorf("want an array of queries or of golden queries: %w", err)
+		}
+		for _, c := range cases {
+			all = append(all, c.Query)
+		}
+	}
+	var queries []string
+	for _, q := range all {
+		if q = strings.TrimSpace(q); q != "" {
+			queries = append(queries, q)
+		}
+	}
+	return queries, nil
+}
+
+// BatchResult is what one query of a batch retrieved.
+type BatchResult struct {
+	Query   string
+	Results []store.SearchResult
+}
+
+// RetrieveBatch runs each query through retrieve, up to concurrency at a
+// time, and returns their results in the order of queries. retrieve must be
+// safe for concurrent use when concurrency is above 1, as Retriever.Retrieve
+// is. It fails with the error of the first query that fails.
+func RetrieveBatch(queries []string, retrieve RetrieveFunc, concurrency int) ([]BatchResult, error) {
+	batch := make([]BatchResult, len(queries))
+	errs := make([]error, len(queries))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range max(concurrency, 1) {
+		wg.Go(func() {
+			for i := range next {
+				batch[i].Query = queries[i]
+				batch[i].Results, errs[i] = retrieve(queries[i])
+			}
+		})
+	}
+	for i := range queries {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("query %q: %w", queries[i], err)
+		}
+	}
+	return batch, nil
+}
@@ -0,0 +1,83 @@
+package rag
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Benchmarked retrieval stages, in the order they run.
+var BenchStages = []string{"embed", "fts", "vector", "fusion", "total"}
+
+// StageLatency summarizes the latency of one retrieval stage, in
+// milliseconds.
+type StageLatency struct {
+	Stage string  `json:"stage"`
+	P50   float64 `json:"p50_ms"`
+	P95   float64 `json:"p95_ms"`
+	Mean  float64 `json:"mean_ms"`
+}
+
+// BenchReport summarizes the latency of repeated retrievals.
+type BenchReport struct {
+	Queries int            `json:"queries"`
+	Runs    int            `json:"runs"`
+	K       int            `json:"k"`
+	Stages  []StageLatency `json:"stages"`
+}
+
+// Bench runs each query runs times through r, bypassing its cache, and
+// reports the latency of each stage. Every query is run once first, untimed,
+// so loading the embedding model and warming the page cache don't count.
+func Bench(r *Retriever, queries []string, runs int) (*BenchReport, error) {
+	for _, q := range queries {
+		if _, _, err := r.RetrieveTimed(q); err != nil {
+			return nil, fmt.Errorf("retrieve %q: %w", q, err)
+		}
+	}
+	samples := make([][]time.Duration, len(BenchStages))
+	for range runs {
+		for _, q := range queries {
+			_, t, err := r.RetrieveTimed(q)
+			if err != nil {
+				return nil, fmt.Errorf("retrieve %q: %w", q, err)
+			}
+			for i, d := range []time.Duration{t.Embed, t.FTS, t.Vector, t.Fusion, t.Total} {
+				samples[i] = append(samples[i], d)
+			}
+		}
+	}
+	report := &BenchReport{Queries: len(queries), Runs: runs, K: r.Options.K}
+	for i, stage := range BenchStages {
+		report.Stages = append(report.Stages, latency(stage, samples[i]))
+	}
+	return report, nil
+}
+
+// latency summarizes the samples of a stage.
+func latency(stage string, samples []time.Duration) StageLatency {
+	l := StageLatency{Stage: stage}
+	if len(samples) == 0 {
+		return l
+	}
+	slices.Sort(samples)
+	var sum time.Duration
+	for _, d := range samples {
+		sum += d
+	}
+	l.P50 = ms(percentile(samples, 50))
+	l.P95 = ms(percentile(samples, 95))
+	l.Mean = ms(sum / time.Duration(len(samples)))
+	return l
+}
+
+// percentile returns the nearest-rank pth percentile of sorted samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
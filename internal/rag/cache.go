@@ -0,0 +1,115 @@
+package rag
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"synapse/internal/store"
+)
+
+// DefaultCacheTTL is how long cached retrieval results stay valid.
+const DefaultCacheTTL = 2 * time.Minute
+
+// Cache is a bounded LRU of retrieval results with a per-entry TTL. Entries
+// are keyed by the normalized query and retrieval options, and the whole cache
+// is flushed whenever the index's last_indexed marker changes, so results never
+// outlive a reindex. It is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	ll      *list.List
+	items   map[cacheKey]*list.Element
+	version string
+}
+
+type cacheKey struct {
+	query     string
+	k         int
+	filter    string
+	recency   float64
+	weighting Weighting
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	results []store.SearchResult
+	expires time.Time
+}
+
+// NewCache creates a cache holding at most size entries, each valid for ttl.
+func NewCache(size int, ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &Cache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[cacheKey]*list.Element),
+	}
+}
+
+// normalizeQuery lowercases and collapses whitespace so trivially different
+// phrasings of the same question share a cache entry.
+func normalizeQuery(q string) string {
+	return strings.Join(strings.Fields(strings.ToLower(q)), " ")
+}
+
+func (c *Cache) get(key cacheKey, version string) ([]store.SearchResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checkVersion(version)
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expires) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return append([]store.SearchResult(nil), e.results...), true
+}
+
+func (c *Cache) put(key cacheKey, version string, results []store.SearchResult) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checkVersion(version)
+	entry := &cacheEntry{
+		key:     key,
+		results: append([]store.SearchResult(nil), results...),
+		expires: time.Now().Add(c.ttl),
+	}
+	if el, ok := c.items[key]; ok {
+		el.Value = entry
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(entry)
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// checkVersion flushes the cache when the index has been modified since the
+// entries were stored. Callers must hold c.mu.
+func (c *Cache) checkVersion(version string) {
+	if version == c.version {
+		return
+	}
+	c.ll.Init()
+	c.items = make(map[cacheKey]*list.Element)
+	c.version = version
+}
@@ -0,0 +1,112 @@
+package rag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"synapse/internal/llm"
+	"synapse/internal/store"
+)
+
+const editPrompt = `You are a code intelligence assistant proposing a change to one file of a codebase. You are given the file's current content and, for reference, related code retrieved from elsewhere in the codebase.
+
+Implement the requested change as a unified diff against that file:
+- Start with "--- a/<path>" and "+++ b/<path>" lines, then hunks with "@@ -start,count +start,count @@" headers.
+- Give each hunk up to three lines of unchanged context, copied exactly from the file, including indentation.
+- Change only what the instruction needs; don't reformat other code.
+- Put the diff in a single ` + "```diff" + ` block, followed by at most a few sentences on what it does.
+
+If the change can't be made in this file, say so instead of giving a diff.`
+
+// BuildEditMessages constructs the message list asking for a unified diff
+// that carries out instruction in the file at path, given its current
+// content. Related chunks from other files are included for reference;
+// chunks of the file itself are left out, as it's included whole.
+func BuildEditMessages(path, language, content string, related []store.SearchResult, instruction string) []llm.Message {
+	var b strings.Builder
+	var others []store.SearchResult
+	for _, r := range related {
+		if r.FilePath != path {
+			others = append(others, r)
+		}
+	}
+	if len(others) > 0 {
+		b.WriteString("## Related code\n\n")
+		for _, g := range GroupByFile(others) {
+			fmt.Fprintf(&b, "=== %s (%s) ===\n\n", g.Path, g.Language)
+			for _, c := range g.Chunks {
+				fmt.Fprintf(&b, "--- [%s %s] lines %d–%d ---\n", c.Chunk.Kind, c.Chunk.Name, c.Chunk.StartLine, c.Chunk.EndLine)
+				b.WriteString(c.Chunk.Content)
+				b.WriteString("\n\n")
+			}
+		}
+	}
+	fmt.Fprintf(&b, "## File to change: %s\n\n```%s\n%s\n```\n\n", path, strings.ToLower(language), strings.TrimSuffix(content, "\n"))
+	fmt.Fprintf(&b, "## Instruction\n\n%s\n", instruction)
+	return []llm.Message{
+		{Role: "system", Content: editPrompt},
+		{Role: "user", Content: b.String()},
+	}
+}
+
+// codeBlockRe matches a fenced code block, capturing its language and
+// content. Every block is matched, so the closing fence of one isn't taken
+// for the opening fence of the next.
+var codeBlockRe = regexp.MustCompile("(?s)```([^`\n]*)\n(.*?)```")
+
+// ExtractDiff returns the unified diff in a model's answer: the first fenced
+// diff, patch, or unlabeled code block holding one, or else everything from
+// the first "--- " line. It returns "" when the answer has no diff.
+func ExtractDiff(answer string) string {
+	for _, m := range codeBlockRe.FindAllStringSubmatch(answer, -1) {
+		switch strings.TrimSpace(m[1]) {
+		case "", "diff", "patch", "udiff":
+			if isDiff(m[2]) {
+				return m[2]
+			}
+		}
+	}
+	if i := strings.Index(answer, "--- "); i >= 0 && (i == 0 || answer[i-1] == '\n') && isDiff(answer[i:]) {
+		return strings.TrimRight(answer[i:], "\n") + "\n"
+	}
+	return ""
+}
+
+// isDiff reports whether s has a unified diff hunk header.
+func isDiff(s string) bool {
+	return strings.Contains(s, "\n@@ ") || strings.HasPrefix(s, "@@ ")
+}
+
+// ErrNoPatch is returned by CheckDiff when the patch program isn't
+// installed, so the diff couldn't be checked.
+var ErrNoPatch = errors.New("patch is not installed; the diff wasn't checked")
+
+// CheckDiff reports whether diff applies cleanly to the file at path, with
+// a dry run of patch(1). Hunks may be offset from the lines they name, but
+// their context must match the file exactly: patch's fuzz, which ignores
+// context lines that don't, is turned off. The file names in the diff's
+// headers are ignored. The error holds patch's complaints when it doesn't
+// apply.
+func CheckDiff(path, diff string) error {
+	bin, err := exec.LookPath("patch")
+	if err != nil {
+		return ErrNoPatch
+	}
+	cmd := exec.Command(bin, "--dry-run", "--force", "--silent", "-F", "0", path)
+	cmd.Stdin = strings.NewReader(diff)
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(out.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("the diff doesn't apply cleanly to %s: %s", filepath.Base(path), msg)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package rag_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"synapse/internal/rag"
+)
+
+const testDiff = `--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+ 
+-func main() {}
++func main() { run() }
+`
+
+func TestExtractDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		want   string
+	}{
+		{"fenced", "Here it is:\n\n```diff\n" + testDiff + "```\n\nThis calls run.", testDiff},
+		{"fenced as patch", "```patch\n" + testDiff + "```", testDiff},
+		{"fenced without a language", "```\n" + testDiff + "```", testDiff},
+		{"first fence not a diff", "```go\nfunc run() {}\n```\n\n```diff\n" + testDiff + "```", testDiff},
+		{"unfenced", "The change:\n" + testDiff + "\n\n", testDiff},
+		{"unfenced at the start", testDiff, testDiff},
+		{"dashes mid-line", "Use -- flags or --- a/b.go then\n@@ nothing", ""},
+		{"no diff", "This can't be done in main.go.", ""},
+	}
+	for _, tt := range tests {
+		if got := rag.ExtractDiff(tt.answer); got != tt.want {
+			t.Errorf("%s: ExtractDiff = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckDiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := rag.CheckDiff(path, testDiff)
+	if errors.Is(err, rag.ErrNoPatch) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Errorf("CheckDiff of a matching diff = %v", err)
+	}
+	offset := strings.Replace(testDiff, "@@ -1,3 +1,3 @@", "@@ -5,3 +5,3 @@", 1)
+	if err := rag.CheckDiff(path, offset); err != nil {
+		t.Errorf("CheckDiff of an offset hunk = %v", err)
+	}
+	// patch would apply this with fuzz, ignoring the mismatched context.
+	fuzzy := strings.Replace(testDiff, " package main\n", " package other\n", 1)
+	if err := rag.CheckDiff(path, fuzzy); err == nil {
+		t.Error("CheckDiff accepted a diff whose context doesn't match")
+	}
+	if err := rag.CheckDiff(path, strings.ReplaceAll(testDiff, "main() {}", "start() {}")); err == nil {
+		t.Error("CheckDiff accepted a diff removing a line the file doesn't have")
+	}
+}
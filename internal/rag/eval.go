@@ -0,0 +1,103 @@
+package rag
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"synapse/internal/store"
+)
+
+// EvalCase is a golden query and the files a good retrieval should return
+// for it.
+type EvalCase struct {
+	Query string `json:"query"`
+	// Expected lists indexed paths; any one of them in the results is a hit.
+	Expected []string `json:"expected"`
+}
+
+// EvalCaseResult is the outcome of one EvalCase.
+type EvalCaseResult struct {
+	Query string `json:"query"`
+	// Rank is the 1-based position of the first result from an expected
+	// file, or 0 when none was retrieved.
+	Rank int `json:"rank"`
+	// Got is the file of the top result, or "" when nothing was retrieved.
+	Got string `json:"got"`
+}
+
+// EvalReport summarizes retrieval quality over a set of golden queries.
+type EvalReport struct {
+	K int `json:"k"`
+	// Recall is recall@K: the fraction of queries with an expected file in
+	// the top K results.
+	Recall float64 `json:"recall"`
+	// MRR is the mean reciprocal rank of the first expected file, counting
+	// misses as 0.
+	MRR   float64          `json:"mrr"`
+	Cases []EvalCaseResult `json:"cases"`
+}
+
+// LoadEvalCases reads golden queries from a JSON array of EvalCase.
+func LoadEvalCases(path string) ([]EvalCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cases []EvalCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, c := range cases {
+		if c.Query == "" || len(c.Expected) == 0 {
+			return nil, fmt.Errorf("%s: case %d needs a query and at least one expected file", path, i+1)
+		}
+	}
+	return cases, nil
+}
+
+// Evaluate runs each case through retrieve and scores the top k results.
+func Evaluate(cases []EvalCase, k int, retrieve RetrieveFunc) (*EvalReport, error) {
+	report := &EvalReport{K: k, Cases: make([]EvalCaseResult, 0, len(cases))}
+	if len(cases) == 0 {
+		return report, nil
+	}
+	var hits int
+	var rrSum float64
+	for _, c := range cases {
+		results, err := retrieve(c.Query)
+		if err != nil {
+			return nil, fmt.Errorf("retrieve %q: %w", c.Query, err)
+		}
+		if len(results) > k {
+			results = results[:k]
+		}
+		res := EvalCaseResult{Query: c.Query, Rank: firstExpected(results, c.Expected)}
+		if len(results) > 0 {
+			res.Got = results[0].FilePath
+		}
+		if res.Rank > 0 {
+			hits++
+			rrSum += 1 / float64(res.Rank)
+		}
+		report.Cases = append(report.Cases, res)
+	}
+	report.Recall = float64(hits) / float64(len(cases))
+	report.MRR = rrSum / float64(len(cases))
+	return report, nil
+}
+
+// firstExpected returns the 1-based rank of the first result from one of the
+// expected files, or 0.
+func firstExpected(results []store.SearchResult, expected []string) int {
+	want := make(map[string]bool, len(expected))
+	for _, p := range expected {
+		want[store.NormalizePath(p)] = true
+	}
+	for i, r := range results {
+		if want[r.FilePath] {
+			return i + 1
+		}
+	}
+	return 0
+}
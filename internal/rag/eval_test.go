@@ -0,0 +1,97 @@
+//go:build sqlite_fts5
+
+package rag_test
+
+import (
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"synapse/internal/embedder"
+	"synapse/internal/index"
+	"synapse/internal/ollamatest"
+	"synapse/internal/rag"
+	"synapse/internal/store"
+)
+
+// TestEvaluateGolden indexes the retrieval fixture with deterministic fake
+// embeddings and checks its golden queries' scores, so changes to chunking,
+// search, or fusion that move retrieval quality show up here. Update the
+// wanted scores deliberately when they do.
+func TestEvaluateGolden(t *testing.T) {
+	const k = 5
+	srv := ollamatest.NewServer(t, 64)
+	dbPath := filepath.Join(t.TempDir(), "index.db")
+	idx, err := index.New(index.Config{
+		DBPath:       dbPath,
+		OllamaURL:    srv.URL,
+		Model:        "fake",
+		Workers:      1,
+		SkipOverview: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	if _, err := idx.Index(filepath.Join("..", "..", "testdata", "retrieval", "repo")); err != nil {
+		t.Fatal(err)
+	}
+
+	cases, err := rag.LoadEvalCases(filepath.Join("..", "..", "testdata", "retrieval", "queries.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	emb := embedder.NewOllamaEmbedder(srv.URL, "fake")
+	retriever := rag.NewRetriever(idx.Store(), emb, rag.Options{K: k, Weighting: rag.Weighting{
+		KeywordWords:  rag.DefaultKeywordQueryWords,
+		SemanticWords: rag.DefaultSemanticQueryWords,
+		Weight:        rag.DefaultQueryWeight,
+	}})
+	report, err := rag.Evaluate(cases, k, retriever.Retrieve)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const wantRecall, wantMRR = 1.0, 0.729
+	if math.Abs(report.Recall-wantRecall) > 1e-3 || math.Abs(report.MRR-wantMRR) > 1e-3 {
+		for _, c := range report.Cases {
+			t.Logf("rank %d  got %-22s  %s", c.Rank, c.Got, c.Query)
+		}
+		t.Errorf("recall@%d %.3f, MRR %.3f; want %.3f, %.3f", k, report.Recall, report.MRR, wantRecall, wantMRR)
+	}
+}
+
+// TestEvaluateScores checks recall@k and MRR against canned results: only
+// the top k count, any expected file is a hit, and expected paths are
+// normalized.
+func TestEvaluateScores(t *testing.T) {
+	results := map[string][]store.SearchResult{
+		"first":   {{FilePath: "a.go"}, {FilePath: "b.go"}},
+		"second":  {{FilePath: "x.go"}, {FilePath: "b.go"}},
+		"too low": {{FilePath: "x.go"}, {FilePath: "y.go"}, {FilePath: "a.go"}},
+		"none":    nil,
+	}
+	cases := []rag.EvalCase{
+		{Query: "first", Expected: []string{"./a.go"}},
+		{Query: "second", Expected: []string{"a.go", "b.go"}},
+		{Query: "too low", Expected: []string{"a.go"}},
+		{Query: "none", Expected: []string{"a.go"}},
+	}
+	report, err := rag.Evaluate(cases, 2, func(q string) ([]store.SearchResult, error) { return results[q], nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Recall != 0.5 || report.MRR != 0.375 {
+		t.Errorf("recall@2 %g, MRR %g; want 0.5, 0.375", report.Recall, report.MRR)
+	}
+	var ranks []int
+	var got []string
+	for _, c := range report.Cases {
+		ranks = append(ranks, c.Rank)
+		got = append(got, c.Got)
+	}
+	if !slices.Equal(ranks, []int{1, 2, 0, 0}) || !slices.Equal(got, []string{"a.go", "x.go", "x.go", ""}) {
+		t.Errorf("ranks %v, top results %v; want [1 2 0 0], [a.go x.go x.go ]", ranks, got)
+	}
+}
@@ -0,0 +1,146 @@
+package rag
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"synapse/internal/llm"
+	"synapse/internal/store"
+)
+
+const expandPrompt = `You rewrite questions about a codebase into search queries for a code search engine.
+
+Given a question, write 3 to 5 alternative search queries that could find the relevant code. Use different vocabulary from the question: synonyms for its key concepts and the function, type, and variable names the code would likely use (e.g. "validateToken", "auth_middleware", "SessionStore").
+
+Output one query per line, with no numbering, quotes, or commentary.`
+
+// maxExpansions caps the alternative queries used from ExpandQuery.
+const maxExpansions = 5
+
+// rrfK is the rank constant of reciprocal rank fusion. 60 is the value from
+// the original paper and damps the advantage of the very top ranks.
+const rrfK = 60
+
+var listMarkerRe = regexp.MustCompile(`^(?:[-*•]|\d+[.)])\s*`)
+
+// RetrieveFunc runs retrieval for a single query.
+type RetrieveFunc func(query string) ([]store.SearchResult, error)
+
+// ExpandQuery asks the chat model for alternative phrasings of query and
+// likely identifier names, to retrieve code that doesn't share the
+// question's vocabulary. The original query is not included.
+func ExpandQuery(query string, chat llm.Chat) ([]string, error) {
+	reply, err := chat.Generate([]llm.Message{
+		{Role: "system", Content: expandPrompt},
+		{Role: "user", Content: "Question: " + query},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("expand query: %w", err)
+	}
+	return parseExpansions(query, reply), nil
+}
+
+// parseExpansions extracts the distinct queries from the model's reply,
+// dropping reasoning blocks, list markers, and repeats of the original.
+func parseExpansions(query, reply string) []string {
+	reply = llm.StripThinking(reply)
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(query)): true}
+	var out []string
+	for _, line := range strings.Split(reply, "\n") {
+		line = listMarkerRe.ReplaceAllString(strings.TrimSpace(line), "")
+		line = strings.Trim(line, "\"'`")
+		key := strings.ToLower(line)
+		if line == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, line)
+		if len(out) == maxExpansions {
+			break
+		}
+	}
+	return out
+}
+
+// RetrieveExpanded runs retrieve for query and each expansion and fuses the
+// result lists with reciprocal rank fusion, so chunks found by several
+// phrasings rank highest. It returns at most k results.
+func RetrieveExpanded(query string, expansions []string, k int, retrieve RetrieveFunc) ([]store.SearchResult, error) {
+	lists := make([][]store.SearchResult, 0, len(expansions)+1)
+	for _, q := range append([]string{query}, expansions...) {
+		results, err := retrieve(q)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, results)
+	}
+	return FuseRRF(lists, k), nil
+}
+
+// resultKey identifies a chunk across indexes, whose chunk IDs overlap.
+type resultKey struct {
+	source string
+	id     int64
+}
+
+// FuseRRF merges ranked result lists by reciprocal rank fusion: each chunk
+// scores the sum of 1/(rrfK+rank) over the lists it appears in, and its
+// Score is that sum scaled by rrfScores. Ties keep the order in which chunks
+// were first seen. It returns at most k results (all of them when k <= 0).
+func FuseRRF(lists [][]store.SearchResult, k int) []store.SearchResult {
+	scores := rrfScores(lists)
+	var fused []store.SearchResult
+	seen := make(map[resultKey]bool)
+	for _, list := range lists {
+		for _, res := range list {
+			key := resultKey{res.Source, res.Chunk.ID}
+			if !seen[key] {
+				seen[key] = true
+				res.Score = scores[key]
+				fused = append(fused, res)
+			}
+		}
+	}
+	sortByScore(fused)
+	if k > 0 && len(fused) > k {
+		fused = fused[:k]
+	}
+	return fused
+}
+
+// rrfScores returns the reciprocal rank fusion score of each result in
+// lists, scaled so that a result ranked first in every non-empty list scores
+// 1 and one ranked lower, or missing from some lists, proportionally less.
+func rrfScores(lists [][]store.SearchResult) map[resultKey]float64 {
+	return weightedRRFScores(lists, nil)
+}
+
+// weightedRRFScores is rrfScores with each list's ranks counting weights[i]
+// times; nil weighs them all 1.
+func weightedRRFScores(lists [][]store.SearchResult, weights []float64) map[resultKey]float64 {
+	scores := make(map[resultKey]float64)
+	var best float64
+	for i, list := range lists {
+		w := 1.0
+		if weights != nil {
+			w = weights[i]
+		}
+		if len(list) > 0 {
+			best += w / float64(rrfK+1)
+		}
+		for rank, res := range list {
+			scores[resultKey{res.Source, res.Chunk.ID}] += w / float64(rrfK+rank+1)
+		}
+	}
+	for key := range scores {
+		scores[key] /= best
+	}
+	return scores
+}
+
+// sortByScore orders results by descending Score, keeping the order of ties.
+func sortByScore(results []store.SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+}
@@ -0,0 +1,53 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+
+	"synapse/internal/llm"
+)
+
+const explainPrompt = `You are a code intelligence assistant walking a developer who is new to this codebase through one of its files. You are given the file's indexed code, grouped into its definitions, and its summary.
+
+Explain the file in this order:
+1. Purpose: what the file is for and where it fits in the project, in a few sentences.
+2. Key definitions: its main types and functions, what each does, with line numbers.
+3. How they interact: the flow through the file, which definitions call or build on which, and what it depends on from elsewhere.
+4. Things to know before changing it: invariants, side effects, and surprising behavior, if any.
+
+Stay grounded in the code shown; say so when something depends on code that isn't.`
+
+// BuildExplainMessages constructs the message list for a walkthrough of the
+// file at path, from its indexed content (see store.Store.GetAllFileContent),
+// its summary, and the project overview, either of which may be "". Content
+// beyond budget estimated tokens is cut at a line boundary; a budget <= 0
+// includes it all. It also reports whether content was cut.
+func BuildExplainMessages(path, language, summary, content, overview string, budget int) ([]llm.Message, bool) {
+	sys := explainPrompt
+	if overview != "" {
+		sys += "\n\n## Project Overview\n\n" + overview
+	}
+
+	truncated := false
+	if budget > 0 && EstimateTokens(content) > budget {
+		content = content[:budget*4]
+		if i := strings.LastIndexByte(content, '\n'); i > 0 {
+			content = content[:i]
+		}
+		truncated = true
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Explain `%s` (%s).\n\n", path, language)
+	if summary != "" {
+		fmt.Fprintf(&b, "## Summary\n\n%s\n\n", summary)
+	}
+	fmt.Fprintf(&b, "## Code\n\n```%s\n%s\n```\n", strings.ToLower(language), content)
+	if truncated {
+		b.WriteString("\nThe file is longer than shown; the rest was left out to fit the context.\n")
+	}
+	return []llm.Message{
+		{Role: "system", Content: sys},
+		{Role: "user", Content: b.String()},
+	}, truncated
+}
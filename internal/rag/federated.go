@@ -0,0 +1,101 @@
+package rag
+
+import (
+	"fmt"
+
+	"synapse/internal/embedder"
+	"synapse/internal/store"
+)
+
+// Source is one of the indexes a FederatedRetriever searches.
+type Source struct {
+	// Name tags the results from this index.
+	Name  string
+	Store store.Store
+}
+
+// FederatedRetriever runs hybrid retrieval over several indexes, such as
+// one per service, and fuses the results as if they came from one.
+type FederatedRetriever struct {
+	Sources  []Source
+	Embedder embedder.Embedder
+	Options  Options
+}
+
+// NewFederatedRetriever creates a FederatedRetriever over sources. The
+// indexes must have been embedded with the same model, since one query
+// vector is compared against all of them; indexes with nothing embedded yet
+// are searched by keyword only.
+func NewFederatedRetriever(sources []Source, emb embedder.Embedder, opts Options) (*FederatedRetriever, error) {
+	var first *Source
+	var firstModel string
+	var firstDim int
+	for i := range sources {
+		src := &sources[i]
+		dim, err := src.Store.EmbeddingDim()
+		if err != nil {
+			return nil, fmt.Errorf("%s: embedding dimension: %w", src.Name, err)
+		}
+		if dim == 0 {
+			continue
+		}
+		model, err := src.Store.GetMeta("embedding_model")
+		if err != nil {
+			return nil, fmt.Errorf("%s: get meta: %w", src.Name, err)
+		}
+		if first == nil {
+			first, firstModel, firstDim = src, model, dim
+			continue
+		}
+		if dim != firstDim || model != firstModel {
+			return nil, fmt.Errorf("%s uses %s (%d-dim) but %s uses %s (%d-dim); indexes searched together need the same embedding model",
+				first.Name, firstModel, firstDim, src.Name, model, dim)
+		}
+	}
+	return &FederatedRetriever{Sources: sources, Embedder: emb, Options: opts}, nil
+}
+
+// Retrieve runs hybrid retrieval for query against each index, tags the
+// results with the index's Name, and merges them by Score. Keyword and
+// vector scores aren't comparable across indexes, but the rank-based Score
+// is, so each index's best match ranks alike; ties keep the order of
+// Sources. It returns at most Options.K results.
+func (f *FederatedRetriever) Retrieve(query string) ([]store.SearchResult, error) {
+	emb := &memoEmbedder{Embedder: f.Embedder}
+	var merged []store.SearchResult
+	for _, src := range f.Sources {
+		results, err := NewRetriever(src.Store, emb, f.Options).Retrieve(query)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src.Name, err)
+		}
+		for i := range results {
+			results[i].Source = src.Name
+		}
+		merged = append(merged, results...)
+	}
+	sortByScore(merged)
+	if f.Options.K > 0 && len(merged) > f.Options.K {
+		merged = merged[:f.Options.K]
+	}
+	return merged, nil
+}
+
+// memoEmbedder remembers the last single text it embedded, so a query is
+// embedded once however many indexes it's run against.
+type memoEmbedder struct {
+	embedder.Embedder
+	text string
+	vec  []float32
+}
+
+func (e *memoEmbedder) EmbedSingle(text string) ([]float32, error) {
+	if e.vec != nil && text == e.text {
+		return e.vec, nil
+	}
+	vec, err := e.Embedder.EmbedSingle(text)
+	if err != nil {
+		return nil, err
+	}
+	e.text, e.vec = text, vec
+	return vec, nil
+}
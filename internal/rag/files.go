@@ -0,0 +1,124 @@
+package rag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"synapse/internal/store"
+)
+
+// DefaultFileBudget is the default number of bytes of file content returned
+// by RetrieveFiles.
+const DefaultFileBudget = 64 * 1024
+
+// FileChunkPool is how many chunks are retrieved per requested file before
+// they're aggregated, so a file can collect several matching chunks.
+const FileChunkPool = 5
+
+// FileResult is a whole file ranked by the relevance of its chunks.
+type FileResult struct {
+	Path     string
+	Language string
+	// Score sums the reciprocal ranks of the file's chunks in the chunk
+	// results, so files with several relevant chunks rank highest.
+	Score float64
+	// Chunks is how many of the file's chunks were retrieved.
+	Chunks int
+	// Content is the file reconstructed from its indexed chunks.
+	Content string
+	// Truncated is set when Content was cut to fit the byte budget.
+	Truncated bool
+}
+
+// FileGroup is the retrieved chunks of one file.
+type FileGroup struct {
+	Path     string
+	Language string
+	// Chunks are the file's results in line order.
+	Chunks []store.SearchResult
+}
+
+// GroupByFile groups results by file, in the order each file first appears,
+// so the best-ranked file comes first. Each file's chunks are sorted by line,
+// letting a reader take in the file top to bottom.
+func GroupByFile(results []store.SearchResult) []FileGroup {
+	byPath := make(map[string]int)
+	var groups []FileGroup
+	for _, res := range results {
+		i, ok := byPath[res.FilePath]
+		if !ok {
+			i = len(groups)
+			byPath[res.FilePath] = i
+			groups = append(groups, FileGroup{Path: res.FilePath, Language: res.Language})
+		}
+		groups[i].Chunks = append(groups[i].Chunks, res)
+	}
+	for _, g := range groups {
+		sort.SliceStable(g.Chunks, func(i, j int) bool {
+			return g.Chunks[i].Chunk.StartLine < g.Chunks[j].Chunk.StartLine
+		})
+	}
+	return groups
+}
+
+// RankFiles aggregates ranked chunk results by file. Each chunk adds
+// 1/(rrfK+rank) to its file's score; ties keep the order in which files
+// were first seen.
+func RankFiles(results []store.SearchResult) []FileResult {
+	byPath := make(map[string]int)
+	var files []FileResult
+	for rank, res := range results {
+		i, ok := byPath[res.FilePath]
+		if !ok {
+			i = len(files)
+			byPath[res.FilePath] = i
+			files = append(files, FileResult{Path: res.FilePath, Language: res.Language})
+		}
+		files[i].Score += 1 / float64(rrfK+rank+1)
+		files[i].Chunks++
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Score > files[j].Score
+	})
+	return files
+}
+
+// RetrieveFiles ranks the files of the chunks retrieve finds for query and
+// returns the top n with their content read from st. Content stops at budget
+// bytes in total: the file that crosses it is truncated and later files are
+// dropped, cutting at a line boundary. A budget <= 0 uses DefaultFileBudget.
+func RetrieveFiles(query string, retrieve RetrieveFunc, st store.Store, n, budget int) ([]FileResult, error) {
+	if budget <= 0 {
+		budget = DefaultFileBudget
+	}
+	results, err := retrieve(query)
+	if err != nil {
+		return nil, err
+	}
+	files := RankFiles(results)
+	if len(files) > n {
+		files = files[:n]
+	}
+
+	used := 0
+	for i := range files {
+		content, err := st.GetAllFileContent(files[i].Path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", files[i].Path, err)
+		}
+		if used+len(content) > budget {
+			// Cut at a line boundary, which is also a valid UTF-8 boundary.
+			cut := strings.LastIndexByte(content[:budget-used], '\n')
+			if cut <= 0 {
+				return files[:i], nil
+			}
+			files[i].Content = content[:cut+1]
+			files[i].Truncated = true
+			return files[:i+1], nil
+		}
+		files[i].Content = content
+		used += len(content)
+	}
+	return files, nil
+}
@@ -0,0 +1,99 @@
+package rag
+
+import (
+	"strings"
+
+	"synapse/internal/store"
+)
+
+// MergeOverlapping collapses results that are overlapping or adjacent pieces
+// of the same oversized symbol, which the chunker splits into windows sharing
+// some lines, into one contiguous chunk, so duplicated lines don't waste
+// context budget or confuse the model. A merged chunk takes the place and ID
+// of its best-ranked piece; other results keep their order. Pieces are
+// told from whole chunks by Chunk.Piece, so those indexed before it was
+// recorded aren't merged.
+func MergeOverlapping(results []store.SearchResult) []store.SearchResult {
+	out := append([]store.SearchResult(nil), results...)
+	// Merging can make a piece touch one it didn't before, so repeat until
+	// nothing changes. Result lists are short.
+	for changed := true; changed; {
+		changed = false
+		for i := 0; i < len(out) && !changed; i++ {
+			for j := i + 1; j < len(out); j++ {
+				content, ok := joinPieces(out[i], out[j])
+				if !ok {
+					continue
+				}
+				out[i].Chunk.Content = content
+				out[i].Chunk.StartLine = min(out[i].Chunk.StartLine, out[j].Chunk.StartLine)
+				out[i].Chunk.EndLine = max(out[i].Chunk.EndLine, out[j].Chunk.EndLine)
+				out = append(out[:j], out[j+1:]...)
+				changed = true
+				break
+			}
+		}
+	}
+	return out
+}
+
+// CollapseOverlapping collapses results from the same file whose line
+// ranges overlap, which are the same code found as different chunks, e.g. a
+// keyword match on a piece of a split function and a vector match on a
+// chunk spanning it. The best-ranked one is kept as it is, so a precise hit
+// isn't traded for the class around it; the others are dropped. Run
+// MergeOverlapping first, which joins split pieces instead.
+func CollapseOverlapping(results []store.SearchResult) []store.SearchResult {
+	out := append([]store.SearchResult(nil), results...)
+	for i := 0; i < len(out); i++ {
+		for j := i + 1; j < len(out); {
+			a, b := out[i], out[j]
+			if a.Source != b.Source || a.FilePath != b.FilePath ||
+				b.Chunk.StartLine > a.Chunk.EndLine || a.Chunk.StartLine > b.Chunk.EndLine {
+				j++
+				continue
+			}
+			out = append(out[:j], out[j+1:]...)
+		}
+	}
+	return out
+}
+
+// joinPieces returns the content of a and b joined without their shared
+// lines, if they're overlapping or adjacent split pieces of one symbol.
+func joinPieces(a, b store.SearchResult) (string, bool) {
+	if a.FilePath != b.FilePath || a.Chunk.Piece() == 0 || b.Chunk.Piece() == 0 ||
+		a.Chunk.Name != b.Chunk.Name || a.Chunk.QualifiedName != b.Chunk.QualifiedName || a.Chunk.Kind != b.Chunk.Kind {
+		return "", false
+	}
+	first, second := a.Chunk, b.Chunk
+	if second.StartLine < first.StartLine || second.StartLine == first.StartLine && second.EndLine > first.EndLine {
+		first, second = second, first
+	}
+	if second.StartLine > first.EndLine+1 {
+		return "", false
+	}
+	// Only pieces with exactly one content line per line of their range can
+	// be joined line for line; those led by a header or signature can't.
+	secondLines := strings.Split(second.Content, "\n")
+	if strings.Count(first.Content, "\n")+1 != first.EndLine-first.StartLine+1 || len(secondLines) != second.EndLine-second.StartLine+1 {
+		return "", false
+	}
+	if second.EndLine <= first.EndLine {
+		return first.Content, true
+	}
+	skip := first.EndLine + 1 - second.StartLine
+	return first.Content + "\n" + strings.Join(secondLines[skip:], "\n"), true
+}
+
+// ChunkCode returns the source lines of c: its content without the header
+// the chunker puts above whole chunks. The rest has one line per line of
+// the chunk's range.
+func ChunkCode(c store.Chunk) string {
+	lines := strings.Split(c.Content, "\n")
+	n := c.EndLine - c.StartLine + 1
+	if n <= 0 || n >= len(lines) {
+		return c.Content
+	}
+	return strings.Join(lines[len(lines)-n:], "\n")
+}
@@ -0,0 +1,86 @@
+package rag
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// QueryLogPath returns the query log location for the index at dbPath.
+func QueryLogPath(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "queries.jsonl")
+}
+
+// QueryRecord is one logged retrieval.
+type QueryRecord struct {
+	Time    time.Time `json:"time"`
+	Query   string    `json:"query"`
+	Results int       `json:"results"`
+	// BestDistance is the distance of the closest vector match, or nil
+	// when no vector search ran or it found nothing.
+	BestDistance *float64 `json:"best_distance,omitempty"`
+}
+
+// QueryLog appends a record of each retrieval to a local JSON Lines file, so
+// queries that found nothing can be reviewed later. Nothing is sent anywhere.
+// It is safe for concurrent use.
+type QueryLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewQueryLog creates a log appending to the file at path.
+func NewQueryLog(path string) *QueryLog {
+	return &QueryLog{path: path}
+}
+
+// Record appends rec to the log.
+func (l *QueryLog) Record(rec QueryRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadQueryLog returns the records in the log at path, oldest first. A
+// missing log has no records.
+func ReadQueryLog(path string) ([]QueryRecord, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []QueryRecord
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec QueryRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		records = append(records, rec)
+	}
+	return records, sc.Err()
+}
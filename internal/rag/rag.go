@@ -1,8 +1,11 @@
 package rag
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
+	"time"
 
 	"synapse/internal/embedder"
 	"synapse/internal/llm"
@@ -15,51 +18,270 @@ Focus on answering how, why, and where questions about the code. Explain archite
 
 Do not generate new code unless explicitly asked. Keep answers concise and grounded in the provided context. If the context doesn't contain enough information to answer, say so.`
 
+// Options controls hybrid retrieval.
+type Options struct {
+	// K is the maximum number of results returned.
+	K int
+	// Filter restricts which files results may come from.
+	Filter store.Filter
+	// Weighting weighs keyword and vector results by the shape of the
+	// query.
+	Weighting Weighting
+	// Recency, from 0 to 1, weighs up results from recently modified files:
+	// each result's Score is scaled down by up to this fraction the longer
+	// ago its file was modified, losing half of that every RecencyHalfLife.
+	// 0 turns it off.
+	Recency float64
+}
+
+// Retriever bundles a store, an embedder, and retrieval options so callers
+// embedding synapse as a library can run queries without the CLI or TUI.
+type Retriever struct {
+	Store    store.Store
+	Embedder embedder.Embedder
+	Options  Options
+	// Cache, when set, memoizes results for repeated queries.
+	Cache *Cache
+	// Log, when set, records each query that isn't answered from Cache.
+	Log *QueryLog
+}
+
+// NewRetriever creates a Retriever over the given store and embedder.
+func NewRetriever(st store.Store, emb embedder.Embedder, opts Options) *Retriever {
+	return &Retriever{Store: st, Embedder: emb, Options: opts}
+}
+
 // HybridRetrieve runs both FTS5 keyword search and vector similarity search,
-// then merges and deduplicates results with BM25 matches first.
-func HybridRetrieve(query string, st store.Store, emb *embedder.OllamaEmbedder, k int) ([]store.SearchResult, error) {
+// then merges and deduplicates the results and ranks them by Score (see
+// Retriever.Retrieve).
+func HybridRetrieve(query string, st store.Store, emb embedder.Embedder, k int) ([]store.SearchResult, error) {
+	return NewRetriever(st, emb, Options{K: k}).Retrieve(query)
+}
+
+// Retrieve runs hybrid retrieval for query using the retriever's options.
+// Each result's Score fuses its ranks in the keyword and vector results by
+// reciprocal rank fusion, weighted for the query by Options.Weighting and
+// scaled to 0–1: 1 is a chunk both searches ranked first, or the one that
+// ran or found anything ranked first. With Options.Recency, scores are then
+// scaled down for files that haven't been modified lately. Results are
+// ordered by Score, keyword matches first among equals.
+func (r *Retriever) Retrieve(query string) ([]store.SearchResult, error) {
+	if r.Cache == nil {
+		return r.retrieve(query)
+	}
+
+	// The last_indexed marker changes on every index run, invalidating the cache.
+	version, err := r.Store.GetMeta("last_indexed")
+	if err != nil {
+		return nil, fmt.Errorf("get meta: %w", err)
+	}
+	key := cacheKey{query: normalizeQuery(query), k: r.Options.K, filter: r.Options.Filter.String(), recency: r.Options.Recency, weighting: r.Options.Weighting}
+	if results, ok := r.Cache.get(key, version); ok {
+		return results, nil
+	}
+	results, err := r.retrieve(query)
+	if err != nil {
+		return nil, err
+	}
+	r.Cache.put(key, version, results)
+	return results, nil
+}
+
+// Timings is how long each stage of one retrieval took. Stages that didn't
+// run, such as embedding for an index without vectors, are 0.
+type Timings struct {
+	Embed  time.Duration
+	FTS    time.Duration
+	Vector time.Duration
+	// Fusion is merging, ranking, and collapsing the two result lists.
+	Fusion time.Duration
+	Total  time.Duration
+}
+
+// RetrieveTimed is Retrieve, bypassing Cache, that also reports how long
+// each stage took.
+func (r *Retriever) RetrieveTimed(query string) ([]store.SearchResult, Timings, error) {
+	var t Timings
+	results, err := r.retrieveTimed(query, &t)
+	return results, t, err
+}
+
+func (r *Retriever) retrieve(query string) ([]store.SearchResult, error) {
+	return r.retrieveTimed(query, new(Timings))
+}
+
+// retrieveTimed runs retrieval for query, recording stage timings in t.
+func (r *Retriever) retrieveTimed(query string, t *Timings) ([]store.SearchResult, error) {
+	k := r.Options.K
+	start := time.Now()
+	defer func() { t.Total = time.Since(start) }()
+
 	// Run both searches.
-	ftsResults, ftsErr := st.FTSSearch(query, k)
+	ftsResults, ftsErr := r.Store.FTSSearch(query, k, r.Options.Filter)
 	// FTS errors (e.g. syntax issues in query) are non-fatal — fall back to vector only.
 	if ftsErr != nil {
 		ftsResults = nil
 	}
+	t.FTS = time.Since(start)
 
-	vec, err := emb.EmbedSingle(query)
-	if err != nil {
-		return nil, fmt.Errorf("embed query: %w", err)
-	}
-	vecResults, err := st.Search(vec, k)
-	if err != nil {
-		return nil, fmt.Errorf("vector search: %w", err)
+	// Without stored vectors (nothing embedded yet, or an index opened
+	// FTS-only) keyword results are all there is; skip embedding the query.
+	var vecResults []store.SearchResult
+	if dim, err := r.Store.EmbeddingDim(); err == nil && dim > 0 {
+		embedStart := time.Now()
+		vec, err := r.Embedder.EmbedSingle(query)
+		if err != nil {
+			return nil, fmt.Errorf("embed query: %w", err)
+		}
+		t.Embed = time.Since(embedStart)
+		// A degenerate query vector would rank chunks arbitrarily, so
+		// fall back to keyword results alone.
+		if !embedder.IsZero(vec) && embedder.Validate(vec) == nil {
+			searchStart := time.Now()
+			vecResults, err = r.Store.Search(vec, k, r.Options.Filter)
+			if err != nil {
+				return nil, fmt.Errorf("vector search: %w", err)
+			}
+			t.Vector = time.Since(searchStart)
+		}
 	}
 
-	// Merge: BM25 results first, then vector results, deduplicated by chunk ID.
+	// Merge: BM25 results first, then vector results, deduplicated by chunk ID
+	// and ranked by fused score. Keyword matches the vector search found too
+	// keep its relevance score.
+	fusionStart := time.Now()
+	w := r.Options.Weighting.For(query)
+	scores := weightedRRFScores([][]store.SearchResult{ftsResults, vecResults}, []float64{w.Keyword, w.Vector})
+	relevance := make(map[int64]*float64)
+	for _, res := range vecResults {
+		relevance[res.Chunk.ID] = res.Relevance
+	}
 	seen := make(map[int64]bool)
 	var merged []store.SearchResult
 
-	for _, r := range ftsResults {
-		if !seen[r.Chunk.ID] {
-			seen[r.Chunk.ID] = true
-			merged = append(merged, r)
+	for _, res := range ftsResults {
+		if !seen[res.Chunk.ID] {
+			res.Relevance = relevance[res.Chunk.ID]
+			res.Score = scores[resultKey{id: res.Chunk.ID}]
+			seen[res.Chunk.ID] = true
+			merged = append(merged, res)
 		}
 	}
-	for _, r := range vecResults {
-		if !seen[r.Chunk.ID] {
-			seen[r.Chunk.ID] = true
-			merged = append(merged, r)
+	for _, res := range vecResults {
+		if !seen[res.Chunk.ID] {
+			res.Score = scores[resultKey{id: res.Chunk.ID}]
+			seen[res.Chunk.ID] = true
+			merged = append(merged, res)
 		}
 	}
 
+	if r.Options.Recency > 0 {
+		if err := boostRecent(r.Store, merged, r.Options.Recency, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	sortByScore(merged)
+	merged = CollapseOverlapping(MergeOverlapping(merged))
 	if len(merged) > k {
 		merged = merged[:k]
 	}
+	t.Fusion = time.Since(fusionStart)
+
+	if r.Log != nil {
+		rec := QueryRecord{Time: time.Now().UTC(), Query: query, Results: len(merged)}
+		if len(vecResults) > 0 {
+			rec.BestDistance = &vecResults[0].Distance
+		}
+		// Logging is best-effort and never fails a query.
+		_ = r.Log.Record(rec)
+	}
 	return merged, nil
 }
 
+// DefaultContextBudget is the default token budget for retrieved chunks in the
+// chat context. It leaves headroom for the system prompt, overview, and history
+// within the context window of typical local chat models.
+const DefaultContextBudget = 8000
+
+// EstimateTokens returns a rough token count for text (~4 bytes per token).
+func EstimateTokens(text string) int {
+	return len(text) / 4
+}
+
+// TrimToBudget drops the lowest-ranked chunks until the estimated token total
+// fits within budget. The top-ranked chunk is always kept. A budget <= 0
+// disables trimming. It returns the kept chunks and how many were dropped.
+func TrimToBudget(chunks []store.SearchResult, budget int) ([]store.SearchResult, int) {
+	if budget <= 0 || len(chunks) == 0 {
+		return chunks, 0
+	}
+	total := 0
+	for i, c := range chunks {
+		total += EstimateTokens(c.Chunk.Content)
+		if total > budget && i > 0 {
+			return chunks[:i], len(chunks) - i
+		}
+	}
+	return chunks, 0
+}
+
+// OrderForAttention reorders ranked chunks so the strongest sit at both ends
+// of the context, where models attend most, and the weakest in the middle:
+// ranks 1..5 become 1, 3, 5, 4, 2. chunks is not modified.
+func OrderForAttention(chunks []store.SearchResult) []store.SearchResult {
+	ordered := make([]store.SearchResult, len(chunks))
+	front, back := 0, len(chunks)-1
+	for i, c := range chunks {
+		if i%2 == 0 {
+			ordered[front] = c
+			front++
+		} else {
+			ordered[back] = c
+			back--
+		}
+	}
+	return ordered
+}
+
+// DefaultSummaryBudget is the default token budget for the summaries of cited
+// files added to the chat context, on top of the chunks' budget.
+const DefaultSummaryBudget = 1000
+
+// CitedSummaries returns the stored summaries of the files chunks come from,
+// keyed by path. Files are taken in the order of their best-ranked chunk
+// until the summaries' estimated tokens reach budget; a budget <= 0 includes
+// them all. Files without a summary are left out.
+func CitedSummaries(st store.Store, chunks []store.SearchResult, budget int) (map[string]string, error) {
+	summaries := make(map[string]string)
+	seen := make(map[string]bool)
+	used := 0
+	for _, c := range chunks {
+		if seen[c.FilePath] {
+			continue
+		}
+		seen[c.FilePath] = true
+		summary, err := st.GetFileSummary(c.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("summary of %s: %w", c.FilePath, err)
+		}
+		if summary == "" {
+			continue
+		}
+		used += EstimateTokens(summary)
+		if budget > 0 && used > budget {
+			break
+		}
+		summaries[c.FilePath] = summary
+	}
+	return summaries, nil
+}
+
 // BuildMessages constructs the message list for the LLM from retrieved chunks,
-// conversation history, and the current question.
-func BuildMessages(chunks []store.SearchResult, history []llm.Message, question string, overview string) []llm.Message {
+// conversation history, and the current question. Chunks are grouped by file
+// under one header each, files in the order they first appear in chunks.
+// summaries, if not empty, maps cited file paths to their summaries, which
+// are shown under the file's header so the model knows each snippet's role.
+func BuildMessages(chunks []store.SearchResult, summaries map[string]string, history []llm.Message, question string, overview string) []llm.Message {
 	var msgs []llm.Message
 
 	// System message with optional overview.
@@ -72,13 +294,19 @@ func BuildMessages(chunks []store.SearchResult, history []llm.Message, question
 	// Context message with retrieved chunks.
 	if len(chunks) > 0 {
 		var ctx strings.Builder
-		ctx.WriteString("Here is the relevant source code context:\n\n")
-		for i, c := range chunks {
-			fmt.Fprintf(&ctx, "--- Chunk %d: %s [%s %s] (lines %d–%d, %s) ---\n",
-				i+1, c.FilePath, c.Chunk.Kind, c.Chunk.Name,
-				c.Chunk.StartLine, c.Chunk.EndLine, c.Language)
-			ctx.WriteString(c.Chunk.Content)
-			ctx.WriteString("\n\n")
+		ctx.WriteString("Here is the relevant source code context, grouped by file:\n\n")
+		for i, g := range GroupByFile(chunks) {
+			fmt.Fprintf(&ctx, "=== File %d: %s (%s) ===\n", i+1, g.Path, g.Language)
+			if summary, ok := summaries[g.Path]; ok {
+				fmt.Fprintf(&ctx, "File summary: %s\n", summary)
+			}
+			ctx.WriteString("\n")
+			for _, c := range g.Chunks {
+				fmt.Fprintf(&ctx, "--- [%s %s] lines %d–%d ---\n",
+					c.Chunk.Kind, c.Chunk.Name, c.Chunk.StartLine, c.Chunk.EndLine)
+				ctx.WriteString(c.Chunk.Content)
+				ctx.WriteString("\n\n")
+			}
 		}
 		msgs = append(msgs, llm.Message{Role: "user", Content: ctx.String()})
 		msgs = append(msgs, llm.Message{Role: "assistant", Content: "I've reviewed the code context. What would you like to know?"})
@@ -92,3 +320,87 @@ func BuildMessages(chunks []store.SearchResult, history []llm.Message, question
 
 	return msgs
 }
+
+const overviewPrompt = `You are a code intelligence assistant. You answer high-level questions about a codebase using its project overview and per-file summaries provided below.
+
+Describe purpose, architecture, and how the main components fit together. Reference file paths when relevant. If the summaries don't contain enough information to answer, say so.`
+
+const plainPrompt = `You are a helpful programming assistant. Answer concisely and say so when you're unsure.`
+
+// metaQuestion matches questions about the project as a whole, which the
+// overview answers better than retrieved chunks.
+var metaQuestion = regexp.MustCompile(`^(please\s+)?(summari[sz]e|describe|explain|give (me\s+)?an? (high[- ]level\s+)?overview of|what (does|is))\s+(this|the)\s+(project|repo|repository|codebase|code base)(\s+(do|about|for))?\s*[?.!]*$`)
+
+// IsMetaQuestion reports whether question asks what the project as a whole is
+// or does, e.g. "what does this project do?".
+func IsMetaQuestion(question string) bool {
+	return metaQuestion.MatchString(strings.ToLower(strings.TrimSpace(question)))
+}
+
+// BuildOverviewMessages constructs the message list for answering from the
+// project overview and file summaries instead of retrieved chunks. Summaries
+// are added in order until budget (in estimated tokens) is reached; a budget
+// <= 0 includes them all.
+func BuildOverviewMessages(files []store.FileSummary, history []llm.Message, question string, overview string, budget int) []llm.Message {
+	sys := overviewPrompt
+	if overview != "" {
+		sys += "\n\n## Project Overview\n\n" + overview
+	}
+	msgs := []llm.Message{{Role: "system", Content: sys}}
+
+	var ctx strings.Builder
+	used := 0
+	for _, f := range files {
+		if f.Summary == "" {
+			continue
+		}
+		line := fmt.Sprintf("- %s (%s): %s\n", f.Path, f.Language, f.Summary)
+		used += EstimateTokens(line)
+		if budget > 0 && used > budget {
+			break
+		}
+		ctx.WriteString(line)
+	}
+	if ctx.Len() > 0 {
+		msgs = append(msgs, llm.Message{Role: "user", Content: "Here are summaries of the project's files:\n\n" + ctx.String()})
+		msgs = append(msgs, llm.Message{Role: "assistant", Content: "I've reviewed the file summaries. What would you like to know?"})
+	}
+
+	msgs = append(msgs, history...)
+	msgs = append(msgs, llm.Message{Role: "user", Content: question})
+	return msgs
+}
+
+// GenerateFitting answers with the messages build returns for chunks, which
+// are ranked best first. If the model reports the prompt is too long for its
+// context (llm.ErrContextLength) before answering, it retries with the
+// better half of the chunks, and so on down to one. It returns the answer and
+// how many chunks it used.
+func GenerateFitting(chunks []store.SearchResult, build func([]store.SearchResult) []llm.Message, generate func([]llm.Message) (string, error)) (string, int, error) {
+	n := len(chunks)
+	for {
+		answer, err := generate(build(chunks[:n]))
+		if err == nil || n <= 1 || answer != "" || !errors.Is(err, llm.ErrContextLength) {
+			return answer, n, err
+		}
+		n /= 2
+	}
+}
+
+// DropLastTurn removes the last question and answer from history if they're
+// for question, so a retried answer replaces them.
+func DropLastTurn(history []llm.Message, question string) []llm.Message {
+	if n := len(history); n >= 2 && history[n-2].Role == "user" && history[n-2].Content == question {
+		return history[:n-2]
+	}
+	return history
+}
+
+// BuildPlainMessages constructs the message list for a conversation with no
+// codebase context at all.
+func BuildPlainMessages(history []llm.Message, question string) []llm.Message {
+	msgs := []llm.Message{{Role: "system", Content: plainPrompt}}
+	msgs = append(msgs, history...)
+	msgs = append(msgs, llm.Message{Role: "user", Content: question})
+	return msgs
+}
@@ -0,0 +1,292 @@
+//go:build sqlite_fts5
+
+package rag_test
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"synapse/internal/llm"
+	"synapse/internal/rag"
+	"synapse/internal/store"
+)
+
+// fixedEmbedder embeds every text as the same vector.
+type fixedEmbedder []float32
+
+func (e fixedEmbedder) Embed(texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i := range texts {
+		vecs[i] = e
+	}
+	return vecs, nil
+}
+
+func (e fixedEmbedder) EmbedSingle(string) ([]float32, error) { return e, nil }
+func (e fixedEmbedder) Model() string                         { return "fixed" }
+
+func TestRetrieveDegenerateQuery(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if err := st.SetEmbeddingDim(2); err != nil {
+		t.Fatal(err)
+	}
+	// Only the vector search can find unrelated, which has no query words.
+	chunks := []store.Chunk{
+		{Name: "parseConfig", Kind: "function", StartLine: 1, EndLine: 3, Content: "func parseConfig() {}"},
+		{Name: "unrelated", Kind: "function", StartLine: 5, EndLine: 7, Content: "func unrelated() {}"},
+	}
+	emb := [][]float32{{1, 0}, {0, 1}}
+	if _, _, err := st.ReplaceFileChunks(store.FileRecord{Path: "a.go", Hash: "1", Language: "Go"}, chunks, emb); err != nil {
+		t.Fatal(err)
+	}
+
+	retrieve := func(query []float32) []string {
+		t.Helper()
+		results, err := rag.NewRetriever(st, fixedEmbedder(query), rag.Options{K: 5}).Retrieve("parseConfig")
+		if err != nil {
+			t.Fatalf("retrieve with query vector %v: %v", query, err)
+		}
+		var names []string
+		for _, r := range results {
+			names = append(names, r.Chunk.Name)
+		}
+		return names
+	}
+
+	if names := retrieve([]float32{0.6, 0.8}); len(names) != 2 {
+		t.Fatalf("retrieve with a valid vector = %v, want both chunks", names)
+	}
+	for _, vec := range [][]float32{{0, 0}, {float32(math.NaN()), 1}, {float32(math.Inf(-1)), 0}} {
+		if names := retrieve(vec); len(names) != 1 || names[0] != "parseConfig" {
+			t.Errorf("retrieve with query vector %v = %v, want the keyword match only", vec, names)
+		}
+	}
+}
+
+func TestMergeOverlapping(t *testing.T) {
+	lines := func(from, to int) string {
+		var b []string
+		for i := from; i <= to; i++ {
+			b = append(b, fmt.Sprintf("line %d", i))
+		}
+		return strings.Join(b, "\n")
+	}
+	result := func(id int64, piece, start, end int) store.SearchResult {
+		c := store.Chunk{ID: id, Name: "Run", Kind: "function", StartLine: start, EndLine: end, Content: lines(start, end)}
+		if piece > 0 {
+			c.Metadata = fmt.Sprintf(`{"piece":%d}`, piece)
+		}
+		return store.SearchResult{Chunk: c, FilePath: "a.go"}
+	}
+	tests := []struct {
+		name    string
+		results []store.SearchResult
+		want    []int64
+		content string
+	}{
+		{"overlapping pieces", []store.SearchResult{result(2, 2, 31, 70), result(1, 1, 1, 40)}, []int64{2}, lines(1, 70)},
+		{"adjacent pieces", []store.SearchResult{result(1, 1, 1, 40), result(2, 2, 41, 60)}, []int64{1}, lines(1, 60)},
+		{"pieces apart", []store.SearchResult{result(1, 1, 1, 40), result(3, 3, 61, 90)}, []int64{1, 3}, lines(1, 40)},
+		// Without a header, whole chunks have one line per line of their
+		// range too, like same-named methods of adjacent types.
+		{"adjacent whole chunks", []store.SearchResult{result(1, 0, 1, 5), result(2, 0, 6, 10)}, []int64{1, 2}, lines(1, 5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rag.MergeOverlapping(tt.results)
+			var ids []int64
+			for _, r := range got {
+				ids = append(ids, r.Chunk.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Fatalf("MergeOverlapping kept chunks %v, want %v", ids, tt.want)
+			}
+			if got[0].Chunk.Content != tt.content {
+				t.Errorf("first chunk's content:\n%s\nwant:\n%s", got[0].Chunk.Content, tt.content)
+			}
+		})
+	}
+}
+
+func TestCollapseOverlapping(t *testing.T) {
+	result := func(id int64, path string, start, end int) store.SearchResult {
+		return store.SearchResult{Chunk: store.Chunk{ID: id, StartLine: start, EndLine: end}, FilePath: path}
+	}
+	tests := []struct {
+		name    string
+		results []store.SearchResult
+		want    []int64
+	}{
+		{"method before its class", []store.SearchResult{result(1, "a.go", 10, 20), result(2, "a.go", 1, 50)}, []int64{1}},
+		{"class before its method", []store.SearchResult{result(2, "a.go", 1, 50), result(1, "a.go", 10, 20)}, []int64{2}},
+		{"partial overlap", []store.SearchResult{result(1, "a.go", 1, 10), result(2, "a.go", 10, 20)}, []int64{1}},
+		{"adjacent", []store.SearchResult{result(1, "a.go", 1, 10), result(2, "a.go", 11, 20)}, []int64{1, 2}},
+		{"other file", []store.SearchResult{result(1, "a.go", 1, 10), result(2, "b.go", 1, 10)}, []int64{1, 2}},
+		{"third overlaps only the dropped one", []store.SearchResult{result(1, "a.go", 1, 10), result(2, "a.go", 5, 30), result(3, "a.go", 25, 40)}, []int64{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rag.CollapseOverlapping(tt.results)
+			var ids []int64
+			for _, r := range got {
+				ids = append(ids, r.Chunk.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("CollapseOverlapping kept chunks %v, want %v", ids, tt.want)
+			}
+			for _, r := range got {
+				for _, in := range tt.results {
+					if in.Chunk.ID == r.Chunk.ID && (in.Chunk.StartLine != r.Chunk.StartLine || in.Chunk.EndLine != r.Chunk.EndLine) {
+						t.Errorf("chunk %d's range changed to %d-%d", r.Chunk.ID, r.Chunk.StartLine, r.Chunk.EndLine)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDropLastTurn(t *testing.T) {
+	user := func(s string) llm.Message { return llm.Message{Role: "user", Content: s} }
+	assistant := func(s string) llm.Message { return llm.Message{Role: "assistant", Content: s} }
+	tests := []struct {
+		name    string
+		history []llm.Message
+		want    int
+	}{
+		{"answered question", []llm.Message{user("a"), assistant("1"), user("q"), assistant("2")}, 2},
+		{"other last question", []llm.Message{user("q"), assistant("1"), user("b"), assistant("2")}, 4},
+		{"answer that repeats the question", []llm.Message{user("a"), user("x"), assistant("q"), assistant("2")}, 4},
+		{"empty", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rag.DropLastTurn(tt.history, "q"); len(got) != tt.want {
+				t.Errorf("DropLastTurn left %d messages, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+// openStore returns an empty index of dim-dimensional embeddings made with
+// model, or with nothing embedded yet if dim is 0.
+func openStore(t *testing.T, dim int, model string) *store.SQLiteStore {
+	t.Helper()
+	st, err := store.Open(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	if dim == 0 {
+		return st
+	}
+	if err := st.SetEmbeddingDim(dim); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetMeta("embedding_model", model); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func TestFederatedRetrieve(t *testing.T) {
+	chunks := []store.Chunk{
+		{Name: "parseConfig", Kind: "function", StartLine: 1, EndLine: 3, Content: "func parseConfig() { readConfig() }"},
+		{Name: "readConfig", Kind: "function", StartLine: 5, EndLine: 7, Content: "func readConfig() {}"},
+		{Name: "unrelated", Kind: "function", StartLine: 9, EndLine: 11, Content: "func unrelated() {}"},
+	}
+	emb := [][]float32{{1, 0}, {0.8, 0.6}, {0, 1}}
+	var sources []rag.Source
+	for _, name := range []string{"api", "web"} {
+		st := openStore(t, 2, "fake")
+		if _, _, err := st.ReplaceFileChunks(store.FileRecord{Path: name + ".go", Hash: "1", Language: "Go"}, chunks, emb); err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, rag.Source{Name: name, Store: st})
+	}
+	f, err := rag.NewFederatedRetriever(sources, fixedEmbedder{1, 0}, rag.Options{K: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := f.Retrieve("parseConfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The indexes hold the same chunks, so each ranks alike and ties keep
+	// the order of the sources.
+	var got []string
+	for _, r := range results {
+		if want := r.Source + ".go"; r.FilePath != want {
+			t.Errorf("result from %s tagged %q", r.FilePath, r.Source)
+		}
+		if r.Score <= 0 || r.Score > 1 {
+			t.Errorf("%s:%s has score %g, want one in (0, 1]", r.Source, r.Chunk.Name, r.Score)
+		}
+		got = append(got, r.Source+":"+r.Chunk.Name)
+	}
+	want := []string{"api:parseConfig", "web:parseConfig", "api:readConfig", "web:readConfig"}
+	if !slices.Equal(got, want) {
+		t.Errorf("merged results %v, want %v", got, want)
+	}
+}
+
+func TestFederatedModelMismatch(t *testing.T) {
+	base := rag.Source{Name: "api", Store: openStore(t, 2, "fake")}
+	empty := rag.Source{Name: "new", Store: openStore(t, 0, "")}
+	if _, err := rag.NewFederatedRetriever([]rag.Source{empty, base}, fixedEmbedder{1, 0}, rag.Options{}); err != nil {
+		t.Errorf("index with nothing embedded yet: %v", err)
+	}
+	for _, other := range []rag.Source{
+		{Name: "web", Store: openStore(t, 3, "fake")},
+		{Name: "web", Store: openStore(t, 2, "other")},
+	} {
+		_, err := rag.NewFederatedRetriever([]rag.Source{base, other}, fixedEmbedder{1, 0}, rag.Options{})
+		if err == nil || !strings.Contains(err.Error(), "same embedding model") {
+			t.Errorf("NewFederatedRetriever = %v, want an error asking for the same embedding model", err)
+		}
+	}
+}
+
+func TestSymbolRelationships(t *testing.T) {
+	st := openStore(t, 2, "fake")
+	chunks := []store.Chunk{
+		{Name: "Parse", Kind: "function", StartLine: 1, EndLine: 3, Content: "func Parse(s string) Node {}"},
+		{Name: "load", Kind: "function", StartLine: 5, EndLine: 7, Content: "func load() { n := Parse(src) }"},
+		{Name: "lower", Kind: "function", StartLine: 9, EndLine: 11, Content: "// parse the input first\nfunc lower() {}"},
+		{Name: "stemmed", Kind: "function", StartLine: 13, EndLine: 15, Content: "// Parses the input.\nfunc stemmed() {}"},
+	}
+	emb := [][]float32{{1, 0}, {1, 0}, {1, 0}, {1, 0}}
+	if _, _, err := st.ReplaceFileChunks(store.FileRecord{Path: "a.go", Hash: "1", Language: "Go"}, chunks, emb); err != nil {
+		t.Fatal(err)
+	}
+	method := []store.Chunk{{Name: "run", Kind: "function", StartLine: 1, EndLine: 1, Content: "func run() { p.Parse(x) }"}}
+	if _, _, err := st.ReplaceFileChunks(store.FileRecord{Path: "b.go", Hash: "1", Language: "Go"}, method, [][]float32{{1, 0}}); err != nil {
+		t.Fatal(err)
+	}
+
+	rel, err := rag.SymbolRelationships(st, "Parse", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rel.Definitions) != 1 || rel.Definitions[0].Chunk.Name != "Parse" {
+		t.Errorf("definitions %v, want Parse", rel.Definitions)
+	}
+	var refs []string
+	for _, g := range rel.Referrers {
+		for _, c := range g.Chunks {
+			refs = append(refs, c.Chunk.Name)
+		}
+	}
+	slices.Sort(refs)
+	if !slices.Equal(refs, []string{"load", "run"}) || rel.Referrals() != 2 {
+		t.Errorf("referrers %v, want load and run: only whole-word, same-case mentions", refs)
+	}
+}
@@ -0,0 +1,44 @@
+package rag
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"synapse/internal/store"
+)
+
+// RecencyHalfLife is how long after a file was last modified its results'
+// recency boost falls by half (see Options.Recency).
+const RecencyHalfLife = 30 * 24 * time.Hour
+
+// recencyFactor is what a result's Score is multiplied by for a file
+// modified age ago, with recency weight w: 1 for a file modified just now,
+// falling toward 1-w as the file ages.
+func recencyFactor(age time.Duration, w float64) float64 {
+	w = min(max(w, 0), 1)
+	decay := math.Pow(0.5, max(age, 0).Hours()/RecencyHalfLife.Hours())
+	return 1 - w + w*decay
+}
+
+// boostRecent multiplies the Score of each result by its file's recency
+// factor as of now. Files indexed before modification times were recorded
+// count as modified when they were indexed, the latest they can have been.
+func boostRecent(st store.Store, results []store.SearchResult, w float64, now time.Time) error {
+	records, err := st.FileRecords()
+	if err != nil {
+		return fmt.Errorf("file records: %w", err)
+	}
+	for i, res := range results {
+		rec, ok := records[res.FilePath]
+		if !ok {
+			continue
+		}
+		modified := rec.ModTime
+		if modified.IsZero() {
+			modified = rec.IndexedAt
+		}
+		results[i].Score *= recencyFactor(now.Sub(modified), w)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package rag
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"synapse/internal/store"
+)
+
+func TestRecencyFactor(t *testing.T) {
+	tests := []struct {
+		name string
+		age  time.Duration
+		w    float64
+		want float64
+	}{
+		{"just modified", 0, 0.4, 1},
+		{"one half-life", RecencyHalfLife, 0.4, 0.8},
+		{"two half-lives", 2 * RecencyHalfLife, 0.4, 0.7},
+		{"off", RecencyHalfLife, 0, 1},
+		{"full weight", RecencyHalfLife, 1, 0.5},
+		{"weight clamped", RecencyHalfLife, 3, 0.5},
+		{"future modification", -time.Hour, 0.4, 1},
+	}
+	for _, tt := range tests {
+		if got := recencyFactor(tt.age, tt.w); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: recencyFactor(%v, %g) = %g, want %g", tt.name, tt.age, tt.w, got, tt.want)
+		}
+	}
+}
+
+// recordsStore serves file records to boostRecent; it has no other methods.
+type recordsStore struct {
+	store.Store
+	records map[string]store.FileRecord
+}
+
+func (s recordsStore) FileRecords() (map[string]store.FileRecord, error) {
+	return s.records, nil
+}
+
+func TestBoostRecent(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	st := recordsStore{records: map[string]store.FileRecord{
+		"new.go": {Path: "new.go", ModTime: now, IndexedAt: now.Add(-2 * RecencyHalfLife)},
+		"old.go": {Path: "old.go", ModTime: now.Add(-RecencyHalfLife), IndexedAt: now},
+		// Indexed before modification times were recorded.
+		"legacy.go": {Path: "legacy.go", IndexedAt: now.Add(-RecencyHalfLife)},
+	}}
+	results := []store.SearchResult{
+		{FilePath: "new.go", Score: 1},
+		{FilePath: "old.go", Score: 1},
+		{FilePath: "legacy.go", Score: 1},
+		{FilePath: "gone.go", Score: 1},
+	}
+	if err := boostRecent(st, results, 0.5, now); err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{1, 0.75, 0.75, 1}
+	for i, res := range results {
+		if math.Abs(res.Score-want[i]) > 1e-9 {
+			t.Errorf("%s: score %g, want %g", res.FilePath, res.Score, want[i])
+		}
+	}
+}
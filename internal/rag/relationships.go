@@ -0,0 +1,67 @@
+package rag
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"synapse/internal/store"
+)
+
+// DefaultReferenceLimit is the default number of chunks SymbolRelationships
+// checks for references.
+const DefaultReferenceLimit = 50
+
+// Relationships approximates the call graph around a symbol: where it's
+// defined and which chunks mention it. It's built from keyword search, not
+// static analysis, so a reference may be a same-named symbol or a comment.
+type Relationships struct {
+	Symbol string
+	// Definitions are the chunks named Symbol.
+	Definitions []store.SearchResult
+	// Referrers are the other chunks whose code contains Symbol as a whole
+	// word, grouped by file, best keyword match first.
+	Referrers []FileGroup
+}
+
+// Referrals returns how many chunks refer to the symbol.
+func (r *Relationships) Referrals() int {
+	n := 0
+	for _, g := range r.Referrers {
+		n += len(g.Chunks)
+	}
+	return n
+}
+
+// SymbolRelationships finds the definitions of symbol and the chunks that
+// mention it. At most limit keyword matches are checked for references;
+// limit <= 0 uses DefaultReferenceLimit.
+func SymbolRelationships(st store.Store, symbol string, limit int) (*Relationships, error) {
+	if limit <= 0 {
+		limit = DefaultReferenceLimit
+	}
+	defs, err := st.FindByName(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("find %s: %w", symbol, err)
+	}
+	isDef := make(map[int64]bool, len(defs))
+	for _, d := range defs {
+		isDef[d.Chunk.ID] = true
+	}
+
+	// The keyword index stems and folds case, so matches are checked for
+	// the exact word.
+	phrase := `"` + strings.ReplaceAll(symbol, `"`, `""`) + `"`
+	matches, err := st.FTSSearch(phrase, limit+len(defs), store.Filter{})
+	if err != nil {
+		return nil, fmt.Errorf("keyword search for %s: %w", symbol, err)
+	}
+	word := regexp.MustCompile(`(^|[^\w$])` + regexp.QuoteMeta(symbol) + `($|[^\w$])`)
+	var refs []store.SearchResult
+	for _, m := range matches {
+		if !isDef[m.Chunk.ID] && word.MatchString(ChunkCode(m.Chunk)) && len(refs) < limit {
+			refs = append(refs, m)
+		}
+	}
+	return &Relationships{Symbol: symbol, Definitions: defs, Referrers: GroupByFile(refs)}, nil
+}
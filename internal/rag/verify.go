@@ -0,0 +1,128 @@
+package rag
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"synapse/internal/llm"
+	"synapse/internal/store"
+)
+
+// Reference is a file path or symbol an answer mentions.
+type Reference struct {
+	Text string
+	// File is set when Text is a file path rather than a symbol.
+	File bool
+}
+
+var (
+	fencedBlock = regexp.MustCompile("(?s)```.*?```")
+	codeSpan    = regexp.MustCompile("`([^`\n]+)`")
+	identWord   = regexp.MustCompile(`[A-Za-z_]\w*`)
+	// symbolSpan is a code span naming a symbol: an identifier, optionally
+	// qualified (pkg.Func) and called (Func()).
+	symbolSpan = regexp.MustCompile(`^[A-Za-z_]\w*(\.[A-Za-z_]\w*)*(\(\))?$`)
+	fileWord   = regexp.MustCompile(`^(\./)?([\w-][\w.-]*/)*[\w-][\w.-]*\.[A-Za-z]\w{0,5}$`)
+	lineSuffix = regexp.MustCompile(`:\d+(-\d+)?$`)
+)
+
+// commonWords are symbols too generic to check, since an answer may use
+// them without quoting the code.
+var commonWords = map[string]bool{
+	"nil": true, "null": true, "true": true, "false": true, "None": true,
+	"self": true, "this": true, "err": true, "error": true, "string": true,
+}
+
+// Ungrounded returns the files and symbols answer mentions that none of
+// chunks contain, a heuristic for claims the model made up. Files are the
+// paths in the answer with an extension of a retrieved file, or any path
+// with a directory; they're grounded if a chunk comes from that file.
+// Symbols are the identifiers in inline code spans; they're grounded if
+// some chunk's content has the word. Code blocks are skipped, since they
+// often show new code, and so is the model's thinking. It also returns how
+// many references were checked.
+func Ungrounded(answer string, chunks []store.SearchResult) ([]Reference, int) {
+	exts := make(map[string]bool)
+	words := make(map[string]bool)
+	for _, c := range chunks {
+		exts[path.Ext(c.FilePath)] = true
+		for _, w := range identWord.FindAllString(c.Chunk.Content, -1) {
+			words[w] = true
+		}
+		words[c.Chunk.Name] = true
+	}
+	isFile := func(s string) bool {
+		return fileWord.MatchString(s) && (strings.Contains(s, "/") || exts[path.Ext(s)])
+	}
+
+	var refs []Reference
+	seen := make(map[string]bool)
+	add := func(text string, file bool) {
+		if !seen[text] {
+			seen[text] = true
+			refs = append(refs, Reference{Text: text, File: file})
+		}
+	}
+	prose := fencedBlock.ReplaceAllString(llm.StripThinking(answer), "")
+	for _, m := range codeSpan.FindAllStringSubmatch(prose, -1) {
+		span := lineSuffix.ReplaceAllString(strings.TrimSpace(m[1]), "")
+		switch {
+		case isFile(span):
+			add(span, true)
+		case symbolSpan.MatchString(span):
+			add(span, false)
+		}
+	}
+	for _, word := range strings.Fields(codeSpan.ReplaceAllString(prose, "")) {
+		word = lineSuffix.ReplaceAllString(strings.Trim(word, `.,;:!?()[]{}"'*`), "")
+		if isFile(word) {
+			add(word, true)
+		}
+	}
+
+	var ungrounded []Reference
+	for _, ref := range refs {
+		if ref.File && !citesFile(chunks, ref.Text) || !ref.File && !hasSymbol(words, ref.Text) {
+			ungrounded = append(ungrounded, ref)
+		}
+	}
+	return ungrounded, len(refs)
+}
+
+// citesFile reports whether a chunk comes from the file p, given as a path
+// or a trailing part of one, e.g. "rag.go" for internal/rag/rag.go.
+func citesFile(chunks []store.SearchResult, p string) bool {
+	p = strings.TrimPrefix(p, "./")
+	for _, c := range chunks {
+		if c.FilePath == p || strings.HasSuffix(c.FilePath, "/"+p) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSymbol reports whether the last part of a (qualified) symbol is among
+// words. Short and generic names always are.
+func hasSymbol(words map[string]bool, symbol string) bool {
+	symbol = strings.TrimSuffix(symbol, "()")
+	if i := strings.LastIndexByte(symbol, '.'); i >= 0 {
+		symbol = symbol[i+1:]
+	}
+	return len(symbol) < 3 || commonWords[symbol] || words[symbol]
+}
+
+// GroundingWarning describes ungrounded references for the user, or returns
+// "" if there are none.
+func GroundingWarning(refs []Reference) string {
+	if len(refs) == 0 {
+		return ""
+	}
+	names := make([]string, len(refs))
+	for i, r := range refs {
+		names[i] = "`" + r.Text + "`"
+	}
+	return fmt.Sprintf("Warning: the answer mentions %s, which the retrieved code doesn't contain. Check these before relying on it.",
+		strings.Join(names, ", "))
+}
@@ -0,0 +1,83 @@
+package rag
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Default thresholds of Weighting.
+const (
+	DefaultKeywordQueryWords  = 3
+	DefaultSemanticQueryWords = 6
+	DefaultQueryWeight        = 2.0
+)
+
+// Kinds of query Weighting tells apart.
+const (
+	QueryKeyword  = "keyword"
+	QuerySemantic = "semantic"
+	QueryMixed    = "mixed"
+)
+
+// codeLikeRe matches words that look like identifiers rather than prose:
+// camelCase, snake_case, qualified names (pkg.Func, a::b, a->b), calls, and
+// backquoted code.
+var codeLikeRe = regexp.MustCompile("[a-z0-9][A-Z]|[A-Za-z0-9]_[A-Za-z0-9]|[A-Za-z0-9](?:\\.|::|->)[A-Za-z_]|\\(\\)|`")
+
+// questionWords start natural-language questions.
+var questionWords = map[string]bool{
+	"how": true, "why": true, "what": true, "where": true, "which": true, "when": true, "who": true,
+	"does": true, "do": true, "is": true, "are": true, "can": true, "should": true, "explain": true,
+}
+
+// Weighting weighs keyword and vector results in reciprocal rank fusion by
+// the shape of the query: short queries naming identifiers lean on keyword
+// search, which matches them exactly, and natural-language questions on
+// vector search; anything else weighs both alike. Zero fields use the
+// defaults.
+type Weighting struct {
+	// KeywordWords is the most words a query with a code-like word can
+	// have to count as a keyword query.
+	KeywordWords int
+	// SemanticWords is the fewest words a query without code-like words
+	// needs to count as a natural-language question, unless it's phrased
+	// as one ("how ...", "...?").
+	SemanticWords int
+	// Weight is how much more the favored search's ranks count; 1 weighs
+	// them alike for every query.
+	Weight float64
+}
+
+// Weights are the weights of the keyword and vector result lists in
+// fusion, with the kind of query they were chosen for.
+type Weights struct {
+	Keyword float64
+	Vector  float64
+	Kind    string
+}
+
+// For returns the weights for query.
+func (w Weighting) For(query string) Weights {
+	if w.KeywordWords == 0 {
+		w.KeywordWords = DefaultKeywordQueryWords
+	}
+	if w.SemanticWords == 0 {
+		w.SemanticWords = DefaultSemanticQueryWords
+	}
+	if w.Weight == 0 {
+		w.Weight = DefaultQueryWeight
+	}
+
+	words := strings.Fields(query)
+	// A lone word is most likely a name.
+	codeLike := len(words) == 1 || codeLikeRe.MatchString(query)
+	question := strings.HasSuffix(strings.TrimSpace(query), "?") ||
+		len(words) > 0 && questionWords[strings.ToLower(words[0])]
+	switch {
+	case codeLike && len(words) <= w.KeywordWords:
+		return Weights{Keyword: w.Weight, Vector: 1, Kind: QueryKeyword}
+	case !codeLike && (question || len(words) >= w.SemanticWords):
+		return Weights{Keyword: 1, Vector: w.Weight, Kind: QuerySemantic}
+	}
+	return Weights{Keyword: 1, Vector: 1, Kind: QueryMixed}
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Filter restricts search results. The zero value matches everything.
+type Filter struct {
+	// PathPrefix limits results to files whose indexed path starts with this
+	// prefix, e.g. "services/payments/".
+	PathPrefix string
+	// Include, if non-empty, limits results to files matching at least one
+	// of these path globs.
+	Include []string
+	// Exclude drops results from files matching any of these path globs.
+	Exclude []string
+}
+
+// IsZero reports whether the filter matches everything.
+func (f Filter) IsZero() bool {
+	return f.PathPrefix == "" && len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// String describes the active restrictions, e.g. "focus: auth; exclude: *_test.go".
+// It's also used as a cache key, so equal filters produce equal strings.
+func (f Filter) String() string {
+	var parts []string
+	if f.PathPrefix != "" {
+		parts = append(parts, "path: "+NormalizePath(f.PathPrefix))
+	}
+	if len(f.Include) > 0 {
+		parts = append(parts, "focus: "+strings.Join(f.Include, ", "))
+	}
+	if len(f.Exclude) > 0 {
+		parts = append(parts, "exclude: "+strings.Join(f.Exclude, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// NormalizePath converts a user-supplied path to the indexed form: forward
+// slashes and no leading "./" or "/".
+func NormalizePath(p string) string {
+	p = filepath.ToSlash(p)
+	p = strings.TrimPrefix(p, "./")
+	return strings.TrimLeft(p, "/")
+}
+
+// ResolvePath returns the absolute path of the indexed path rel under root.
+// It fails when rel, e.g. a path supplied by a model or a user, points outside
+// root.
+func ResolvePath(root, rel string) (string, error) {
+	if root == "" {
+		return "", fmt.Errorf("resolve %q: index has no project root; re-run synapse index", rel)
+	}
+	abs := filepath.Join(root, filepath.FromSlash(NormalizePath(rel)))
+	r, err := filepath.Rel(root, abs)
+	if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("resolve %q: outside project root %s", rel, root)
+	}
+	return abs, nil
+}
+
+// clause returns an SQL condition (starting with " AND") and its arguments.
+// It expects the files table to be aliased as f.
+func (f Filter) clause() (string, []any) {
+	var b strings.Builder
+	var args []any
+	if prefix := NormalizePath(f.PathPrefix); prefix != "" {
+		// substr avoids LIKE's case-insensitivity and wildcard escaping.
+		b.WriteString(" AND substr(f.path, 1, ?) = ?")
+		args = append(args, len(prefix), prefix)
+	}
+	if len(f.Include) > 0 {
+		cond, globArgs := globsClause(f.Include)
+		b.WriteString(" AND " + cond)
+		args = append(args, globArgs...)
+	}
+	if len(f.Exclude) > 0 {
+		cond, globArgs := globsClause(f.Exclude)
+		b.WriteString(" AND NOT " + cond)
+		args = append(args, globArgs...)
+	}
+	return b.String(), args
+}
+
+// globsClause matches f.path against any of the patterns. A pattern matches
+// the whole path, or any trailing run of path segments, or a directory whose
+// files it contains — so "auth", "internal/auth", and "*.go" all behave as
+// users expect.
+func globsClause(patterns []string) (string, []any) {
+	var conds []string
+	var args []any
+	for _, p := range patterns {
+		p = strings.TrimSuffix(NormalizePath(p), "/")
+		conds = append(conds, "f.path GLOB ? OR f.path GLOB ? OR f.path GLOB ? OR f.path GLOB ?")
+		args = append(args, p, p+"/*", "*/"+p, "*/"+p+"/*")
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
@@ -1,6 +1,9 @@
 package store
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // FileRecord represents an indexed source file.
 type FileRecord struct {
@@ -10,6 +13,19 @@ type FileRecord struct {
 	Language  string
 	IndexedAt time.Time
 	SizeBytes int64
+	// ModTime is when the file was last modified, as of indexing. It's zero
+	// for files indexed before it was recorded.
+	ModTime time.Time
+}
+
+// Unchanged reports whether a file of size bytes last modified at modTime
+// can be taken to be the one f records without hashing it: its size and
+// modification time, to the second it's stored with, are those recorded.
+// Any other modification time counts, as files restored from a backup or
+// checked out by git can be older than the index. Files without a recorded
+// modification time have to be hashed.
+func (f FileRecord) Unchanged(size int64, modTime time.Time) bool {
+	return !f.ModTime.IsZero() && size == f.SizeBytes && modTime.Unix() == f.ModTime.Unix()
 }
 
 // Chunk represents a parsed code chunk from a source file.
@@ -22,6 +38,57 @@ type Chunk struct {
 	EndLine   int
 	Content   string
 	Metadata  string
+	// Hash identifies the chunk's name and content (see ChunkHash) so unchanged
+	// chunks keep their embeddings when a file is re-indexed.
+	Hash string
+	// QualifiedName is Name prefixed with the type or class the symbol
+	// belongs to, such as SQLiteStore.Search, or "" when it belongs to none
+	// or was indexed before qualified names were recorded.
+	QualifiedName string
+}
+
+// Piece returns c's 1-based place among the pieces an oversized definition
+// was split into, as recorded in its Metadata, or 0 for a whole definition
+// or a chunk indexed before pieces were recorded.
+func (c Chunk) Piece() int {
+	var meta struct {
+		Piece int `json:"piece"`
+	}
+	if err := json.Unmarshal([]byte(c.Metadata), &meta); err != nil {
+		return 0
+	}
+	return meta.Piece
+}
+
+// Symbol returns the chunk's qualified name, or its name without one.
+func (c Chunk) Symbol() string {
+	if c.QualifiedName != "" {
+		return c.QualifiedName
+	}
+	return c.Name
+}
+
+// StaleFiles lists the indexed files that no longer match the disk, by path.
+type StaleFiles struct {
+	// Changed files have different content than when they were indexed.
+	Changed []string
+	// Missing files were deleted or moved.
+	Missing []string
+}
+
+// Count returns the number of stale files.
+func (f *StaleFiles) Count() int {
+	return len(f.Changed) + len(f.Missing)
+}
+
+// RelatedFile is a file near another in summary-embedding space.
+type RelatedFile struct {
+	Path     string
+	Language string
+	Summary  string
+	Distance float64
+	// Relevance is Distance as a 0–100 score; see Relevance.
+	Relevance float64
 }
 
 // FileSummary is a lightweight file record for overview generation.
@@ -45,4 +112,21 @@ type SearchResult struct {
 	FilePath string
 	Language string
 	Distance float64
+	// Metric is what Distance measures: MetricBM25 for a keyword match, or
+	// the vector metric for a vector match. Results found by other means,
+	// such as FindByName, have none.
+	Metric string `json:",omitempty"`
+	// Relevance is a vector match's Distance as a 0–100 score (see
+	// Relevance), or nil for keyword matches, whose BM25 score has no scale.
+	Relevance *float64 `json:",omitempty"`
+	// Score is the result's rank-based relevance from 0 to 1, set by hybrid
+	// retrieval for every result whichever search found it; see
+	// rag.Retriever.Retrieve. Results of one retrieval are ordered by it.
+	// Unlike Relevance it compares results of one query, not across queries.
+	Score float64 `json:",omitempty"`
+	// Embedding is the chunk's vector. Only SearchWithEmbeddings fills it.
+	Embedding []float32 `json:",omitempty"`
+	// Source names the index the result came from in a search over
+	// several, and is empty otherwise.
+	Source string `json:",omitempty"`
 }
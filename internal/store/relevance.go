@@ -0,0 +1,73 @@
+package store
+
+import (
+	"database/sql"
+	"math"
+)
+
+// Distance metrics vector tables can be created with. sqlite-vec measures
+// L2 unless a table says otherwise, and synapse's tables don't.
+const (
+	MetricL2     = "l2"
+	MetricCosine = "cosine"
+)
+
+// MetricBM25 is the metric of keyword matches' Distance: their BM25 score,
+// where unlike vector distances higher is better.
+const MetricBM25 = "bm25"
+
+// Relevance converts a vector distance measured with metric into a 0–100
+// score: the cosine similarity of the two vectors as a percentage, with
+// opposing vectors scoring 0. L2 distances are converted assuming unit-length
+// vectors, which indexes that normalize embeddings store; for them
+// cos = 1 - d²/2.
+func Relevance(distance float64, metric string) float64 {
+	var cos float64
+	switch metric {
+	case MetricCosine:
+		cos = 1 - distance
+	default:
+		cos = 1 - distance*distance/2
+	}
+	return 100 * math.Max(0, math.Min(1, cos))
+}
+
+// distanceMetric returns the metric recorded in meta when the vector tables
+// were created. Indexes that predate the record use sqlite-vec's default.
+func distanceMetric(db *sql.DB) (string, error) {
+	var metric string
+	err := db.QueryRow("SELECT value FROM meta WHERE key = 'distance_metric'").Scan(&metric)
+	if err == sql.ErrNoRows || err == nil && metric == "" {
+		return MetricL2, nil
+	}
+	return metric, err
+}
+
+// normalizedEmbeddings reports whether meta records that the index scales
+// embeddings to unit length. Indexes that predate the record don't.
+func normalizedEmbeddings(db *sql.DB) (bool, error) {
+	var value string
+	err := db.QueryRow("SELECT value FROM meta WHERE key = 'normalize_embeddings'").Scan(&value)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return value == "true", err
+}
+
+// unit returns v scaled to unit length, so the L2 distance between two such
+// vectors ranks them like cosine similarity. A zero vector is returned as is.
+func unit(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := math.Sqrt(sum)
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(float64(x) / norm)
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRelevance(t *testing.T) {
+	tests := []struct {
+		name     string
+		distance float64
+		metric   string
+		want     float64
+	}{
+		{"cosine identical", 0, MetricCosine, 100},
+		{"cosine orthogonal", 1, MetricCosine, 0},
+		{"cosine opposed", 2, MetricCosine, 0},
+		{"cosine close", 0.25, MetricCosine, 75},
+		{"l2 identical", 0, MetricL2, 100},
+		{"l2 orthogonal", math.Sqrt2, MetricL2, 0},
+		{"l2 opposed", 2, MetricL2, 0},
+		{"l2 close", 0.5, MetricL2, 87.5},
+		{"unrecorded metric is l2", 0.5, "", 87.5},
+	}
+	for _, tt := range tests {
+		if got := Relevance(tt.distance, tt.metric); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: Relevance(%g, %q) = %g, want %g", tt.name, tt.distance, tt.metric, got, tt.want)
+		}
+	}
+}
+
+func TestUnit(t *testing.T) {
+	got := unit([]float32{3, 0, -4})
+	want := []float32{0.6, 0, -0.8}
+	for i := range want {
+		if math.Abs(float64(got[i]-want[i])) > 1e-6 {
+			t.Fatalf("unit = %v, want %v", got, want)
+		}
+	}
+	if zero := unit([]float32{0, 0}); zero[0] != 0 || zero[1] != 0 {
+		t.Errorf("unit of a zero vector = %v, want it unchanged", zero)
+	}
+}
@@ -2,6 +2,9 @@ package store
 
 import (
 	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -16,7 +19,8 @@ CREATE TABLE IF NOT EXISTS files (
     language   TEXT NOT NULL DEFAULT '',
     indexed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
     size_bytes INTEGER NOT NULL DEFAULT 0,
-    summary    TEXT NOT NULL DEFAULT ''
+    summary    TEXT NOT NULL DEFAULT '',
+    mtime      INTEGER NOT NULL DEFAULT 0
 );
 
 CREATE TABLE IF NOT EXISTS chunks (
@@ -27,45 +31,233 @@ CREATE TABLE IF NOT EXISTS chunks (
     start_line INTEGER NOT NULL,
     end_line   INTEGER NOT NULL,
     content    TEXT NOT NULL,
-    metadata   TEXT NOT NULL DEFAULT '{}'
+    metadata   TEXT NOT NULL DEFAULT '{}',
+    content_hash TEXT NOT NULL DEFAULT '',
+    name_words TEXT NOT NULL DEFAULT '',
+    qualified_name TEXT NOT NULL DEFAULT ''
 );
 
-CREATE VIRTUAL TABLE IF NOT EXISTS vec_chunks USING vec0(
-    chunk_id INTEGER PRIMARY KEY,
-    embedding float[768]
+CREATE TABLE IF NOT EXISTS dir_summaries (
+    dir        TEXT PRIMARY KEY,
+    input_hash TEXT NOT NULL,
+    summary    TEXT NOT NULL
 );
 
 CREATE TABLE IF NOT EXISTS meta (
     key   TEXT PRIMARY KEY,
     value TEXT NOT NULL
 );
+`
 
-CREATE VIRTUAL TABLE IF NOT EXISTS chunks_fts USING fts5(
-    name, content, content=chunks, content_rowid=id
-);
-
+// ftsTriggersDDL keeps chunks_fts in step with chunks.
+const ftsTriggersDDL = `
 CREATE TRIGGER IF NOT EXISTS chunks_ai AFTER INSERT ON chunks BEGIN
-    INSERT INTO chunks_fts(rowid, name, content) VALUES (new.id, new.name, new.content);
+    INSERT INTO chunks_fts(rowid, name, content, name_words) VALUES (new.id, new.name, new.content, new.name_words);
 END;
 
 CREATE TRIGGER IF NOT EXISTS chunks_ad AFTER DELETE ON chunks BEGIN
-    INSERT INTO chunks_fts(chunks_fts, rowid, name, content) VALUES('delete', old.id, old.name, old.content);
+    INSERT INTO chunks_fts(chunks_fts, rowid, name, content, name_words) VALUES('delete', old.id, old.name, old.content, old.name_words);
 END;
 `
 
+// DefaultTokenizer is the FTS5 tokenizer for new indexes. Porter stemming
+// lets keyword search match word variants ("authenticate" for
+// "authentication").
+const DefaultTokenizer = "porter unicode61"
+
+// ftsTableDDL creates the full-text index over chunks. It's created
+// separately because its tokenizer is configurable. name_words holds the
+// words of compound names (see nameWords), so "retrieve" finds
+// HybridRetrieve.
+const ftsTableDDL = `
+CREATE VIRTUAL TABLE IF NOT EXISTS chunks_fts USING fts5(
+    name, content, name_words, content=chunks, content_rowid=id, tokenize='%s'
+);`
+
+// vecTableDDL creates the sqlite-vec table. It's created separately from the
+// rest of the schema because its dimension depends on the embedding model.
+const vecTableDDL = `
+CREATE VIRTUAL TABLE IF NOT EXISTS vec_chunks USING vec0(
+    chunk_id INTEGER PRIMARY KEY,
+    embedding float[%d]
+);`
+
+// vecFilesDDL creates the per-file summary embedding table, which shares the
+// chunk embeddings' dimension.
+const vecFilesDDL = `
+CREATE VIRTUAL TABLE IF NOT EXISTS vec_files USING vec0(
+    file_id INTEGER PRIMARY KEY,
+    embedding float[%d]
+);`
+
+var vecDimRe = regexp.MustCompile(`float\[(\d+)\]`)
+
+// vecDimension returns the dimension vec_chunks was created with, or 0 if the
+// table doesn't exist yet.
+func vecDimension(db *sql.DB) (int, error) {
+	var ddl string
+	err := db.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'vec_chunks'").Scan(&ddl)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	m := vecDimRe.FindStringSubmatch(ddl)
+	if m == nil {
+		return 0, fmt.Errorf("cannot determine dimension of vec_chunks from %q", ddl)
+	}
+	return strconv.Atoi(m[1])
+}
+
+var tokenizeRe = regexp.MustCompile(`tokenize\s*=\s*'([^']*)'`)
+
+// ftsTokenizer returns the tokenizer chunks_fts was created with. Indexes
+// created before it was configurable use FTS5's default, unicode61.
+func ftsTokenizer(db *sql.DB) (string, error) {
+	var ddl string
+	if err := db.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'chunks_fts'").Scan(&ddl); err != nil {
+		return "", err
+	}
+	if m := tokenizeRe.FindStringSubmatch(ddl); m != nil {
+		return m[1], nil
+	}
+	return "unicode61", nil
+}
+
 // Init creates the schema tables if they don't exist.
 func Init(db *sql.DB) error {
 	if _, err := db.Exec(ddl); err != nil {
 		return err
 	}
+	if _, err := db.Exec(fmt.Sprintf(ftsTableDDL, DefaultTokenizer)); err != nil {
+		return err
+	}
 	// Migration: add summary column for existing databases.
 	_, err := db.Exec("ALTER TABLE files ADD COLUMN summary TEXT NOT NULL DEFAULT ''")
 	if err != nil && !isDuplicateColumn(err) {
 		return err
 	}
+	// Migration: add content_hash for chunk-level updates. Chunks indexed
+	// before it have an empty hash and are re-embedded on their next change.
+	_, err = db.Exec("ALTER TABLE chunks ADD COLUMN content_hash TEXT NOT NULL DEFAULT ''")
+	if err != nil && !isDuplicateColumn(err) {
+		return err
+	}
+	// Migration: add qualified_name. Chunks indexed before it have none
+	// until their file is re-indexed.
+	_, err = db.Exec("ALTER TABLE chunks ADD COLUMN qualified_name TEXT NOT NULL DEFAULT ''")
+	if err != nil && !isDuplicateColumn(err) {
+		return err
+	}
+	// Migration: add mtime, in Unix seconds. Files indexed before it have 0
+	// until they're re-indexed.
+	_, err = db.Exec("ALTER TABLE files ADD COLUMN mtime INTEGER NOT NULL DEFAULT 0")
+	if err != nil && !isDuplicateColumn(err) {
+		return err
+	}
+	// Migration: add name_words and index it.
+	_, err = db.Exec("ALTER TABLE chunks ADD COLUMN name_words TEXT NOT NULL DEFAULT ''")
+	if err != nil && !isDuplicateColumn(err) {
+		return err
+	}
+	if err := indexNameWords(db); err != nil {
+		return fmt.Errorf("index name words: %w", err)
+	}
+	_, err = db.Exec(ftsTriggersDDL)
+	return err
+}
+
+// indexNameWords fills in name_words for indexes created before it and
+// recreates chunks_fts, and its triggers, with the column. It does nothing
+// once chunks_fts has it.
+func indexNameWords(db *sql.DB) error {
+	var ddl string
+	if err := db.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'chunks_fts'").Scan(&ddl); err != nil {
+		return err
+	}
+	if strings.Contains(ddl, "name_words") {
+		return nil
+	}
+	tokenizer, err := ftsTokenizer(db)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	rows, err := tx.Query("SELECT id, name FROM chunks WHERE name != ''")
+	if err != nil {
+		return err
+	}
+	words := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		if w := nameWords(name); w != "" {
+			words[id] = w
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, w := range words {
+		if _, err := tx.Exec("UPDATE chunks SET name_words = ? WHERE id = ?", w, id); err != nil {
+			return err
+		}
+	}
+	for _, stmt := range []string{
+		"DROP TRIGGER IF EXISTS chunks_ai",
+		"DROP TRIGGER IF EXISTS chunks_ad",
+		"DROP TABLE chunks_fts",
+		fmt.Sprintf(ftsTableDDL, tokenizer),
+		"INSERT INTO chunks_fts(chunks_fts) VALUES('rebuild')",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// checkSchema returns an error if the tables Init creates are missing, for
+// read-only opens, which can't create them.
+func checkSchema(db *sql.DB) error {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name IN ('files', 'chunks', 'chunks_fts', 'meta')").Scan(&n)
+	if err != nil {
+		return fmt.Errorf("read schema: %w", err)
+	}
+	if n < 4 {
+		return fmt.Errorf("index schema is missing; run 'synapse index' to build it")
+	}
 	return nil
 }
 
+// hasColumn reports whether table has the named column, for reading indexes
+// opened read-only, which aren't migrated.
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&n)
+	return n > 0, err
+}
+
+// hasTable reports whether the named table exists, for reading indexes
+// opened read-only, which aren't migrated.
+func hasTable(db *sql.DB, table string) (bool, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&n)
+	return n > 0, err
+}
+
 func isDuplicateColumn(err error) bool {
 	return err != nil && strings.Contains(err.Error(), "duplicate column")
 }
@@ -1,9 +1,22 @@
 package store
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
@@ -17,51 +30,270 @@ func init() {
 type Store interface {
 	// GetFileHash returns the stored hash for a path, or "" if not indexed.
 	GetFileHash(path string) (string, error)
+	// FileRecords returns the record of every indexed file, keyed by path.
+	// Only Path, Hash, Language, IndexedAt, SizeBytes, and ModTime are set.
+	FileRecords() (map[string]FileRecord, error)
+	// ListStaleFiles compares every indexed file with the one at the same
+	// path under root and returns those that changed or no longer exist.
+	// Files whose size and modification time match those recorded are taken
+	// as unchanged (see FileRecord.Unchanged); others are hashed. Files on disk that
+	// were never indexed aren't reported.
+	ListStaleFiles(root string) (*StaleFiles, error)
 	// UpsertFile inserts or updates a file record and returns its ID.
-	// It also deletes any existing chunks and embeddings for the file.
+	// It also deletes any existing chunks and embeddings for the file, and
+	// clears its summary and summary embedding if the hash changed.
 	UpsertFile(f FileRecord) (int64, error)
 	// InsertChunks inserts chunks for a file and returns their IDs.
 	InsertChunks(fileID int64, chunks []Chunk) ([]int64, error)
-	// InsertEmbeddings stores embeddings keyed by chunk ID.
+	// InsertEmbeddings stores embeddings keyed by chunk ID. All-zero, NaN, or
+	// infinite vectors are skipped.
 	InsertEmbeddings(chunkIDs []int64, embeddings [][]float32) error
-	// Search finds the top-k chunks closest to the query embedding.
-	Search(queryEmbedding []float32, k int) ([]SearchResult, error)
+	// ChunkHashes counts the stored chunks of a file by ChunkHash.
+	ChunkHashes(path string) (map[string]int, error)
+	// ReplaceFileChunks upserts a file record and makes its chunks match
+	// chunks, keeping the rows and embeddings of chunks whose name and
+	// content are unchanged. embeddings is parallel to chunks; entries for reused
+	// chunks may be nil. Degenerate (all-zero, NaN, or infinite) embeddings
+	// aren't stored, leaving those chunks to keyword search. It returns the
+	// file ID and how many chunks were reused. Like UpsertFile, it clears
+	// the file's summary and summary embedding if the hash changed.
+	ReplaceFileChunks(f FileRecord, chunks []Chunk, embeddings [][]float32) (int64, int, error)
+	// Search finds the top-k chunks closest to the query embedding that match the filter.
+	// Chunks at equal distances are ordered by path and start line.
+	Search(queryEmbedding []float32, k int, filter Filter) ([]SearchResult, error)
+	// SearchWithEmbeddings is like Search but also returns each result's
+	// embedding, for reranking without another query.
+	SearchWithEmbeddings(queryEmbedding []float32, k int, filter Filter) ([]SearchResult, error)
 	// FTSSearch finds the top-k chunks matching the query via FTS5/BM25 keyword search.
-	FTSSearch(query string, k int) ([]SearchResult, error)
+	FTSSearch(query string, k int, filter Filter) ([]SearchResult, error)
+	// FindByName returns all chunks whose symbol name or qualified name
+	// (see Chunk.QualifiedName) exactly matches name, or whose qualified
+	// name ends in it after a dot.
+	FindByName(name string) ([]SearchResult, error)
 	// GetMeta returns a metadata value by key, or "" if not set.
 	GetMeta(key string) (string, error)
 	// SetMeta sets a metadata key-value pair.
 	SetMeta(key, value string) error
+	// ProjectRoot returns the absolute path of the indexed directory, or ""
+	// for indexes that predate recording it.
+	ProjectRoot() (string, error)
+	// EmbeddingDim returns the vector dimension of the index, or 0 if no
+	// vectors have been configured yet.
+	EmbeddingDim() (int, error)
+	// SetEmbeddingDim prepares the index for dim-sized embeddings, creating
+	// the vector table if needed and recording the dimension and distance
+	// metric in meta.
+	SetEmbeddingDim(dim int) error
+	// SetNormalize records in meta whether embeddings are scaled to unit
+	// length when stored and before searching, so L2 distance ranks like
+	// cosine similarity. Turning it on normalizes the stored embeddings.
+	SetNormalize(on bool) error
+	// Tokenizer returns the full-text index's FTS5 tokenizer.
+	Tokenizer() (string, error)
+	// SetTokenizer switches the full-text index to tokenizer, rebuilding it
+	// from the stored chunks if it differs. It reports whether it rebuilt.
+	SetTokenizer(tokenizer string) (bool, error)
+	// SetFileEmbedding stores the embedding of a file's summary.
+	SetFileEmbedding(path string, embedding []float32) error
+	// FilesWithoutEmbedding returns summarized files whose summary hasn't
+	// been embedded yet.
+	FilesWithoutEmbedding() ([]FileSummary, error)
+	// RelatedFiles returns up to k files whose summaries are closest to the
+	// summary of the file at path.
+	RelatedFiles(path string, k int) ([]RelatedFile, error)
 	// ListFiles returns a summary of all indexed files.
 	ListFiles() ([]FileSummary, error)
 	// ListTopChunks returns name, kind, and file path for all named chunks.
 	ListTopChunks() ([]ChunkSummary, error)
 	// GetAllFileContent returns all chunk content for a single file, concatenated.
 	GetAllFileContent(path string) (string, error)
+	// GetFileSummary returns the summary of the file at path, or "" if it
+	// has none or isn't indexed.
+	GetFileSummary(path string) (string, error)
 	// SetFileSummary updates the summary for a file.
 	SetFileSummary(path string, summary string) error
-	// DeleteAllChunks removes all files, chunks, and embeddings.
+	// DirSummary returns the stored summary of a directory and the hash of
+	// the input it was generated from, or empty strings if there is none.
+	DirSummary(dir string) (summary, inputHash string, err error)
+	// SetDirSummary stores the summary of a directory along with the hash
+	// of the input it was generated from.
+	SetDirSummary(dir, inputHash, summary string) error
+	// DeleteAllChunks removes all files, chunks, embeddings, and directory
+	// summaries.
 	DeleteAllChunks() error
+	// Backup writes a consistent copy of the database to dest.
+	Backup(dest string) error
+	// Optimize compacts the database and merges the full-text index.
+	Optimize() error
+	// VerifyFTS checks that the full-text index is consistent with chunks.
+	VerifyFTS() error
+	// RepairFTS rebuilds the full-text index from chunks.
+	RepairFTS() error
 	// Close closes the underlying database.
 	Close() error
 }
 
 // SQLiteStore implements Store backed by SQLite + sqlite-vec.
 type SQLiteStore struct {
-	db *sql.DB
+	db  *sql.DB
+	dim int // vec_chunks dimension, 0 until configured
+	// metric is the distance metric of the vector tables, from meta.
+	metric string
+	// normalize scales embeddings to unit length before they're stored or
+	// searched with, as recorded in meta. normalizeIndexed is the
+	// last_indexed marker when it was read, so searches can re-read it
+	// after another process re-indexes; see refreshNormalize.
+	normalizeMu      sync.Mutex
+	normalize        bool
+	normalizeIndexed string
+
+	ftsOnly bool // opened without sqlite-vec; vector search is disabled
+
+	ftsRepaired bool // the full-text index was rebuilt by Open
+
+	// qualifiedCol selects chunks' qualified names: the column, or '' in
+	// read-only indexes created before it.
+	qualifiedCol string
+	// mtimeCol selects files' modification times: the column, or 0 in
+	// read-only opens of indexes created before it.
+	mtimeCol string
+	// noVecFiles is set in read-only opens of indexes created before file
+	// summary embeddings, which have no vec_files table.
+	noVecFiles bool
+}
+
+// ErrVecUnavailable is returned when the sqlite-vec extension couldn't be
+// loaded, or by vector operations on a store opened with OpenFTSOnly.
+var ErrVecUnavailable = errors.New("sqlite-vec extension is not available")
+
+// OpenOptions control how OpenWith opens an index.
+type OpenOptions struct {
+	// ReadOnly opens an existing index with mode=ro: the schema isn't
+	// created or migrated, and every write fails. Readers can run alongside
+	// an 'index' run writing to the same database.
+	ReadOnly bool
+	// FTSOnly opens the index without the sqlite-vec extension, for
+	// platforms where it fails to load. Keyword search works; vector search,
+	// related files, and indexing return ErrVecUnavailable.
+	FTSOnly bool
 }
 
 // Open creates or opens a SQLite database at the given path and initializes the schema.
 func Open(dbPath string) (*SQLiteStore, error) {
-	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_foreign_keys=on")
+	return OpenWith(dbPath, OpenOptions{})
+}
+
+// OpenFTSOnly opens an index without the sqlite-vec extension; see
+// OpenOptions.FTSOnly.
+func OpenFTSOnly(dbPath string) (*SQLiteStore, error) {
+	return OpenWith(dbPath, OpenOptions{FTSOnly: true})
+}
+
+// OpenReadOnly opens an existing index for reading; see OpenOptions.ReadOnly.
+func OpenReadOnly(dbPath string) (*SQLiteStore, error) {
+	return OpenWith(dbPath, OpenOptions{ReadOnly: true})
+}
+
+// OpenWith opens the SQLite database at the given path as configured by opts.
+func OpenWith(dbPath string, opts OpenOptions) (*SQLiteStore, error) {
+	dsn := dbPath + "?_journal_mode=WAL&_foreign_keys=on"
+	if opts.ReadOnly {
+		// Not immutable=1: that skips locking and change detection, which is
+		// only safe if nothing writes the index while it's open.
+		dsn = "file:" + dbPath + "?mode=ro&_foreign_keys=on"
+	}
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("open db: %w", err)
 	}
-	if err := Init(db); err != nil {
+	// The extension is registered in init and only fails on first use, with
+	// errors like "no such module: vec0"; check for it up front instead.
+	if !opts.FTSOnly {
+		var version string
+		if err := db.QueryRow("SELECT vec_version()").Scan(&version); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("%w: it failed to load on this platform (%v); run queries with --fts-only for keyword search without vectors", ErrVecUnavailable, err)
+		}
+	}
+	if opts.ReadOnly {
+		if err := checkSchema(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	} else if err := Init(db); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("init schema: %w", err)
 	}
-	return &SQLiteStore{db: db}, nil
+	var dim int
+	if !opts.FTSOnly {
+		dim, err = vecDimension(db)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("read vector dimension: %w", err)
+		}
+	}
+	metric, err := distanceMetric(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("read distance metric: %w", err)
+	}
+	normalize, err := normalizedEmbeddings(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("read normalization: %w", err)
+	}
+	if opts.ReadOnly {
+		qualifiedCol := "c.qualified_name"
+		if ok, err := hasColumn(db, "chunks", "qualified_name"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("read schema: %w", err)
+		} else if !ok {
+			qualifiedCol = "''"
+		}
+		mtimeCol := "mtime"
+		if ok, err := hasColumn(db, "files", "mtime"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("read schema: %w", err)
+		} else if !ok {
+			mtimeCol = "0"
+		}
+		vecFiles, err := hasTable(db, "vec_files")
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("read schema: %w", err)
+		}
+		return &SQLiteStore{db: db, dim: dim, metric: metric, normalize: normalize, ftsOnly: opts.FTSOnly, qualifiedCol: qualifiedCol, mtimeCol: mtimeCol, noVecFiles: dim > 0 && !vecFiles}, nil
+	}
+	// Migration: indexes created before file summary embeddings.
+	if dim > 0 {
+		if _, err := db.Exec(fmt.Sprintf(vecFilesDDL, dim)); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("create vec_files: %w", err)
+		}
+	}
+	s := &SQLiteStore{db: db, dim: dim, metric: metric, normalize: normalize, ftsOnly: opts.FTSOnly, qualifiedCol: "c.qualified_name", mtimeCol: "mtime"}
+	// A crash or writes that bypass the triggers can leave chunks_fts out of
+	// step with chunks. A full integrity check is too slow for every open, but
+	// a differing row count is cheap to spot and always means it's stale.
+	inSync, err := s.ftsInSync()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("check fts: %w", err)
+	}
+	if !inSync {
+		if err := s.RepairFTS(); err != nil {
+			db.Close()
+			return nil, err
+		}
+		s.ftsRepaired = true
+	}
+	return s, nil
+}
+
+// FTSRepaired reports whether Open found the full-text index out of sync
+// with the stored chunks and rebuilt it.
+func (s *SQLiteStore) FTSRepaired() bool {
+	return s.ftsRepaired
 }
 
 func (s *SQLiteStore) GetFileHash(path string) (string, error) {
@@ -73,6 +305,68 @@ func (s *SQLiteStore) GetFileHash(path string) (string, error) {
 	return hash, err
 }
 
+func (s *SQLiteStore) FileRecords() (map[string]FileRecord, error) {
+	rows, err := s.db.Query("SELECT path, hash, language, indexed_at, size_bytes, " + s.mtimeCol + " FROM files")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make(map[string]FileRecord)
+	for rows.Next() {
+		var f FileRecord
+		var mtime int64
+		if err := rows.Scan(&f.Path, &f.Hash, &f.Language, &f.IndexedAt, &f.SizeBytes, &mtime); err != nil {
+			return nil, err
+		}
+		if mtime > 0 {
+			f.ModTime = time.Unix(mtime, 0)
+		}
+		records[f.Path] = f
+	}
+	return records, rows.Err()
+}
+
+// unixTime returns t in Unix seconds, or 0 for the zero time.
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func (s *SQLiteStore) ListStaleFiles(root string) (*StaleFiles, error) {
+	records, err := s.FileRecords()
+	if err != nil {
+		return nil, err
+	}
+	stale := &StaleFiles{}
+	for path, rec := range records {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		info, err := os.Stat(full)
+		if errors.Is(err, fs.ErrNotExist) {
+			stale.Missing = append(stale.Missing, path)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rec.Unchanged(info.Size(), info.ModTime()) {
+			continue
+		}
+		src, err := os.ReadFile(full)
+		if err != nil {
+			return nil, err
+		}
+		if h := sha256.Sum256(src); hex.EncodeToString(h[:]) != rec.Hash {
+			stale.Changed = append(stale.Changed, path)
+		}
+	}
+	sort.Strings(stale.Changed)
+	sort.Strings(stale.Missing)
+	return stale, nil
+}
+
 func (s *SQLiteStore) UpsertFile(f FileRecord) (int64, error) {
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -82,7 +376,8 @@ func (s *SQLiteStore) UpsertFile(f FileRecord) (int64, error) {
 
 	// Check if file exists.
 	var existingID int64
-	err = tx.QueryRow("SELECT id FROM files WHERE path = ?", f.Path).Scan(&existingID)
+	var oldHash string
+	err = tx.QueryRow("SELECT id, hash FROM files WHERE path = ?", f.Path).Scan(&existingID, &oldHash)
 	if err == nil {
 		// File exists — delete old chunks and embeddings.
 		rows, err := tx.Query("SELECT id FROM chunks WHERE file_id = ?", existingID)
@@ -110,12 +405,17 @@ func (s *SQLiteStore) UpsertFile(f FileRecord) (int64, error) {
 		}
 		// Update the file record.
 		_, err = tx.Exec(
-			"UPDATE files SET hash = ?, language = ?, indexed_at = CURRENT_TIMESTAMP, size_bytes = ?, summary = '' WHERE id = ?",
-			f.Hash, f.Language, f.SizeBytes, existingID,
+			"UPDATE files SET hash = ?, language = ?, indexed_at = CURRENT_TIMESTAMP, size_bytes = ?, mtime = ? WHERE id = ?",
+			f.Hash, f.Language, f.SizeBytes, unixTime(f.ModTime), existingID,
 		)
 		if err != nil {
 			return 0, err
 		}
+		if oldHash != f.Hash {
+			if err := s.clearSummary(tx, existingID); err != nil {
+				return 0, err
+			}
+		}
 		if err := tx.Commit(); err != nil {
 			return 0, err
 		}
@@ -127,8 +427,8 @@ func (s *SQLiteStore) UpsertFile(f FileRecord) (int64, error) {
 
 	// Insert new file.
 	res, err := tx.Exec(
-		"INSERT INTO files (path, hash, language, size_bytes) VALUES (?, ?, ?, ?)",
-		f.Path, f.Hash, f.Language, f.SizeBytes,
+		"INSERT INTO files (path, hash, language, size_bytes, mtime) VALUES (?, ?, ?, ?, ?)",
+		f.Path, f.Hash, f.Language, f.SizeBytes, unixTime(f.ModTime),
 	)
 	if err != nil {
 		return 0, err
@@ -151,7 +451,7 @@ func (s *SQLiteStore) InsertChunks(fileID int64, chunks []Chunk) ([]int64, error
 	defer tx.Rollback()
 
 	stmt, err := tx.Prepare(
-		"INSERT INTO chunks (file_id, name, kind, start_line, end_line, content, metadata) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		"INSERT INTO chunks (file_id, name, kind, start_line, end_line, content, metadata, content_hash, name_words, qualified_name) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 	)
 	if err != nil {
 		return nil, err
@@ -164,7 +464,11 @@ func (s *SQLiteStore) InsertChunks(fileID int64, chunks []Chunk) ([]int64, error
 		if meta == "" {
 			meta = "{}"
 		}
-		res, err := stmt.Exec(fileID, c.Name, c.Kind, c.StartLine, c.EndLine, c.Content, meta)
+		hash := c.Hash
+		if hash == "" {
+			hash = ChunkHash(c.Name, c.Content)
+		}
+		res, err := stmt.Exec(fileID, c.Name, c.Kind, c.StartLine, c.EndLine, c.Content, meta, hash, nameWords(c.Symbol()), c.QualifiedName)
 		if err != nil {
 			return nil, err
 		}
@@ -180,10 +484,35 @@ func (s *SQLiteStore) InsertChunks(fileID int64, chunks []Chunk) ([]int64, error
 	return ids, nil
 }
 
+// degenerate reports whether v is all zeros or contains NaN or infinite
+// values. Such vectors would make distances meaningless, so they aren't
+// stored: their chunks are left out of vector search and only found by
+// keyword search.
+func degenerate(v []float32) bool {
+	zero := true
+	for _, x := range v {
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return true
+		}
+		if x != 0 {
+			zero = false
+		}
+	}
+	return zero
+}
+
 func (s *SQLiteStore) InsertEmbeddings(chunkIDs []int64, embeddings [][]float32) error {
 	if len(chunkIDs) != len(embeddings) {
 		return fmt.Errorf("mismatched chunk IDs (%d) and embeddings (%d)", len(chunkIDs), len(embeddings))
 	}
+	if s.dim == 0 {
+		return fmt.Errorf("embedding dimension not configured")
+	}
+	for i, e := range embeddings {
+		if len(e) != s.dim {
+			return fmt.Errorf("embedding for chunk %d has %d dimensions, index expects %d", chunkIDs[i], len(e), s.dim)
+		}
+	}
 	tx, err := s.db.Begin()
 	if err != nil {
 		return err
@@ -197,7 +526,10 @@ func (s *SQLiteStore) InsertEmbeddings(chunkIDs []int64, embeddings [][]float32)
 	defer stmt.Close()
 
 	for i, cid := range chunkIDs {
-		blob, err := sqlite_vec.SerializeFloat32(embeddings[i])
+		if degenerate(embeddings[i]) {
+			continue
+		}
+		blob, err := s.vectorBlob(embeddings[i])
 		if err != nil {
 			return fmt.Errorf("serialize embedding for chunk %d: %w", cid, err)
 		}
@@ -208,20 +540,283 @@ func (s *SQLiteStore) InsertEmbeddings(chunkIDs []int64, embeddings [][]float32)
 	return tx.Commit()
 }
 
-func (s *SQLiteStore) Search(queryEmbedding []float32, k int) ([]SearchResult, error) {
-	blob, err := sqlite_vec.SerializeFloat32(queryEmbedding)
+// ChunkHash returns the identity of a chunk, its name and content, used to
+// match chunks across re-indexes. The name is part of it so a renamed chunk
+// whose content is otherwise unchanged, such as a doc comment chunk, gets a
+// new row, indexed under its new name.
+func ChunkHash(name, content string) string {
+	h := sha256.New()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(content))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *SQLiteStore) ChunkHashes(path string) (map[string]int, error) {
+	rows, err := s.db.Query(`
+		SELECT c.content_hash FROM chunks c
+		JOIN files f ON f.id = c.file_id
+		WHERE f.path = ? AND c.content_hash != ''`, path)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]int)
+	for rows.Next() {
+		var h string
+		if err := rows.Scan(&h); err != nil {
+			return nil, err
+		}
+		hashes[h]++
+	}
+	return hashes, rows.Err()
+}
+
+// clearSummary empties the summary of a file whose content changed, along
+// with its summary embedding, so the next summarize pass regenerates both.
+func (s *SQLiteStore) clearSummary(tx *sql.Tx, fileID int64) error {
+	if _, err := tx.Exec("UPDATE files SET summary = '' WHERE id = ?", fileID); err != nil {
+		return err
+	}
+	if s.dim > 0 {
+		if _, err := tx.Exec("DELETE FROM vec_files WHERE file_id = ?", fileID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplaceFileChunks runs in one transaction, so a file is never left with a
+// mix of old and new chunks.
+func (s *SQLiteStore) ReplaceFileChunks(f FileRecord, chunks []Chunk, embeddings [][]float32) (int64, int, error) {
+	if len(chunks) != len(embeddings) {
+		return 0, 0, fmt.Errorf("mismatched chunks (%d) and embeddings (%d)", len(chunks), len(embeddings))
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	var fileID int64
+	var oldHash string
+	err = tx.QueryRow("SELECT id, hash FROM files WHERE path = ?", f.Path).Scan(&fileID, &oldHash)
+	switch {
+	case err == sql.ErrNoRows:
+		res, err := tx.Exec(
+			"INSERT INTO files (path, hash, language, size_bytes, mtime) VALUES (?, ?, ?, ?, ?)",
+			f.Path, f.Hash, f.Language, f.SizeBytes, unixTime(f.ModTime),
+		)
+		if err != nil {
+			return 0, 0, err
+		}
+		if fileID, err = res.LastInsertId(); err != nil {
+			return 0, 0, err
+		}
+	case err != nil:
+		return 0, 0, err
+	default:
+		_, err = tx.Exec(
+			"UPDATE files SET hash = ?, language = ?, indexed_at = CURRENT_TIMESTAMP, size_bytes = ?, mtime = ? WHERE id = ?",
+			f.Hash, f.Language, f.SizeBytes, unixTime(f.ModTime), fileID,
+		)
+		if err != nil {
+			return 0, 0, err
+		}
+		if oldHash != f.Hash {
+			if err := s.clearSummary(tx, fileID); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	// Existing chunks by hash, in file order.
+	type stored struct {
+		id        int64
+		nameWords string
+	}
+	existing := make(map[string][]stored)
+	rows, err := tx.Query("SELECT id, content_hash, name_words FROM chunks WHERE file_id = ? ORDER BY id", fileID)
+	if err != nil {
+		return 0, 0, err
+	}
+	for rows.Next() {
+		var c stored
+		var h string
+		if err := rows.Scan(&c.id, &h, &c.nameWords); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		existing[h] = append(existing[h], c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	reused := 0
+	for i, c := range chunks {
+		hash := c.Hash
+		if hash == "" {
+			hash = ChunkHash(c.Name, c.Content)
+		}
+		meta := c.Metadata
+		if meta == "" {
+			meta = "{}"
+		}
+		words := nameWords(c.Symbol())
+		if old := existing[hash]; hash != "" && len(old) > 0 {
+			// Same name and content: keep the row and its embedding, but
+			// the chunk may have moved within the file or into another type.
+			existing[hash] = old[1:]
+			if err := reuseChunk(tx, old[0].id, old[0].nameWords, c, meta, words); err != nil {
+				return 0, 0, err
+			}
+			reused++
+			continue
+		}
+
+		if embeddings[i] == nil {
+			return 0, 0, fmt.Errorf("no embedding for new chunk %q at line %d", c.Name, c.StartLine)
+		}
+		if len(embeddings[i]) != s.dim {
+			return 0, 0, fmt.Errorf("embedding for chunk %q has %d dimensions, index expects %d", c.Name, len(embeddings[i]), s.dim)
+		}
+		res, err := tx.Exec(
+			"INSERT INTO chunks (file_id, name, kind, start_line, end_line, content, metadata, content_hash, name_words, qualified_name) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			fileID, c.Name, c.Kind, c.StartLine, c.EndLine, c.Content, meta, hash, words, c.QualifiedName,
+		)
+		if err != nil {
+			return 0, 0, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return 0, 0, err
+		}
+		if degenerate(embeddings[i]) {
+			continue // keyword search only; see degenerate
+		}
+		blob, err := s.vectorBlob(embeddings[i])
+		if err != nil {
+			return 0, 0, fmt.Errorf("serialize embedding for chunk %d: %w", id, err)
+		}
+		if _, err := tx.Exec("INSERT INTO vec_chunks (chunk_id, embedding) VALUES (?, ?)", id, blob); err != nil {
+			return 0, 0, fmt.Errorf("insert embedding for chunk %d: %w", id, err)
+		}
+	}
+
+	// Whatever wasn't matched is gone from the file.
+	for _, old := range existing {
+		for _, c := range old {
+			if _, err := tx.Exec("DELETE FROM vec_chunks WHERE chunk_id = ?", c.id); err != nil {
+				return 0, 0, err
+			}
+			if _, err := tx.Exec("DELETE FROM chunks WHERE id = ?", c.id); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return fileID, reused, nil
+}
+
+// reuseChunk updates the row id of a chunk kept across a re-index with c's
+// position and metadata. chunks_fts has no update trigger, so when its name
+// words change, from oldWords to words, the row is taken out of chunks_fts
+// before the update and put back after it.
+func reuseChunk(tx *sql.Tx, id int64, oldWords string, c Chunk, meta, words string) error {
+	resync := words != oldWords
+	if resync {
+		if _, err := tx.Exec(
+			"INSERT INTO chunks_fts(chunks_fts, rowid, name, content, name_words) SELECT 'delete', id, name, content, name_words FROM chunks WHERE id = ?", id,
+		); err != nil {
+			return fmt.Errorf("unindex chunk %d: %w", id, err)
+		}
+	}
+	if _, err := tx.Exec(
+		"UPDATE chunks SET name = ?, kind = ?, start_line = ?, end_line = ?, metadata = ?, qualified_name = ?, name_words = ? WHERE id = ?",
+		c.Name, c.Kind, c.StartLine, c.EndLine, meta, c.QualifiedName, words, id,
+	); err != nil {
+		return err
+	}
+	if resync {
+		if _, err := tx.Exec(
+			"INSERT INTO chunks_fts(rowid, name, content, name_words) SELECT id, name, content, name_words FROM chunks WHERE id = ?", id,
+		); err != nil {
+			return fmt.Errorf("reindex chunk %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// maxKNN is sqlite-vec's upper bound on k for a KNN query.
+const maxKNN = 4096
+
+func (s *SQLiteStore) Search(queryEmbedding []float32, k int, filter Filter) ([]SearchResult, error) {
+	return s.vectorSearch(queryEmbedding, k, filter, false)
+}
+
+func (s *SQLiteStore) SearchWithEmbeddings(queryEmbedding []float32, k int, filter Filter) ([]SearchResult, error) {
+	return s.vectorSearch(queryEmbedding, k, filter, true)
+}
+
+// vectorSearch runs a KNN query over vec_chunks, selecting the stored
+// embeddings too when withEmbeddings is set. Equal distances, common with
+// quantized or degenerate embeddings, are ordered by path and line so the
+// same query always returns the same results.
+func (s *SQLiteStore) vectorSearch(queryEmbedding []float32, k int, filter Filter, withEmbeddings bool) ([]SearchResult, error) {
+	if s.dim == 0 {
+		return nil, nil // nothing embedded yet
+	}
+	if len(queryEmbedding) != s.dim {
+		return nil, fmt.Errorf("query embedding has %d dimensions but the index is %d-dim; was it built with a different --model?", len(queryEmbedding), s.dim)
+	}
+	if err := s.refreshNormalize(); err != nil {
+		return nil, err
+	}
+	blob, err := s.vectorBlob(queryEmbedding)
 	if err != nil {
 		return nil, fmt.Errorf("serialize query embedding: %w", err)
 	}
-	rows, err := s.db.Query(`
-		SELECT v.chunk_id, v.distance, c.name, c.kind, c.start_line, c.end_line, c.content, c.metadata,
-		       f.path, f.language
+	embeddingCol := "NULL"
+	if withEmbeddings {
+		embeddingCol = "v.embedding"
+	}
+	columns := `c.name, ` + s.qualifiedCol + `, c.kind, c.start_line, c.end_line, c.content, c.metadata,
+		       f.path, f.language, ` + embeddingCol
+	var rows *sql.Rows
+	if filter.IsZero() {
+		rows, err = s.db.Query(`
+		SELECT v.chunk_id, v.distance, `+columns+`
 		FROM vec_chunks v
 		JOIN chunks c ON c.id = v.chunk_id
 		JOIN files f ON f.id = c.file_id
 		WHERE v.embedding MATCH ? AND k = ?
-		ORDER BY v.distance
-	`, blob, k)
+		ORDER BY v.distance, f.path, c.start_line, v.chunk_id
+	`, blob, min(k, maxKNN))
+	} else {
+		// A KNN query filters only after finding the nearest k, which may
+		// all be filtered out; measure the distance to each chunk the
+		// filter lets through instead, which costs the same full scan.
+		distance := "vec_distance_l2"
+		if s.metric == MetricCosine {
+			distance = "vec_distance_cosine"
+		}
+		where, args := filter.clause()
+		rows, err = s.db.Query(`
+		SELECT c.id, `+distance+`(v.embedding, ?) AS distance, `+columns+`
+		FROM chunks c
+		JOIN files f ON f.id = c.file_id
+		JOIN vec_chunks v ON v.chunk_id = c.id
+		WHERE 1 = 1`+where+`
+		ORDER BY distance, f.path, c.start_line, c.id
+		LIMIT ?
+	`, append(append([]any{blob}, args...), k)...)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -230,31 +825,54 @@ func (s *SQLiteStore) Search(queryEmbedding []float32, k int) ([]SearchResult, e
 	var results []SearchResult
 	for rows.Next() {
 		var r SearchResult
+		var blob []byte
 		err := rows.Scan(
 			&r.Chunk.ID, &r.Distance,
-			&r.Chunk.Name, &r.Chunk.Kind, &r.Chunk.StartLine, &r.Chunk.EndLine,
+			&r.Chunk.Name, &r.Chunk.QualifiedName, &r.Chunk.Kind, &r.Chunk.StartLine, &r.Chunk.EndLine,
 			&r.Chunk.Content, &r.Chunk.Metadata,
-			&r.FilePath, &r.Language,
+			&r.FilePath, &r.Language, &blob,
 		)
 		if err != nil {
 			return nil, err
 		}
+		r.Metric = s.metric
+		relevance := Relevance(r.Distance, s.metric)
+		r.Relevance = &relevance
+		if withEmbeddings {
+			if r.Embedding, err = deserializeFloat32(blob); err != nil {
+				return nil, fmt.Errorf("embedding of chunk %d: %w", r.Chunk.ID, err)
+			}
+		}
 		results = append(results, r)
 	}
 	return results, rows.Err()
 }
 
-func (s *SQLiteStore) FTSSearch(query string, k int) ([]SearchResult, error) {
+// deserializeFloat32 decodes a vector stored by sqlite_vec.SerializeFloat32:
+// packed little-endian float32s.
+func deserializeFloat32(blob []byte) ([]float32, error) {
+	if len(blob)%4 != 0 {
+		return nil, fmt.Errorf("vector blob of %d bytes is not a float32 array", len(blob))
+	}
+	v := make([]float32, len(blob)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[i*4:]))
+	}
+	return v, nil
+}
+
+func (s *SQLiteStore) FTSSearch(query string, k int, filter Filter) ([]SearchResult, error) {
+	where, args := filter.clause()
 	rows, err := s.db.Query(`
-		SELECT c.id, bm25(chunks_fts), c.name, c.kind, c.start_line, c.end_line, c.content, c.metadata,
+		SELECT c.id, bm25(chunks_fts), c.name, `+s.qualifiedCol+`, c.kind, c.start_line, c.end_line, c.content, c.metadata,
 		       f.path, f.language
 		FROM chunks_fts
 		JOIN chunks c ON c.id = chunks_fts.rowid
 		JOIN files f ON f.id = c.file_id
-		WHERE chunks_fts MATCH ?
-		ORDER BY bm25(chunks_fts)
+		WHERE chunks_fts MATCH ?`+where+`
+		ORDER BY bm25(chunks_fts), f.path, c.start_line, c.id
 		LIMIT ?
-	`, query, k)
+	`, append(append([]any{query}, args...), k)...)
 	if err != nil {
 		return nil, err
 	}
@@ -266,15 +884,49 @@ func (s *SQLiteStore) FTSSearch(query string, k int) ([]SearchResult, error) {
 		var bm25Score float64
 		err := rows.Scan(
 			&r.Chunk.ID, &bm25Score,
-			&r.Chunk.Name, &r.Chunk.Kind, &r.Chunk.StartLine, &r.Chunk.EndLine,
+			&r.Chunk.Name, &r.Chunk.QualifiedName, &r.Chunk.Kind, &r.Chunk.StartLine, &r.Chunk.EndLine,
+			&r.Chunk.Content, &r.Chunk.Metadata,
+			&r.FilePath, &r.Language,
+		)
+		if err != nil {
+			return nil, err
+		}
+		// SQLite's bm25() is negative, lower for better matches; negated,
+		// Distance is the positive BM25 score, higher for better matches.
+		r.Distance, r.Metric = -bm25Score, MetricBM25
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+func (s *SQLiteStore) FindByName(name string) ([]SearchResult, error) {
+	// A dotted name matches qualified names ending in it, so Store.Search
+	// finds pkg.Store.Search too.
+	rows, err := s.db.Query(`
+		SELECT c.id, c.name, `+s.qualifiedCol+` AS qualified, c.kind, c.start_line, c.end_line, c.content, c.metadata,
+		       f.path, f.language
+		FROM chunks c
+		JOIN files f ON f.id = c.file_id
+		WHERE c.name = ?1 OR qualified = ?1 OR substr(qualified, -length(?1) - 1) = '.' || ?1
+		ORDER BY f.path, c.start_line
+	`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		err := rows.Scan(
+			&r.Chunk.ID,
+			&r.Chunk.Name, &r.Chunk.QualifiedName, &r.Chunk.Kind, &r.Chunk.StartLine, &r.Chunk.EndLine,
 			&r.Chunk.Content, &r.Chunk.Metadata,
 			&r.FilePath, &r.Language,
 		)
 		if err != nil {
 			return nil, err
 		}
-		// BM25 returns negative scores (lower = more relevant), negate to make Distance positive (lower = better).
-		r.Distance = -bm25Score
 		results = append(results, r)
 	}
 	return results, rows.Err()
@@ -297,25 +949,478 @@ func (s *SQLiteStore) SetMeta(key, value string) error {
 	return err
 }
 
-func (s *SQLiteStore) DeleteAllChunks() error {
+func (s *SQLiteStore) ProjectRoot() (string, error) {
+	return s.GetMeta("project_root")
+}
+
+func (s *SQLiteStore) EmbeddingDim() (int, error) {
+	return s.dim, nil
+}
+
+// SetEmbeddingDim creates vec_chunks for dim-sized vectors. If the table exists
+// with another dimension it's recreated when empty (e.g. after DeleteAllChunks
+// for a model change); otherwise an error is returned, since mixing dimensions
+// would break search.
+func (s *SQLiteStore) SetEmbeddingDim(dim int) error {
+	if s.ftsOnly {
+		return ErrVecUnavailable
+	}
+	if dim <= 0 {
+		return fmt.Errorf("invalid embedding dimension %d", dim)
+	}
+	if s.dim != 0 && s.dim != dim {
+		var n int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM vec_chunks").Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("index holds %d-dim vectors but the model produces %d-dim vectors; re-index with the original model or delete the index", s.dim, dim)
+		}
+		if _, err := s.db.Exec("DROP TABLE vec_chunks"); err != nil {
+			return fmt.Errorf("drop vec_chunks: %w", err)
+		}
+		if _, err := s.db.Exec("DROP TABLE IF EXISTS vec_files"); err != nil {
+			return fmt.Errorf("drop vec_files: %w", err)
+		}
+		s.dim = 0
+	}
+	if s.dim == 0 {
+		if _, err := s.db.Exec(fmt.Sprintf(vecTableDDL, dim)); err != nil {
+			return fmt.Errorf("create vec_chunks: %w", err)
+		}
+		if _, err := s.db.Exec(fmt.Sprintf(vecFilesDDL, dim)); err != nil {
+			return fmt.Errorf("create vec_files: %w", err)
+		}
+		s.dim = dim
+		if err := s.SetMeta("distance_metric", MetricL2); err != nil {
+			return err
+		}
+		s.metric = MetricL2
+	}
+	return s.SetMeta("embedding_dim", strconv.Itoa(dim))
+}
+
+// vectorBlob serializes v for the vector tables, scaled to unit length if
+// the index normalizes embeddings.
+func (s *SQLiteStore) vectorBlob(v []float32) ([]byte, error) {
+	s.normalizeMu.Lock()
+	normalize := s.normalize
+	s.normalizeMu.Unlock()
+	if normalize {
+		v = unit(v)
+	}
+	return sqlite_vec.SerializeFloat32(v)
+}
+
+// refreshNormalize re-reads whether the index normalizes embeddings if it
+// was re-indexed since that was read, so a long-running process such as the
+// MCP server searches the way the embeddings are now stored.
+func (s *SQLiteStore) refreshNormalize() error {
+	indexed, err := s.GetMeta("last_indexed")
+	if err != nil {
+		return fmt.Errorf("get meta: %w", err)
+	}
+	s.normalizeMu.Lock()
+	defer s.normalizeMu.Unlock()
+	if indexed == s.normalizeIndexed {
+		return nil
+	}
+	normalize, err := normalizedEmbeddings(s.db)
+	if err != nil {
+		return fmt.Errorf("read normalization: %w", err)
+	}
+	s.normalize, s.normalizeIndexed = normalize, indexed
+	return nil
+}
+
+// SetNormalize records whether embeddings are normalized. Turning it on
+// rewrites the stored embeddings at unit length. Turning it off is refused
+// while normalized embeddings are stored, since their lengths are lost and
+// raw ones added next to them would rank inconsistently.
+func (s *SQLiteStore) SetNormalize(on bool) error {
+	s.normalizeMu.Lock()
+	defer s.normalizeMu.Unlock()
+	if on == s.normalize {
+		return s.SetMeta("normalize_embeddings", strconv.FormatBool(on))
+	}
+	if s.dim > 0 && s.ftsOnly {
+		return ErrVecUnavailable
+	}
+
 	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
+	switch {
+	case s.dim == 0:
+	case on:
+		for _, table := range []struct{ name, id string }{{"vec_chunks", "chunk_id"}, {"vec_files", "file_id"}} {
+			if err := normalizeTable(tx, table.name, table.id); err != nil {
+				return fmt.Errorf("normalize %s: %w", table.name, err)
+			}
+		}
+	default:
+		var stored bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM vec_chunks)").Scan(&stored); err != nil {
+			return err
+		}
+		if stored {
+			return fmt.Errorf("the index stores normalized embeddings; delete it and re-index to store them unnormalized")
+		}
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO meta (key, value) VALUES ('normalize_embeddings', ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value",
+		strconv.FormatBool(on),
+	); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.normalize = on
+	return nil
+}
+
+// normalizeTable rewrites every vector in a vec0 table at unit length, a
+// batch at a time so large indexes aren't held in memory.
+func normalizeTable(tx *sql.Tx, table, id string) error {
+	const batchSize = 1000
+	var last int64 = -1
+	for {
+		rows, err := tx.Query(fmt.Sprintf("SELECT %s, embedding FROM %s WHERE %s > ? ORDER BY %s LIMIT ?", id, table, id, id), last, batchSize)
+		if err != nil {
+			return err
+		}
+		var ids []int64
+		var vecs [][]float32
+		for rows.Next() {
+			var rowID int64
+			var blob []byte
+			if err := rows.Scan(&rowID, &blob); err != nil {
+				rows.Close()
+				return err
+			}
+			v, err := deserializeFloat32(blob)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, rowID)
+			vecs = append(vecs, v)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		for i, rowID := range ids {
+			blob, err := sqlite_vec.SerializeFloat32(unit(vecs[i]))
+			if err != nil {
+				return err
+			}
+			// vec0 tables don't support upserts.
+			if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s = ?", table, id), rowID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (%s, embedding) VALUES (?, ?)", table, id), rowID, blob); err != nil {
+				return err
+			}
+		}
+		last = ids[len(ids)-1]
+	}
+}
+
+// Tokenizer returns the tokenizer recorded in meta, falling back to the one
+// in the chunks_fts definition for indexes that predate the meta key.
+func (s *SQLiteStore) Tokenizer() (string, error) {
+	tok, err := s.GetMeta("fts_tokenizer")
+	if err != nil || tok != "" {
+		return tok, err
+	}
+	return ftsTokenizer(s.db)
+}
+
+// SetTokenizer recreates chunks_fts with tokenizer and re-populates it from
+// chunks when the current tokenizer differs. Embeddings are unaffected.
+func (s *SQLiteStore) SetTokenizer(tokenizer string) (bool, error) {
+	tokenizer = strings.Join(strings.Fields(tokenizer), " ")
+	if tokenizer == "" || strings.ContainsAny(tokenizer, "'\"") {
+		return false, fmt.Errorf("invalid tokenizer %q", tokenizer)
+	}
+	current, err := ftsTokenizer(s.db)
+	if err != nil {
+		return false, fmt.Errorf("read tokenizer: %w", err)
+	}
+	if current == tokenizer {
+		return false, s.SetMeta("fts_tokenizer", tokenizer)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DROP TABLE chunks_fts"); err != nil {
+		return false, fmt.Errorf("drop chunks_fts: %w", err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf(ftsTableDDL, tokenizer)); err != nil {
+		return false, fmt.Errorf("create chunks_fts with tokenizer %q: %w", tokenizer, err)
+	}
+	if _, err := tx.Exec("INSERT INTO chunks_fts(chunks_fts) VALUES('rebuild')"); err != nil {
+		return false, fmt.Errorf("rebuild chunks_fts: %w", err)
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO meta (key, value) VALUES ('fts_tokenizer', ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value",
+		tokenizer,
+	); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
 
-	if _, err := tx.Exec("DELETE FROM vec_chunks"); err != nil {
+func (s *SQLiteStore) SetFileEmbedding(path string, embedding []float32) error {
+	if s.dim == 0 {
+		return fmt.Errorf("embedding dimension not configured")
+	}
+	if len(embedding) != s.dim {
+		return fmt.Errorf("summary embedding for %s has %d dimensions, index expects %d", path, len(embedding), s.dim)
+	}
+	var fileID int64
+	if err := s.db.QueryRow("SELECT id FROM files WHERE path = ?", path).Scan(&fileID); err != nil {
+		return fmt.Errorf("find file %s: %w", path, err)
+	}
+	blob, err := s.vectorBlob(embedding)
+	if err != nil {
+		return fmt.Errorf("serialize embedding for %s: %w", path, err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// vec0 tables don't support upserts.
+	if _, err := tx.Exec("DELETE FROM vec_files WHERE file_id = ?", fileID); err != nil {
 		return err
 	}
+	if _, err := tx.Exec("INSERT INTO vec_files (file_id, embedding) VALUES (?, ?)", fileID, blob); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) FilesWithoutEmbedding() ([]FileSummary, error) {
+	if s.dim == 0 {
+		return nil, nil
+	}
+	rows, err := s.db.Query(`
+		SELECT f.path, f.language, f.summary
+		FROM files f
+		WHERE f.summary != '' AND f.id NOT IN (SELECT file_id FROM vec_files)
+		ORDER BY f.path
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []FileSummary
+	for rows.Next() {
+		var f FileSummary
+		if err := rows.Scan(&f.Path, &f.Language, &f.Summary); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+func (s *SQLiteStore) RelatedFiles(path string, k int) ([]RelatedFile, error) {
+	if s.ftsOnly {
+		return nil, ErrVecUnavailable
+	}
+	if s.dim == 0 {
+		return nil, nil
+	}
+	if s.noVecFiles {
+		return nil, errors.New("the index was built before related files were supported; re-index with 'synapse index' to enable them")
+	}
+	var fileID int64
+	err := s.db.QueryRow("SELECT id FROM files WHERE path = ?", path).Scan(&fileID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("file %q is not indexed", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var blob []byte
+	err = s.db.QueryRow("SELECT embedding FROM vec_files WHERE file_id = ?", fileID).Scan(&blob)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("file %q has no summary embedding yet; run 'synapse summarize'", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch one extra neighbour since the file itself is the closest.
+	rows, err := s.db.Query(`
+		SELECT v.distance, f.path, f.language, f.summary
+		FROM vec_files v
+		JOIN files f ON f.id = v.file_id
+		WHERE v.embedding MATCH ? AND k = ? AND v.file_id != ?
+		ORDER BY v.distance, f.path
+	`, blob, min(k+1, maxKNN), fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var related []RelatedFile
+	for rows.Next() {
+		var r RelatedFile
+		if err := rows.Scan(&r.Distance, &r.Path, &r.Language, &r.Summary); err != nil {
+			return nil, err
+		}
+		r.Relevance = Relevance(r.Distance, s.metric)
+		related = append(related, r)
+	}
+	if len(related) > k {
+		related = related[:k]
+	}
+	return related, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteAllChunks() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if s.dim > 0 {
+		if _, err := tx.Exec("DELETE FROM vec_chunks"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM vec_files"); err != nil {
+			return err
+		}
+	}
 	if _, err := tx.Exec("DELETE FROM chunks"); err != nil {
 		return err
 	}
 	if _, err := tx.Exec("DELETE FROM files"); err != nil {
 		return err
 	}
+	if _, err := tx.Exec("DELETE FROM dir_summaries"); err != nil {
+		return err
+	}
 	return tx.Commit()
 }
 
+// Backup uses VACUUM INTO, which produces a consistent snapshot including
+// changes still in the WAL. An existing file at dest is replaced.
+func (s *SQLiteStore) Backup(dest string) error {
+	if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	_, err := s.db.Exec("VACUUM INTO ?", dest)
+	return err
+}
+
+// Restore replaces the database at dbPath with a copy of the one at src,
+// such as a file written by Backup. The current database's write-ahead log
+// is checkpointed and removed first, so SQLite doesn't apply it to the
+// restored file. It fails if another connection is using the database.
+func Restore(dbPath, src string) error {
+	if _, err := os.Stat(dbPath); err == nil {
+		db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL")
+		if err != nil {
+			return fmt.Errorf("open db: %w", err)
+		}
+		var busy, logFrames, checkpointed int
+		err = db.QueryRow("PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &logFrames, &checkpointed)
+		db.Close()
+		if err != nil {
+			return fmt.Errorf("checkpoint wal: %w", err)
+		}
+		if busy != 0 {
+			return fmt.Errorf("%s is in use; stop other synapse processes using it and try again", dbPath)
+		}
+	}
+	for _, p := range []string{dbPath + "-wal", dbPath + "-shm"} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := dbPath + ".restore"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dbPath)
+}
+
+// Optimize merges the FTS5 index segments, rebuilds the database file to
+// reclaim space left by deletes, and truncates the WAL. sqlite-vec has no
+// separate maintenance step; its shadow tables are compacted by VACUUM.
+func (s *SQLiteStore) Optimize() error {
+	if _, err := s.db.Exec("INSERT INTO chunks_fts(chunks_fts) VALUES('optimize')"); err != nil {
+		return fmt.Errorf("optimize fts: %w", err)
+	}
+	if _, err := s.db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("vacuum: %w", err)
+	}
+	if _, err := s.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("checkpoint wal: %w", err)
+	}
+	return nil
+}
+
+// VerifyFTS runs FTS5's integrity check, including the comparison against
+// the chunks table, and returns an error describing any inconsistency.
+func (s *SQLiteStore) VerifyFTS() error {
+	if _, err := s.db.Exec("INSERT INTO chunks_fts(chunks_fts, rank) VALUES('integrity-check', 1)"); err != nil {
+		return fmt.Errorf("full-text index is inconsistent with chunks: %w", err)
+	}
+	return nil
+}
+
+// RepairFTS rebuilds chunks_fts from the chunks table.
+func (s *SQLiteStore) RepairFTS() error {
+	if _, err := s.db.Exec("INSERT INTO chunks_fts(chunks_fts) VALUES('rebuild')"); err != nil {
+		return fmt.Errorf("rebuild fts: %w", err)
+	}
+	return nil
+}
+
+// ftsInSync reports whether chunks_fts indexes as many rows as chunks holds.
+func (s *SQLiteStore) ftsInSync() (bool, error) {
+	var chunks, indexed int
+	err := s.db.QueryRow("SELECT (SELECT COUNT(*) FROM chunks), (SELECT COUNT(*) FROM chunks_fts_docsize)").Scan(&chunks, &indexed)
+	if err != nil {
+		return false, err
+	}
+	return chunks == indexed, nil
+}
+
 func (s *SQLiteStore) ListFiles() ([]FileSummary, error) {
 	rows, err := s.db.Query(`
 		SELECT f.path, f.language, COUNT(c.id) AS chunk_count, f.summary
@@ -391,8 +1496,42 @@ func (s *SQLiteStore) GetAllFileContent(path string) (string, error) {
 	return b.String(), rows.Err()
 }
 
+func (s *SQLiteStore) GetFileSummary(path string) (string, error) {
+	var summary string
+	err := s.db.QueryRow("SELECT summary FROM files WHERE path = ?", path).Scan(&summary)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return summary, err
+}
+
+// SetFileSummary also drops the file's summary embedding, which no longer
+// matches; it's recomputed on the next Summarize.
 func (s *SQLiteStore) SetFileSummary(path string, summary string) error {
-	_, err := s.db.Exec("UPDATE files SET summary = ? WHERE path = ?", summary, path)
+	if _, err := s.db.Exec("UPDATE files SET summary = ? WHERE path = ?", summary, path); err != nil {
+		return err
+	}
+	if s.dim == 0 {
+		return nil
+	}
+	_, err := s.db.Exec("DELETE FROM vec_files WHERE file_id = (SELECT id FROM files WHERE path = ?)", path)
+	return err
+}
+
+func (s *SQLiteStore) DirSummary(dir string) (string, string, error) {
+	var summary, inputHash string
+	err := s.db.QueryRow("SELECT summary, input_hash FROM dir_summaries WHERE dir = ?", dir).Scan(&summary, &inputHash)
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
+	return summary, inputHash, err
+}
+
+func (s *SQLiteStore) SetDirSummary(dir, inputHash, summary string) error {
+	_, err := s.db.Exec(
+		"INSERT INTO dir_summaries (dir, input_hash, summary) VALUES (?, ?, ?) ON CONFLICT(dir) DO UPDATE SET input_hash = excluded.input_hash, summary = excluded.summary",
+		dir, inputHash, summary,
+	)
 	return err
 }
 
@@ -0,0 +1,336 @@
+//go:build sqlite_fts5
+
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestStore opens a new index in a temporary directory, ready for
+// dim-sized embeddings.
+func openTestStore(t *testing.T, dim int) *SQLiteStore {
+	t.Helper()
+	st, err := Open(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	if err := st.SetEmbeddingDim(dim); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// ftsNames returns the names of the chunks a keyword search for query finds.
+func ftsNames(t *testing.T, st *SQLiteStore, query string) []string {
+	t.Helper()
+	results, err := st.FTSSearch(query, 10, Filter{})
+	if err != nil {
+		t.Fatalf("search %q: %v", query, err)
+	}
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Chunk.Name)
+	}
+	return names
+}
+
+func TestReplaceFileChunksRename(t *testing.T) {
+	st := openTestStore(t, 3)
+	file := FileRecord{Path: "a.go", Hash: "1", Language: "Go"}
+	doc := "// Does the work, carefully."
+	chunks := []Chunk{
+		{Name: "Alpha", Kind: "doc", StartLine: 1, EndLine: 1, Content: doc},
+		{Name: "Run", QualifiedName: "Old.Run", Kind: "method_declaration", StartLine: 3, EndLine: 5, Content: "func (Old) Run() {}"},
+	}
+	emb := [][]float32{{1, 0, 0}, {0, 1, 0}}
+	if _, _, err := st.ReplaceFileChunks(file, chunks, emb); err != nil {
+		t.Fatal(err)
+	}
+
+	// Alpha is renamed Beta with its doc comment unchanged, and Run moves
+	// to another type with its content unchanged.
+	file.Hash = "2"
+	chunks[0].Name = "Beta"
+	chunks[1].QualifiedName = "New.Run"
+	_, reused, err := st.ReplaceFileChunks(file, chunks, [][]float32{{1, 0, 0}, nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reused != 1 {
+		t.Errorf("reused %d chunks, want 1 (only Run keeps its row)", reused)
+	}
+
+	if err := st.VerifyFTS(); err != nil {
+		t.Fatalf("doctor check after rename: %v", err)
+	}
+	if names := ftsNames(t, st, "Beta"); len(names) != 1 || names[0] != "Beta" {
+		t.Errorf("search Beta = %v, want [Beta]", names)
+	}
+	if names := ftsNames(t, st, "Alpha"); len(names) != 0 {
+		t.Errorf("search Alpha = %v, want nothing", names)
+	}
+	if names := ftsNames(t, st, "name_words:new"); len(names) != 1 || names[0] != "Run" {
+		t.Errorf("search name_words:new = %v, want [Run]", names)
+	}
+	if names := ftsNames(t, st, "name_words:old"); len(names) != 0 {
+		t.Errorf("search name_words:old = %v, want nothing", names)
+	}
+}
+
+func TestDegenerateEmbeddings(t *testing.T) {
+	st := openTestStore(t, 3)
+	nan, inf := float32(math.NaN()), float32(math.Inf(1))
+	chunks := []Chunk{
+		{Name: "zeroChunk", Kind: "function", StartLine: 1, EndLine: 1, Content: "zero vector"},
+		{Name: "nanChunk", Kind: "function", StartLine: 2, EndLine: 2, Content: "nan vector"},
+		{Name: "infChunk", Kind: "function", StartLine: 3, EndLine: 3, Content: "inf vector"},
+		{Name: "firstTwin", Kind: "function", StartLine: 4, EndLine: 4, Content: "twin one"},
+		{Name: "secondTwin", Kind: "function", StartLine: 5, EndLine: 5, Content: "twin two"},
+	}
+	emb := [][]float32{{0, 0, 0}, {nan, 1, 0}, {0, inf, 0}, {0, 0, 1}, {0, 0, 1}}
+	if _, _, err := st.ReplaceFileChunks(FileRecord{Path: "a.go", Hash: "1", Language: "Go"}, chunks, emb); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the twins have vectors, and identical ones are both found.
+	results, err := st.Search([]float32{0, 0, 1}, 10, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Chunk.Name)
+		if math.IsNaN(r.Distance) || math.IsInf(r.Distance, 0) {
+			t.Errorf("%s has distance %v", r.Chunk.Name, r.Distance)
+		}
+	}
+	if len(names) != 2 || !slices.Contains(names, "firstTwin") || !slices.Contains(names, "secondTwin") {
+		t.Errorf("vector search = %v, want both twins only", names)
+	}
+
+	// Chunks without vectors are still found by keyword.
+	for _, query := range []string{"zero", "nan", "inf"} {
+		if names := ftsNames(t, st, query); len(names) != 1 {
+			t.Errorf("search %q = %v, want one chunk", query, names)
+		}
+	}
+
+	// InsertEmbeddings skips them the same way.
+	ids, err := st.InsertChunks(1, []Chunk{{Name: "later", Kind: "function", StartLine: 6, EndLine: 6, Content: "later zero"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.InsertEmbeddings(ids, [][]float32{{0, 0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := st.Search([]float32{0, 0, 1}, 10, Filter{}); err != nil || len(results) != 2 {
+		t.Errorf("vector search after InsertEmbeddings = %d results, %v; want 2", len(results), err)
+	}
+}
+
+func TestRelatedFilesBeforeVecFiles(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "index.db")
+	st, err := Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetEmbeddingDim(3); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := st.ReplaceFileChunks(FileRecord{Path: "a.go", Hash: "1", Language: "Go"}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Indexes created before file summary embeddings have no vec_files.
+	if _, err := st.db.Exec("DROP TABLE vec_files"); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	ro, err := OpenReadOnly(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	_, err = ro.RelatedFiles("a.go", 5)
+	if err == nil || !strings.Contains(err.Error(), "re-index") {
+		t.Errorf("RelatedFiles = %v, want an error saying to re-index", err)
+	}
+}
+
+func TestSearchMetric(t *testing.T) {
+	st := openTestStore(t, 3)
+	chunks := []Chunk{{Name: "Backup", Kind: "function", StartLine: 1, EndLine: 1, Content: "backup the database"}}
+	if _, _, err := st.ReplaceFileChunks(FileRecord{Path: "a.go", Hash: "1", Language: "Go"}, chunks, [][]float32{{1, 0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	keyword, err := st.FTSSearch("backup", 1, Filter{})
+	if err != nil || len(keyword) != 1 {
+		t.Fatalf("keyword search = %v, %v", keyword, err)
+	}
+	if keyword[0].Metric != MetricBM25 || keyword[0].Distance <= 0 {
+		t.Errorf("keyword match has %s distance %v, want a positive bm25 score", keyword[0].Metric, keyword[0].Distance)
+	}
+	vector, err := st.Search([]float32{1, 0, 0}, 1, Filter{})
+	if err != nil || len(vector) != 1 {
+		t.Fatalf("vector search = %v, %v", vector, err)
+	}
+	if vector[0].Metric != MetricL2 {
+		t.Errorf("vector match has metric %q, want %q", vector[0].Metric, MetricL2)
+	}
+}
+
+func TestListStaleFilesModTime(t *testing.T) {
+	st := openTestStore(t, 3)
+	root := t.TempDir()
+	path := filepath.Join(root, "a.go")
+	if err := os.WriteFile(path, []byte("package a // v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.Sum256([]byte("package a // v1"))
+	rec := FileRecord{Path: "a.go", Hash: hex.EncodeToString(h[:]), Language: "Go", SizeBytes: info.Size(), ModTime: info.ModTime()}
+	if _, _, err := st.ReplaceFileChunks(rec, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err := st.ListStaleFiles(root)
+	if err != nil || stale.Count() != 0 {
+		t.Fatalf("untouched file: stale %+v, %v; want none", stale, err)
+	}
+
+	// Content of the same size restored with an older timestamp, as from
+	// a backup, predates the index but still changed.
+	if err := os.WriteFile(path, []byte("package a // v2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := info.ModTime().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	stale, err = st.ListStaleFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(stale.Changed, []string{"a.go"}) {
+		t.Errorf("restored file: changed %v, want [a.go]", stale.Changed)
+	}
+}
+
+// TestSearchFilterBeyondNeighbours checks that a filtered vector search finds
+// matching chunks even when many closer ones are filtered out.
+func TestSearchFilterBeyondNeighbours(t *testing.T) {
+	st := openTestStore(t, 2)
+	var near []Chunk
+	var nearEmb [][]float32
+	for i := range 50 {
+		near = append(near, Chunk{Name: fmt.Sprintf("near%d", i), Kind: "function", StartLine: i*2 + 1, EndLine: i*2 + 1, Content: fmt.Sprintf("near %d", i)})
+		nearEmb = append(nearEmb, []float32{1, 0})
+	}
+	if _, _, err := st.ReplaceFileChunks(FileRecord{Path: "a.go", Hash: "1", Language: "Go"}, near, nearEmb); err != nil {
+		t.Fatal(err)
+	}
+	far := []Chunk{
+		{Name: "far1", Kind: "function", StartLine: 1, EndLine: 1, Content: "far 1"},
+		{Name: "far2", Kind: "function", StartLine: 3, EndLine: 3, Content: "far 2"},
+	}
+	if _, _, err := st.ReplaceFileChunks(FileRecord{Path: "sub/b.go", Hash: "2", Language: "Go"}, far, [][]float32{{0, 1}, {0.1, 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := st.Search([]float32{1, 0}, 2, Filter{PathPrefix: "sub/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Chunk.Name)
+	}
+	if !slices.Equal(names, []string{"far2", "far1"}) {
+		t.Fatalf("filtered search found %v, want [far2 far1]", names)
+	}
+	if results[0].Metric != MetricL2 || results[0].Relevance == nil {
+		t.Errorf("filtered match has metric %q and relevance %v, want l2 and a relevance", results[0].Metric, results[0].Relevance)
+	}
+}
+
+// TestSetNormalize checks that turning normalization on rewrites the stored
+// embeddings at unit length and persists, that turning it off is refused
+// once they're stored, and that a reader opened before the change follows
+// it after the next index run.
+func TestSetNormalize(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "index.db")
+	st, err := Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if err := st.SetEmbeddingDim(2); err != nil {
+		t.Fatal(err)
+	}
+	chunks := []Chunk{{Name: "run", Kind: "function", StartLine: 1, EndLine: 1, Content: "func run() {}"}}
+	if _, _, err := st.ReplaceFileChunks(FileRecord{Path: "a.go", Hash: "1", Language: "Go"}, chunks, [][]float32{{3, 4}}); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := OpenReadOnly(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	distance := func(s *SQLiteStore, query []float32) float64 {
+		t.Helper()
+		results, err := s.Search(query, 1, Filter{})
+		if err != nil || len(results) != 1 {
+			t.Fatalf("search = %v, %v", results, err)
+		}
+		return results[0].Distance
+	}
+	if d := distance(reader, []float32{0.6, 0.8}); math.Abs(d-4) > 1e-5 {
+		t.Fatalf("distance before normalizing = %g, want 4", d)
+	}
+
+	if err := st.SetNormalize(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetMeta("last_indexed", "after"); err != nil {
+		t.Fatal(err)
+	}
+	// Both the stored vector and the query are scaled to unit length.
+	if d := distance(st, []float32{6, 8}); d > 1e-5 {
+		t.Errorf("distance after normalizing = %g, want 0", d)
+	}
+	if d := distance(reader, []float32{6, 8}); d > 1e-5 {
+		t.Errorf("distance in a reader opened before normalizing = %g, want 0", d)
+	}
+	reopened, err := OpenReadOnly(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if d := distance(reopened, []float32{6, 8}); d > 1e-5 {
+		t.Errorf("distance after reopening = %g, want 0", d)
+	}
+
+	if err := st.SetNormalize(false); err == nil {
+		t.Error("SetNormalize(false) succeeded with normalized embeddings stored")
+	}
+	empty := openTestStore(t, 2)
+	if err := empty.SetNormalize(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := empty.SetNormalize(false); err != nil {
+		t.Errorf("SetNormalize(false) with nothing stored: %v", err)
+	}
+}
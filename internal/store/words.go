@@ -0,0 +1,45 @@
+package store
+
+import (
+	"strings"
+	"unicode"
+)
+
+// nameWords splits a compound identifier into its lowercase words, joined by
+// spaces: "HybridRetrieve" and "hybrid_retrieve" give "hybrid retrieve", and
+// "HTTPServer" gives "http server". Dots and other punctuation separate
+// words too, so "Store.FTSSearch" gives "store fts search". Names that are
+// a single word give "", as the name column already matches them.
+func nameWords(name string) string {
+	var words []string
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words = append(words, splitCase(part)...)
+	}
+	if len(words) < 2 {
+		return ""
+	}
+	return strings.ToLower(strings.Join(words, " "))
+}
+
+// splitCase splits s at its camelCase boundaries: before an upper-case
+// letter that follows a lower-case letter or digit, and before the last
+// letter of an upper-case run followed by a lower-case letter.
+func splitCase(s string) []string {
+	rs := []rune(s)
+	var words []string
+	start := 0
+	for i := 1; i < len(rs); i++ {
+		if !unicode.IsUpper(rs[i]) {
+			continue
+		}
+		prev := rs[i-1]
+		acronymEnd := unicode.IsUpper(prev) && i+1 < len(rs) && unicode.IsLower(rs[i+1])
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) || acronymEnd {
+			words = append(words, string(rs[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(rs[start:]))
+}
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -32,29 +33,51 @@ type chatModel struct {
 	renderer    *glamour.TermRenderer
 	messages    []chatMessage
 	history     []llm.Message
-	st          store.Store
-	emb         *embedder.OllamaEmbedder
-	chat        *llm.OllamaChat
+	retriever   *rag.Retriever
+	chat        llm.Chat
+	newChat     func(model string) llm.Chat // for /retry <model>
 	overview    string
+	noRAG       bool
+	showThink   bool       // show reasoning models' <think> blocks
+	lastAsked   string     // the last question, for /retry
+	lastMode    answerMode // how lastAsked was answered
 	state       chatState
-	k           int
+	cancel      context.CancelFunc // cancels the in-flight question, if any
+	asked       int                // numbers questions; see answerMsg
 	width       int
 	height      int
 	initialized bool
 }
 
 type chatMessage struct {
-	role    string
+	role string
+	// content is an assistant's answer as the model gave it, with any
+	// reasoning, so /show-thinking can show it after the fact.
 	content string
 }
 
-// answerMsg is sent when a RAG query completes.
+// answerMode selects where the context for an answer comes from.
+type answerMode int
+
+const (
+	answerRetrieve answerMode = iota // retrieved chunks
+	answerOverview                   // project overview and file summaries
+	answerPlain                      // no codebase context
+)
+
+// answerMsg is sent when a RAG query completes. id is the question's
+// number, so answers to questions cancelled with Esc, which can still
+// arrive, are told apart from the current question's. When the retrieved
+// chunks didn't all fit the model's context, used of retrieved were sent.
 type answerMsg struct {
-	answer string
-	err    error
+	id        int
+	answer    string
+	used      int
+	retrieved int
+	err       error
 }
 
-func newChatModel(st store.Store, ollamaURL, embedModel, chatModelName, overview string, k int) chatModel {
+func newChatModel(st store.Store, cfg Config, overview string, k int) chatModel {
 	sp := spinner.New()
 	sp.Spinner = spinner.Dot
 	sp.Style = selectedStyle
@@ -64,15 +87,27 @@ func newChatModel(st store.Store, ollamaURL, embedModel, chatModelName, overview
 	ti.CharLimit = 2000
 	ti.Focus()
 
+	emb := embedder.NewOllamaEmbedder(cfg.OllamaURL, cfg.Model)
+	emb.KeepAlive = cfg.KeepAlive
+	emb.QueryTimeout = cfg.QueryTimeout
+	newChat := func(model string) llm.Chat {
+		chat := llm.NewOllamaChat(cfg.OllamaURL, model)
+		chat.KeepAlive = cfg.KeepAlive
+		return chat
+	}
+	retriever := rag.NewRetriever(st, emb, rag.Options{K: k, Weighting: cfg.Weighting, Recency: cfg.Recency})
+	if cfg.CacheSize > 0 {
+		retriever.Cache = rag.NewCache(cfg.CacheSize, rag.DefaultCacheTTL)
+	}
+
 	return chatModel{
-		spinner:  sp,
-		input:    ti,
-		st:       st,
-		emb:      embedder.NewOllamaEmbedder(ollamaURL, embedModel),
-		chat:     llm.NewOllamaChat(ollamaURL, chatModelName),
-		overview: overview,
-		k:        k,
-		state:    chatIdle,
+		spinner:   sp,
+		input:     ti,
+		retriever: retriever,
+		chat:      newChat(cfg.ChatModel),
+		newChat:   newChat,
+		overview:  overview,
+		state:     chatIdle,
 	}
 }
 
@@ -102,21 +137,64 @@ func (m *chatModel) initViewport(width, height int) {
 	m.initialized = true
 }
 
-func askQuestion(question string, st store.Store, emb *embedder.OllamaEmbedder, chat *llm.OllamaChat, history []llm.Message, overview string, k int) tea.Cmd {
+// askQuestion answers question number id in the background.
+func askQuestion(ctx context.Context, id int, question string, mode answerMode, retriever *rag.Retriever, chat llm.Chat, history []llm.Message, overview string) tea.Cmd {
 	return func() tea.Msg {
-		chunks, err := rag.HybridRetrieve(question, st, emb, k)
+		msg := answerQuestion(ctx, question, mode, retriever, chat, history, overview)
+		msg.id = id
+		return msg
+	}
+}
+
+// answerQuestion retrieves context for question as mode says and asks chat.
+func answerQuestion(ctx context.Context, question string, mode answerMode, retriever *rag.Retriever, chat llm.Chat, history []llm.Message, overview string) answerMsg {
+	var msgs []llm.Message
+	var cited []store.SearchResult
+	var build func([]store.SearchResult) []llm.Message
+	switch mode {
+	case answerPlain:
+		msgs = rag.BuildPlainMessages(history, question)
+	case answerOverview:
+		files, err := retriever.Store.ListFiles()
 		if err != nil {
-			return answerMsg{err: fmt.Errorf("retrieval error: %w", err)}
+			return answerMsg{err: fmt.Errorf("list files: %w", err)}
 		}
-
-		msgs := rag.BuildMessages(chunks, history, question, overview)
-		answer, err := chat.Generate(msgs)
+		msgs = rag.BuildOverviewMessages(files, history, question, overview, rag.DefaultContextBudget)
+	default:
+		chunks, err := retriever.Retrieve(question)
 		if err != nil {
-			return answerMsg{err: fmt.Errorf("generation error: %w", err)}
+			return answerMsg{err: fmt.Errorf("retrieval error: %w", err)}
 		}
+		chunks, _ = rag.TrimToBudget(chunks, rag.DefaultContextBudget)
+		cited = chunks
+		build = func(subset []store.SearchResult) []llm.Message {
+			return rag.BuildMessages(subset, nil, history, question, overview)
+		}
+	}
+	if ctx.Err() != nil {
+		return answerMsg{err: ctx.Err()}
+	}
 
-		return answerMsg{answer: answer}
+	generate := func(msgs []llm.Message) (string, error) {
+		return chat.GenerateContext(ctx, msgs)
+	}
+	var answer string
+	var used int
+	var err error
+	if build != nil {
+		// Retry with fewer chunks if they don't fit the model's context.
+		answer, used, err = rag.GenerateFitting(cited, build, generate)
+	} else {
+		answer, err = generate(msgs)
 	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return answerMsg{err: ctx.Err()}
+		}
+		return answerMsg{err: fmt.Errorf("generation error: %w", err)}
+	}
+
+	return answerMsg{answer: answer, used: used, retrieved: len(cited)}
 }
 
 func (m chatModel) Update(msg tea.Msg) (chatModel, tea.Cmd) {
@@ -130,12 +208,23 @@ func (m chatModel) Update(msg tea.Msg) (chatModel, tea.Cmd) {
 		return m, nil
 
 	case answerMsg:
+		// The question was cancelled with Esc, and the model is idle or
+		// already answering the next one.
+		if msg.id != m.asked {
+			return m, nil
+		}
 		m.state = chatIdle
+		m.cancel = nil
 		if msg.err != nil {
 			m.messages = append(m.messages, chatMessage{role: "error", content: msg.err.Error()})
+			// Drop the unanswered question so history stays paired.
+			m.history = m.history[:len(m.history)-1]
 		} else {
+			if msg.used < msg.retrieved {
+				m.messages = append(m.messages, chatMessage{role: "system", content: fmt.Sprintf("[Context reduced to %d of %d chunks to fit the model's context window]", msg.used, msg.retrieved)})
+			}
 			m.messages = append(m.messages, chatMessage{role: "assistant", content: msg.answer})
-			m.history = append(m.history, llm.Message{Role: "assistant", Content: msg.answer})
+			m.history = append(m.history, llm.Message{Role: "assistant", Content: llm.StripThinking(msg.answer)})
 			if len(m.history) > 20 {
 				m.history = m.history[len(m.history)-20:]
 			}
@@ -157,6 +246,15 @@ func (m chatModel) Update(msg tea.Msg) (chatModel, tea.Cmd) {
 
 	case tea.KeyMsg:
 		if m.state != chatIdle {
+			if msg.Type == tea.KeyEsc && m.cancel != nil {
+				m.cancel()
+				m.cancel = nil
+				m.asked++
+				m.state = chatIdle
+				// Drop the unanswered question so history stays paired.
+				m.history = m.history[:len(m.history)-1]
+				return m.systemNote("Cancelled."), nil
+			}
 			return m, nil
 		}
 		switch msg.Type {
@@ -167,7 +265,16 @@ func (m chatModel) Update(msg tea.Msg) (chatModel, tea.Cmd) {
 			}
 			m.input.Reset()
 
-			switch question {
+			mode := answerRetrieve
+			if m.noRAG {
+				mode = answerPlain
+			} else if rag.IsMetaQuestion(question) {
+				mode = answerOverview
+			}
+
+			command, arg, _ := strings.Cut(question, " ")
+			arg = strings.TrimSpace(arg)
+			switch command {
 			case "/exit", "/quit":
 				return m, tea.Quit
 			case "/clear":
@@ -176,12 +283,54 @@ func (m chatModel) Update(msg tea.Msg) (chatModel, tea.Cmd) {
 				m.viewport.SetContent(dimStyle.Render("Conversation cleared."))
 				return m, nil
 			case "/help":
-				helpText := "Commands:\n  /clear  - clear conversation history\n  /exit   - quit\n  /help   - show this help"
-				m.messages = append(m.messages, chatMessage{role: "system", content: helpText})
-				m.viewport.SetContent(m.renderMessages())
-				m.viewport.GotoBottom()
-				return m, nil
+				return m.systemNote(chatHelp), nil
+			case "/focus", "/exclude":
+				if arg == "" {
+					return m.systemNote(fmt.Sprintf("Usage: %s <path-glob>", command)), nil
+				}
+				f := &m.retriever.Options.Filter
+				if command == "/focus" {
+					f.Include = append(f.Include, arg)
+				} else {
+					f.Exclude = append(f.Exclude, arg)
+				}
+				return m.systemNote("Active filters: " + f.String()), nil
+			case "/clear-filters":
+				m.retriever.Options.Filter = store.Filter{}
+				return m.systemNote("Filters cleared."), nil
+			case "/no-rag":
+				m.noRAG = !m.noRAG
+				if m.noRAG {
+					return m.systemNote("Retrieval off: questions go to the model without codebase context."), nil
+				}
+				return m.systemNote("Retrieval on."), nil
+			case "/show-thinking":
+				m.showThink = !m.showThink
+				if m.showThink {
+					return m.systemNote("Showing the model's reasoning."), nil
+				}
+				return m.systemNote("Hiding the model's reasoning."), nil
+			case "/overview":
+				question = arg
+				if question == "" {
+					question = defaultOverviewQuestion
+				}
+				mode = answerOverview
+			case "/retry":
+				if m.lastAsked == "" {
+					return m.systemNote("Nothing to retry yet."), nil
+				}
+				note := "Retrying the last question."
+				if arg != "" {
+					m.chat = m.newChat(arg)
+					note = fmt.Sprintf("Retrying the last question with %s, used from now on.", arg)
+				}
+				m = m.systemNote(note)
+				question, mode = m.lastAsked, m.lastMode
+				// The new answer replaces the last one in the history.
+				m.history = rag.DropLastTurn(m.history, question)
 			}
+			m.lastAsked, m.lastMode = question, mode
 
 			m.messages = append(m.messages, chatMessage{role: "user", content: question})
 			m.history = append(m.history, llm.Message{Role: "user", Content: question})
@@ -189,9 +338,12 @@ func (m chatModel) Update(msg tea.Msg) (chatModel, tea.Cmd) {
 			m.viewport.SetContent(m.renderMessages())
 			m.viewport.GotoBottom()
 
+			ctx, cancel := context.WithCancel(context.Background())
+			m.cancel = cancel
+			m.asked++
 			return m, tea.Batch(
 				m.spinner.Tick,
-				askQuestion(question, m.st, m.emb, m.chat, m.history[:len(m.history)-1], m.overview, m.k),
+				askQuestion(ctx, m.asked, question, mode, m.retriever, m.chat, m.history[:len(m.history)-1], m.overview),
 			)
 		}
 	}
@@ -211,6 +363,31 @@ func (m chatModel) Update(msg tea.Msg) (chatModel, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// defaultOverviewQuestion is asked by /overview without an argument.
+const defaultOverviewQuestion = "Summarize what this project does."
+
+const chatHelp = `Commands:
+  /overview [q]    - answer from the project overview and file summaries
+  /no-rag          - toggle retrieval off for a plain conversation with the model
+  /retry [model]   - ask the last question again, optionally switching to another chat model
+  /show-thinking   - toggle showing reasoning models' <think> blocks
+  /focus <glob>    - only retrieve from matching paths (e.g. /focus internal/auth)
+  /exclude <glob>  - never retrieve from matching paths (e.g. /exclude *_test.go)
+  /clear-filters   - remove all focus/exclude filters
+  /clear           - clear conversation history
+  /exit            - quit
+  /help            - show this help
+
+Press Esc while an answer is being generated to cancel it.`
+
+// systemNote appends an informational message to the transcript.
+func (m chatModel) systemNote(text string) chatModel {
+	m.messages = append(m.messages, chatMessage{role: "system", content: text})
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+	return m
+}
+
 func (m chatModel) renderMarkdown(content string) string {
 	if m.renderer == nil {
 		return assistantMsgStyle.Render(content)
@@ -229,7 +406,11 @@ func (m chatModel) renderMessages() string {
 		case "user":
 			sb.WriteString(userMsgStyle.Render("You: ") + msg.content + "\n\n")
 		case "assistant":
-			sb.WriteString(m.renderMarkdown(msg.content) + "\n\n")
+			thinking, answer := llm.SplitThinking(msg.content)
+			if m.showThink && thinking != "" {
+				sb.WriteString(dimStyle.Render(thinking) + "\n\n")
+			}
+			sb.WriteString(m.renderMarkdown(answer) + "\n\n")
 		case "error":
 			sb.WriteString(errorStyle.Render("Error: "+msg.content) + "\n\n")
 		case "system":
@@ -256,13 +437,23 @@ func (m chatModel) View(width, height int) string {
 	statusText := "idle"
 	switch m.state {
 	case chatSearching:
-		statusText = "searching..."
+		statusText = "searching... (esc to cancel)"
 	case chatGenerating:
-		statusText = "generating..."
+		statusText = "generating... (esc to cancel)"
+	}
+	status := fmt.Sprintf(" synapse chat • %s", statusText)
+	if filters := m.retriever.Options.Filter.String(); filters != "" {
+		status += " • " + filters
+	}
+	if m.noRAG {
+		status += " • no-rag"
+	}
+	if m.showThink {
+		status += " • thinking shown"
 	}
 	statusBar := statusBarStyle.
 		Width(m.width).
-		Render(fmt.Sprintf(" synapse chat • %s", statusText))
+		Render(status)
 
 	return lipgloss.JoinVertical(
 		lipgloss.Left,
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -20,6 +21,8 @@ type indexingModel struct {
 	done           bool
 	stats          *index.Stats
 	err            error
+	// embedding is the latest progress embedding a large file.
+	embedding indexProgressMsg
 }
 
 func newIndexingModel() indexingModel {
@@ -39,35 +42,28 @@ type indexDoneMsg struct {
 }
 
 // indexProgressMsg is sent periodically during indexing.
-type indexProgressMsg struct {
-	phase          string
-	filesProcessed int
-	filesTotal     int
-}
+type indexProgressMsg index.Progress
 
 func runIndex(cfg Config) tea.Cmd {
 	return func() tea.Msg {
-		wd, err := os.Getwd()
-		if err != nil {
-			return indexDoneMsg{err: err}
+		root := cfg.Root
+		if root == "" {
+			wd, err := os.Getwd()
+			if err != nil {
+				return indexDoneMsg{err: err}
+			}
+			root = wd
 		}
 
 		dbPath := cfg.DBPath
 		if dbPath == "" {
-			dbPath = filepath.Join(wd, ".synapse", "index.db")
+			dbPath = filepath.Join(root, ".synapse", "index.db")
 		}
 
 		if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
 			return indexDoneMsg{err: fmt.Errorf("create db directory: %w", err)}
 		}
 
-		// Redirect stdout to suppress indexer's fmt.Printf output.
-		origStdout := os.Stdout
-		devNull, err := os.Open(os.DevNull)
-		if err == nil {
-			os.Stdout = devNull
-		}
-
 		// Progress channel — the callback sends updates, we drain after indexing.
 		// We use cfg.program (set by the TUI) to send messages to the tea program.
 		idx, err := index.New(index.Config{
@@ -76,31 +72,24 @@ func runIndex(cfg Config) tea.Cmd {
 			Model:         cfg.Model,
 			Workers:       runtime.NumCPU(),
 			OverviewModel: cfg.ChatModel,
-			OnProgress: func(phase string, processed, total int) {
+			KeepAlive:     cfg.KeepAlive,
+			// The progress messages would garble the screen; OnProgress
+			// reports progress instead.
+			Log: io.Discard,
+			// Model selection in the setup screen is the confirmation; keep a
+			// backup in case the wrong model was picked.
+			BackupOnReindex: true,
+			OnProgress: func(p index.Progress) {
 				if cfg.program != nil && cfg.program.p != nil {
-					cfg.program.p.Send(indexProgressMsg{
-						phase:          phase,
-						filesProcessed: processed,
-						filesTotal:     total,
-					})
+					cfg.program.p.Send(indexProgressMsg(p))
 				}
 			},
 		})
 		if err != nil {
-			os.Stdout = origStdout
-			if devNull != nil {
-				devNull.Close()
-			}
 			return indexDoneMsg{err: err}
 		}
 
-		stats, indexErr := idx.Index(wd)
-
-		// Restore stdout.
-		os.Stdout = origStdout
-		if devNull != nil {
-			devNull.Close()
-		}
+		stats, indexErr := idx.Index(root)
 
 		if indexErr != nil {
 			idx.Close()
@@ -119,9 +108,14 @@ func (m indexingModel) Update(msg tea.Msg) (indexingModel, tea.Cmd) {
 		m.err = msg.err
 		return m, nil
 	case indexProgressMsg:
-		m.phase = msg.phase
-		m.filesProcessed = msg.filesProcessed
-		m.filesTotal = msg.filesTotal
+		if msg.Phase == index.PhaseEmbedding {
+			// Embedding updates leave the file counts alone.
+			m.embedding = msg
+			return m, nil
+		}
+		m.phase = msg.Phase
+		m.filesProcessed = msg.FilesProcessed
+		m.filesTotal = msg.FilesTotal
 		return m, nil
 	case spinner.TickMsg:
 		var cmd tea.Cmd
@@ -145,6 +139,24 @@ func (m indexingModel) View(width, height int) string {
 		if m.stats != nil {
 			s += fmt.Sprintf("  Files: %d total, %d indexed, %d skipped\n",
 				m.stats.FilesTotal, m.stats.FilesIndexed, m.stats.FilesSkipped)
+			if m.stats.FilesSkippedGenerated > 0 {
+				s += fmt.Sprintf("  Generated: %d skipped\n", m.stats.FilesSkippedGenerated)
+			}
+			if m.stats.FilesSkippedBinary > 0 {
+				s += fmt.Sprintf("  Binary: %d skipped (binary or non-UTF-8)\n", m.stats.FilesSkippedBinary)
+			}
+			if m.stats.FilesPanicked > 0 {
+				s += fmt.Sprintf("  Failed: %d files crashed the parser\n", m.stats.FilesPanicked)
+			}
+			if m.stats.FilesCapped > 0 {
+				s += fmt.Sprintf("  Capped: %d files kept only their largest definitions\n", m.stats.FilesCapped)
+			}
+			if m.stats.ChunksTruncated > 0 {
+				s += fmt.Sprintf("  Truncated: %d oversized chunks capped\n", m.stats.ChunksTruncated)
+			}
+			if m.stats.EmbeddingsDegenerate > 0 {
+				s += fmt.Sprintf("  Degenerate: %d chunks keyword-search only\n", m.stats.EmbeddingsDegenerate)
+			}
 			s += fmt.Sprintf("  Chunks: %d\n", m.stats.ChunksTotal)
 		}
 		s += "\n"
@@ -156,6 +168,9 @@ func (m indexingModel) View(width, height int) string {
 	if m.filesTotal > 0 {
 		s += fmt.Sprintf("  %d / %d files processed\n", m.filesProcessed, m.filesTotal)
 	}
+	if e := m.embedding; e.BatchesDone < e.Batches {
+		s += fmt.Sprintf("  Embedding %s: %d / %d chunk batches\n", e.File, e.BatchesDone, e.Batches)
+	}
 	s += "\n"
 	s += dimStyle.Render("  This may take a while for large codebases...") + "\n"
 	return s
@@ -1,9 +1,12 @@
 package tui
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -37,6 +40,74 @@ func ListModels(baseURL string) ([]OllamaModel, error) {
 	return result.Models, nil
 }
 
+// PullProgress is a status update streamed by /api/pull. Total and
+// Completed count the bytes of the layer being downloaded, if any.
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// PullModel downloads the model called name with the Ollama /api/pull
+// endpoint, calling onProgress with each status update as it streams in.
+// It returns once the pull succeeds or fails.
+func PullModel(baseURL, name string, onProgress func(PullProgress)) error {
+	body, err := json.Marshal(map[string]any{"model": name, "stream": true})
+	if err != nil {
+		return err
+	}
+	// No timeout: a large model takes as long as it takes to download.
+	resp, err := http.Post(baseURL+"/api/pull", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("connect to ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("ollama /api/pull returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var p PullProgress
+		if err := dec.Decode(&p); err == io.EOF {
+			return fmt.Errorf("pull %s: stream ended before it succeeded", name)
+		} else if err != nil {
+			return fmt.Errorf("decode pull progress: %w", err)
+		}
+		if p.Error != "" {
+			return fmt.Errorf("pull %s: %s", name, p.Error)
+		}
+		if onProgress != nil {
+			onProgress(p)
+		}
+		if p.Status == "success" {
+			return nil
+		}
+	}
+}
+
+// IsEmbedModel reports whether the model called name looks like an embedding
+// model rather than a chat model, going by its name.
+func IsEmbedModel(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.Contains(lower, "embed") || strings.Contains(lower, "nomic")
+}
+
+// HasModel reports whether name is among models. A name without a tag
+// matches the model's ":latest" tag, as Ollama resolves it.
+func HasModel(models []OllamaModel, name string) bool {
+	for _, m := range models {
+		if m.Name == name || !strings.Contains(name, ":") && m.Name == name+":latest" {
+			return true
+		}
+	}
+	return false
+}
+
 // formatSize returns a human-readable size string.
 func formatSize(bytes int64) string {
 	const gb = 1024 * 1024 * 1024
@@ -7,6 +7,10 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+// defaultEmbedModel is pulled when no embedding model is installed and the
+// configured model isn't one.
+const defaultEmbedModel = "nomic-embed-text"
+
 type setupPage int
 
 const (
@@ -23,6 +27,16 @@ type setupModel struct {
 	page        setupPage
 	loaded      bool
 	err         error
+
+	// toPull is the embedding model offered for pulling when none is
+	// installed, or "".
+	toPull  string
+	pulling bool
+	pull    PullProgress
+	pullErr error
+	// pulled is the model pulled in this session, selected once the models
+	// are fetched again.
+	pulled string
 }
 
 // fetchModelsMsg is sent when models have been fetched from Ollama.
@@ -38,6 +52,35 @@ func fetchModels(baseURL string) tea.Cmd {
 	}
 }
 
+// pullProgressMsg is sent for each status update while a model is pulled.
+type pullProgressMsg PullProgress
+
+// pullDoneMsg is sent when pulling a model finishes.
+type pullDoneMsg struct {
+	model string
+	err   error
+}
+
+func pullModel(cfg Config, name string) tea.Cmd {
+	return func() tea.Msg {
+		err := PullModel(cfg.OllamaURL, name, func(p PullProgress) {
+			if cfg.program != nil && cfg.program.p != nil {
+				cfg.program.p.Send(pullProgressMsg(p))
+			}
+		})
+		return pullDoneMsg{model: name, err: err}
+	}
+}
+
+// embedModelToPull returns the embedding model to offer pulling: the
+// configured one if it's an embedding model, or the default.
+func embedModelToPull(cfg Config) string {
+	if cfg.Model != "" && IsEmbedModel(cfg.Model) {
+		return cfg.Model
+	}
+	return defaultEmbedModel
+}
+
 func (m setupModel) Update(msg tea.Msg, cfg Config) (setupModel, tea.Cmd) {
 	switch msg := msg.(type) {
 	case fetchModelsMsg:
@@ -50,31 +93,41 @@ func (m setupModel) Update(msg tea.Msg, cfg Config) (setupModel, tea.Cmd) {
 		m.loaded = true
 
 		// Split into embedding and chat model lists.
+		m.embedModels, m.chatModels = nil, nil
 		for _, model := range msg.models {
-			nameLower := strings.ToLower(model.Name)
-			isEmbed := strings.Contains(nameLower, "embed") || strings.Contains(nameLower, "nomic")
-			if isEmbed {
+			if IsEmbedModel(model.Name) {
 				m.embedModels = append(m.embedModels, model)
 			} else {
 				m.chatModels = append(m.chatModels, model)
 			}
 		}
 
-		// Fallback: if no embedding models found, show all.
+		// Fallback: if no embedding models found, offer to pull one and
+		// show all.
+		m.toPull = ""
 		if len(m.embedModels) == 0 {
+			m.toPull = embedModelToPull(cfg)
 			m.embedModels = msg.models
 		}
 		if len(m.chatModels) == 0 {
 			m.chatModels = msg.models
 		}
 
-		// Find cursor positions for defaults.
+		// Find cursor positions for defaults. A model just pulled is
+		// selected, and the user moves on to picking the chat model.
+		want := cfg.Model
+		if m.pulled != "" {
+			want = m.pulled
+		}
 		for i, model := range m.embedModels {
-			if model.Name == cfg.Model {
+			if HasModel([]OllamaModel{model}, want) {
 				m.embedCursor = i
 				break
 			}
 		}
+		if m.pulled != "" && m.toPull == "" {
+			m.page = setupPageChat
+		}
 		for i, model := range m.chatModels {
 			if model.Name == cfg.ChatModel {
 				m.chatCursor = i
@@ -82,11 +135,30 @@ func (m setupModel) Update(msg tea.Msg, cfg Config) (setupModel, tea.Cmd) {
 			}
 		}
 
+	case pullProgressMsg:
+		m.pull = PullProgress(msg)
+
+	case pullDoneMsg:
+		m.pulling = false
+		if msg.err != nil {
+			m.pullErr = msg.err
+			return m, nil
+		}
+		m.pulled = msg.model
+		return m, fetchModels(cfg.OllamaURL)
+
 	case tea.KeyMsg:
-		if !m.loaded || m.err != nil {
+		if !m.loaded || m.err != nil || m.pulling {
 			return m, nil
 		}
 		switch msg.String() {
+		case "p":
+			if m.toPull != "" && m.page == setupPageEmbed {
+				m.pulling = true
+				m.pull = PullProgress{Status: "starting"}
+				m.pullErr = nil
+				return m, pullModel(cfg, m.toPull)
+			}
 		case "up", "k":
 			if m.page == setupPageEmbed && m.embedCursor > 0 {
 				m.embedCursor--
@@ -135,16 +207,32 @@ func (m setupModel) View(width, height int) string {
 		return s
 	}
 
+	if m.pulling {
+		s += titleStyle.Render("  Pulling Embedding Model") + "\n\n"
+		s += "  " + m.pull.Status + "\n"
+		if m.pull.Total > 0 {
+			s += fmt.Sprintf("  %s %s / %s\n", progressBar(m.pull.Completed, m.pull.Total, 40),
+				formatSize(m.pull.Completed), formatSize(m.pull.Total))
+		}
+		s += "\n"
+		s += dimStyle.Render("  Downloading from the Ollama library; this can take a few minutes.") + "\n"
+		return s
+	}
+
 	if len(m.models) == 0 {
 		s += titleStyle.Render("  Model Selection") + "\n\n"
 		s += warnStyle.Render("  No models found in Ollama.") + "\n"
-		s += dimStyle.Render("  Pull a model first: ollama pull nomic-embed-text") + "\n"
+		s += m.pullHelp()
 		return s
 	}
 
 	if m.page == setupPageEmbed {
 		s += titleStyle.Render("  Select Embedding Model") + "\n"
 		s += dimStyle.Render("  Used to generate vector embeddings for code chunks") + "\n\n"
+		if m.toPull != "" {
+			s += warnStyle.Render("  No embedding model installed.") + "\n"
+			s += m.pullHelp() + "\n"
+		}
 		for i, model := range m.embedModels {
 			cursor := "  "
 			style := listItemStyle
@@ -175,6 +263,21 @@ func (m setupModel) View(width, height int) string {
 	return s
 }
 
+// pullHelp offers to pull m.toPull, with the error of a failed pull.
+func (m setupModel) pullHelp() string {
+	s := ""
+	if m.pullErr != nil {
+		s += errorStyle.Render(fmt.Sprintf("  Pull failed: %v", m.pullErr)) + "\n"
+	}
+	return s + dimStyle.Render(fmt.Sprintf("  Press p to pull %s now (or run: ollama pull %s)", m.toPull, m.toPull)) + "\n"
+}
+
+// progressBar renders completed out of total as a bar width cells wide.
+func progressBar(completed, total int64, width int) string {
+	filled := int(float64(width) * float64(min(completed, total)) / float64(total))
+	return selectedStyle.Render(strings.Repeat("█", filled)) + dimStyle.Render(strings.Repeat("░", width-filled))
+}
+
 func (m setupModel) selectedEmbedModel() string {
 	if len(m.embedModels) > 0 && m.embedCursor < len(m.embedModels) {
 		return m.embedModels[m.embedCursor].Name
@@ -3,7 +3,10 @@ package tui
 import (
 	"os"
 	"path/filepath"
+	"time"
 
+	"synapse/internal/index"
+	"synapse/internal/rag"
 	"synapse/internal/store"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -31,6 +34,20 @@ type Config struct {
 	OllamaURL string
 	Model     string
 	ChatModel string
+	// CacheSize enables the chat query cache when > 0.
+	CacheSize int
+	// KeepAlive is how long Ollama keeps models loaded between requests;
+	// 0 uses the server's default.
+	KeepAlive time.Duration
+	// QueryTimeout bounds embedding a question; 0 uses
+	// embedder.DefaultQueryTimeout.
+	QueryTimeout time.Duration
+	// Weighting and Recency tune retrieval as in rag.Options.
+	Weighting rag.Weighting
+	Recency   float64
+	// Root is the directory indexed and checked for changes, in place of
+	// the root recorded in the index; empty indexes the working directory.
+	Root string
 
 	// program is set internally so background goroutines can send messages.
 	program *programRef
@@ -110,7 +127,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, cmd
 		}
 		// Handle Enter.
-		if keyMsg, ok := msg.(tea.KeyMsg); ok && keyMsg.Type == tea.KeyEnter && m.setup.loaded && m.setup.err == nil && len(m.setup.models) > 0 {
+		if keyMsg, ok := msg.(tea.KeyMsg); ok && keyMsg.Type == tea.KeyEnter && m.setup.loaded && m.setup.err == nil && !m.setup.pulling && len(m.setup.models) > 0 {
 			// If on embed page, advance to chat page.
 			if m.setup.advancePage() {
 				return m, nil
@@ -165,13 +182,9 @@ func (m *Model) transitionToChat() tea.Cmd {
 	}
 
 	// Load overview.
-	var overview string
-	overviewPath := filepath.Join(filepath.Dir(dbPath), "overview.md")
-	if data, err := os.ReadFile(overviewPath); err == nil {
-		overview = string(data)
-	}
+	overview, _ := index.LoadOverview(st, dbPath)
 
-	m.chat = newChatModel(st, m.config.OllamaURL, m.config.Model, m.config.ChatModel, overview, 10)
+	m.chat = newChatModel(st, m.config, overview, 10)
 	m.chat.initViewport(m.width, m.height)
 	m.state = ViewChat
 
@@ -55,6 +55,28 @@ func checkIndex(cfg Config) tea.Cmd {
 			}
 		}
 
+		// Chatting against outdated code gives outdated answers. Sizes and
+		// modification times rule out most files without reading them.
+		root := cfg.Root
+		if root == "" {
+			root, err = st.ProjectRoot()
+		}
+		if err == nil && root == "" {
+			root, err = os.Getwd()
+		}
+		if err == nil {
+			if stale, err := st.ListStaleFiles(root); err == nil && stale.Count() > 0 {
+				files := "files"
+				if stale.Count() == 1 {
+					files = "file"
+				}
+				return checkIndexMsg{
+					status:      indexStale,
+					staleReason: fmt.Sprintf("%d %s changed since last index", stale.Count(), files),
+				}
+			}
+		}
+
 		return checkIndexMsg{status: indexReady}
 	}
 }
@@ -2,10 +2,13 @@ package walker
 
 import (
 	"bufio"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 )
 
 // FileInfo holds metadata about a discovered source file.
@@ -13,6 +16,7 @@ type FileInfo struct {
 	Path    string
 	RelPath string
 	Size    int64
+	ModTime time.Time
 }
 
 // maxFileSize is the largest file we'll consider (1 MB).
@@ -33,10 +37,78 @@ var defaultIgnores = []string{
 	"build",
 }
 
+// alwaysIgnored are directories skipped even when Options.IncludeHidden
+// lifts the hidden-directory ignore patterns: version control metadata and
+// synapse's own index.
+var alwaysIgnored = []string{".git", ".svn", ".hg", ".synapse"}
+
+// generatedPatterns match file names of common generated code, which rarely
+// helps retrieval and can dominate protobuf- or codegen-heavy repos. They're
+// applied separately from .synapseignore so they can be turned off as a whole.
+var generatedPatterns = []string{
+	// Go
+	"*.pb.go", "*.pb.gw.go", "*_gen.go", "*.gen.go", "*_generated.go", "zz_generated*.go",
+	// Python
+	"*_pb2.py", "*_pb2.pyi", "*_pb2_grpc.py",
+	// JavaScript / TypeScript
+	"*.generated.ts", "*.generated.js", "*.gen.ts", "*.gen.js", "*_pb.js", "*_pb.d.ts", "*_grpc_pb.js", "*.min.js",
+	// Dart
+	"*.g.dart", "*.freezed.dart", "*.pb.dart",
+}
+
+// IsGenerated reports whether a file name matches a known generated-code pattern.
+func IsGenerated(name string) bool {
+	for _, p := range generatedPatterns {
+		if matched, _ := filepath.Match(p, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Options controls which files Walk emits.
+type Options struct {
+	// IndexGenerated includes files matching the generated-code patterns.
+	IndexGenerated bool
+	// OnSkipGenerated, if set, is called with the relative path of each
+	// generated file that's skipped. It's called from the walk goroutine.
+	OnSkipGenerated func(relPath string)
+	// OnSkipUnsupported, if set, is called with the relative path of each
+	// file skipped because its extension isn't allowed (and, without one,
+	// Sniff didn't accept it). It's called from the walk goroutine.
+	OnSkipUnsupported func(relPath string)
+	// OnSkipSize, if set, is called with the relative path of each file
+	// skipped for being empty or larger than 1 MB. It's called from the walk
+	// goroutine.
+	OnSkipSize func(relPath string)
+	// Sniff, if set, is asked about files without an extension, with up to
+	// SniffSize bytes from their start; files it accepts are emitted too.
+	// This picks up scripts identified only by a "#!" line.
+	Sniff func(head []byte) bool
+	// Filenames are exact file names emitted whatever their extension.
+	Filenames map[string]bool
+	// IncludeHidden walks the hidden directories that defaultIgnores names
+	// (e.g. ".vscode") even when an ignore file lists them, except for those
+	// in alwaysIgnored; other ignore patterns still apply. It also treats
+	// dotfiles such as ".bashrc" as files without an extension, so Filenames
+	// and Sniff can accept them.
+	IncludeHidden bool
+	// OnHidden, if set, is called with the relative path of each emitted
+	// file that is hidden or inside a hidden directory, when IncludeHidden
+	// is set. It's called from the walk goroutine.
+	OnHidden func(relPath string)
+}
+
+// SniffSize is the number of leading bytes passed to Options.Sniff, and
+// all chunker.Registry.Detect examines.
+const SniffSize = 256
+
 // Walk traverses the directory tree rooted at root and sends discovered
 // source files on the returned channel. It only emits files whose extension
-// is in allowedExts, and skips directories matching .synapseignore patterns.
-func Walk(root string, allowedExts map[string]bool) (<-chan FileInfo, <-chan error) {
+// is in allowedExts or whose name is in opts.Filenames, and skips files and
+// directories matching ignore patterns (see loadIgnorePatterns) and, unless
+// opts.IndexGenerated is set, generated files.
+func Walk(root string, allowedExts map[string]bool, opts Options) (<-chan FileInfo, <-chan error) {
 	files := make(chan FileInfo, 64)
 	errs := make(chan error, 1)
 
@@ -51,6 +123,9 @@ func Walk(root string, allowedExts map[string]bool) (<-chan FileInfo, <-chan err
 		}
 
 		ignores := loadIgnorePatterns(absRoot)
+		if opts.IncludeHidden {
+			ignores = visibleIgnores(ignores)
+		}
 
 		err = filepath.WalkDir(absRoot, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
@@ -74,9 +149,21 @@ func Walk(root string, allowedExts map[string]bool) (<-chan FileInfo, <-chan err
 				return nil
 			}
 
-			// Only process files with registered extensions.
+			relPath, _ := filepath.Rel(absRoot, path)
+			relPath = filepath.ToSlash(relPath)
+			if matchesIgnore(d.Name(), relPath, ignores) {
+				return nil
+			}
+
+			// Only process files with registered names or extensions.
 			ext := strings.TrimPrefix(filepath.Ext(path), ".")
-			if !allowedExts[ext] {
+			if opts.IncludeHidden && "."+ext == d.Name() {
+				ext = "" // a dotfile such as .bashrc
+			}
+			if !opts.Filenames[d.Name()] && !allowedExts[ext] && (ext != "" || opts.Sniff == nil || !sniff(path, opts.Sniff)) {
+				if opts.OnSkipUnsupported != nil {
+					opts.OnSkipUnsupported(relPath)
+				}
 				return nil
 			}
 
@@ -87,14 +174,27 @@ func Walk(root string, allowedExts map[string]bool) (<-chan FileInfo, <-chan err
 
 			// Skip large or empty files.
 			if info.Size() > maxFileSize || info.Size() == 0 {
+				if opts.OnSkipSize != nil {
+					opts.OnSkipSize(relPath)
+				}
 				return nil
 			}
 
-			relPath, _ := filepath.Rel(absRoot, path)
+			if !opts.IndexGenerated && IsGenerated(d.Name()) {
+				if opts.OnSkipGenerated != nil {
+					opts.OnSkipGenerated(relPath)
+				}
+				return nil
+			}
+
+			if opts.IncludeHidden && opts.OnHidden != nil && isHidden(relPath) {
+				opts.OnHidden(relPath)
+			}
 			files <- FileInfo{
 				Path:    path,
-				RelPath: filepath.ToSlash(relPath),
+				RelPath: relPath,
 				Size:    info.Size(),
+				ModTime: info.ModTime(),
 			}
 			return nil
 		})
@@ -106,16 +206,83 @@ func Walk(root string, allowedExts map[string]bool) (<-chan FileInfo, <-chan err
 	return files, errs
 }
 
-// loadIgnorePatterns reads .synapseignore from the project root.
-// If the file doesn't exist, it creates one with the default patterns.
+// visibleIgnores returns patterns without the defaultIgnores naming hidden
+// directories, plus alwaysIgnored. Hidden names the user added to an ignore
+// file stay ignored.
+func visibleIgnores(patterns []string) []string {
+	var kept []string
+	for _, p := range patterns {
+		if !strings.HasPrefix(p, ".") || !slices.Contains(defaultIgnores, p) {
+			kept = append(kept, p)
+		}
+	}
+	return append(kept, alwaysIgnored...)
+}
+
+// isHidden reports whether any element of the slash-separated relPath
+// starts with a dot.
+func isHidden(relPath string) bool {
+	for _, elem := range strings.Split(relPath, "/") {
+		if strings.HasPrefix(elem, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// sniff reads the start of the file at path and passes it to fn.
+func sniff(path string, fn func(head []byte) bool) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, SniffSize)
+	n, _ := io.ReadFull(f, head)
+	return n > 0 && fn(head[:n])
+}
+
+// GlobalIgnorePath returns the path of the ignore file applied to every
+// project: $XDG_CONFIG_HOME/synapse/ignore, or ~/.config/synapse/ignore
+// without it. It returns "" when neither directory is known.
+func GlobalIgnorePath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "synapse", "ignore")
+}
+
+// loadIgnorePatterns returns the ignore patterns for the project at root:
+// the global ignore file's (see GlobalIgnorePath), then .synapseignore's, or
+// the defaults if it has none. The last matching pattern wins (see
+// matchesIgnore), so the project's patterns take precedence. If
+// .synapseignore doesn't exist, it's created with the default patterns.
 func loadIgnorePatterns(root string) []string {
-	ignorePath := filepath.Join(root, ".synapseignore")
+	patterns, _ := readIgnoreFile(GlobalIgnorePath())
 
-	f, err := os.Open(ignorePath)
+	ignorePath := filepath.Join(root, ".synapseignore")
+	project, err := readIgnoreFile(ignorePath)
 	if err != nil {
 		// File doesn't exist — create it with defaults.
 		createDefaultIgnoreFile(ignorePath)
-		return defaultIgnores
+	}
+	if len(project) == 0 {
+		project = defaultIgnores
+	}
+	return append(patterns, project...)
+}
+
+// readIgnoreFile returns the patterns in an ignore file, skipping blank
+// lines and # comments.
+func readIgnoreFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
 	}
 	defer f.Close()
 
@@ -128,10 +295,7 @@ func loadIgnorePatterns(root string) []string {
 		}
 		patterns = append(patterns, line)
 	}
-	if len(patterns) == 0 {
-		return defaultIgnores
-	}
-	return patterns
+	return patterns, scanner.Err()
 }
 
 func createDefaultIgnoreFile(path string) {
@@ -146,24 +310,40 @@ func createDefaultIgnoreFile(path string) {
 	os.WriteFile(path, []byte(b.String()), 0o644)
 }
 
-// matchesIgnore checks if a directory name or relative path matches any ignore pattern.
+// matchesIgnore checks if a file or directory name or relative path is
+// ignored by patterns. The last pattern that matches decides: a pattern
+// starting with "!" un-ignores what an earlier one ignored.
 func matchesIgnore(name, relPath string, patterns []string) bool {
+	ignored := false
 	for _, p := range patterns {
-		// Exact directory name match (e.g. "node_modules", ".git").
-		if name == p {
-			return true
-		}
-		// Path prefix match (e.g. "third_party/vendor").
-		if strings.HasPrefix(relPath, p) {
-			return true
+		negated := strings.HasPrefix(p, "!")
+		// Only a pattern that would change the outcome needs matching.
+		if negated != ignored {
+			continue
 		}
-		// Glob match against the relative path.
-		if matched, _ := filepath.Match(p, relPath); matched {
-			return true
-		}
-		if matched, _ := filepath.Match(p, name); matched {
-			return true
+		if matchesPattern(name, relPath, strings.TrimPrefix(p, "!")) {
+			ignored = !negated
 		}
 	}
-	return false
+	return ignored
+}
+
+// matchesPattern checks if a file or directory name or relative path
+// matches one ignore pattern.
+func matchesPattern(name, relPath, p string) bool {
+	// Exact name match (e.g. "node_modules", ".git").
+	if name == p {
+		return true
+	}
+	// Path prefix match (e.g. "third_party/vendor"), on whole path
+	// elements so that ".git" doesn't match ".github".
+	if prefix := strings.TrimSuffix(p, "/"); relPath == prefix || strings.HasPrefix(relPath, prefix+"/") {
+		return true
+	}
+	// Glob match against the relative path.
+	if matched, _ := filepath.Match(p, relPath); matched {
+		return true
+	}
+	matched, _ := filepath.Match(p, name)
+	return matched
 }
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Routes registers the HTTP handlers of the service.
+func Routes(mux *http.ServeMux, h *Handlers) {
+	mux.HandleFunc("GET /healthz", h.Health)
+	mux.HandleFunc("POST /login", h.Login)
+	mux.HandleFunc("GET /orders", h.ListOrders)
+	mux.HandleFunc("POST /orders", h.CreateOrder)
+}
+
+// Handlers holds the dependencies of the HTTP handlers.
+type Handlers struct{}
+
+// Health reports that the service is up.
+func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h *Handlers) Login(w http.ResponseWriter, r *http.Request)       {}
+func (h *Handlers) ListOrders(w http.ResponseWriter, r *http.Request)  {}
+func (h *Handlers) CreateOrder(w http.ResponseWriter, r *http.Request) {}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
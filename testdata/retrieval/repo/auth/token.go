@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrExpired is returned for tokens past their expiry.
+var ErrExpired = errors.New("token expired")
+
+// Sign issues a session token for userID that expires after ttl.
+func Sign(secret []byte, userID string, ttl time.Duration) string {
+	payload := userID + "|" + time.Now().Add(ttl).UTC().Format(time.RFC3339)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	sig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + sig
+}
+
+// Verify checks a token's HMAC signature and expiry and returns its user.
+func Verify(secret []byte, token string) (string, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return "", errors.New("malformed token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	want := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(parts[1])) {
+		return "", errors.New("bad signature")
+	}
+	userID, expiry, _ := strings.Cut(string(payload), "|")
+	exp, err := time.Parse(time.RFC3339, expiry)
+	if err != nil {
+		return "", err
+	}
+	if time.Now().After(exp) {
+		return "", ErrExpired
+	}
+	return userID, nil
+}
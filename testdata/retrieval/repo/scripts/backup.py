@@ -0,0 +1,21 @@
+"""Nightly database backup to object storage."""
+
+import datetime
+import gzip
+import subprocess
+
+
+def dump_database(dsn: str) -> bytes:
+    """Run pg_dump against dsn and return the compressed dump."""
+    raw = subprocess.run(["pg_dump", dsn], check=True, capture_output=True).stdout
+    return gzip.compress(raw)
+
+
+def backup_key(now: datetime.datetime) -> str:
+    """Object key for a backup taken at now, e.g. backups/2024/01/31.sql.gz."""
+    return now.strftime("backups/%Y/%m/%d.sql.gz")
+
+
+def upload(client, bucket: str, key: str, data: bytes) -> None:
+    """Upload the dump to an S3 bucket."""
+    client.put_object(Bucket=bucket, Key=key, Body=data)
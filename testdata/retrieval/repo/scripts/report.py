@@ -0,0 +1,22 @@
+"""Weekly revenue report emailed to finance."""
+
+import smtplib
+from email.message import EmailMessage
+
+
+def weekly_revenue(orders):
+    """Sum order totals in cents, grouped by customer."""
+    totals = {}
+    for order in orders:
+        totals[order["customer"]] = totals.get(order["customer"], 0) + order["total_cents"]
+    return totals
+
+
+def send_report(totals, to_addr, smtp_host="localhost"):
+    """Email the revenue table."""
+    msg = EmailMessage()
+    msg["Subject"] = "Weekly revenue"
+    msg["To"] = to_addr
+    msg.set_content("\n".join(f"{c}: {t / 100:.2f}" for c, t in sorted(totals.items())))
+    with smtplib.SMTP(smtp_host) as smtp:
+        smtp.send_message(msg)
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU is a fixed-size least-recently-used cache safe for concurrent use.
+type LRU struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type entry struct {
+	key   string
+	value []byte
+}
+
+// NewLRU creates a cache holding at most size entries.
+func NewLRU(size int) *LRU {
+	return &LRU{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// Get returns the cached value for key and marks it recently used.
+func (c *LRU) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*entry).value, true
+}
+
+// Put stores value under key, evicting the least recently used entry when full.
+func (c *LRU) Put(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*entry).value = value
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&entry{key, value})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry).key)
+	}
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Order is a customer order row.
+type Order struct {
+	ID         int64
+	Customer   string
+	TotalCents int64
+}
+
+// OrderRepo reads and writes orders in PostgreSQL.
+type OrderRepo struct {
+	db *sql.DB
+}
+
+// Create inserts an order inside a transaction and returns its new ID.
+func (r *OrderRepo) Create(ctx context.Context, o Order) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO orders (customer, total_cents) VALUES ($1, $2) RETURNING id",
+		o.Customer, o.TotalCents).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// ByCustomer lists a customer's orders, newest first.
+func (r *OrderRepo) ByCustomer(ctx context.Context, customer string) ([]Order, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, customer, total_cents FROM orders WHERE customer = $1 ORDER BY id DESC", customer)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var orders []Order
+	for rows.Next() {
+		var o Order
+		if err := rows.Scan(&o.ID, &o.Customer, &o.TotalCents); err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}